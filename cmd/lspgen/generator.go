@@ -0,0 +1,854 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Generate produces the Go source code for the structures, enumerations,
+// type aliases, method constants and handler boilerplate described by
+// the provided meta model.
+//
+// The generated code expects the package it is written to
+// to define the base types of the protocol (`Method`, `Integer`, `UInteger`,
+// `Decimal`, `URI` and `DocumentURI`) along with a `Handler` type that embeds
+// `messageHandlerFuncs`, has a `messageHandlers map[string]common.Handler` field
+// and a `mu sync.Mutex` field, and a `HandlerOption func(*Handler)` type.
+func Generate(model *MetaModel, packageName string) ([]byte, error) {
+	g := &generator{
+		model:        model,
+		structures:   make(map[string]*Structure),
+		typeAliases:  make(map[string]*TypeAlias),
+		literalNames: make(map[*StructureLiteral]string),
+	}
+	for _, structure := range model.Structures {
+		g.structures[structure.Name] = structure
+	}
+	for _, typeAlias := range model.TypeAliases {
+		g.typeAliases[typeAlias.Name] = typeAlias
+	}
+
+	body, err := g.generateBody()
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(
+		&out,
+		"// Code generated by lspgen from the LSP %s meta model. DO NOT EDIT.\n\n",
+		model.MetaData.Version,
+	)
+	fmt.Fprintf(&out, "package %s\n\n", packageName)
+	g.writeImports(&out)
+	out.Write(body)
+
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return source, nil
+}
+
+type generator struct {
+	model        *MetaModel
+	structures   map[string]*Structure
+	typeAliases  map[string]*TypeAlias
+	literalNames map[*StructureLiteral]string
+	// Literals are queued when the type of a property is resolved
+	// and written after the structure that the property belongs to.
+	pendingLiterals []*namedLiteral
+	usesJSON        bool
+	usesUnions      bool
+	usesCommon      bool
+}
+
+type namedLiteral struct {
+	name    string
+	literal *StructureLiteral
+}
+
+func (g *generator) writeImports(out *bytes.Buffer) {
+	stdImports := []string{}
+	if g.usesUnions {
+		stdImports = append(stdImports, "bytes")
+	}
+	if g.usesJSON || g.usesUnions {
+		stdImports = append(stdImports, "encoding/json")
+	}
+	if g.usesUnions {
+		stdImports = append(stdImports, "fmt", "reflect")
+	}
+
+	if len(stdImports) == 0 && !g.usesCommon {
+		return
+	}
+
+	out.WriteString("import (\n")
+	for _, stdImport := range stdImports {
+		fmt.Fprintf(out, "\t%q\n", stdImport)
+	}
+	if g.usesCommon {
+		if len(stdImports) > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString("\t\"github.com/two-hundred/ls-builder/common\"\n")
+	}
+	out.WriteString(")\n\n")
+}
+
+func (g *generator) generateBody() ([]byte, error) {
+	var body bytes.Buffer
+
+	g.writeMethods(&body)
+
+	for _, enumeration := range g.model.Enumerations {
+		if err := g.writeEnumeration(&body, enumeration); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, typeAlias := range g.model.TypeAliases {
+		g.writeTypeAlias(&body, typeAlias)
+	}
+
+	for _, structure := range g.model.Structures {
+		properties, err := g.flattenProperties(structure, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		g.writeStructure(&body, structure.Name, structure.Documentation, structure.Deprecated, properties)
+		g.writePendingLiterals(&body)
+	}
+
+	g.writeHandlers(&body)
+
+	if g.usesUnions {
+		body.WriteString(unmarshalUnionSource)
+	}
+
+	return body.Bytes(), nil
+}
+
+func (g *generator) writeMethods(body *bytes.Buffer) {
+	if len(g.model.Requests) == 0 && len(g.model.Notifications) == 0 {
+		return
+	}
+
+	body.WriteString("const (\n")
+	for _, request := range g.model.Requests {
+		writeDoc(body, "\t", request.Documentation, request.Deprecated)
+		fmt.Fprintf(body, "\tMethod%s = Method(%q)\n\n", messageName(request.TypeName, request.Method), request.Method)
+	}
+	for _, notification := range g.model.Notifications {
+		writeDoc(body, "\t", notification.Documentation, notification.Deprecated)
+		fmt.Fprintf(
+			body,
+			"\tMethod%s = Method(%q)\n\n",
+			messageName(notification.TypeName, notification.Method),
+			notification.Method,
+		)
+	}
+	body.WriteString(")\n\n")
+}
+
+func (g *generator) writeEnumeration(body *bytes.Buffer, enumeration *Enumeration) error {
+	baseType := g.goType(enumeration.Type, enumeration.Name)
+	writeDoc(body, "", enumeration.Documentation, enumeration.Deprecated)
+	fmt.Fprintf(body, "type %s = %s\n\n", enumeration.Name, baseType)
+
+	body.WriteString("const (\n")
+	for _, entry := range enumeration.Values {
+		value, err := enumerationValue(entry, baseType)
+		if err != nil {
+			return fmt.Errorf("enumeration %s: %w", enumeration.Name, err)
+		}
+		writeDoc(body, "\t", entry.Documentation, entry.Deprecated)
+		fmt.Fprintf(body, "\t%s%s %s = %s\n\n", enumeration.Name, goName(entry.Name), enumeration.Name, value)
+	}
+	body.WriteString(")\n\n")
+	return nil
+}
+
+func enumerationValue(entry *EnumerationEntry, baseType string) (string, error) {
+	if baseType == "string" {
+		var value string
+		if err := json.Unmarshal(entry.Value, &value); err != nil {
+			return "", fmt.Errorf("expected string value for %s: %w", entry.Name, err)
+		}
+		return strconv.Quote(value), nil
+	}
+
+	var value json.Number
+	if err := json.Unmarshal(entry.Value, &value); err != nil {
+		return "", fmt.Errorf("expected number value for %s: %w", entry.Name, err)
+	}
+	return value.String(), nil
+}
+
+func (g *generator) writeTypeAlias(body *bytes.Buffer, typeAlias *TypeAlias) {
+	// Literals used in a type alias need a name that is distinct from the alias.
+	literalName := typeAlias.Name + "Literal"
+	writeDoc(body, "", typeAlias.Documentation, typeAlias.Deprecated)
+	if alternatives, isUnion := g.resolveUnion(typeAlias.Type); isUnion {
+		fmt.Fprintf(body, "//\n// This is one of: %s\n", g.describeAlternatives(alternatives, literalName))
+		fmt.Fprintf(body, "type %s = any\n\n", typeAlias.Name)
+	} else {
+		fmt.Fprintf(body, "type %s = %s\n\n", typeAlias.Name, g.goType(typeAlias.Type, literalName))
+	}
+	g.writePendingLiterals(body)
+}
+
+// Collects the properties of a structure including those of the structures
+// it extends and the mixins it uses, properties of the structure take precedence.
+// Properties are flattened instead of embedding structures to avoid the JSON
+// unmarshalling of unions for an embedded structure taking over the unmarshalling
+// of the whole structure.
+func (g *generator) flattenProperties(structure *Structure, visiting map[string]bool) ([]*Property, error) {
+	if visiting[structure.Name] {
+		return nil, fmt.Errorf("structure %s extends itself", structure.Name)
+	}
+	visiting[structure.Name] = true
+	defer delete(visiting, structure.Name)
+
+	properties := []*Property{}
+	indices := map[string]int{}
+	add := func(property *Property) {
+		if index, exists := indices[property.Name]; exists {
+			properties[index] = property
+			return
+		}
+		indices[property.Name] = len(properties)
+		properties = append(properties, property)
+	}
+
+	for _, parent := range append(append([]*Type{}, structure.Extends...), structure.Mixins...) {
+		parentStructure, ok := g.structures[parent.Name]
+		if parent.Kind != TypeKindReference || !ok {
+			return nil, fmt.Errorf("structure %s extends unknown structure %q", structure.Name, parent.Name)
+		}
+
+		parentProperties, err := g.flattenProperties(parentStructure, visiting)
+		if err != nil {
+			return nil, err
+		}
+		for _, property := range parentProperties {
+			add(property)
+		}
+	}
+
+	for _, property := range structure.Properties {
+		add(property)
+	}
+
+	return properties, nil
+}
+
+type unionField struct {
+	goName       string
+	jsonName     string
+	alternatives []string
+}
+
+func (g *generator) writeStructure(
+	body *bytes.Buffer,
+	name string,
+	documentation string,
+	deprecated string,
+	properties []*Property,
+) {
+	writeDoc(body, "", documentation, deprecated)
+	fmt.Fprintf(body, "type %s struct {\n", name)
+
+	unionFields := []*unionField{}
+	for i, property := range properties {
+		if i > 0 {
+			body.WriteString("\n")
+		}
+		writeDoc(body, "\t", property.Documentation, property.Deprecated)
+
+		fieldName := goName(property.Name)
+		fieldType := g.propertyType(property, name+fieldName)
+		if alternatives, isUnion := g.resolveUnion(property.Type); isUnion {
+			unionFields = append(unionFields, &unionField{
+				goName:       fieldName,
+				jsonName:     property.Name,
+				alternatives: g.unionCandidates(alternatives, name+fieldName),
+			})
+			fmt.Fprintf(body, "\t//\n\t// This is one of: %s\n", g.describeAlternatives(alternatives, name+fieldName))
+		}
+
+		tag := property.Name
+		if property.Optional {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(body, "\t%s %s `json:%q`\n", fieldName, fieldType, tag)
+	}
+	body.WriteString("}\n\n")
+
+	if len(unionFields) > 0 {
+		g.writeUnionUnmarshaler(body, name, unionFields)
+	}
+}
+
+func (g *generator) writeUnionUnmarshaler(body *bytes.Buffer, name string, unionFields []*unionField) {
+	g.usesUnions = true
+	fieldsType := lowerFirst(name) + "Fields"
+
+	body.WriteString("// Fulfils the json.Unmarshaler interface.\n")
+	fmt.Fprintf(body, "func (s *%s) UnmarshalJSON(data []byte) error {\n", name)
+	fmt.Fprintf(body, "\ttype %s %s\n", fieldsType, name)
+	body.WriteString("\tintermediate := struct {\n")
+	fmt.Fprintf(body, "\t\t*%s\n", fieldsType)
+	for _, field := range unionFields {
+		fmt.Fprintf(body, "\t\t%s json.RawMessage `json:%q`\n", field.goName, field.jsonName+",omitempty")
+	}
+	fmt.Fprintf(body, "\t}{%s: (*%s)(s)}\n", fieldsType, fieldsType)
+	body.WriteString("\tif err := json.Unmarshal(data, &intermediate); err != nil {\n")
+	body.WriteString("\t\treturn err\n")
+	body.WriteString("\t}\n\n")
+
+	for _, field := range unionFields {
+		fmt.Fprintf(body, "\tif len(intermediate.%s) > 0 {\n", field.goName)
+		candidates := []string{}
+		for _, alternative := range field.alternatives {
+			candidates = append(candidates, fmt.Sprintf("new(%s)", alternative))
+		}
+		fmt.Fprintf(
+			body,
+			"\t\tvalue, err := unmarshalUnion(intermediate.%s, %s)\n",
+			field.goName,
+			strings.Join(candidates, ", "),
+		)
+		body.WriteString("\t\tif err != nil {\n")
+		body.WriteString("\t\t\treturn err\n")
+		body.WriteString("\t\t}\n")
+		fmt.Fprintf(body, "\t\ts.%s = value\n", field.goName)
+		body.WriteString("\t}\n\n")
+	}
+
+	body.WriteString("\treturn nil\n")
+	body.WriteString("}\n\n")
+}
+
+func (g *generator) writePendingLiterals(body *bytes.Buffer) {
+	for len(g.pendingLiterals) > 0 {
+		literal := g.pendingLiterals[0]
+		g.pendingLiterals = g.pendingLiterals[1:]
+		g.writeStructure(
+			body,
+			literal.name,
+			literal.literal.Documentation,
+			literal.literal.Deprecated,
+			literal.literal.Properties,
+		)
+	}
+}
+
+func (g *generator) propertyType(property *Property, literalName string) string {
+	if _, isUnion := g.resolveUnion(property.Type); isUnion {
+		return "any"
+	}
+
+	goType, isNullable := g.nullableGoType(property.Type, literalName)
+	if (property.Optional || isNullable) && canBePointer(goType) {
+		return "*" + goType
+	}
+	return goType
+}
+
+// Resolves the Go type for a type that may be a union with null,
+// reporting whether null is an allowed value.
+func (g *generator) nullableGoType(t *Type, literalName string) (string, bool) {
+	if t.Kind == TypeKindOr {
+		nonNull := withoutNull(t.Items)
+		if len(nonNull) == 1 && len(nonNull) < len(t.Items) {
+			return g.goType(nonNull[0], literalName), true
+		}
+	}
+	return g.goType(t, literalName), false
+}
+
+func canBePointer(goType string) bool {
+	return !strings.HasPrefix(goType, "[]") &&
+		!strings.HasPrefix(goType, "map[") &&
+		!strings.HasPrefix(goType, "*") &&
+		goType != "any"
+}
+
+// Types that can hold any JSON value are left to the standard JSON decoding
+// instead of being decoded as a union.
+var opaqueTypes = map[string]bool{
+	"LSPAny": true,
+}
+
+// Determines whether a type is a union of multiple types other than null,
+// expanding type aliases of unions so that values are decoded to one of the
+// alternatives of the aliased union.
+func (g *generator) resolveUnion(t *Type) ([]*Type, bool) {
+	if t.Kind == TypeKindReference && opaqueTypes[t.Name] {
+		return nil, false
+	}
+
+	alternatives := g.expandAlternatives(t, map[string]bool{})
+	if len(alternatives) < 2 {
+		return nil, false
+	}
+
+	// Unions of literals of the same primitive type such as
+	// `'off' | 'messages' | 'verbose'` are represented by the primitive type.
+	if _, isSamePrimitive := samePrimitive(alternatives); isSamePrimitive {
+		return nil, false
+	}
+	return alternatives, true
+}
+
+func (g *generator) expandAlternatives(t *Type, visiting map[string]bool) []*Type {
+	if t.Kind == TypeKindReference {
+		typeAlias, isAlias := g.typeAliases[t.Name]
+		if !isAlias || opaqueTypes[t.Name] || visiting[t.Name] || typeAlias.Type.Kind != TypeKindOr {
+			return []*Type{t}
+		}
+		visiting[t.Name] = true
+		defer delete(visiting, t.Name)
+		return g.expandAlternatives(typeAlias.Type, visiting)
+	}
+
+	if t.Kind != TypeKindOr {
+		return []*Type{t}
+	}
+
+	alternatives := []*Type{}
+	for _, item := range withoutNull(t.Items) {
+		alternatives = append(alternatives, g.expandAlternatives(item, visiting)...)
+	}
+	return alternatives
+}
+
+// Determines whether all the provided types are literals or base types
+// of the same primitive type, returning the Go type for the primitive.
+func samePrimitive(items []*Type) (string, bool) {
+	primitive := ""
+	for _, item := range items {
+		itemPrimitive := primitiveGoType(item)
+		if itemPrimitive == "" || (primitive != "" && itemPrimitive != primitive) {
+			return "", false
+		}
+		primitive = itemPrimitive
+	}
+	return primitive, primitive != ""
+}
+
+func primitiveGoType(t *Type) string {
+	switch {
+	case t.Kind == TypeKindStringLiteral || (t.Kind == TypeKindBase && t.Name == "string"):
+		return "string"
+	case t.Kind == TypeKindIntegerLiteral || (t.Kind == TypeKindBase && t.Name == "integer"):
+		return "Integer"
+	case t.Kind == TypeKindBooleanLiteral || (t.Kind == TypeKindBase && t.Name == "boolean"):
+		return "bool"
+	default:
+		return ""
+	}
+}
+
+func withoutNull(items []*Type) []*Type {
+	nonNull := []*Type{}
+	for _, item := range items {
+		if item.Kind != TypeKindBase || item.Name != "null" {
+			nonNull = append(nonNull, item)
+		}
+	}
+	return nonNull
+}
+
+// Produces the Go types to try in order when unmarshalling a union,
+// removing duplicates such as multiple string literals.
+func (g *generator) unionCandidates(alternatives []*Type, literalName string) []string {
+	candidates := []string{}
+	seen := map[string]bool{}
+	for i, alternative := range alternatives {
+		goType := g.goType(alternative, alternativeLiteralName(literalName, i, alternatives))
+		if !seen[goType] {
+			seen[goType] = true
+			candidates = append(candidates, goType)
+		}
+	}
+	return candidates
+}
+
+func (g *generator) describeAlternatives(alternatives []*Type, literalName string) string {
+	descriptions := []string{}
+	for i, alternative := range alternatives {
+		descriptions = append(
+			descriptions,
+			g.goType(alternative, alternativeLiteralName(literalName, i, alternatives)),
+		)
+	}
+	return strings.Join(descriptions, " | ")
+}
+
+// Literals that are alternatives of a union are numbered when there are
+// multiple literals in the union.
+func alternativeLiteralName(literalName string, index int, alternatives []*Type) string {
+	literalCount := 0
+	for _, alternative := range alternatives {
+		if alternative.Kind == TypeKindLiteral {
+			literalCount += 1
+		}
+	}
+	if literalCount > 1 {
+		return fmt.Sprintf("%s%d", literalName, index+1)
+	}
+	return literalName
+}
+
+func (g *generator) goType(t *Type, literalName string) string {
+	switch t.Kind {
+	case TypeKindBase:
+		return baseGoType(t.Name)
+	case TypeKindReference:
+		return t.Name
+	case TypeKindArray:
+		return "[]" + g.goType(t.Element, literalName+"Item")
+	case TypeKindMap:
+		return fmt.Sprintf("map[%s]%s", g.goType(t.Key, literalName+"Key"), g.goType(t.Value, literalName+"Value"))
+	case TypeKindOr:
+		nonNull := withoutNull(t.Items)
+		if len(nonNull) == 1 {
+			return g.goType(nonNull[0], literalName)
+		}
+		if primitive, isSamePrimitive := samePrimitive(nonNull); isSamePrimitive {
+			return primitive
+		}
+		return "any"
+	case TypeKindTuple:
+		itemTypes := map[string]bool{}
+		var itemType string
+		for _, item := range t.Items {
+			itemType = g.goType(item, literalName)
+			itemTypes[itemType] = true
+		}
+		if len(itemTypes) == 1 {
+			return "[]" + itemType
+		}
+		return "[]any"
+	case TypeKindLiteral:
+		return g.literalType(t.Literal, literalName)
+	case TypeKindStringLiteral:
+		return "string"
+	case TypeKindIntegerLiteral:
+		return "Integer"
+	case TypeKindBooleanLiteral:
+		return "bool"
+	default:
+		// "and" types are not used for properties in the specification,
+		// so are represented as any value.
+		return "any"
+	}
+}
+
+func (g *generator) literalType(literal *StructureLiteral, name string) string {
+	if len(literal.Properties) == 0 {
+		return "struct{}"
+	}
+
+	if existingName, ok := g.literalNames[literal]; ok {
+		return existingName
+	}
+
+	g.literalNames[literal] = name
+	g.pendingLiterals = append(g.pendingLiterals, &namedLiteral{
+		name:    name,
+		literal: literal,
+	})
+	return name
+}
+
+func baseGoType(name string) string {
+	switch name {
+	case "URI":
+		return "URI"
+	case "DocumentUri":
+		return "DocumentURI"
+	case "integer":
+		return "Integer"
+	case "uinteger":
+		return "UInteger"
+	case "decimal":
+		return "Decimal"
+	case "boolean":
+		return "bool"
+	case "null":
+		return "any"
+	default:
+		// string and RegExp.
+		return "string"
+	}
+}
+
+type handlerMessage struct {
+	name         string
+	params       *Params
+	result       *Type
+	isRequest    bool
+	deprecated   string
+	typeNameDesc string
+}
+
+func (g *generator) handlerMessages() []*handlerMessage {
+	messages := []*handlerMessage{}
+	for _, request := range g.model.Requests {
+		if request.MessageDirection != MessageDirectionServerToClient {
+			messages = append(messages, &handlerMessage{
+				name:         messageName(request.TypeName, request.Method),
+				params:       request.Params,
+				result:       request.Result,
+				isRequest:    true,
+				deprecated:   request.Deprecated,
+				typeNameDesc: fmt.Sprintf("`%s` request", request.Method),
+			})
+		}
+	}
+	for _, notification := range g.model.Notifications {
+		if notification.MessageDirection != MessageDirectionServerToClient {
+			messages = append(messages, &handlerMessage{
+				name:         messageName(notification.TypeName, notification.Method),
+				params:       notification.Params,
+				deprecated:   notification.Deprecated,
+				typeNameDesc: fmt.Sprintf("`%s` notification", notification.Method),
+			})
+		}
+	}
+	return messages
+}
+
+func (g *generator) writeHandlers(body *bytes.Buffer) {
+	messages := g.handlerMessages()
+	if len(messages) == 0 {
+		return
+	}
+	g.usesCommon = true
+
+	for _, message := range messages {
+		fmt.Fprintf(body, "// %sHandlerFunc is the function signature for the %s.\n", message.name, message.typeNameDesc)
+		fmt.Fprintf(body, "type %sHandlerFunc func(\n", message.name)
+		body.WriteString("\tctx *common.LSPContext,\n")
+		if message.params != nil {
+			fmt.Fprintf(body, "\tparams %s,\n", g.paramsType(message))
+		}
+		if message.isRequest {
+			fmt.Fprintf(body, ") (%s, error)\n\n", g.resultType(message))
+		} else {
+			body.WriteString(") error\n\n")
+		}
+	}
+
+	body.WriteString("// messageHandlerFuncs holds the user-provided handler functions\n")
+	body.WriteString("// for all the messages that can be sent from the client to the server.\n")
+	body.WriteString("type messageHandlerFuncs struct {\n")
+	for _, message := range messages {
+		fmt.Fprintf(body, "\t%s %sHandlerFunc\n", lowerFirst(message.name), message.name)
+	}
+	body.WriteString("}\n\n")
+
+	for _, message := range messages {
+		g.writeHandlerSetters(body, message)
+	}
+}
+
+func (g *generator) writeHandlerSetters(body *bytes.Buffer, message *handlerMessage) {
+	fieldName := lowerFirst(message.name)
+
+	fmt.Fprintf(body, "// With%sHandler sets the handler for the %s.\n", message.name, message.typeNameDesc)
+	if message.deprecated != "" {
+		fmt.Fprintf(body, "//\n// Deprecated: %s\n", message.deprecated)
+	}
+	fmt.Fprintf(body, "func With%sHandler(handler %sHandlerFunc) HandlerOption {\n", message.name, message.name)
+	body.WriteString("\treturn func(root *Handler) {\n")
+	fmt.Fprintf(body, "\t\troot.Set%sHandler(handler)\n", message.name)
+	body.WriteString("\t}\n")
+	body.WriteString("}\n\n")
+
+	fmt.Fprintf(body, "// Set%sHandler sets the handler for the %s.\n", message.name, message.typeNameDesc)
+	if message.deprecated != "" {
+		fmt.Fprintf(body, "//\n// Deprecated: %s\n", message.deprecated)
+	}
+	fmt.Fprintf(body, "func (h *Handler) Set%sHandler(handler %sHandlerFunc) {\n", message.name, message.name)
+	body.WriteString("\th.mu.Lock()\n")
+	body.WriteString("\tdefer h.mu.Unlock()\n")
+	fmt.Fprintf(body, "\th.%s = handler\n", fieldName)
+	fmt.Fprintf(body, "\th.messageHandlers[Method%s] = create%sHandler(h)\n", message.name, message.name)
+	body.WriteString("}\n\n")
+
+	fmt.Fprintf(body, "func create%sHandler(root *Handler) common.Handler {\n", message.name)
+	body.WriteString("\treturn common.HandlerFunc(\n")
+	body.WriteString("\t\tfunc(\n")
+	body.WriteString("\t\t\tctx *common.LSPContext,\n")
+	body.WriteString("\t\t) (r any, validMethod bool, validParams bool, err error) {\n")
+	fmt.Fprintf(body, "\t\t\tif root.%s != nil {\n", fieldName)
+	body.WriteString("\t\t\t\tvalidMethod = true\n")
+
+	resultAssignment := "err = "
+	if message.isRequest {
+		resultAssignment = "r, err = "
+	}
+	if message.params == nil {
+		body.WriteString("\t\t\t\tvalidParams = true\n")
+		fmt.Fprintf(body, "\t\t\t\t%sroot.%s(ctx)\n", resultAssignment, fieldName)
+	} else {
+		g.usesJSON = true
+		paramsType := strings.TrimPrefix(g.paramsType(message), "*")
+		paramsArg := "params"
+		if paramsType != g.paramsType(message) {
+			paramsArg = "&params"
+		}
+		fmt.Fprintf(body, "\t\t\t\tvar params %s\n", paramsType)
+		body.WriteString("\t\t\t\tif err = json.Unmarshal(ctx.Params, &params); err == nil {\n")
+		body.WriteString("\t\t\t\t\tvalidParams = true\n")
+		fmt.Fprintf(body, "\t\t\t\t\t%sroot.%s(ctx, %s)\n", resultAssignment, fieldName, paramsArg)
+		body.WriteString("\t\t\t\t}\n")
+	}
+
+	body.WriteString("\t\t\t}\n")
+	body.WriteString("\t\t\treturn\n")
+	body.WriteString("\t\t},\n")
+	body.WriteString("\t)\n")
+	body.WriteString("}\n\n")
+}
+
+func (g *generator) paramsType(message *handlerMessage) string {
+	paramsType := g.goType(message.params.Type, message.name+"Params")
+	if canBePointer(paramsType) {
+		return "*" + paramsType
+	}
+	return paramsType
+}
+
+func (g *generator) resultType(message *handlerMessage) string {
+	if message.result == nil {
+		return "any"
+	}
+
+	if _, isUnion := g.resolveUnion(message.result); isUnion {
+		return "any"
+	}
+
+	resultType, isNullable := g.nullableGoType(message.result, message.name+"Result")
+	if isNullable && canBePointer(resultType) {
+		return "*" + resultType
+	}
+	return resultType
+}
+
+// Derives the name used for the method constant and handler
+// of a message from the type name in the meta model,
+// for example, `ShowDocument` for `ShowDocumentRequest`.
+func messageName(typeName string, method string) string {
+	if typeName != "" {
+		name := strings.TrimSuffix(typeName, "Request")
+		return strings.TrimSuffix(name, "Notification")
+	}
+
+	var name strings.Builder
+	for _, segment := range strings.FieldsFunc(method, func(r rune) bool {
+		return r == '/' || r == '$'
+	}) {
+		name.WriteString(goName(segment))
+	}
+	return name.String()
+}
+
+var initialisms = map[string]string{
+	"Uri": "URI",
+	"Id":  "ID",
+	"Url": "URL",
+}
+
+// Converts a name from the meta model to an exported Go name,
+// using the Go convention for initialisms such as "URI" and "ID".
+func goName(name string) string {
+	if name == "" {
+		return name
+	}
+
+	exported := strings.ToUpper(name[:1]) + name[1:]
+	var result strings.Builder
+	for i := 0; i < len(exported); {
+		replaced := false
+		for from, to := range initialisms {
+			if !strings.HasPrefix(exported[i:], from) {
+				continue
+			}
+			end := i + len(from)
+			if end == len(exported) || unicode.IsUpper(rune(exported[end])) {
+				result.WriteString(to)
+				i = end
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result.WriteByte(exported[i])
+			i += 1
+		}
+	}
+	return result.String()
+}
+
+func lowerFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func writeDoc(body *bytes.Buffer, indent string, documentation string, deprecated string) {
+	documentation = strings.TrimSpace(documentation)
+	if documentation != "" {
+		for _, line := range strings.Split(documentation, "\n") {
+			line = strings.TrimRight(line, " \t")
+			if line == "" {
+				fmt.Fprintf(body, "%s//\n", indent)
+			} else {
+				fmt.Fprintf(body, "%s// %s\n", indent, line)
+			}
+		}
+	}
+
+	if deprecated != "" {
+		if documentation != "" {
+			fmt.Fprintf(body, "%s//\n", indent)
+		}
+		fmt.Fprintf(body, "%s// Deprecated: %s\n", indent, deprecated)
+	}
+}
+
+const unmarshalUnionSource = `// unmarshalUnion unmarshals a value of a union type by trying each of the
+// candidate types in order, structures only match values without unknown fields.
+// Structures are returned as pointers and all other values are dereferenced.
+func unmarshalUnion(data json.RawMessage, candidates ...any) (any, error) {
+	if string(data) == "null" {
+		return nil, nil
+	}
+
+	for _, candidate := range candidates {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(candidate); err == nil {
+			value := reflect.ValueOf(candidate).Elem()
+			if value.Kind() == reflect.Struct {
+				return candidate, nil
+			}
+			return value.Interface(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("value %s does not match any of the types of the union", data)
+}
+`
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var update = flag.Bool("update", false, "update the golden files with the generated output")
+
+type GeneratorTestSuite struct {
+	suite.Suite
+}
+
+// testdata/metaModel.json is a small fixture in the format of the meta model
+// with a selection of messages and types from LSP 3.17, it is not the official meta model.
+func (s *GeneratorTestSuite) Test_generates_code_matching_golden_file() {
+	model, err := loadMetaModel("testdata/metaModel.json")
+	s.Require().NoError(err)
+
+	source, err := Generate(model, "lsp")
+	s.Require().NoError(err)
+
+	goldenPath := "testdata/protocol_gen.go.golden"
+	if *update {
+		err = os.WriteFile(goldenPath, source, 0644)
+		s.Require().NoError(err)
+	}
+
+	expected, err := os.ReadFile(goldenPath)
+	s.Require().NoError(err)
+	s.Require().Equal(string(expected), string(source))
+}
+
+func (s *GeneratorTestSuite) Test_checks_generated_file_is_up_to_date() {
+	goldenPath := "testdata/protocol_gen.go.golden"
+	err := run("testdata/metaModel.json", goldenPath, "lsp", true)
+	s.Require().NoError(err)
+
+	outPath := filepath.Join(s.T().TempDir(), "protocol_gen.go")
+	err = os.WriteFile(outPath, []byte("package lsp\n"), 0644)
+	s.Require().NoError(err)
+	err = run("testdata/metaModel.json", outPath, "lsp", true)
+	s.Require().ErrorContains(err, "is out of date with the meta model")
+}
+
+func (s *GeneratorTestSuite) Test_fails_to_parse_positional_parameters() {
+	model := &MetaModel{}
+	err := json.Unmarshal(
+		[]byte(`{"requests":[{"method":"test","params":[{"kind":"base","name":"string"}]}]}`),
+		model,
+	)
+	s.Require().ErrorContains(err, "positional parameters are not supported")
+}
+
+func (s *GeneratorTestSuite) Test_fails_to_parse_unknown_type_kind() {
+	model := &MetaModel{}
+	err := json.Unmarshal(
+		[]byte(`{"typeAliases":[{"name":"Test","type":{"kind":"unknown"}}]}`),
+		model,
+	)
+	s.Require().ErrorContains(err, `unknown type kind "unknown"`)
+}
+
+func (s *GeneratorTestSuite) Test_fails_to_generate_structure_extending_unknown_structure() {
+	model := &MetaModel{
+		Structures: []*Structure{
+			{
+				Name:    "TestParams",
+				Extends: []*Type{{Kind: TypeKindReference, Name: "MissingParams"}},
+			},
+		},
+	}
+	_, err := Generate(model, "lsp")
+	s.Require().ErrorContains(err, `structure TestParams extends unknown structure "MissingParams"`)
+}
+
+func (s *GeneratorTestSuite) Test_converts_names_to_go_names() {
+	tests := map[string]string{
+		"uri":             "URI",
+		"rootUri":         "RootURI",
+		"processId":       "ProcessID",
+		"identifier":      "Identifier",
+		"codeDescription": "CodeDescription",
+		"targetUri":       "TargetURI",
+		"href":            "Href",
+	}
+	for name, expected := range tests {
+		s.Require().Equal(expected, goName(name))
+	}
+}
+
+func TestGeneratorTestSuite(t *testing.T) {
+	suite.Run(t, new(GeneratorTestSuite))
+}
@@ -0,0 +1,92 @@
+// Command lspgen generates Go protocol types, method constants and
+// handler boilerplate from the meta model of a version of the
+// Language Server Protocol specification.
+//
+// Usage:
+//
+//	go run ./cmd/lspgen -model path/to/metaModel.json -out path/to/protocol_gen.go -package lsp
+//
+// With the -check flag, the generated source is compared with the existing
+// file at the output path instead of being written so that CI can detect
+// generated files that have drifted from the meta model.
+//
+// The meta model for a version of the specification is published alongside
+// the specification, for example, the meta model for LSP 3.17 can be found at
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/metaModel/metaModel.json
+//
+// The protocol packages of this module (e.g. lsp_3_17) are written by hand,
+// they are not generated with lspgen.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	modelPath := flag.String("model", "metaModel.json", "path to the meta model JSON file")
+	outPath := flag.String("out", "", "path to write the generated Go source to, defaults to stdout")
+	packageName := flag.String("package", "lsp", "name of the package for the generated Go source")
+	check := flag.Bool("check", false, "fail if the file at the output path differs from the generated Go source")
+	flag.Parse()
+
+	if err := run(*modelPath, *outPath, *packageName, *check); err != nil {
+		fmt.Fprintf(os.Stderr, "lspgen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(modelPath string, outPath string, packageName string, check bool) error {
+	model, err := loadMetaModel(modelPath)
+	if err != nil {
+		return err
+	}
+
+	source, err := Generate(model, packageName)
+	if err != nil {
+		return err
+	}
+
+	if check {
+		return checkGenerated(outPath, source)
+	}
+
+	if outPath == "" {
+		_, err = os.Stdout.Write(source)
+		return err
+	}
+	return os.WriteFile(outPath, source, 0644)
+}
+
+func checkGenerated(outPath string, source []byte) error {
+	if outPath == "" {
+		return errors.New("an output path must be provided to check the generated source")
+	}
+
+	existing, err := os.ReadFile(outPath)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(existing, source) {
+		return fmt.Errorf("%s is out of date with the meta model, run lspgen without -check to regenerate it", outPath)
+	}
+	return nil
+}
+
+func loadMetaModel(path string) (*MetaModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	model := &MetaModel{}
+	if err := json.Unmarshal(data, model); err != nil {
+		return nil, fmt.Errorf("failed to parse meta model %s: %w", path, err)
+	}
+	return model, nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MetaModel represents the LSP meta model that describes all the
+// messages and types of a version of the specification.
+// See: https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/metaModel/metaModel.schema.json
+type MetaModel struct {
+	MetaData      MetaData        `json:"metaData"`
+	Requests      []*Request      `json:"requests"`
+	Notifications []*Notification `json:"notifications"`
+	Structures    []*Structure    `json:"structures"`
+	Enumerations  []*Enumeration  `json:"enumerations"`
+	TypeAliases   []*TypeAlias    `json:"typeAliases"`
+}
+
+// MetaData holds additional information about the meta model.
+type MetaData struct {
+	// The protocol version.
+	Version string `json:"version"`
+}
+
+// MessageDirection indicates in which direction a message is sent in the protocol.
+type MessageDirection string
+
+const (
+	MessageDirectionClientToServer MessageDirection = "clientToServer"
+	MessageDirectionServerToClient MessageDirection = "serverToClient"
+	MessageDirectionBoth           MessageDirection = "both"
+)
+
+// Request represents a LSP request.
+type Request struct {
+	Method              string           `json:"method"`
+	TypeName            string           `json:"typeName"`
+	Params              *Params          `json:"params"`
+	Result              *Type            `json:"result"`
+	PartialResult       *Type            `json:"partialResult"`
+	ErrorData           *Type            `json:"errorData"`
+	RegistrationMethod  string           `json:"registrationMethod"`
+	RegistrationOptions *Type            `json:"registrationOptions"`
+	MessageDirection    MessageDirection `json:"messageDirection"`
+	Documentation       string           `json:"documentation"`
+	Since               string           `json:"since"`
+	Proposed            bool             `json:"proposed"`
+	Deprecated          string           `json:"deprecated"`
+}
+
+// Notification represents a LSP notification.
+type Notification struct {
+	Method              string           `json:"method"`
+	TypeName            string           `json:"typeName"`
+	Params              *Params          `json:"params"`
+	RegistrationMethod  string           `json:"registrationMethod"`
+	RegistrationOptions *Type            `json:"registrationOptions"`
+	MessageDirection    MessageDirection `json:"messageDirection"`
+	Documentation       string           `json:"documentation"`
+	Since               string           `json:"since"`
+	Proposed            bool             `json:"proposed"`
+	Deprecated          string           `json:"deprecated"`
+}
+
+// Params holds the type of the parameters of a request or notification.
+// The meta model allows for a list of parameter types for positional parameters,
+// this is not used by the LSP specification and is not supported by the generator.
+type Params struct {
+	Type *Type
+}
+
+// Fulfils the json.Unmarshaler interface.
+func (p *Params) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		return errors.New("positional parameters are not supported")
+	}
+
+	p.Type = &Type{}
+	return json.Unmarshal(data, p.Type)
+}
+
+// Structure defines the structure of an object literal.
+type Structure struct {
+	Name          string      `json:"name"`
+	Extends       []*Type     `json:"extends"`
+	Mixins        []*Type     `json:"mixins"`
+	Properties    []*Property `json:"properties"`
+	Documentation string      `json:"documentation"`
+	Since         string      `json:"since"`
+	Proposed      bool        `json:"proposed"`
+	Deprecated    string      `json:"deprecated"`
+}
+
+// StructureLiteral defines an unnamed structure of an object literal.
+type StructureLiteral struct {
+	Properties    []*Property `json:"properties"`
+	Documentation string      `json:"documentation"`
+	Since         string      `json:"since"`
+	Proposed      bool        `json:"proposed"`
+	Deprecated    string      `json:"deprecated"`
+}
+
+// Property represents an object property.
+type Property struct {
+	Name          string `json:"name"`
+	Type          *Type  `json:"type"`
+	Optional      bool   `json:"optional"`
+	Documentation string `json:"documentation"`
+	Since         string `json:"since"`
+	Proposed      bool   `json:"proposed"`
+	Deprecated    string `json:"deprecated"`
+}
+
+// Enumeration defines an enumeration.
+type Enumeration struct {
+	Name                 string              `json:"name"`
+	Type                 *Type               `json:"type"`
+	Values               []*EnumerationEntry `json:"values"`
+	SupportsCustomValues bool                `json:"supportsCustomValues"`
+	Documentation        string              `json:"documentation"`
+	Since                string              `json:"since"`
+	Proposed             bool                `json:"proposed"`
+	Deprecated           string              `json:"deprecated"`
+}
+
+// EnumerationEntry defines an enumeration entry,
+// the value is either a string or a number.
+type EnumerationEntry struct {
+	Name          string          `json:"name"`
+	Value         json.RawMessage `json:"value"`
+	Documentation string          `json:"documentation"`
+	Since         string          `json:"since"`
+	Proposed      bool            `json:"proposed"`
+	Deprecated    string          `json:"deprecated"`
+}
+
+// TypeAlias defines a type alias, for example `type Definition = Location | LocationLink`.
+type TypeAlias struct {
+	Name          string `json:"name"`
+	Type          *Type  `json:"type"`
+	Documentation string `json:"documentation"`
+	Since         string `json:"since"`
+	Proposed      bool   `json:"proposed"`
+	Deprecated    string `json:"deprecated"`
+}
+
+// TypeKind is the kind of a type in the meta model.
+type TypeKind string
+
+const (
+	TypeKindBase           TypeKind = "base"
+	TypeKindReference      TypeKind = "reference"
+	TypeKindArray          TypeKind = "array"
+	TypeKindMap            TypeKind = "map"
+	TypeKindAnd            TypeKind = "and"
+	TypeKindOr             TypeKind = "or"
+	TypeKindTuple          TypeKind = "tuple"
+	TypeKindLiteral        TypeKind = "literal"
+	TypeKindStringLiteral  TypeKind = "stringLiteral"
+	TypeKindIntegerLiteral TypeKind = "integerLiteral"
+	TypeKindBooleanLiteral TypeKind = "booleanLiteral"
+)
+
+// Type represents any of the types that can be used in the meta model,
+// the fields that are set depend on the kind of the type.
+type Type struct {
+	Kind TypeKind
+
+	// The name of a base type or a referenced type.
+	Name string
+
+	// The element type of an array.
+	Element *Type
+
+	// The key and value types of a map.
+	Key   *Type
+	Value *Type
+
+	// The items of an and, or or tuple type.
+	Items []*Type
+
+	// The structure of a literal type.
+	Literal *StructureLiteral
+
+	// The value of a string, integer or boolean literal type.
+	LiteralValue json.RawMessage
+}
+
+// Fulfils the json.Unmarshaler interface.
+func (t *Type) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Kind    TypeKind        `json:"kind"`
+		Name    string          `json:"name"`
+		Element *Type           `json:"element"`
+		Key     *Type           `json:"key"`
+		Value   json.RawMessage `json:"value"`
+		Items   []*Type         `json:"items"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*t = Type{
+		Kind:    raw.Kind,
+		Name:    raw.Name,
+		Element: raw.Element,
+		Key:     raw.Key,
+		Items:   raw.Items,
+	}
+
+	switch raw.Kind {
+	case TypeKindMap:
+		t.Value = &Type{}
+		return json.Unmarshal(raw.Value, t.Value)
+	case TypeKindLiteral:
+		t.Literal = &StructureLiteral{}
+		return json.Unmarshal(raw.Value, t.Literal)
+	case TypeKindStringLiteral, TypeKindIntegerLiteral, TypeKindBooleanLiteral:
+		t.LiteralValue = raw.Value
+	case TypeKindBase, TypeKindReference, TypeKindArray, TypeKindAnd, TypeKindOr, TypeKindTuple:
+	default:
+		return fmt.Errorf("unknown type kind %q", raw.Kind)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package common
+
+import "context"
+
+type messageOrderKey struct{}
+
+// WithMessageOrder creates a copy of the provided context that holds the order
+// in which the message being handled was received from the client,
+// see `MessageOrder`.
+func WithMessageOrder(ctx context.Context, order uint64) context.Context {
+	return context.WithValue(ctx, messageOrderKey{}, order)
+}
+
+// MessageOrder returns the order in which the message being handled with the provided
+// context was received relative to the notifications received on the same connection.
+// Notifications are numbered from 1 in the order they were received and requests
+// take the number of the last notification received before them, so a notification
+// with a greater order than a request was received after the request, even when
+// requests and notifications are handled concurrently.
+//
+// This returns false when the order is not known, for example, for messages
+// that are not handled by a server from the `server` package.
+func MessageOrder(ctx context.Context) (uint64, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+
+	order, ok := ctx.Value(messageOrderKey{}).(uint64)
+	return order, ok
+}
@@ -18,10 +18,15 @@ type CallFunc func(method string, params any, result any) error
 
 // LSPContext contains the context for an LSP request from a client.
 type LSPContext struct {
-	Method  string
-	Params  json.RawMessage
-	Notify  NotifyFunc
-	Call    CallFunc
+	Method string
+	Params json.RawMessage
+	// IsNotification is true when the message is a notification
+	// that does not expect a response.
+	IsNotification bool
+	Notify         NotifyFunc
+	Call           CallFunc
+	// Context is cancelled when the request times out
+	// or is cancelled by the client.
 	Context context.Context
 }
 
@@ -0,0 +1,212 @@
+package fuzzy
+
+import (
+	"unicode"
+)
+
+// Match holds the result of matching a query against a word.
+type Match struct {
+	// The score of the match, a higher score is a better match.
+	Score int
+	// The indexes of the runes in the word that the runes
+	// of the query were matched with.
+	Positions []int
+}
+
+const (
+	// Only the first runes of a word are considered for matching to keep
+	// scoring fast for very long words.
+	maxWordLength = 255
+
+	matchScore       = 1
+	sameCaseBonus    = 1
+	wordStartBonus   = 8
+	boundaryBonus    = 6
+	consecutiveBonus = 5
+	gapPenalty       = 1
+)
+
+// Score matches a query against a word, in the same way as the completion
+// and symbol filtering of editors such as VS Code.
+// Matching is case-insensitive and the runes of the query must appear in order
+// in the word, the first rune of the query must match the start of the word
+// or the start of a segment of the word (e.g. "b" matches "fooBar" and "foo_bar"
+// but not "foobar").
+//
+// Matches at the start of segments, consecutive matches and matches with the same case
+// score higher, gaps between matched runes lower the score.
+// An empty query matches every word with a score of 0.
+func Score(query string, word string) (Match, bool) {
+	queryRunes := []rune(query)
+	wordRunes := []rune(word)
+	if len(wordRunes) > maxWordLength {
+		wordRunes = wordRunes[:maxWordLength]
+	}
+
+	if len(queryRunes) == 0 {
+		return Match{Positions: []int{}}, true
+	}
+
+	if len(queryRunes) > len(wordRunes) {
+		return Match{}, false
+	}
+
+	return newMatcher(queryRunes, wordRunes).match()
+}
+
+type matcher struct {
+	query      []rune
+	word       []rune
+	lowerQuery []rune
+	lowerWord  []rune
+	// scores[i][j] holds the best score for matching the query up to
+	// and including rune i with rune i of the query matched at rune j of the word.
+	scores [][]int
+	// previous[i][j] holds the position in the word that rune i-1 of the query
+	// was matched at for the best score in scores[i][j].
+	previous [][]int
+}
+
+const noMatch = -1 << 31
+
+func newMatcher(query []rune, word []rune) *matcher {
+	m := &matcher{
+		query:      query,
+		word:       word,
+		lowerQuery: toLower(query),
+		lowerWord:  toLower(word),
+		scores:     make([][]int, len(query)),
+		previous:   make([][]int, len(query)),
+	}
+	for i := range query {
+		m.scores[i] = make([]int, len(word))
+		m.previous[i] = make([]int, len(word))
+		for j := range word {
+			m.scores[i][j] = noMatch
+			m.previous[i][j] = -1
+		}
+	}
+	return m
+}
+
+func (m *matcher) match() (Match, bool) {
+	for j := range m.word {
+		if m.lowerQuery[0] == m.lowerWord[j] && (j == 0 || isSegmentStart(m.word, j)) {
+			m.scores[0][j] = m.runeScore(0, j)
+		}
+	}
+
+	for i := 1; i < len(m.query); i += 1 {
+		// The best score for rune i-1 of the query matched at some position k
+		// adjusted by k, so the gap penalty for matching rune i at j
+		// can be applied in constant time.
+		gapBest := noMatch
+		gapBestPos := -1
+		for j := i; j < len(m.word); j += 1 {
+			if j >= 2 {
+				k := j - 2
+				if m.scores[i-1][k] != noMatch && m.scores[i-1][k]+k > gapBest {
+					gapBest = m.scores[i-1][k] + k
+					gapBestPos = k
+				}
+			}
+
+			if m.lowerQuery[i] != m.lowerWord[j] {
+				continue
+			}
+
+			best := noMatch
+			bestPos := -1
+			if m.scores[i-1][j-1] != noMatch {
+				best = m.scores[i-1][j-1] + consecutiveBonus
+				bestPos = j - 1
+			}
+			if gapBest != noMatch {
+				withGap := gapBest - (j-1)*gapPenalty
+				if withGap > best {
+					best = withGap
+					bestPos = gapBestPos
+				}
+			}
+
+			if bestPos >= 0 {
+				m.scores[i][j] = best + m.runeScore(i, j)
+				m.previous[i][j] = bestPos
+			}
+		}
+	}
+
+	last := len(m.query) - 1
+	bestScore := noMatch
+	bestEnd := -1
+	for j := range m.word {
+		if m.scores[last][j] > bestScore {
+			bestScore = m.scores[last][j]
+			bestEnd = j
+		}
+	}
+	if bestEnd < 0 {
+		return Match{}, false
+	}
+
+	positions := make([]int, len(m.query))
+	position := bestEnd
+	for i := last; i >= 0; i -= 1 {
+		positions[i] = position
+		position = m.previous[i][position]
+	}
+	return Match{Score: bestScore, Positions: positions}, true
+}
+
+func (m *matcher) runeScore(i int, j int) int {
+	score := matchScore
+	if m.query[i] == m.word[j] {
+		score += sameCaseBonus
+	}
+
+	if j == 0 {
+		score += wordStartBonus
+	} else if isSegmentStart(m.word, j) {
+		score += boundaryBonus
+	}
+	return score
+}
+
+// isSegmentStart determines whether the rune at the provided position
+// starts a segment of a word, for example, after a separator in snake_case
+// or kebab-case words or at a hump in camelCase words.
+func isSegmentStart(word []rune, j int) bool {
+	current := word[j]
+	previous := word[j-1]
+	if isSeparator(current) {
+		return false
+	}
+
+	if isSeparator(previous) {
+		return true
+	}
+
+	if unicode.IsUpper(current) {
+		// The end of an acronym followed by a word, e.g. "S" in "HTTPServer".
+		return !unicode.IsUpper(previous) ||
+			(j+1 < len(word) && unicode.IsLower(word[j+1]))
+	}
+
+	return unicode.IsDigit(current) && !unicode.IsDigit(previous)
+}
+
+func isSeparator(r rune) bool {
+	switch r {
+	case '_', '-', '.', '/', '\\', ':', ' ', '$', '#', '@':
+		return true
+	}
+	return false
+}
+
+func toLower(runes []rune) []rune {
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	return lower
+}
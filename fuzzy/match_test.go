@@ -0,0 +1,131 @@
+package fuzzy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MatchTestSuite struct {
+	suite.Suite
+}
+
+func (s *MatchTestSuite) Test_matches_query_against_segments_of_words() {
+	tests := []struct {
+		name              string
+		query             string
+		word              string
+		expectedPositions []int
+	}{
+		{
+			name:              "prefix",
+			query:             "get",
+			word:              "getValue",
+			expectedPositions: []int{0, 1, 2},
+		},
+		{
+			name:              "camelCase humps",
+			query:             "gv",
+			word:              "getValue",
+			expectedPositions: []int{0, 3},
+		},
+		{
+			name:              "snake_case segments",
+			query:             "fbb",
+			word:              "foo_bar_baz",
+			expectedPositions: []int{0, 4, 8},
+		},
+		{
+			name:              "acronym followed by a word",
+			query:             "hs",
+			word:              "HTTPServer",
+			expectedPositions: []int{0, 4},
+		},
+		{
+			name:              "first rune matching a segment",
+			query:             "val",
+			word:              "getValue",
+			expectedPositions: []int{3, 4, 5},
+		},
+		{
+			name:              "case-insensitive",
+			query:             "GETV",
+			word:              "getValue",
+			expectedPositions: []int{0, 1, 2, 3},
+		},
+		{
+			name:              "prefers consecutive matches",
+			query:             "sn",
+			word:              "snake_name",
+			expectedPositions: []int{0, 1},
+		},
+		{
+			name:              "multi-byte runes",
+			query:             "çb",
+			word:              "çaBar",
+			expectedPositions: []int{0, 2},
+		},
+		{
+			name:              "empty query",
+			query:             "",
+			word:              "getValue",
+			expectedPositions: []int{},
+		},
+	}
+
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			match, isMatch := Score(test.query, test.word)
+			s.Require().True(isMatch)
+			s.Require().Equal(test.expectedPositions, match.Positions)
+		})
+	}
+}
+
+func (s *MatchTestSuite) Test_does_not_match_query_not_in_word() {
+	tests := []struct {
+		name  string
+		query string
+		word  string
+	}{
+		{name: "runes out of order", query: "vg", word: "getValue"},
+		{name: "first rune within a segment", query: "et", word: "getValue"},
+		{name: "query longer than word", query: "getValues", word: "getValue"},
+		{name: "missing rune", query: "gx", word: "getValue"},
+	}
+
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			_, isMatch := Score(test.query, test.word)
+			s.Require().False(isMatch)
+		})
+	}
+}
+
+func (s *MatchTestSuite) Test_scores_better_matches_higher() {
+	tests := []struct {
+		name   string
+		query  string
+		better string
+		worse  string
+	}{
+		{name: "segment start over inner rune", query: "fb", better: "fooBar", worse: "fabric"},
+		{name: "word start over segment start", query: "val", better: "value", worse: "getValue"},
+		{name: "consecutive over gaps", query: "abc", better: "abcd", worse: "aXbXc"},
+		{name: "same case", query: "Get", better: "Get", worse: "get"},
+	}
+
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			better, isMatch := Score(test.query, test.better)
+			s.Require().True(isMatch)
+			worse, isMatch := Score(test.query, test.worse)
+			s.Require().True(isMatch)
+			s.Require().Greater(better.Score, worse.Score)
+		})
+	}
+}
+
+func TestMatchTestSuite(t *testing.T) {
+	suite.Run(t, new(MatchTestSuite))
+}
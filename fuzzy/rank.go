@@ -0,0 +1,339 @@
+package fuzzy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	lsp "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+// Option is a function that can be used to configure how completion items
+// and workspace symbols are filtered and ranked.
+type Option func(*options)
+
+type options struct {
+	maxResults  int
+	editRange   any
+	clientState *lsp.ClientState
+}
+
+// WithMaxResults sets the maximum number of results to return,
+// when there are more matches than the maximum, the best matches are returned
+// and completion lists are marked as incomplete so the client requests
+// completions again as the user types.
+// There is no limit when the maximum is 0 or less.
+func WithMaxResults(maxResults int) Option {
+	return func(opts *options) {
+		opts.maxResults = maxResults
+	}
+}
+
+// WithEditRange sets the range of the text that completion items replace
+// for the items that do not have their own text edit,
+// this is usually the range of the word being completed up to the cursor.
+//
+// Range | InsertReplaceRange
+func WithEditRange(editRange any) Option {
+	return func(opts *options) {
+		opts.editRange = editRange
+	}
+}
+
+// WithClientState sets the client state used to determine the features supported
+// by the client such as item defaults for completion lists.
+// Item defaults are not used when a client state is not provided.
+func WithClientState(clientState *lsp.ClientState) Option {
+	return func(opts *options) {
+		opts.clientState = clientState
+	}
+}
+
+// FilterCompletionItems filters and ranks completion items against a query,
+// usually the word being completed up to the cursor.
+// Items are matched on their filter text, falling back to the label
+// for items without a filter text.
+//
+// The items in the returned list are copies of the provided items
+// in the order of the ranking with the sort text set to reflect the ranking
+// and the filter text set to the text that was matched, so the client
+// preserves the ranking when it filters and sorts the items.
+//
+// When the client supports the `editRange` item default, the edit range shared
+// by all the items is moved to the item defaults of the list.
+func FilterCompletionItems(
+	query string,
+	items []*lsp.CompletionItem,
+	opts ...Option,
+) *lsp.CompletionList {
+	options := createOptions(opts)
+
+	ranked := []rankedCompletionItem{}
+	for _, item := range items {
+		filterText := item.Label
+		if item.FilterText != nil {
+			filterText = *item.FilterText
+		}
+
+		match, isMatch := Score(query, filterText)
+		if isMatch {
+			ranked = append(ranked, rankedCompletionItem{
+				item:       item,
+				filterText: filterText,
+				score:      match.Score,
+			})
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].less(ranked[j])
+	})
+
+	isIncomplete := false
+	if options.maxResults > 0 && len(ranked) > options.maxResults {
+		ranked = ranked[:options.maxResults]
+		isIncomplete = true
+	}
+
+	list := &lsp.CompletionList{
+		IsIncomplete: isIncomplete,
+		Items:        make([]*lsp.CompletionItem, len(ranked)),
+	}
+	for i, rankedItem := range ranked {
+		item := *rankedItem.item
+		sortText := rankSortText(i, len(ranked))
+		item.SortText = &sortText
+		filterText := rankedItem.filterText
+		item.FilterText = &filterText
+		list.Items[i] = &item
+	}
+
+	applyEditRange(list, options)
+	return list
+}
+
+// FilterWorkspaceSymbols filters and ranks workspace symbols against the query
+// of a `workspace/symbol` request, symbols are matched on their name.
+// The best matches come first in the returned slice.
+func FilterWorkspaceSymbols(
+	query string,
+	symbols []lsp.WorkspaceSymbol,
+	opts ...Option,
+) []lsp.WorkspaceSymbol {
+	options := createOptions(opts)
+
+	ranked := []rankedWorkspaceSymbol{}
+	for _, symbol := range symbols {
+		match, isMatch := Score(query, symbol.Name)
+		if isMatch {
+			ranked = append(ranked, rankedWorkspaceSymbol{
+				symbol: symbol,
+				score:  match.Score,
+			})
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].symbol.Name < ranked[j].symbol.Name
+	})
+
+	if options.maxResults > 0 && len(ranked) > options.maxResults {
+		ranked = ranked[:options.maxResults]
+	}
+
+	results := make([]lsp.WorkspaceSymbol, len(ranked))
+	for i, rankedSymbol := range ranked {
+		results[i] = rankedSymbol.symbol
+	}
+	return results
+}
+
+type rankedCompletionItem struct {
+	item       *lsp.CompletionItem
+	filterText string
+	score      int
+}
+
+func (r rankedCompletionItem) less(other rankedCompletionItem) bool {
+	if r.score != other.score {
+		return r.score > other.score
+	}
+
+	// Fall back to the order provided by the server for items
+	// that match equally well.
+	sortText := r.sortText()
+	otherSortText := other.sortText()
+	if sortText != otherSortText {
+		return sortText < otherSortText
+	}
+	return r.item.Label < other.item.Label
+}
+
+func (r rankedCompletionItem) sortText() string {
+	if r.item.SortText != nil {
+		return *r.item.SortText
+	}
+	return r.item.Label
+}
+
+type rankedWorkspaceSymbol struct {
+	symbol lsp.WorkspaceSymbol
+	score  int
+}
+
+func createOptions(opts []Option) *options {
+	options := &options{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// rankSortText creates a sort text for the item at the provided rank
+// that is padded so the items are sorted in the order of the ranking
+// when compared as strings.
+func rankSortText(rank int, total int) string {
+	width := len(strconv.Itoa(total - 1))
+	return fmt.Sprintf("%0*d", width, rank)
+}
+
+func applyEditRange(list *lsp.CompletionList, options *options) {
+	supportsDefault := options.clientState != nil &&
+		options.clientState.SupportsCompletionItemDefault("editRange")
+
+	editRange := options.editRange
+	if editRange == nil && supportsDefault {
+		editRange = sharedEditRange(list.Items)
+	}
+
+	if editRange == nil || len(list.Items) == 0 {
+		return
+	}
+
+	if supportsDefault {
+		list.ItemDefaults = &lsp.CompletionItemDefaults{
+			EditRange: normaliseEditRange(editRange),
+		}
+	}
+
+	for _, item := range list.Items {
+		newText, itemRange := textEditRange(item.TextEdit)
+		if supportsDefault && itemRange != nil && equalEditRanges(itemRange, editRange) {
+			// The text edit is replaced by the default edit range of the list
+			// where the text to insert is the label unless the text edit text is set.
+			item.TextEdit = nil
+			if newText != item.Label {
+				item.TextEditText = &newText
+			}
+		} else if supportsDefault && item.TextEdit == nil && item.TextEditText == nil {
+			// Clients insert the label for items that use the default edit range
+			// without a text edit text instead of the insert text.
+			if text := completionItemText(item); text != item.Label {
+				item.TextEditText = &text
+			}
+		} else if !supportsDefault && item.TextEdit == nil {
+			item.TextEdit = textEditForRange(editRange, completionItemText(item))
+		}
+	}
+}
+
+// sharedEditRange returns the edit range shared by the text edits
+// of all the provided items, nil is returned when there is no
+// shared edit range.
+func sharedEditRange(items []*lsp.CompletionItem) any {
+	var shared any
+	for _, item := range items {
+		_, itemRange := textEditRange(item.TextEdit)
+		if itemRange == nil {
+			return nil
+		}
+
+		if shared == nil {
+			shared = itemRange
+		} else if !equalEditRanges(shared, itemRange) {
+			return nil
+		}
+	}
+	return shared
+}
+
+// textEditRange extracts the text to insert and the range of a completion item
+// text edit, the range will either be a Range or an InsertReplaceRange.
+func textEditRange(textEdit any) (string, any) {
+	switch edit := textEdit.(type) {
+	case lsp.TextEdit:
+		if edit.Range != nil {
+			return edit.NewText, *edit.Range
+		}
+	case *lsp.TextEdit:
+		if edit != nil && edit.Range != nil {
+			return edit.NewText, *edit.Range
+		}
+	case lsp.InsertReplaceEdit:
+		return edit.NewText, lsp.InsertReplaceRange{Insert: edit.Insert, Replace: edit.Replace}
+	case *lsp.InsertReplaceEdit:
+		if edit != nil {
+			return edit.NewText, lsp.InsertReplaceRange{Insert: edit.Insert, Replace: edit.Replace}
+		}
+	}
+	return "", nil
+}
+
+func textEditForRange(editRange any, newText string) any {
+	switch rangeValue := normaliseEditRange(editRange).(type) {
+	case lsp.Range:
+		return lsp.TextEdit{Range: &rangeValue, NewText: newText}
+	case lsp.InsertReplaceRange:
+		return lsp.InsertReplaceEdit{
+			NewText: newText,
+			Insert:  rangeValue.Insert,
+			Replace: rangeValue.Replace,
+		}
+	}
+	return nil
+}
+
+func completionItemText(item *lsp.CompletionItem) string {
+	if item.InsertText != nil {
+		return *item.InsertText
+	}
+	return item.Label
+}
+
+func equalEditRanges(a any, b any) bool {
+	switch aRange := normaliseEditRange(a).(type) {
+	case lsp.Range:
+		bRange, isRange := normaliseEditRange(b).(lsp.Range)
+		return isRange && aRange == bRange
+	case lsp.InsertReplaceRange:
+		bRange, isInsertReplace := normaliseEditRange(b).(lsp.InsertReplaceRange)
+		return isInsertReplace &&
+			equalRangePointers(aRange.Insert, bRange.Insert) &&
+			equalRangePointers(aRange.Replace, bRange.Replace)
+	}
+	return false
+}
+
+func normaliseEditRange(editRange any) any {
+	switch rangeValue := editRange.(type) {
+	case *lsp.Range:
+		if rangeValue != nil {
+			return *rangeValue
+		}
+	case *lsp.InsertReplaceRange:
+		if rangeValue != nil {
+			return *rangeValue
+		}
+	}
+	return editRange
+}
+
+func equalRangePointers(a *lsp.Range, b *lsp.Range) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,195 @@
+package fuzzy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	lsp "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+type RankTestSuite struct {
+	suite.Suite
+}
+
+var testWordRange = lsp.Range{
+	Start: lsp.Position{Line: 4, Character: 2},
+	End:   lsp.Position{Line: 4, Character: 5},
+}
+
+func (s *RankTestSuite) Test_ranks_completion_items_by_match_score() {
+	items := []*lsp.CompletionItem{
+		{Label: "fabric"},
+		{Label: "fooBar"},
+		{Label: "unrelated"},
+		{Label: "foo_bar", SortText: strPtr("b")},
+		{Label: "fromBase", SortText: strPtr("a")},
+		{Label: "setFooBar", FilterText: strPtr("fooBar")},
+	}
+
+	list := FilterCompletionItems("fb", items)
+	s.Require().False(list.IsIncomplete)
+	s.Require().Nil(list.ItemDefaults)
+	s.Require().Equal(
+		[]string{"foo_bar", "fooBar", "setFooBar", "fromBase", "fabric"},
+		completionLabels(list),
+	)
+	s.Require().Equal([]string{"0", "1", "2", "3", "4"}, completionSortTexts(list))
+	s.Require().Equal("fooBar", *list.Items[2].FilterText)
+	s.Require().Equal("fabric", *list.Items[4].FilterText)
+
+	// The provided items are left untouched.
+	s.Require().Nil(items[0].SortText)
+	s.Require().Equal("b", *items[3].SortText)
+}
+
+func (s *RankTestSuite) Test_marks_completion_list_as_incomplete_when_truncated() {
+	items := []*lsp.CompletionItem{}
+	for _, label := range []string{"a1", "a2", "a3", "a4", "a5", "a6", "a7", "a8", "a9", "a10", "a11", "b"} {
+		items = append(items, &lsp.CompletionItem{Label: label})
+	}
+
+	list := FilterCompletionItems("a", items)
+	s.Require().False(list.IsIncomplete)
+	s.Require().Len(list.Items, 11)
+	s.Require().Equal("00", *list.Items[0].SortText)
+	s.Require().Equal("10", *list.Items[10].SortText)
+
+	list = FilterCompletionItems("a", items, WithMaxResults(3))
+	s.Require().True(list.IsIncomplete)
+	s.Require().Equal([]string{"a1", "a10", "a11"}, completionLabels(list))
+}
+
+func (s *RankTestSuite) Test_moves_shared_edit_range_to_item_defaults() {
+	items := []*lsp.CompletionItem{
+		{Label: "fooBar", TextEdit: lsp.TextEdit{Range: &testWordRange, NewText: "fooBar"}},
+		{Label: "fooBaz", TextEdit: &lsp.TextEdit{Range: &testWordRange, NewText: "fooBaz()"}},
+	}
+
+	list := FilterCompletionItems("fo", items, WithClientState(s.clientState([]string{"editRange"})))
+	s.Require().Equal(&lsp.CompletionItemDefaults{EditRange: testWordRange}, list.ItemDefaults)
+	s.Require().Nil(list.Items[0].TextEdit)
+	s.Require().Nil(list.Items[0].TextEditText)
+	s.Require().Nil(list.Items[1].TextEdit)
+	s.Require().Equal("fooBaz()", *list.Items[1].TextEditText)
+
+	// Edits are left as they are when the client does not support the edit range item default.
+	list = FilterCompletionItems("fo", items, WithClientState(s.clientState([]string{"data"})))
+	s.Require().Nil(list.ItemDefaults)
+	s.Require().Equal(items[0].TextEdit, list.Items[0].TextEdit)
+	s.Require().Equal(items[1].TextEdit, list.Items[1].TextEdit)
+
+	// Items with different ranges do not share an edit range.
+	otherRange := lsp.Range{Start: testWordRange.Start, End: testWordRange.Start}
+	items = append(items, &lsp.CompletionItem{
+		Label:    "fooBat",
+		TextEdit: lsp.TextEdit{Range: &otherRange, NewText: "fooBat"},
+	})
+	list = FilterCompletionItems("fo", items, WithClientState(s.clientState([]string{"editRange"})))
+	s.Require().Nil(list.ItemDefaults)
+}
+
+func (s *RankTestSuite) Test_applies_provided_edit_range_to_items() {
+	insertReplaceRange := lsp.InsertReplaceRange{
+		Insert:  &lsp.Range{Start: testWordRange.Start, End: lsp.Position{Line: 4, Character: 4}},
+		Replace: &testWordRange,
+	}
+	items := []*lsp.CompletionItem{
+		{Label: "fooBar", InsertText: strPtr("fooBar()")},
+		{Label: "fooBaz"},
+	}
+
+	list := FilterCompletionItems(
+		"fo",
+		items,
+		WithEditRange(&insertReplaceRange),
+		WithClientState(s.clientState([]string{"editRange"})),
+	)
+	s.Require().Equal(&lsp.CompletionItemDefaults{EditRange: insertReplaceRange}, list.ItemDefaults)
+	s.Require().Nil(list.Items[0].TextEdit)
+	s.Require().Equal("fooBar()", *list.Items[0].TextEditText)
+	s.Require().Nil(list.Items[1].TextEditText)
+
+	list = FilterCompletionItems("fo", items, WithEditRange(insertReplaceRange))
+	s.Require().Nil(list.ItemDefaults)
+	s.Require().Equal(
+		lsp.InsertReplaceEdit{
+			NewText: "fooBar()",
+			Insert:  insertReplaceRange.Insert,
+			Replace: insertReplaceRange.Replace,
+		},
+		list.Items[0].TextEdit,
+	)
+	s.Require().Equal(
+		lsp.InsertReplaceEdit{
+			NewText: "fooBaz",
+			Insert:  insertReplaceRange.Insert,
+			Replace: insertReplaceRange.Replace,
+		},
+		list.Items[1].TextEdit,
+	)
+}
+
+func (s *RankTestSuite) Test_ranks_workspace_symbols_by_match_score() {
+	symbols := []lsp.WorkspaceSymbol{
+		{Name: "parseFile", Kind: lsp.SymbolKindFunction},
+		{Name: "ParseFile", Kind: lsp.SymbolKindFunction},
+		{Name: "printFormatted", Kind: lsp.SymbolKindFunction},
+		{Name: "Config", Kind: lsp.SymbolKindStruct},
+		{Name: "purifiedFilter", Kind: lsp.SymbolKindVariable},
+	}
+
+	results := FilterWorkspaceSymbols("pF", symbols)
+	s.Require().Equal(
+		[]string{"parseFile", "printFormatted", "ParseFile", "purifiedFilter"},
+		symbolNames(results),
+	)
+
+	results = FilterWorkspaceSymbols("pF", symbols, WithMaxResults(2))
+	s.Require().Equal([]string{"parseFile", "printFormatted"}, symbolNames(results))
+}
+
+func (s *RankTestSuite) clientState(itemDefaults []string) *lsp.ClientState {
+	return lsp.NewClientState(&lsp.InitializeParams{
+		Capabilities: lsp.ClientCapabilities{
+			TextDocument: &lsp.TextDocumentClientCapabilities{
+				Completion: &lsp.CompletionClientCapabilities{
+					CompletionList: &lsp.CompletionListCapabilities{
+						ItemDefaults: itemDefaults,
+					},
+				},
+			},
+		},
+	})
+}
+
+func completionLabels(list *lsp.CompletionList) []string {
+	labels := []string{}
+	for _, item := range list.Items {
+		labels = append(labels, item.Label)
+	}
+	return labels
+}
+
+func completionSortTexts(list *lsp.CompletionList) []string {
+	sortTexts := []string{}
+	for _, item := range list.Items {
+		sortTexts = append(sortTexts, *item.SortText)
+	}
+	return sortTexts
+}
+
+func symbolNames(symbols []lsp.WorkspaceSymbol) []string {
+	names := []string{}
+	for _, symbol := range symbols {
+		names = append(names, symbol.Name)
+	}
+	return names
+}
+
+func strPtr(value string) *string {
+	return &value
+}
+
+func TestRankTestSuite(t *testing.T) {
+	suite.Run(t, new(RankTestSuite))
+}
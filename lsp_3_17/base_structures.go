@@ -131,6 +131,32 @@ func (p Position) EndOfLineIn(content string, posEncodingKind PositionEncodingKi
 	}
 }
 
+// clampedIndexIn returns the byte offset in the text for the position, unlike `IndexIn`,
+// character offsets past the end of the line are clamped to the end of the line
+// (including the last line of the text) and positions on lines past the end
+// of the text are clamped to the end of the text.
+// The position is within the text when both its line and character offset are.
+func (p Position) clampedIndexIn(
+	text string,
+	posEncodingKind PositionEncodingKind,
+) (index int, lineInText bool, characterInLine bool) {
+	if strings.Count(text, "\n") < int(p.Line) {
+		return len(text), false, false
+	}
+
+	lineStart := p.getLineIndex(text)
+	lineEnd := len(text)
+	if eol := strings.Index(text[lineStart:], "\n"); eol != -1 {
+		lineEnd = lineStart + eol
+	}
+
+	if p.Character > codeUnitsIn(text[lineStart:lineEnd], posEncodingKind) {
+		return lineEnd, true, false
+	}
+
+	return p.IndexIn(text, posEncodingKind), true, true
+}
+
 func (p Position) getLineIndex(text string) int {
 	hasNewLine := true
 	index := 0
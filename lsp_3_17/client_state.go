@@ -0,0 +1,422 @@
+package lsp
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/two-hundred/ls-builder/common"
+)
+
+// ClientState holds information about the client that is captured
+// from the `initialize` request, such as the capabilities of the client,
+// along with helpers to query support for specific features.
+//
+// A `Handler` captures the client state during initialisation,
+// it can be retrieved with `Handler.ClientState` or from the context of any
+// message handled after initialisation with `ClientStateFromContext`.
+type ClientState struct {
+	capabilities     ClientCapabilities
+	clientInfo       *InitializeClientInfo
+	rootURI          *DocumentURI
+	workspaceFolders []WorkspaceFolder
+	mu               sync.RWMutex
+}
+
+// NewClientState creates a new client state from the parameters
+// of an `initialize` request.
+func NewClientState(params *InitializeParams) *ClientState {
+	rootURI := params.RootURI
+	if rootURI == nil && params.RootPath != nil && *params.RootPath != "" {
+		rootPathURI := pathToDocumentURI(*params.RootPath)
+		rootURI = &rootPathURI
+	}
+
+	return &ClientState{
+		capabilities:     params.Capabilities,
+		clientInfo:       params.ClientInfo,
+		rootURI:          rootURI,
+		workspaceFolders: slices.Clone(params.WorkspaceFolders),
+	}
+}
+
+// pathToDocumentURI converts a file system path provided by the client
+// to a `file` URI, paths are converted regardless of the operating system
+// of the server as the client may be running on a different operating system.
+func pathToDocumentURI(path string) DocumentURI {
+	if strings.HasPrefix(path, "file://") {
+		return path
+	}
+
+	slashPath := strings.ReplaceAll(path, "\\", "/")
+	if !strings.HasPrefix(slashPath, "/") {
+		// Windows paths that start with a drive letter (e.g. C:/projects).
+		slashPath = "/" + slashPath
+	}
+
+	uri := url.URL{Scheme: "file", Path: slashPath}
+	return uri.String()
+}
+
+// Capabilities returns the capabilities the client provided
+// in the `initialize` request.
+func (s *ClientState) Capabilities() ClientCapabilities {
+	return s.capabilities
+}
+
+// ClientInfo returns information about the client, this will be nil
+// if the client did not provide information about itself.
+func (s *ClientState) ClientInfo() *InitializeClientInfo {
+	return s.clientInfo
+}
+
+// RootURI returns the root URI of the workspace, falling back to the
+// deprecated root path if the client did not provide a root URI.
+// This will be nil if no folder is open in the client.
+func (s *ClientState) RootURI() *DocumentURI {
+	return s.rootURI
+}
+
+// WorkspaceFolders returns the workspace folders that are currently open in the client.
+// This is kept up to date with `workspace/didChangeWorkspaceFolders` notifications
+// when a handler is set for the notification.
+func (s *ClientState) WorkspaceFolders() []WorkspaceFolder {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.workspaceFolders)
+}
+
+// ApplyWorkspaceFoldersChange applies a change event from a
+// `workspace/didChangeWorkspaceFolders` notification to the
+// workspace folders stored for the client.
+func (s *ClientState) ApplyWorkspaceFoldersChange(event WorkspaceFoldersChangeEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	folders := slices.DeleteFunc(s.workspaceFolders, func(folder WorkspaceFolder) bool {
+		return slices.ContainsFunc(event.Removed, func(removed WorkspaceFolder) bool {
+			return removed.URI == folder.URI
+		})
+	})
+	s.workspaceFolders = append(folders, event.Added...)
+}
+
+// SupportsWorkDoneProgress determines whether the client supports
+// server-initiated work done progress with `window/workDoneProgress/create`.
+func (s *ClientState) SupportsWorkDoneProgress() bool {
+	window := s.capabilities.Window
+	return window != nil && isTrue(window.WorkDoneProgress)
+}
+
+// SupportsShowDocument determines whether the client supports
+// the `window/showDocument` request.
+func (s *ClientState) SupportsShowDocument() bool {
+	window := s.capabilities.Window
+	return window != nil && window.ShowDocument != nil && isTrue(window.ShowDocument.Support)
+}
+
+// SupportsSnippets determines whether the client supports snippets
+// as insert text for completion items.
+func (s *ClientState) SupportsSnippets() bool {
+	textDocument := s.capabilities.TextDocument
+	return textDocument != nil &&
+		textDocument.Completion != nil &&
+		textDocument.Completion.CompletionItem != nil &&
+		isTrue(textDocument.Completion.CompletionItem.SnippetSupport)
+}
+
+// SupportsCompletionItemDefault determines whether the client supports
+// the provided property (e.g. "editRange") in the item defaults
+// of a completion list.
+func (s *ClientState) SupportsCompletionItemDefault(property string) bool {
+	textDocument := s.capabilities.TextDocument
+	if textDocument == nil ||
+		textDocument.Completion == nil ||
+		textDocument.Completion.CompletionList == nil {
+		return false
+	}
+
+	return slices.Contains(textDocument.Completion.CompletionList.ItemDefaults, property)
+}
+
+// SupportsApplyEdit determines whether the client supports
+// the `workspace/applyEdit` request.
+func (s *ClientState) SupportsApplyEdit() bool {
+	workspace := s.capabilities.Workspace
+	return workspace != nil && isTrue(workspace.ApplyEdit)
+}
+
+// SupportsPullDiagnostics determines whether the client supports
+// pulling diagnostics with the `textDocument/diagnostic` request.
+func (s *ClientState) SupportsPullDiagnostics() bool {
+	textDocument := s.capabilities.TextDocument
+	return textDocument != nil && textDocument.Diagnostics != nil
+}
+
+// SupportsConfiguration determines whether the client supports
+// the `workspace/configuration` request.
+func (s *ClientState) SupportsConfiguration() bool {
+	workspace := s.capabilities.Workspace
+	return workspace != nil && isTrue(workspace.Configuration)
+}
+
+// SupportsWorkspaceFolders determines whether the client supports
+// workspace folders.
+func (s *ClientState) SupportsWorkspaceFolders() bool {
+	workspace := s.capabilities.Workspace
+	return workspace != nil && isTrue(workspace.WorkspaceFolders)
+}
+
+// RetriesOnContentModified determines whether the client will retry
+// the provided request (e.g. `textDocument/semanticTokens/full`) if it receives
+// a response with the `ContentModified` error code.
+func (s *ClientState) RetriesOnContentModified(method string) bool {
+	general := s.capabilities.General
+	return general != nil &&
+		general.StaleRequestSupport != nil &&
+		slices.Contains(general.StaleRequestSupport.RetryOnContentModified, method)
+}
+
+// SupportsRefresh determines whether the client supports the provided
+// refresh request (e.g. `workspace/codeLens/refresh`).
+// This will be false for methods that are not refresh requests.
+func (s *ClientState) SupportsRefresh(method string) bool {
+	workspace := s.capabilities.Workspace
+	if workspace == nil {
+		return false
+	}
+
+	switch method {
+	case MethodCodeLensRefresh:
+		return workspace.CodeLens != nil && isTrue(workspace.CodeLens.RefreshSupport)
+	case MethodSemanticTokensRefresh:
+		return workspace.SemanticTokens != nil && isTrue(workspace.SemanticTokens.RefreshSupport)
+	case MethodInlayHintRefresh:
+		return workspace.InlayHint != nil && isTrue(workspace.InlayHint.RefreshSupport)
+	case MethodInlineValueRefresh:
+		return workspace.InlineValue != nil && isTrue(workspace.InlineValue.RefreshSupport)
+	case MethodDiagnosticsRefresh:
+		return workspace.Diagnostics != nil && isTrue(workspace.Diagnostics.RefreshSupport)
+	default:
+		return false
+	}
+}
+
+// SupportsDynamicRegistration determines whether the client supports
+// dynamic registration of the capability for the provided method
+// with `client/registerCapability`.
+func (s *ClientState) SupportsDynamicRegistration(method string) bool {
+	isDynamicRegistrationSupported, _ := s.dynamicRegistration(method)
+	return isDynamicRegistrationSupported
+}
+
+// dynamicRegistration determines whether the client supports dynamic registration
+// of the capability for the provided method along with whether the method
+// has a client capability that declares support for dynamic registration.
+func (s *ClientState) dynamicRegistration(method string) (bool, bool) {
+	if isDynamicRegistrationSupported, ok := s.textDocumentDynamicRegistration(method); ok {
+		return isDynamicRegistrationSupported, true
+	}
+
+	if isDynamicRegistrationSupported, ok := s.workspaceDynamicRegistration(method); ok {
+		return isDynamicRegistrationSupported, true
+	}
+
+	notebookDocument := s.capabilities.NotebookDocument
+	switch method {
+	case MethodNotebookDocumentDidOpen, MethodNotebookDocumentDidChange,
+		MethodNotebookDocumentDidSave, MethodNotebookDocumentDidClose:
+		return notebookDocument != nil &&
+			notebookDocument.Synchronization != nil &&
+			isTrue(notebookDocument.Synchronization.DynamicRegistration), true
+	}
+
+	return false, false
+}
+
+func (s *ClientState) textDocumentDynamicRegistration(method string) (bool, bool) {
+	textDocument := s.capabilities.TextDocument
+	if textDocument == nil {
+		textDocument = &TextDocumentClientCapabilities{}
+	}
+
+	switch method {
+	case MethodTextDocumentDidOpen, MethodTextDocumentDidChange, MethodTextDocumentDidClose,
+		MethodTextDocumentWillSave, MethodTextDocumentWillSaveWaitUntil, MethodTextDocumentDidSave:
+		return textDocument.Synchronization != nil && isTrue(textDocument.Synchronization.DynamicRegistration), true
+	case MethodCompletion:
+		return textDocument.Completion != nil && isTrue(textDocument.Completion.DynamicRegistration), true
+	case MethodHover:
+		return textDocument.Hover != nil && isTrue(textDocument.Hover.DynamicRegistration), true
+	case MethodSignatureHelp:
+		return textDocument.SignatureHelp != nil && isTrue(textDocument.SignatureHelp.DynamicRegistration), true
+	case MethodGotoDeclaration:
+		return textDocument.Declaration != nil && isTrue(textDocument.Declaration.DynamicRegistration), true
+	case MethodGotoDefinition:
+		return textDocument.Definition != nil && isTrue(textDocument.Definition.DynamicRegistration), true
+	case MethodGotoTypeDefinition:
+		return textDocument.TypeDefinition != nil && isTrue(textDocument.TypeDefinition.DynamicRegistration), true
+	case MethodGotoImplementation:
+		return textDocument.Implementation != nil && isTrue(textDocument.Implementation.DynamicRegistration), true
+	case MethodFindReferences:
+		return textDocument.References != nil && isTrue(textDocument.References.DynamicRegistration), true
+	case MethodDocumentHighlight:
+		return textDocument.DocumentHighlight != nil && isTrue(textDocument.DocumentHighlight.DynamicRegistration), true
+	case MethodDocumentSymbol:
+		return textDocument.DocumentSymbol != nil && isTrue(textDocument.DocumentSymbol.DynamicRegistration), true
+	case MethodCodeAction:
+		return textDocument.CodeAction != nil && isTrue(textDocument.CodeAction.DynamicRegistration), true
+	case MethodCodeLens:
+		return textDocument.CodeLens != nil && isTrue(textDocument.CodeLens.DynamicRegistration), true
+	case MethodDocumentLink:
+		return textDocument.DocumentLink != nil && isTrue(textDocument.DocumentLink.DynamicRegistration), true
+	case MethodDocumentColor:
+		return textDocument.ColorProvider != nil && isTrue(textDocument.ColorProvider.DynamicRegistration), true
+	case MethodDocumentFormatting:
+		return textDocument.Formatting != nil && isTrue(textDocument.Formatting.DynamicRegistration), true
+	case MethodDocumentRangeFormatting:
+		return textDocument.RangeFormatting != nil && isTrue(textDocument.RangeFormatting.DynamicRegistration), true
+	case MethodDocumentOnTypeFormatting:
+		return textDocument.OnTypeFormatting != nil && isTrue(textDocument.OnTypeFormatting.DynamicRegistration), true
+	case MethodDocumentRename:
+		return textDocument.Rename != nil && isTrue(textDocument.Rename.DynamicRegistration), true
+	case MethodFoldingRange:
+		return textDocument.FoldingRange != nil && isTrue(textDocument.FoldingRange.DynamicRegistration), true
+	case MethodSelectionRange:
+		return textDocument.SelectionRange != nil && isTrue(textDocument.SelectionRange.DynamicRegistration), true
+	case MethodDocumentLinkedEditingRange:
+		return textDocument.LinkedEditingRange != nil && isTrue(textDocument.LinkedEditingRange.DynamicRegistration), true
+	case MethodPrepareCallHierarchy:
+		return textDocument.CallHierarchy != nil && isTrue(textDocument.CallHierarchy.DynamicRegistration), true
+	case MethodSemanticTokens:
+		return textDocument.SemanticTokens != nil && isTrue(textDocument.SemanticTokens.DynamicRegistration), true
+	case MethodMoniker:
+		return textDocument.Moniker != nil && isTrue(textDocument.Moniker.DynamicRegistration), true
+	case MethodPrepareTypeHierarchy:
+		return textDocument.TypeHierarchy != nil && isTrue(textDocument.TypeHierarchy.DynamicRegistration), true
+	case MethodInlineValue:
+		return textDocument.InlineValue != nil && isTrue(textDocument.InlineValue.DynamicRegistration), true
+	case MethodInlayHint:
+		return textDocument.InlayHint != nil && isTrue(textDocument.InlayHint.DynamicRegistration), true
+	case MethodDocumentDiagnostic:
+		return textDocument.Diagnostics != nil && isTrue(textDocument.Diagnostics.DynamicRegistration), true
+	default:
+		return false, false
+	}
+}
+
+func (s *ClientState) workspaceDynamicRegistration(method string) (bool, bool) {
+	workspace := s.capabilities.Workspace
+	if workspace == nil {
+		workspace = &ClientWorkspaceCapabilities{}
+	}
+
+	switch method {
+	case MethodWorkspaceDidChangeConfiguration:
+		return workspace.DidChangeConfiguration != nil && isTrue(workspace.DidChangeConfiguration.DynamicRegistration), true
+	case MethodWorkspaceDidChangeWatchedFiles:
+		return workspace.DidChangeWatchedFiles != nil && isTrue(workspace.DidChangeWatchedFiles.DynamicRegistration), true
+	case MethodWorkspaceSymbol:
+		return workspace.Symbol != nil && isTrue(workspace.Symbol.DynamicRegistration), true
+	case MethodWorkspaceExecuteCommand:
+		return workspace.ExecuteCommand != nil && isTrue(workspace.ExecuteCommand.DynamicRegistration), true
+	case MethodWorkspaceWillCreateFiles, MethodWorkspaceDidCreateFiles,
+		MethodWorkspaceWillRenameFiles, MethodWorkspaceDidRenameFiles,
+		MethodWorkspaceWillDeleteFiles, MethodWorkspaceDidDeleteFiles:
+		return workspace.FileOperations != nil && isTrue(workspace.FileOperations.DynamicRegistration), true
+	default:
+		return false, false
+	}
+}
+
+// DowngradePublishDiagnosticsParams removes the properties from the provided
+// diagnostics parameters that the client has not advertised support for
+// in the `textDocument.publishDiagnostics` client capability.
+// The provided parameters are not modified, a copy is returned.
+func (s *ClientState) DowngradePublishDiagnosticsParams(params PublishDiagnosticsParams) PublishDiagnosticsParams {
+	capabilities := &PublishDiagnosticsClientCapabilities{}
+	if s.capabilities.TextDocument != nil && s.capabilities.TextDocument.PublishDiagnostics != nil {
+		capabilities = s.capabilities.TextDocument.PublishDiagnostics
+	}
+
+	downgraded := PublishDiagnosticsParams{
+		URI:         params.URI,
+		Diagnostics: make([]Diagnostic, len(params.Diagnostics)),
+	}
+	if isTrue(capabilities.VersionSupport) {
+		downgraded.Version = params.Version
+	}
+
+	for i, diagnostic := range params.Diagnostics {
+		if !isTrue(capabilities.RelatedInformation) {
+			diagnostic.RelatedInformation = nil
+		}
+
+		if !isTrue(capabilities.CodeDescriptionSupport) {
+			diagnostic.CodeDescription = nil
+		}
+
+		if !isTrue(capabilities.DataSupport) {
+			diagnostic.Data = nil
+		}
+
+		if capabilities.TagSupport == nil {
+			diagnostic.Tags = nil
+		} else if diagnostic.Tags != nil {
+			diagnostic.Tags = slices.DeleteFunc(slices.Clone(diagnostic.Tags), func(tag DiagnosticTag) bool {
+				return !slices.Contains(capabilities.TagSupport.ValueSet, tag)
+			})
+		}
+
+		downgraded.Diagnostics[i] = diagnostic
+	}
+
+	return downgraded
+}
+
+// ClientState returns the state captured from the client during initialisation,
+// this will be nil if the `initialize` request has not been handled.
+func (h *Handler) ClientState() *ClientState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.clientState
+}
+
+func (h *Handler) setClientState(state *ClientState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clientState = state
+}
+
+type clientStateKey struct{}
+
+func withClientState(ctx *common.LSPContext, state *ClientState) {
+	if state == nil {
+		return
+	}
+
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx.Context = context.WithValue(parent, clientStateKey{}, state)
+}
+
+// ClientStateFromContext retrieves the state captured from the client during
+// initialisation for the session that the provided LSP context belongs to.
+// This will be nil for contexts that were not created by a `Handler`
+// or before the `initialize` request has been handled.
+func ClientStateFromContext(ctx *common.LSPContext) *ClientState {
+	if ctx == nil || ctx.Context == nil {
+		return nil
+	}
+
+	state, _ := ctx.Context.Value(clientStateKey{}).(*ClientState)
+	return state
+}
+
+func isTrue(value *bool) bool {
+	return value != nil && *value
+}
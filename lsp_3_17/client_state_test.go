@@ -0,0 +1,280 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"github.com/two-hundred/ls-builder/server"
+	"go.uber.org/zap"
+)
+
+type ClientStateTestSuite struct {
+	suite.Suite
+}
+
+func (s *ClientStateTestSuite) Test_queries_client_capabilities() {
+	trueVal := true
+	falseVal := false
+	clientState := NewClientState(&InitializeParams{
+		Capabilities: ClientCapabilities{
+			Window: &WindowClientCapabilities{
+				WorkDoneProgress: &trueVal,
+			},
+			TextDocument: &TextDocumentClientCapabilities{
+				Completion: &CompletionClientCapabilities{
+					DynamicRegistration: &trueVal,
+					CompletionItem: &CompletionItemCapabilities{
+						SnippetSupport: &trueVal,
+					},
+					CompletionList: &CompletionListCapabilities{
+						ItemDefaults: []string{"editRange", "data"},
+					},
+				},
+				Hover: &HoverClientCapabilities{
+					DynamicRegistration: &falseVal,
+				},
+			},
+			Workspace: &ClientWorkspaceCapabilities{
+				Configuration: &trueVal,
+				FileOperations: &FileOperationClientCapabilities{
+					DynamicRegistration: &trueVal,
+				},
+				CodeLens: &CodeLensWorkspaceClientCapabilities{
+					RefreshSupport: &trueVal,
+				},
+			},
+		},
+	})
+
+	s.Require().True(clientState.SupportsWorkDoneProgress())
+	s.Require().True(clientState.SupportsSnippets())
+	s.Require().True(clientState.SupportsCompletionItemDefault("editRange"))
+	s.Require().False(clientState.SupportsCompletionItemDefault("commitCharacters"))
+	s.Require().True(clientState.SupportsConfiguration())
+	s.Require().False(clientState.SupportsApplyEdit())
+	s.Require().False(clientState.SupportsShowDocument())
+	s.Require().False(clientState.SupportsWorkspaceFolders())
+	s.Require().True(clientState.SupportsDynamicRegistration(MethodCompletion))
+	s.Require().True(clientState.SupportsDynamicRegistration(MethodWorkspaceDidRenameFiles))
+	s.Require().False(clientState.SupportsDynamicRegistration(MethodHover))
+	s.Require().False(clientState.SupportsDynamicRegistration(MethodSignatureHelp))
+	s.Require().False(clientState.SupportsDynamicRegistration(MethodNotebookDocumentDidOpen))
+	s.Require().False(clientState.SupportsDynamicRegistration("unknown/method"))
+	s.Require().True(clientState.SupportsRefresh(MethodCodeLensRefresh))
+	s.Require().False(clientState.SupportsRefresh(MethodInlayHintRefresh))
+	s.Require().False(clientState.RetriesOnContentModified(MethodSemanticTokensFull))
+}
+
+func (s *ClientStateTestSuite) Test_determines_requests_retried_on_content_modified() {
+	clientState := NewClientState(&InitializeParams{
+		Capabilities: ClientCapabilities{
+			General: &GeneralClientCapabilities{
+				StaleRequestSupport: &StaleRequestSupport{
+					Cancel:                 true,
+					RetryOnContentModified: []string{MethodSemanticTokensFull},
+				},
+			},
+		},
+	})
+
+	s.Require().True(clientState.RetriesOnContentModified(MethodSemanticTokensFull))
+	s.Require().False(clientState.RetriesOnContentModified(MethodHover))
+}
+
+func (s *ClientStateTestSuite) Test_falls_back_to_root_path_and_tracks_workspace_folders() {
+	rootPath := "/path/to/workspace"
+	clientState := NewClientState(&InitializeParams{
+		RootPath: &rootPath,
+		WorkspaceFolders: []WorkspaceFolder{
+			{URI: "file:///a", Name: "a"},
+			{URI: "file:///b", Name: "b"},
+		},
+	})
+	s.Require().Equal("file:///path/to/workspace", *clientState.RootURI())
+
+	clientState.ApplyWorkspaceFoldersChange(WorkspaceFoldersChangeEvent{
+		Added:   []WorkspaceFolder{{URI: "file:///c", Name: "c"}},
+		Removed: []WorkspaceFolder{{URI: "file:///a", Name: "a"}},
+	})
+	s.Require().Equal(
+		[]WorkspaceFolder{
+			{URI: "file:///b", Name: "b"},
+			{URI: "file:///c", Name: "c"},
+		},
+		clientState.WorkspaceFolders(),
+	)
+}
+
+func (s *ClientStateTestSuite) Test_downgrades_publish_diagnostics_params() {
+	trueVal := true
+	version := Integer(3)
+	clientState := NewClientState(&InitializeParams{
+		Capabilities: ClientCapabilities{
+			TextDocument: &TextDocumentClientCapabilities{
+				PublishDiagnostics: &PublishDiagnosticsClientCapabilities{
+					RelatedInformation: &trueVal,
+					TagSupport: &DiagnosticTagSupport{
+						ValueSet: []DiagnosticTag{DiagnosticTagDeprecated},
+					},
+				},
+			},
+		},
+	})
+
+	params := PublishDiagnosticsParams{
+		URI:     testDocumentURI,
+		Version: &version,
+		Diagnostics: []Diagnostic{
+			{
+				Message:         "test diagnostic",
+				Tags:            []DiagnosticTag{DiagnosticTagUnnecessary, DiagnosticTagDeprecated},
+				CodeDescription: &CodeDescription{Href: "https://example.com"},
+				RelatedInformation: []DiagnosticRelatedInformation{
+					{Message: "related"},
+				},
+				Data: "data",
+			},
+		},
+	}
+
+	downgraded := clientState.DowngradePublishDiagnosticsParams(params)
+	s.Require().Equal(
+		PublishDiagnosticsParams{
+			URI: testDocumentURI,
+			Diagnostics: []Diagnostic{
+				{
+					Message: "test diagnostic",
+					Tags:    []DiagnosticTag{DiagnosticTagDeprecated},
+					RelatedInformation: []DiagnosticRelatedInformation{
+						{Message: "related"},
+					},
+				},
+			},
+		},
+		downgraded,
+	)
+	// The original parameters should be left untouched.
+	s.Require().Len(params.Diagnostics[0].Tags, 2)
+	s.Require().NotNil(params.Version)
+}
+
+func (s *ClientStateTestSuite) Test_dispatcher_refuses_requests_not_supported_by_client() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	withClientState(lspCtx, NewClientState(&InitializeParams{}))
+	dispatcher := NewDispatcher(lspCtx)
+
+	err := dispatcher.CodeLensRefresh()
+	s.Require().ErrorIs(err, ErrNotSupportedByClient)
+
+	err = dispatcher.CreateWorkDoneProgress(WorkDoneProgressCreateParams{})
+	s.Require().ErrorIs(err, ErrNotSupportedByClient)
+
+	_, err = dispatcher.ApplyWorkspaceEdit(ApplyWorkspaceEditParams{})
+	s.Require().ErrorIs(err, ErrNotSupportedByClient)
+
+	err = dispatcher.RegisterCapability(RegistrationParams{
+		Registrations: []Registration{{ID: "1", Method: MethodCompletion}},
+	})
+	s.Require().ErrorIs(err, ErrNotSupportedByClient)
+}
+
+func (s *ClientStateTestSuite) Test_converts_root_path_to_file_uri() {
+	for rootPath, expectedURI := range map[string]string{
+		"/path/to/my workspace":   "file:///path/to/my%20workspace",
+		"C:\\projects\\workspace": "file:///C:/projects/workspace",
+		"file:///path/to/uri":     "file:///path/to/uri",
+	} {
+		clientState := NewClientState(&InitializeParams{RootPath: &rootPath})
+		s.Require().Equal(expectedURI, *clientState.RootURI())
+	}
+
+	emptyRootPath := ""
+	s.Require().Nil(NewClientState(&InitializeParams{RootPath: &emptyRootPath}).RootURI())
+}
+
+func (s *ClientStateTestSuite) Test_dispatcher_registers_capabilities_for_custom_methods() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	withClientState(lspCtx, NewClientState(&InitializeParams{}))
+	dispatcher := NewDispatcher(lspCtx)
+
+	err := dispatcher.RegisterCapability(RegistrationParams{
+		Registrations: []Registration{{ID: "1", Method: "custom/refreshGraph"}},
+	})
+	s.Require().NoError(err)
+
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	s.Require().Equal([]string{ClientRegisterCapability}, container.clientReceivedMethods)
+}
+
+func (s *ClientStateTestSuite) Test_handler_captures_client_state_on_initialize() {
+	logger, err := zap.NewDevelopment()
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	callChan := make(chan *ClientState, 1)
+	serverHandler := NewHandler(
+		WithInitializeHandler(
+			func(ctx *common.LSPContext, params *InitializeParams) (any, error) {
+				return InitializeResult{}, nil
+			},
+		),
+		WithInitializedHandler(
+			func(ctx *common.LSPContext, params *InitializedParams) error {
+				callChan <- ClientStateFromContext(ctx)
+				return nil
+			},
+		),
+	)
+	srv := server.NewServer(serverHandler, true, nil, nil)
+
+	container := createTestConnectionsContainer(srv.NewHandler())
+
+	go srv.Serve(container.serverConn, logger)
+
+	clientLSPContext := server.NewLSPContext(ctx, container.clientConn, nil)
+
+	trueVal := true
+	rootURI := "file:///workspace"
+	initializeParams := InitializeParams{
+		ClientInfo: &InitializeClientInfo{Name: "test-client", Version: "0.1.0"},
+		RootURI:    &rootURI,
+		Capabilities: ClientCapabilities{
+			Window: &WindowClientCapabilities{WorkDoneProgress: &trueVal},
+		},
+	}
+
+	returnedResult := InitializeResult{}
+	err = clientLSPContext.Call(MethodInitialize, initializeParams, &returnedResult)
+	s.Require().NoError(err)
+
+	err = clientLSPContext.Notify(MethodInitialized, InitializedParams{})
+	s.Require().NoError(err)
+
+	select {
+	case <-ctx.Done():
+		s.Fail("timeout")
+	case clientState := <-callChan:
+		s.Require().NotNil(clientState)
+		s.Require().Same(serverHandler.ClientState(), clientState)
+		s.Require().Equal("test-client", clientState.ClientInfo().Name)
+		s.Require().Equal(rootURI, *clientState.RootURI())
+		s.Require().True(clientState.SupportsWorkDoneProgress())
+	}
+}
+
+func TestClientStateTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientStateTestSuite))
+}
@@ -0,0 +1,364 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/two-hundred/ls-builder/common"
+	"go.uber.org/zap"
+)
+
+// ConfigurationChangeFunc is the function signature for subscribers that are notified
+// when the effective settings for a scope change.
+// The scope URI is empty for the settings that are not specific to a resource.
+type ConfigurationChangeFunc[T any] func(ctx *common.LSPContext, scopeURI DocumentURI, settings T)
+
+// ConfigurationService provides typed access to the settings of a configuration section
+// on the client, settings are fetched per scope URI with `workspace/configuration` requests
+// and cached until the client notifies the server that the configuration has changed.
+//
+// For clients that do not support the `workspace/configuration` request, the settings
+// for the section are taken from the settings pushed by the client
+// in `workspace/didChangeConfiguration` notifications.
+//
+// Settings are decoded into a copy of the default settings so fields that are not
+// set on the client keep their default values.
+type ConfigurationService[T any] struct {
+	section       string
+	defaults      T
+	registrations *RegistrationManager
+	logger        *zap.Logger
+	cache         map[DocumentURI]T
+	// The settings for the section from the last settings pushed by the client.
+	pushed            json.RawMessage
+	generation        uint64
+	registration      *RegistrationHandle
+	subscribers       map[uint64]ConfigurationChangeFunc[T]
+	subscriberCounter uint64
+	mu                sync.Mutex
+	// Ensures subscribers are notified of changes in the order
+	// in which the changes were received.
+	notifyMu sync.Mutex
+}
+
+// ConfigurationServiceOption is a function that can be used to configure
+// a configuration service.
+type ConfigurationServiceOption func(*configurationServiceOptions)
+
+type configurationServiceOptions struct {
+	registrations *RegistrationManager
+	logger        *zap.Logger
+}
+
+// WithConfigurationRegistrationManager sets the registration manager used to
+// dynamically register for `workspace/didChangeConfiguration` notifications.
+// A registration manager is created for the service when one is not provided.
+func WithConfigurationRegistrationManager(manager *RegistrationManager) ConfigurationServiceOption {
+	return func(opts *configurationServiceOptions) {
+		opts.registrations = manager
+	}
+}
+
+// WithConfigurationLogger sets the logger used to log errors that occur
+// when fetching settings in the background after the configuration has changed.
+func WithConfigurationLogger(logger *zap.Logger) ConfigurationServiceOption {
+	return func(opts *configurationServiceOptions) {
+		opts.logger = logger
+	}
+}
+
+// NewConfigurationService creates a new configuration service for the provided
+// configuration section (e.g. "languageServerExample"), nested sections
+// can be separated with dots (e.g. "languageServerExample.trace").
+func NewConfigurationService[T any](
+	section string,
+	defaults T,
+	opts ...ConfigurationServiceOption,
+) *ConfigurationService[T] {
+	options := &configurationServiceOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.registrations == nil {
+		options.registrations = NewRegistrationManager()
+	}
+
+	if options.logger == nil {
+		options.logger = zap.NewNop()
+	}
+
+	return &ConfigurationService[T]{
+		section:       section,
+		defaults:      defaults,
+		registrations: options.registrations,
+		logger:        options.logger,
+		cache:         make(map[DocumentURI]T),
+		subscribers:   make(map[uint64]ConfigurationChangeFunc[T]),
+	}
+}
+
+// Section returns the configuration section that the service provides settings for.
+func (s *ConfigurationService[T]) Section() string {
+	return s.section
+}
+
+// Register dynamically registers for `workspace/didChangeConfiguration` notifications
+// for the configuration section of the service.
+// This should be called in the handler for the `initialized` notification.
+//
+// When the client does not support dynamic registration for the notification,
+// this does nothing and the service relies on the notifications that clients
+// send without registration.
+func (s *ConfigurationService[T]) Register(ctx *common.LSPContext) error {
+	clientState := ClientStateFromContext(ctx)
+	if clientState != nil && !clientState.SupportsDynamicRegistration(MethodWorkspaceDidChangeConfiguration) {
+		return nil
+	}
+
+	s.mu.Lock()
+	isRegistered := s.registration != nil
+	s.mu.Unlock()
+	if isRegistered {
+		return nil
+	}
+
+	handle, err := s.registrations.Register(
+		ctx,
+		MethodWorkspaceDidChangeConfiguration,
+		DidChangeConfigurationRegistrationOptions{
+			Section: s.section,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registration = handle
+	return nil
+}
+
+// Get retrieves the settings for the provided scope URI,
+// an empty scope URI can be used to retrieve the settings
+// that are not specific to a resource.
+// Settings are fetched from the client the first time they are requested for a scope
+// and are cached until the configuration changes or the scope is forgotten.
+func (s *ConfigurationService[T]) Get(ctx *common.LSPContext, scopeURI DocumentURI) (T, error) {
+	s.mu.Lock()
+	settings, isCached := s.cache[scopeURI]
+	generation := s.generation
+	s.mu.Unlock()
+	if isCached {
+		return settings, nil
+	}
+
+	settings, err := s.fetch(ctx, scopeURI)
+	if err != nil {
+		return settings, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Settings fetched before the configuration changed are stale
+	// and must not be cached.
+	if generation == s.generation {
+		s.cache[scopeURI] = settings
+	}
+	return settings, nil
+}
+
+// Forget removes the cached settings for the provided scope URI,
+// this should be called when a document is closed so the service does not
+// hold on to settings for documents that are no longer open.
+// Subscribers are not notified of changes to settings for forgotten scopes.
+func (s *ConfigurationService[T]) Forget(scopeURI DocumentURI) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cache, scopeURI)
+}
+
+// Subscribe registers a function to be called when the effective settings
+// for a scope that have previously been retrieved change.
+// The returned function removes the subscription.
+func (s *ConfigurationService[T]) Subscribe(onChange ConfigurationChangeFunc[T]) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscriberCounter += 1
+	id := s.subscriberCounter
+	s.subscribers[id] = onChange
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+// CreateDidChangeConfigurationHandler creates a new handler function
+// that can be configured with a `Handler` instance to invalidate cached settings
+// when the client sends a `workspace/didChangeConfiguration` notification.
+//
+// Settings for the scopes that were cached are fetched again in the background
+// to notify subscribers of the scopes that the effective settings have changed for.
+func (s *ConfigurationService[T]) CreateDidChangeConfigurationHandler() WorkspaceDidChangeConfigurationHandlerFunc {
+	return func(ctx *common.LSPContext, params *DidChangeConfigurationParams) error {
+		pushed, err := s.pushedSection(params.Settings)
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		previous := s.cache
+		s.cache = make(map[DocumentURI]T)
+		s.pushed = pushed
+		s.generation += 1
+		hasSubscribers := len(s.subscribers) > 0
+		s.mu.Unlock()
+
+		if !hasSubscribers || len(previous) == 0 {
+			return nil
+		}
+
+		// Settings are fetched in the background so that messages received after
+		// the notification are not held up waiting for the client to respond.
+		notifyCtx, cancel := detachLSPContext(ctx)
+		go func() {
+			defer cancel()
+			s.notifyChanges(notifyCtx, previous)
+		}()
+		return nil
+	}
+}
+
+func (s *ConfigurationService[T]) notifyChanges(ctx *common.LSPContext, previous map[DocumentURI]T) {
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+
+	scopeURIs := make([]DocumentURI, 0, len(previous))
+	for scopeURI := range previous {
+		scopeURIs = append(scopeURIs, scopeURI)
+	}
+	sort.Strings(scopeURIs)
+
+	for _, scopeURI := range scopeURIs {
+		settings, err := s.Get(ctx, scopeURI)
+		if err != nil {
+			s.logger.Error(
+				"failed to fetch settings after configuration change",
+				zap.String("section", s.section),
+				zap.String("scopeURI", scopeURI),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		if !reflect.DeepEqual(previous[scopeURI], settings) {
+			for _, onChange := range s.subscriberSnapshot() {
+				onChange(ctx, scopeURI, settings)
+			}
+		}
+	}
+}
+
+func (s *ConfigurationService[T]) subscriberSnapshot() []ConfigurationChangeFunc[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]uint64, 0, len(s.subscribers))
+	for id := range s.subscribers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	subscribers := make([]ConfigurationChangeFunc[T], 0, len(ids))
+	for _, id := range ids {
+		subscribers = append(subscribers, s.subscribers[id])
+	}
+	return subscribers
+}
+
+func (s *ConfigurationService[T]) fetch(ctx *common.LSPContext, scopeURI DocumentURI) (T, error) {
+	clientState := ClientStateFromContext(ctx)
+	if clientState != nil && !clientState.SupportsConfiguration() {
+		s.mu.Lock()
+		pushed := s.pushed
+		s.mu.Unlock()
+		return s.decode(pushed)
+	}
+
+	item := ConfigurationItem{
+		Section: &s.section,
+	}
+	if scopeURI != "" {
+		item.ScopeURI = &scopeURI
+	}
+
+	result := []json.RawMessage{}
+	err := NewDispatcher(ctx).WorkspaceConfiguration(
+		ConfigurationParams{
+			Items: []ConfigurationItem{item},
+		},
+		&result,
+	)
+	if err != nil {
+		var settings T
+		return settings, err
+	}
+
+	if len(result) == 0 {
+		return s.decode(nil)
+	}
+	return s.decode(result[0])
+}
+
+func (s *ConfigurationService[T]) decode(data json.RawMessage) (T, error) {
+	var settings T
+	// Round trip the defaults through JSON so the settings do not share
+	// maps, slices or pointers with the defaults.
+	defaults, err := json.Marshal(s.defaults)
+	if err != nil {
+		return settings, err
+	}
+
+	err = json.Unmarshal(defaults, &settings)
+	if err != nil {
+		return settings, err
+	}
+
+	if len(data) == 0 || string(data) == "null" {
+		return settings, nil
+	}
+
+	err = json.Unmarshal(data, &settings)
+	if err != nil {
+		return settings, fmt.Errorf("failed to decode settings for section %q: %w", s.section, err)
+	}
+	return settings, nil
+}
+
+// pushedSection extracts the settings for the section of the service
+// from the settings pushed by the client.
+func (s *ConfigurationService[T]) pushedSection(settings LSPAny) (json.RawMessage, error) {
+	value := settings
+	if s.section != "" {
+		for _, key := range strings.Split(s.section, ".") {
+			object, isObject := value.(map[string]any)
+			if !isObject {
+				return nil, nil
+			}
+			value = object[key]
+		}
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+	return json.Marshal(value)
+}
@@ -0,0 +1,286 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+)
+
+type ConfigurationServiceTestSuite struct {
+	suite.Suite
+	client *testConfigurationClient
+}
+
+type testSettings struct {
+	MaxNumberOfProblems int               `json:"maxNumberOfProblems"`
+	Trace               testTraceSettings `json:"trace"`
+}
+
+type testTraceSettings struct {
+	Server string `json:"server"`
+}
+
+var defaultTestSettings = testSettings{
+	MaxNumberOfProblems: 100,
+	Trace: testTraceSettings{
+		Server: "off",
+	},
+}
+
+type testSettingsChange struct {
+	scopeURI DocumentURI
+	settings testSettings
+}
+
+// testConfigurationClient emulates a client that responds to
+// `workspace/configuration` requests with the settings for each scope.
+type testConfigurationClient struct {
+	settings       map[string]string
+	calledMethods  []string
+	receivedParams []json.RawMessage
+	mu             sync.Mutex
+}
+
+func (c *testConfigurationClient) setSettings(scopeURI string, settings string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settings[scopeURI] = settings
+}
+
+func (c *testConfigurationClient) calls() ([]string, []json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.calledMethods...), append([]json.RawMessage{}, c.receivedParams...)
+}
+
+func (c *testConfigurationClient) call(method string, params any, result any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	c.calledMethods = append(c.calledMethods, method)
+	c.receivedParams = append(c.receivedParams, paramsBytes)
+
+	if method != MethodWorkspaceConfiguration {
+		return nil
+	}
+
+	configParams := ConfigurationParams{}
+	err = json.Unmarshal(paramsBytes, &configParams)
+	if err != nil {
+		return err
+	}
+
+	response := []json.RawMessage{}
+	for _, item := range configParams.Items {
+		scopeURI := ""
+		if item.ScopeURI != nil {
+			scopeURI = *item.ScopeURI
+		}
+		settings, hasSettings := c.settings[scopeURI]
+		if !hasSettings {
+			settings = "null"
+		}
+		response = append(response, json.RawMessage(settings))
+	}
+
+	responseBytes, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(responseBytes, result)
+}
+
+func (s *ConfigurationServiceTestSuite) SetupTest() {
+	s.client = &testConfigurationClient{
+		settings: map[string]string{},
+	}
+}
+
+func (s *ConfigurationServiceTestSuite) Test_fetches_and_caches_settings_per_scope() {
+	s.client.setSettings(testDocumentURI, `{"maxNumberOfProblems":10}`)
+	s.client.setSettings("", `{"trace":{"server":"verbose"}}`)
+	service := NewConfigurationService("languageServerExample", defaultTestSettings)
+	ctx := s.lspContext(&ClientCapabilities{
+		Workspace: &ClientWorkspaceCapabilities{Configuration: &True},
+	})
+
+	settings, err := service.Get(ctx, testDocumentURI)
+	s.Require().NoError(err)
+	s.Require().Equal(testSettings{MaxNumberOfProblems: 10, Trace: testTraceSettings{Server: "off"}}, settings)
+
+	settings, err = service.Get(ctx, testDocumentURI)
+	s.Require().NoError(err)
+	s.Require().Equal(10, settings.MaxNumberOfProblems)
+
+	settings, err = service.Get(ctx, "")
+	s.Require().NoError(err)
+	s.Require().Equal(testSettings{MaxNumberOfProblems: 100, Trace: testTraceSettings{Server: "verbose"}}, settings)
+
+	settings, err = service.Get(ctx, "file:///unconfigured.txt")
+	s.Require().NoError(err)
+	s.Require().Equal(defaultTestSettings, settings)
+
+	methods, params := s.client.calls()
+	s.Require().Equal(
+		[]string{MethodWorkspaceConfiguration, MethodWorkspaceConfiguration, MethodWorkspaceConfiguration},
+		methods,
+	)
+	s.Require().JSONEq(
+		`{"items":[{"scopeUri":"file:///test.txt","section":"languageServerExample"}]}`,
+		string(params[0]),
+	)
+	s.Require().JSONEq(`{"items":[{"section":"languageServerExample"}]}`, string(params[1]))
+}
+
+func (s *ConfigurationServiceTestSuite) Test_notifies_subscribers_when_settings_for_scope_change() {
+	s.client.setSettings(testDocumentURI, `{"maxNumberOfProblems":10}`)
+	s.client.setSettings("file:///other.txt", `{"maxNumberOfProblems":20}`)
+	service := NewConfigurationService("languageServerExample", defaultTestSettings)
+	ctx := s.lspContext(&ClientCapabilities{
+		Workspace: &ClientWorkspaceCapabilities{Configuration: &True},
+	})
+
+	_, err := service.Get(ctx, testDocumentURI)
+	s.Require().NoError(err)
+	_, err = service.Get(ctx, "file:///other.txt")
+	s.Require().NoError(err)
+
+	changes := make(chan testSettingsChange, 10)
+	unsubscribe := service.Subscribe(func(ctx *common.LSPContext, scopeURI DocumentURI, settings testSettings) {
+		changes <- testSettingsChange{scopeURI: scopeURI, settings: settings}
+	})
+
+	s.client.setSettings(testDocumentURI, `{"maxNumberOfProblems":15}`)
+	handler := service.CreateDidChangeConfigurationHandler()
+	err = handler(ctx, &DidChangeConfigurationParams{})
+	s.Require().NoError(err)
+
+	select {
+	case change := <-changes:
+		s.Require().Equal(testDocumentURI, change.scopeURI)
+		s.Require().Equal(15, change.settings.MaxNumberOfProblems)
+	case <-time.After(time.Second):
+		s.Fail("subscriber was not notified of the settings change")
+	}
+
+	// The settings for both scopes are fetched again after the configuration change.
+	s.Require().Eventually(func() bool {
+		methods, _ := s.client.calls()
+		return len(methods) == 4
+	}, time.Second, 5*time.Millisecond)
+	settings, err := service.Get(ctx, testDocumentURI)
+	s.Require().NoError(err)
+	s.Require().Equal(15, settings.MaxNumberOfProblems)
+	methods, _ := s.client.calls()
+	s.Require().Len(methods, 4)
+
+	unsubscribe()
+	s.client.setSettings(testDocumentURI, `{"maxNumberOfProblems":5}`)
+	err = handler(ctx, &DidChangeConfigurationParams{})
+	s.Require().NoError(err)
+	settings, err = service.Get(ctx, testDocumentURI)
+	s.Require().NoError(err)
+	s.Require().Equal(5, settings.MaxNumberOfProblems)
+	s.Require().Empty(changes)
+}
+
+func (s *ConfigurationServiceTestSuite) Test_uses_pushed_settings_for_client_without_configuration_support() {
+	service := NewConfigurationService("languageServerExample", defaultTestSettings)
+	ctx := s.lspContext(&ClientCapabilities{})
+
+	settings, err := service.Get(ctx, testDocumentURI)
+	s.Require().NoError(err)
+	s.Require().Equal(defaultTestSettings, settings)
+
+	changes := make(chan testSettings, 10)
+	service.Subscribe(func(ctx *common.LSPContext, scopeURI DocumentURI, settings testSettings) {
+		changes <- settings
+	})
+
+	pushedSettings := map[string]any{}
+	err = json.Unmarshal(
+		[]byte(`{"languageServerExample":{"maxNumberOfProblems":3},"other":{"maxNumberOfProblems":1}}`),
+		&pushedSettings,
+	)
+	s.Require().NoError(err)
+	err = service.CreateDidChangeConfigurationHandler()(ctx, &DidChangeConfigurationParams{
+		Settings: pushedSettings,
+	})
+	s.Require().NoError(err)
+
+	expected := testSettings{MaxNumberOfProblems: 3, Trace: testTraceSettings{Server: "off"}}
+	select {
+	case changed := <-changes:
+		s.Require().Equal(expected, changed)
+	case <-time.After(time.Second):
+		s.Fail("subscriber was not notified of the settings change")
+	}
+
+	settings, err = service.Get(ctx, "file:///other.txt")
+	s.Require().NoError(err)
+	s.Require().Equal(expected, settings)
+
+	methods, _ := s.client.calls()
+	s.Require().Empty(methods)
+}
+
+func (s *ConfigurationServiceTestSuite) Test_registers_for_changes_when_client_supports_dynamic_registration() {
+	service := NewConfigurationService(
+		"languageServerExample",
+		defaultTestSettings,
+		WithConfigurationRegistrationManager(NewRegistrationManager(WithRegistrationIDPrefix("config"))),
+	)
+
+	err := service.Register(s.lspContext(&ClientCapabilities{
+		Workspace: &ClientWorkspaceCapabilities{Configuration: &True},
+	}))
+	s.Require().NoError(err)
+	methods, _ := s.client.calls()
+	s.Require().Empty(methods)
+
+	ctx := s.lspContext(&ClientCapabilities{
+		Workspace: &ClientWorkspaceCapabilities{
+			Configuration: &True,
+			DidChangeConfiguration: &DidChangeConfigurationClientCapabilities{
+				DynamicRegistration: &True,
+			},
+		},
+	})
+	err = service.Register(ctx)
+	s.Require().NoError(err)
+	// Registering again is a no-op.
+	err = service.Register(ctx)
+	s.Require().NoError(err)
+
+	methods, params := s.client.calls()
+	s.Require().Equal([]string{ClientRegisterCapability}, methods)
+	s.Require().JSONEq(
+		`{"registrations":[{"id":"config-1","method":"workspace/didChangeConfiguration",`+
+			`"registerOptions":{"section":"languageServerExample"}}]}`,
+		string(params[0]),
+	)
+}
+
+func (s *ConfigurationServiceTestSuite) lspContext(capabilities *ClientCapabilities) *common.LSPContext {
+	ctx := &common.LSPContext{
+		Call:    s.client.call,
+		Context: context.Background(),
+	}
+	withClientState(ctx, NewClientState(&InitializeParams{
+		Capabilities: *capabilities,
+	}))
+	return ctx
+}
+
+func TestConfigurationServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigurationServiceTestSuite))
+}
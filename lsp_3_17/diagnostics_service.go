@@ -0,0 +1,438 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/two-hundred/ls-builder/common"
+	"go.uber.org/zap"
+)
+
+// DefaultDiagnosticsDebounceDelay is the default delay between a change to a document
+// and the document being re-validated to publish diagnostics to the client.
+var DefaultDiagnosticsDebounceDelay = 200 * time.Millisecond
+
+// DiagnosticsValidatorFunc produces the diagnostics for a snapshot of a document.
+type DiagnosticsValidatorFunc func(
+	ctx *common.LSPContext,
+	document *TextDocument,
+) ([]Diagnostic, error)
+
+// DiagnosticsService produces diagnostics for the documents in a document store
+// from a single validator function, supporting both diagnostics pushed to the client
+// with `textDocument/publishDiagnostics` notifications and diagnostics pulled by the client
+// with `textDocument/diagnostic` and `workspace/diagnostic` requests.
+//
+// Diagnostics are pulled by clients that advertise support for the `textDocument.diagnostic`
+// capability, for all other clients, documents are re-validated after changes
+// settle and the diagnostics are pushed to the client.
+//
+// Results are cached for each document version along with a result ID so that
+// pull requests for documents that have not changed are answered with
+// unchanged reports.
+//
+// A diagnostics service can be attached to a `Handler` with `WithDiagnosticsService`
+// or `SetDiagnosticsService`.
+type DiagnosticsService struct {
+	store                 *DocumentStore
+	validate              DiagnosticsValidatorFunc
+	debounceDelay         time.Duration
+	workspaceBatchSize    int
+	interFileDependencies bool
+	logger                *zap.Logger
+	pending               map[DocumentURI]*pendingValidation
+	results               map[DocumentURI]*diagnosticsResult
+	resultCounter         uint64
+	mu                    sync.Mutex
+}
+
+type pendingValidation struct {
+	timer  *time.Timer
+	cancel context.CancelFunc
+}
+
+type diagnosticsResult struct {
+	resultID    string
+	version     Integer
+	diagnostics []Diagnostic
+}
+
+// DiagnosticsServiceOption is a function that can be used to configure a diagnostics service.
+type DiagnosticsServiceOption func(*DiagnosticsService)
+
+// WithDiagnosticsDebounceDelay sets the delay between a change to a document
+// and the document being re-validated when diagnostics are pushed to the client.
+// Changes made within the delay reset the timer so a document is only
+// validated once edits have settled.
+func WithDiagnosticsDebounceDelay(delay time.Duration) DiagnosticsServiceOption {
+	return func(s *DiagnosticsService) {
+		s.debounceDelay = delay
+	}
+}
+
+// WithWorkspaceDiagnosticsBatchSize sets the number of document reports sent to the
+// client in each partial result of a `workspace/diagnostic` request when the client
+// has provided a partial result token.
+func WithWorkspaceDiagnosticsBatchSize(batchSize int) DiagnosticsServiceOption {
+	return func(s *DiagnosticsService) {
+		s.workspaceBatchSize = batchSize
+	}
+}
+
+// WithInterFileDependencies sets whether editing one document can change
+// the diagnostics of another document, this is advertised to clients
+// that pull diagnostics.
+func WithInterFileDependencies(interFileDependencies bool) DiagnosticsServiceOption {
+	return func(s *DiagnosticsService) {
+		s.interFileDependencies = interFileDependencies
+	}
+}
+
+// WithDiagnosticsLogger sets the logger used to report failures to validate
+// documents in the background for diagnostics that are pushed to the client.
+func WithDiagnosticsLogger(logger *zap.Logger) DiagnosticsServiceOption {
+	return func(s *DiagnosticsService) {
+		s.logger = logger
+	}
+}
+
+// NewDiagnosticsService creates a new diagnostics service that validates
+// documents from the provided document store.
+func NewDiagnosticsService(
+	store *DocumentStore,
+	validate DiagnosticsValidatorFunc,
+	opts ...DiagnosticsServiceOption,
+) *DiagnosticsService {
+	service := &DiagnosticsService{
+		store:              store,
+		validate:           validate,
+		debounceDelay:      DefaultDiagnosticsDebounceDelay,
+		workspaceBatchSize: 1,
+		logger:             zap.NewNop(),
+		pending:            make(map[DocumentURI]*pendingValidation),
+		results:            make(map[DocumentURI]*diagnosticsResult),
+	}
+
+	for _, opt := range opts {
+		opt(service)
+	}
+
+	return service
+}
+
+// Store returns the document store that documents are validated from.
+func (s *DiagnosticsService) Store() *DocumentStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store
+}
+
+// setStore rebinds the service to validate documents from the provided store,
+// discarding the results cached for documents from the previous store.
+func (s *DiagnosticsService) setStore(store *DocumentStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store == store {
+		return
+	}
+
+	s.store = store
+	s.results = make(map[DocumentURI]*diagnosticsResult)
+}
+
+// Options returns the server capability options for pull diagnostics
+// provided by the service.
+func (s *DiagnosticsService) Options() *DiagnosticOptions {
+	return &DiagnosticOptions{
+		InterFileDependencies: s.interFileDependencies,
+		WorkspaceDiagnostics:  true,
+	}
+}
+
+// DocumentOpened validates a document that has been opened in the client and
+// pushes the diagnostics to the client when the client does not pull diagnostics.
+func (s *DiagnosticsService) DocumentOpened(ctx *common.LSPContext, uri DocumentURI) {
+	s.schedule(ctx, uri, 0)
+}
+
+// DocumentChanged schedules a document that has changed to be re-validated once changes
+// have settled and pushes the diagnostics to the client when the client does not pull diagnostics.
+func (s *DiagnosticsService) DocumentChanged(ctx *common.LSPContext, uri DocumentURI) {
+	s.schedule(ctx, uri, s.debounceDelay)
+}
+
+// DocumentClosed cancels any pending validation of a document that has been closed
+// and clears the diagnostics of the document in the client when the client does not pull diagnostics.
+func (s *DiagnosticsService) DocumentClosed(ctx *common.LSPContext, uri DocumentURI) {
+	s.mu.Lock()
+	s.cancelPending(uri)
+	delete(s.results, uri)
+	s.mu.Unlock()
+
+	if s.clientPullsDiagnostics(ctx) {
+		return
+	}
+
+	err := NewDispatcher(ctx).PublishDiagnostics(PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: []Diagnostic{},
+	})
+	if err != nil {
+		s.logger.Error("failed to clear diagnostics", zap.String("uri", uri), zap.Error(err))
+	}
+}
+
+// DocumentDiagnostics handles the `textDocument/diagnostic` request,
+// responding with an unchanged report when the diagnostics of the document
+// have not changed since the previous result.
+// Fulfils the DocumentDiagnosticHandlerFunc signature.
+func (s *DiagnosticsService) DocumentDiagnostics(
+	ctx *common.LSPContext,
+	params *DocumentDiagnosticParams,
+) (any, error) {
+	document, ok := s.Store().Get(params.TextDocument.URI)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDocumentNotOpen, params.TextDocument.URI)
+	}
+
+	result, err := s.diagnose(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.PreviousResultID != nil && *params.PreviousResultID == result.resultID {
+		return &RelatedUnchangedDocumentDiagnosticReport{
+			UnchangedDocumentDiagnosticReport: UnchangedDocumentDiagnosticReport{
+				Kind:     DocumentDiagnosticReportKindUnchanged,
+				ResultID: result.resultID,
+			},
+		}, nil
+	}
+
+	resultID := result.resultID
+	return &RelatedFullDocumentDiagnosticReport{
+		FullDocumentDiagnosticReport: FullDocumentDiagnosticReport{
+			Kind:     DocumentDiagnosticReportKindFull,
+			ResultID: &resultID,
+			Items:    result.diagnostics,
+		},
+	}, nil
+}
+
+// WorkspaceDiagnostics handles the `workspace/diagnostic` request for all the documents
+// in the document store, responding with unchanged reports for documents whose diagnostics
+// have not changed since the previous results provided by the client.
+// When the client provides a partial result token, reports are streamed to the client
+// as partial results and the response contains no items.
+// Fulfils the WorkspaceDiagnosticHandlerFunc signature.
+func (s *DiagnosticsService) WorkspaceDiagnostics(
+	ctx *common.LSPContext,
+	params *WorkspaceDiagnosticParams,
+) (*WorkspaceDiagnosticReport, error) {
+	previousResultIDs := map[DocumentURI]string{}
+	for _, previous := range params.PreviousResultIDs {
+		previousResultIDs[previous.URI] = previous.Value
+	}
+
+	dispatcher := NewDispatcher(ctx)
+	token := params.PartialResultToken
+	items := []any{}
+	for _, uri := range s.Store().URIs() {
+		document, ok := s.Store().Get(uri)
+		if !ok {
+			continue
+		}
+
+		result, err := s.diagnose(ctx, document)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, workspaceDocumentReport(document, result, previousResultIDs))
+
+		if token != nil && len(items) >= s.workspaceBatchSize {
+			if err := dispatcher.PartialResult(token, &WorkspaceDiagnosticReport{Items: items}); err != nil {
+				return nil, err
+			}
+			items = []any{}
+		}
+	}
+
+	if token != nil && len(items) > 0 {
+		if err := dispatcher.PartialResult(token, &WorkspaceDiagnosticReport{Items: items}); err != nil {
+			return nil, err
+		}
+		items = []any{}
+	}
+
+	return &WorkspaceDiagnosticReport{Items: items}, nil
+}
+
+func workspaceDocumentReport(
+	document *TextDocument,
+	result *diagnosticsResult,
+	previousResultIDs map[DocumentURI]string,
+) any {
+	version := document.Version
+	if previousResultIDs[document.URI] == result.resultID {
+		return &WorkspaceUnchangedDocumentDiagnosticReport{
+			UnchangedDocumentDiagnosticReport: UnchangedDocumentDiagnosticReport{
+				Kind:     DocumentDiagnosticReportKindUnchanged,
+				ResultID: result.resultID,
+			},
+			URI:     document.URI,
+			Version: &version,
+		}
+	}
+
+	resultID := result.resultID
+	return &WorkspaceFullDocumentDiagnosticReport{
+		FullDocumentDiagnosticReport: FullDocumentDiagnosticReport{
+			Kind:     DocumentDiagnosticReportKindFull,
+			ResultID: &resultID,
+			Items:    result.diagnostics,
+		},
+		URI:     document.URI,
+		Version: &version,
+	}
+}
+
+// diagnose returns the diagnostics for the document, only running the validator
+// when the document has changed since the last result.
+// The result ID is only changed when the diagnostics are different
+// from the last result.
+func (s *DiagnosticsService) diagnose(
+	ctx *common.LSPContext,
+	document *TextDocument,
+) (*diagnosticsResult, error) {
+	s.mu.Lock()
+	cached, hasCached := s.results[document.URI]
+	s.mu.Unlock()
+
+	if hasCached && cached.version == document.Version {
+		return cached, nil
+	}
+
+	diagnostics, err := s.validate(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := &diagnosticsResult{
+		version:     document.Version,
+		diagnostics: diagnostics,
+	}
+	if hasCached && reflect.DeepEqual(cached.diagnostics, diagnostics) {
+		result.resultID = cached.resultID
+	} else {
+		s.resultCounter += 1
+		result.resultID = strconv.FormatUint(s.resultCounter, 10)
+	}
+	s.results[document.URI] = result
+	return result, nil
+}
+
+func (s *DiagnosticsService) schedule(ctx *common.LSPContext, uri DocumentURI, delay time.Duration) {
+	if s.clientPullsDiagnostics(ctx) {
+		return
+	}
+
+	// Validation is only cancelled when the document is validated again or closed.
+	lspCtx, cancel := detachLSPContext(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancelPending(uri)
+	pending := &pendingValidation{cancel: cancel}
+	pending.timer = time.AfterFunc(delay, func() {
+		s.publish(lspCtx, uri, pending)
+	})
+	s.pending[uri] = pending
+}
+
+func (s *DiagnosticsService) publish(ctx *common.LSPContext, uri DocumentURI, pending *pendingValidation) {
+	defer s.finishPending(uri, pending)
+
+	document, ok := s.Store().Get(uri)
+	if !ok || ctx.Context.Err() != nil {
+		return
+	}
+
+	result, err := s.diagnose(ctx, document)
+	if err != nil {
+		s.logger.Error("failed to validate document", zap.String("uri", uri), zap.Error(err))
+		return
+	}
+
+	// Diagnostics are not published when the document has been changed or closed
+	// during validation as they would be out of date.
+	if ctx.Context.Err() != nil {
+		return
+	}
+
+	version := result.version
+	err = NewDispatcher(ctx).PublishDiagnostics(PublishDiagnosticsParams{
+		URI:         uri,
+		Version:     &version,
+		Diagnostics: result.diagnostics,
+	})
+	if err != nil {
+		s.logger.Error("failed to publish diagnostics", zap.String("uri", uri), zap.Error(err))
+	}
+}
+
+func (s *DiagnosticsService) finishPending(uri DocumentURI, pending *pendingValidation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending.cancel()
+	if s.pending[uri] == pending {
+		delete(s.pending, uri)
+	}
+}
+
+// Expects the lock to be held.
+func (s *DiagnosticsService) cancelPending(uri DocumentURI) {
+	pending, ok := s.pending[uri]
+	if !ok {
+		return
+	}
+
+	pending.timer.Stop()
+	pending.cancel()
+	delete(s.pending, uri)
+}
+
+func (s *DiagnosticsService) clientPullsDiagnostics(ctx *common.LSPContext) bool {
+	clientState := ClientStateFromContext(ctx)
+	return clientState != nil && clientState.SupportsPullDiagnostics()
+}
+
+// detachLSPContext creates a copy of the provided context for work that outlives
+// the message being handled.
+// The context of a message is cancelled once the message has been handled,
+// the detached context keeps the values of the original context (e.g. client state)
+// and is only cancelled with the returned cancel function.
+func detachLSPContext(ctx *common.LSPContext) (*common.LSPContext, context.CancelFunc) {
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	detached, cancel := context.WithCancel(context.WithoutCancel(parent))
+	return &common.LSPContext{
+		Method:  ctx.Method,
+		Notify:  ctx.Notify,
+		Call:    ctx.Call,
+		Context: detached,
+	}, cancel
+}
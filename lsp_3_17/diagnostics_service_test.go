@@ -0,0 +1,321 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"github.com/two-hundred/ls-builder/server"
+	"go.uber.org/zap"
+)
+
+type DiagnosticsServiceTestSuite struct {
+	suite.Suite
+	validations map[DocumentURI]int
+	mu          sync.Mutex
+}
+
+func (s *DiagnosticsServiceTestSuite) SetupTest() {
+	s.validations = map[DocumentURI]int{}
+}
+
+func (s *DiagnosticsServiceTestSuite) Test_handler_pushes_debounced_diagnostics_to_client() {
+	logger, err := zap.NewDevelopment()
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	service := NewDiagnosticsService(
+		NewDocumentStore(),
+		s.validateTodos,
+		WithDiagnosticsDebounceDelay(50*time.Millisecond),
+	)
+	serverHandler := NewHandler(WithDiagnosticsService(service))
+	// Emulate the LSP initialisation process.
+	serverHandler.SetInitialized(true)
+	srv := server.NewServer(serverHandler, true, nil, nil)
+
+	container := createTestConnectionsContainer(srv.NewHandler())
+	go srv.Serve(container.serverConn, logger)
+
+	clientLSPContext := server.NewLSPContext(ctx, container.clientConn, nil)
+	err = clientLSPContext.Notify(MethodTextDocumentDidOpen, DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testDocumentURI, Version: 1, Text: "TODO: open"},
+	})
+	s.Require().NoError(err)
+	s.waitForClientMessages(container, 1)
+
+	for version, text := range []string{"first", "TODO: second", "TODO: third"} {
+		err = clientLSPContext.Notify(MethodTextDocumentDidChange, DidChangeTextDocumentParams{
+			TextDocument: VersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: TextDocumentIdentifier{URI: testDocumentURI},
+				Version:                Integer(version + 2),
+			},
+			ContentChanges: []any{TextDocumentContentChangeEventWhole{Text: text}},
+		})
+		s.Require().NoError(err)
+	}
+	s.waitForClientMessages(container, 2)
+
+	err = clientLSPContext.Notify(MethodTextDocumentDidClose, DidCloseTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: testDocumentURI},
+	})
+	s.Require().NoError(err)
+	s.waitForClientMessages(container, 3)
+
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	s.Require().Equal(
+		[]string{MethodPublishDiagnostics, MethodPublishDiagnostics, MethodPublishDiagnostics},
+		container.clientReceivedMethods,
+	)
+	s.Require().JSONEq(
+		`{"uri":"file:///test.txt","version":1,"diagnostics":[{"range":{"start":{"line":0,"character":0},`+
+			`"end":{"line":0,"character":4}},"message":"TODO: open"}]}`,
+		string(*container.clientReceivedMessages[0]),
+	)
+	// Only the last of the changes made within the debounce delay is validated.
+	s.Require().JSONEq(
+		`{"uri":"file:///test.txt","version":4,"diagnostics":[{"range":{"start":{"line":0,"character":0},`+
+			`"end":{"line":0,"character":4}},"message":"TODO: third"}]}`,
+		string(*container.clientReceivedMessages[1]),
+	)
+	s.Require().JSONEq(
+		`{"uri":"file:///test.txt","diagnostics":[]}`,
+		string(*container.clientReceivedMessages[2]),
+	)
+	s.Require().Equal(2, s.validationCount(testDocumentURI))
+
+	capabilities := serverHandler.CreateServerCapabilities()
+	s.Require().Equal(&DiagnosticOptions{WorkspaceDiagnostics: true}, capabilities.DiagnosticProvider)
+}
+
+func (s *DiagnosticsServiceTestSuite) Test_does_not_push_diagnostics_to_client_that_pulls_diagnostics() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	withClientState(lspCtx, NewClientState(&InitializeParams{
+		Capabilities: ClientCapabilities{
+			TextDocument: &TextDocumentClientCapabilities{
+				Diagnostics: &DiagnosticClientCapabilities{},
+			},
+		},
+	}))
+
+	store := NewDocumentStore()
+	service := NewDiagnosticsService(store, s.validateTodos, WithDiagnosticsDebounceDelay(0))
+	s.openDocument(store, testDocumentURI, 1, "TODO: pull")
+
+	service.DocumentOpened(lspCtx, testDocumentURI)
+	service.DocumentChanged(lspCtx, testDocumentURI)
+	service.DocumentClosed(lspCtx, testDocumentURI)
+	time.Sleep(20 * time.Millisecond)
+
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	s.Require().Empty(container.clientReceivedMethods)
+	s.Require().Equal(0, s.validationCount(testDocumentURI))
+}
+
+func (s *DiagnosticsServiceTestSuite) Test_responds_with_unchanged_document_report_for_previous_result() {
+	store := NewDocumentStore()
+	service := NewDiagnosticsService(store, s.validateTodos)
+	ctx := &common.LSPContext{}
+	s.openDocument(store, testDocumentURI, 1, "TODO: pull")
+
+	report, err := service.DocumentDiagnostics(ctx, &DocumentDiagnosticParams{
+		TextDocument: TextDocumentIdentifier{URI: testDocumentURI},
+	})
+	s.Require().NoError(err)
+	fullReport, isFullReport := report.(*RelatedFullDocumentDiagnosticReport)
+	s.Require().True(isFullReport)
+	s.Require().Equal("1", *fullReport.ResultID)
+	s.Require().Len(fullReport.Items, 1)
+
+	// The diagnostics are the same for the new version of the document.
+	s.openDocument(store, testDocumentURI, 2, "TODO: pull")
+	previousResultID := "1"
+	report, err = service.DocumentDiagnostics(ctx, &DocumentDiagnosticParams{
+		TextDocument:     TextDocumentIdentifier{URI: testDocumentURI},
+		PreviousResultID: &previousResultID,
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(
+		&RelatedUnchangedDocumentDiagnosticReport{
+			UnchangedDocumentDiagnosticReport: UnchangedDocumentDiagnosticReport{
+				Kind:     DocumentDiagnosticReportKindUnchanged,
+				ResultID: "1",
+			},
+		},
+		report,
+	)
+	s.Require().Equal(2, s.validationCount(testDocumentURI))
+
+	// The document is not validated again when it has not changed.
+	_, err = service.DocumentDiagnostics(ctx, &DocumentDiagnosticParams{
+		TextDocument:     TextDocumentIdentifier{URI: testDocumentURI},
+		PreviousResultID: &previousResultID,
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(2, s.validationCount(testDocumentURI))
+
+	s.openDocument(store, testDocumentURI, 3, "done")
+	report, err = service.DocumentDiagnostics(ctx, &DocumentDiagnosticParams{
+		TextDocument:     TextDocumentIdentifier{URI: testDocumentURI},
+		PreviousResultID: &previousResultID,
+	})
+	s.Require().NoError(err)
+	resultID := "2"
+	s.Require().Equal(
+		&RelatedFullDocumentDiagnosticReport{
+			FullDocumentDiagnosticReport: FullDocumentDiagnosticReport{
+				Kind:     DocumentDiagnosticReportKindFull,
+				ResultID: &resultID,
+				Items:    []Diagnostic{},
+			},
+		},
+		report,
+	)
+
+	_, err = service.DocumentDiagnostics(ctx, &DocumentDiagnosticParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///missing.txt"},
+	})
+	s.Require().ErrorIs(err, ErrDocumentNotOpen)
+}
+
+func (s *DiagnosticsServiceTestSuite) Test_streams_workspace_diagnostic_reports_as_partial_results() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+
+	store := NewDocumentStore()
+	service := NewDiagnosticsService(store, s.validateTodos, WithWorkspaceDiagnosticsBatchSize(2))
+	s.openDocument(store, "file:///a.txt", 1, "TODO: a")
+	s.openDocument(store, "file:///b.txt", 1, "b")
+	s.openDocument(store, "file:///c.txt", 1, "TODO: c")
+
+	report, err := service.WorkspaceDiagnostics(lspCtx, &WorkspaceDiagnosticParams{})
+	s.Require().NoError(err)
+	s.Require().Len(report.Items, 3)
+
+	token := "workspace-diagnostics"
+	report, err = service.WorkspaceDiagnostics(lspCtx, &WorkspaceDiagnosticParams{
+		PartialResultParams: PartialResultParams{
+			PartialResultToken: &ProgressToken{StrVal: &token},
+		},
+		PreviousResultIDs: []PreviousResultID{{URI: "file:///b.txt", Value: "2"}},
+	})
+	s.Require().NoError(err)
+	s.Require().Empty(report.Items)
+
+	time.Sleep(20 * time.Millisecond)
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	s.Require().Equal([]string{MethodProgress, MethodProgress}, container.clientReceivedMethods)
+
+	var firstBatch struct {
+		Token string                    `json:"token"`
+		Value WorkspaceDiagnosticReport `json:"value"`
+	}
+	err = json.Unmarshal(*container.clientReceivedMessages[0], &firstBatch)
+	s.Require().NoError(err)
+	s.Require().Equal(token, firstBatch.Token)
+	s.Require().Len(firstBatch.Value.Items, 2)
+	fullReport, isFullReport := firstBatch.Value.Items[0].(WorkspaceFullDocumentDiagnosticReport)
+	s.Require().True(isFullReport)
+	s.Require().Equal("file:///a.txt", fullReport.URI)
+	unchangedReport, isUnchangedReport := firstBatch.Value.Items[1].(WorkspaceUnchangedDocumentDiagnosticReport)
+	s.Require().True(isUnchangedReport)
+	s.Require().Equal("file:///b.txt", unchangedReport.URI)
+	s.Require().Equal("2", unchangedReport.ResultID)
+
+	s.Require().Contains(string(*container.clientReceivedMessages[1]), `"uri":"file:///c.txt"`)
+
+	// Documents are only validated once as they have not changed between requests.
+	s.Require().Equal(1, s.validationCount("file:///a.txt"))
+}
+
+func (s *DiagnosticsServiceTestSuite) Test_validates_documents_from_the_handler_document_store() {
+	handlerStore := NewDocumentStore()
+	service := NewDiagnosticsService(NewDocumentStore(), s.validateTodos)
+	handler := NewHandler(WithDocumentStore(handlerStore), WithDiagnosticsService(service))
+	s.Require().Same(handlerStore, handler.DocumentStore())
+	s.Require().Same(handlerStore, service.Store())
+
+	s.openDocument(handlerStore, testDocumentURI, 1, "TODO: handler store")
+	report, err := service.DocumentDiagnostics(&common.LSPContext{}, &DocumentDiagnosticParams{
+		TextDocument: TextDocumentIdentifier{URI: testDocumentURI},
+	})
+	s.Require().NoError(err)
+	fullReport, isFullReport := report.(*RelatedFullDocumentDiagnosticReport)
+	s.Require().True(isFullReport)
+	s.Require().Len(fullReport.Items, 1)
+
+	// Replacing the handler's document store rebinds the attached service.
+	replacementStore := NewDocumentStore()
+	handler.SetDocumentStore(replacementStore)
+	s.Require().Same(replacementStore, service.Store())
+	_, err = service.DocumentDiagnostics(&common.LSPContext{}, &DocumentDiagnosticParams{
+		TextDocument: TextDocumentIdentifier{URI: testDocumentURI},
+	})
+	s.Require().ErrorIs(err, ErrDocumentNotOpen)
+}
+
+func (s *DiagnosticsServiceTestSuite) validateTodos(
+	ctx *common.LSPContext,
+	document *TextDocument,
+) ([]Diagnostic, error) {
+	s.mu.Lock()
+	s.validations[document.URI] += 1
+	s.mu.Unlock()
+
+	if !strings.HasPrefix(document.Text, "TODO") {
+		return nil, nil
+	}
+
+	return []Diagnostic{
+		{
+			Range:   Range{Start: Position{Line: 0, Character: 0}, End: Position{Line: 0, Character: 4}},
+			Message: document.Text,
+		},
+	}, nil
+}
+
+func (s *DiagnosticsServiceTestSuite) validationCount(uri DocumentURI) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.validations[uri]
+}
+
+func (s *DiagnosticsServiceTestSuite) openDocument(
+	store *DocumentStore,
+	uri DocumentURI,
+	version Integer,
+	text string,
+) {
+	store.Open(&DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, Version: version, Text: text},
+	})
+}
+
+func (s *DiagnosticsServiceTestSuite) waitForClientMessages(container *testConnectionsContainer, count int) {
+	s.Require().Eventually(func() bool {
+		container.mu.Lock()
+		defer container.mu.Unlock()
+		return len(container.clientReceivedMessages) >= count
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestDiagnosticsServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(DiagnosticsServiceTestSuite))
+}
@@ -1,11 +1,20 @@
 package lsp
 
-import "github.com/two-hundred/ls-builder/common"
+import (
+	"fmt"
+
+	"github.com/two-hundred/ls-builder/common"
+)
 
 // Dispatcher provides a convenient way to dispatch
 // requests and notification to the client with types
 // for known requests and notifications that a server can
 // send to a client in LSP 3.17.0.
+//
+// When the underlying LSP context was created by a `Handler` after initialisation,
+// the dispatcher will refuse to send requests that the client has not advertised
+// support for with an `ErrNotSupportedByClient` error and will remove properties
+// from notifications that the client does not support.
 type Dispatcher struct {
 	ctx *common.LSPContext
 }
@@ -22,18 +31,82 @@ func (d *Dispatcher) Context() *common.LSPContext {
 	return d.ctx
 }
 
+// Checks whether the client supports a feature when client state is available,
+// when there is no client state, the dispatcher can't know what the client supports
+// so will always send the message.
+func (d *Dispatcher) checkClientSupport(method string, isSupported func(*ClientState) bool) error {
+	clientState := ClientStateFromContext(d.ctx)
+	if clientState == nil || isSupported(clientState) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrNotSupportedByClient, method)
+}
+
+func (d *Dispatcher) checkRefreshSupport(method string) error {
+	return d.checkClientSupport(method, func(clientState *ClientState) bool {
+		return clientState.SupportsRefresh(method)
+	})
+}
+
 // Progress notifies the client of progress for a specific task.
 func (d *Dispatcher) Progress(params ProgressParams) error {
 	return d.ctx.Notify(MethodProgress, params)
 }
 
+// ProgressBegin notifies the client of the beginning of work done progress
+// for the provided token.
+func (d *Dispatcher) ProgressBegin(token *ProgressToken, begin WorkDoneProgressBegin) error {
+	begin.Kind = workDoneProgressKindBegin
+	return d.Progress(ProgressParams{Token: token, Value: begin})
+}
+
+// ProgressReport notifies the client of progress made on work
+// for the provided token.
+func (d *Dispatcher) ProgressReport(token *ProgressToken, report WorkDoneProgressReport) error {
+	report.Kind = workDoneProgressKindReport
+	return d.Progress(ProgressParams{Token: token, Value: report})
+}
+
+// ProgressEnd notifies the client of the end of work done progress
+// for the provided token.
+func (d *Dispatcher) ProgressEnd(token *ProgressToken, end WorkDoneProgressEnd) error {
+	end.Kind = workDoneProgressKindEnd
+	return d.Progress(ProgressParams{Token: token, Value: end})
+}
+
+// PartialResult sends a partial result to the client for the provided
+// partial result token, the value must be of the same type as the result
+// of the request or a list of items of the result when the result is a list.
+func (d *Dispatcher) PartialResult(token *ProgressToken, value any) error {
+	return d.Progress(ProgressParams{Token: token, Value: value})
+}
+
 // CancelRequest cancels a request with the client.
 func (d *Dispatcher) CancelRequest(params CancelParams) error {
 	return d.ctx.Notify(MethodCancelRequest, params)
 }
 
 // RegisterCapability registers a new capability with the client.
+// This will fail without sending the request if the client does not support
+// dynamic registration for any of the provided registrations.
+// Registrations for methods that do not have a client capability declaring
+// support for dynamic registration (e.g. custom methods) are sent to the client
+// as is.
 func (d *Dispatcher) RegisterCapability(params RegistrationParams) error {
+	for _, registration := range params.Registrations {
+		err := d.checkClientSupport(
+			registration.Method,
+			func(clientState *ClientState) bool {
+				isDynamicRegistrationSupported, isKnown := clientState.dynamicRegistration(registration.Method)
+				return isDynamicRegistrationSupported || !isKnown
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
 	return d.ctx.Call(ClientRegisterCapability, params, nil)
 }
 
@@ -53,6 +126,10 @@ func (d *Dispatcher) LogTrace(params LogTraceParams) error {
 
 // CodeLensRefresh requests the client to refresh all code lenses.
 func (d *Dispatcher) CodeLensRefresh() error {
+	if err := d.checkRefreshSupport(MethodCodeLensRefresh); err != nil {
+		return err
+	}
+
 	return d.ctx.Call(MethodCodeLensRefresh, nil, nil)
 }
 
@@ -63,24 +140,41 @@ func (d *Dispatcher) CodeLensRefresh() error {
 // re-calculation of all semantic tokens. Note that the client still has the freedom
 // to delay the re-calculation of the semantic tokens if for example an editor is currently not visible.
 func (d *Dispatcher) SemanticTokensRefresh() error {
+	if err := d.checkRefreshSupport(MethodSemanticTokensRefresh); err != nil {
+		return err
+	}
+
 	return d.ctx.Call(MethodSemanticTokensRefresh, nil, nil)
 }
 
 // InlayHintRefresh requests the client to refresh inlay hints
 // currently shown in editors.
 func (d *Dispatcher) InlayHintRefresh() error {
+	if err := d.checkRefreshSupport(MethodInlayHintRefresh); err != nil {
+		return err
+	}
+
 	return d.ctx.Call(MethodInlayHintRefresh, nil, nil)
 }
 
 // InlineValueRefresh requests the client to refresh inline values
 // currently shown in editors.
 func (d *Dispatcher) InlineValueRefresh() error {
+	if err := d.checkRefreshSupport(MethodInlineValueRefresh); err != nil {
+		return err
+	}
+
 	return d.ctx.Call(MethodInlineValueRefresh, nil, nil)
 }
 
 // PublishDiagnostics sends diagnostics from the server to the client to signal
 // results of validation runs.
+// Properties of the diagnostics that the client does not support will be removed.
 func (d *Dispatcher) PublishDiagnostics(params PublishDiagnosticsParams) error {
+	if clientState := ClientStateFromContext(d.ctx); clientState != nil {
+		params = clientState.DowngradePublishDiagnosticsParams(params)
+	}
+
 	return d.ctx.Notify(MethodPublishDiagnostics, params)
 }
 
@@ -88,31 +182,69 @@ func (d *Dispatcher) PublishDiagnostics(params PublishDiagnosticsParams) error {
 // and workspace diagnostics. This is useful if the server detects a project wide
 // configuration change which requires a re-calculation of all diagnostics.
 func (d *Dispatcher) DiagnosticsRefresh() error {
+	if err := d.checkRefreshSupport(MethodDiagnosticsRefresh); err != nil {
+		return err
+	}
+
 	return d.ctx.Call(MethodDiagnosticsRefresh, nil, nil)
 }
 
 // WorkspaceConfiguration requests the client to fetch the configuration settings
 // for the given scopes and configuration sections within a workspace.
 func (d *Dispatcher) WorkspaceConfiguration(params ConfigurationParams, target any) error {
-	err := d.ctx.Call(MethodWorkspaceConfiguration, params, target)
+	err := d.checkClientSupport(MethodWorkspaceConfiguration, (*ClientState).SupportsConfiguration)
+	if err != nil {
+		return err
+	}
+
+	err = d.ctx.Call(MethodWorkspaceConfiguration, params, target)
 	return err
 }
 
 // WorkspaceFolders requests that the client fetches the workspace folders
 // that are currently open.
 func (d *Dispatcher) WorkspaceFolders() ([]WorkspaceFolder, error) {
+	err := d.checkClientSupport(MethodWorkspaceFolders, (*ClientState).SupportsWorkspaceFolders)
+	if err != nil {
+		return nil, err
+	}
+
 	var result []WorkspaceFolder
-	err := d.ctx.Call(MethodWorkspaceFolders, nil, &result)
+	err = d.ctx.Call(MethodWorkspaceFolders, nil, &result)
 	return result, err
 }
 
 // ApplyWorkspaceEdit requests that the client applies a workspace edit.
 func (d *Dispatcher) ApplyWorkspaceEdit(params ApplyWorkspaceEditParams) (*ApplyWorkspaceEditResult, error) {
+	err := d.checkClientSupport(MethodWorkspaceApplyEdit, (*ClientState).SupportsApplyEdit)
+	if err != nil {
+		return nil, err
+	}
+
 	var result ApplyWorkspaceEditResult
-	err := d.ctx.Call(MethodWorkspaceApplyEdit, params, &result)
+	err = d.ctx.Call(MethodWorkspaceApplyEdit, params, &result)
 	return &result, err
 }
 
+// ApplyWorkspaceEditFromBuilder builds a workspace edit in the shape supported
+// by the client and requests that the client applies it.
+// The edit is not sent to the client if it could not be built, for example when the client
+// does not support a file operation or a document has changed since the edits were computed.
+func (d *Dispatcher) ApplyWorkspaceEditFromBuilder(
+	label *string,
+	builder *WorkspaceEditBuilder,
+) (*ApplyWorkspaceEditResult, error) {
+	edit, err := builder.Build(ClientStateFromContext(d.ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return d.ApplyWorkspaceEdit(ApplyWorkspaceEditParams{
+		Label: label,
+		Edit:  *edit,
+	})
+}
+
 // ShowMessageNotification sends a notification to the client to show a message
 // without waiting for a response.
 func (d *Dispatcher) ShowMessageNotification(params ShowMessageParams) error {
@@ -127,6 +259,20 @@ func (d *Dispatcher) ShowMessageRequest(params ShowMessageRequestParams) (*Messa
 	return &result, err
 }
 
+// ShowDocument requests the client to show a document identified by a URI
+// in the client, this can be a text document in the editor or a resource
+// such as a web page in an external program.
+func (d *Dispatcher) ShowDocument(params ShowDocumentParams) (*ShowDocumentResult, error) {
+	err := d.checkClientSupport(MethodShowDocument, (*ClientState).SupportsShowDocument)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ShowDocumentResult
+	err = d.ctx.Call(MethodShowDocument, params, &result)
+	return &result, err
+}
+
 // LogMessage sends a notification to the client to log a message.
 func (d *Dispatcher) LogMessage(params LogMessageParams) error {
 	return d.ctx.Notify(MethodLogMessage, params)
@@ -134,6 +280,11 @@ func (d *Dispatcher) LogMessage(params LogMessageParams) error {
 
 // CreateWorkDoneProgress sends a request to the client to create a new work done progress.
 func (d *Dispatcher) CreateWorkDoneProgress(params WorkDoneProgressCreateParams) error {
+	err := d.checkClientSupport(MethodWorkDoneProgressCreate, (*ClientState).SupportsWorkDoneProgress)
+	if err != nil {
+		return err
+	}
+
 	return d.ctx.Call(MethodWorkDoneProgressCreate, params, nil)
 }
 
@@ -3,6 +3,7 @@ package lsp
 import (
 	"context"
 	"encoding/json"
+	"reflect"
 	"testing"
 	"time"
 
@@ -668,6 +669,149 @@ func (s *DispatchTestSuite) Test_server_sends_window_telemetry_event_notificatio
 	s.Require().Equal(MethodTelemetryEvent, container.clientReceivedMethods[0])
 }
 
+func (s *DispatchTestSuite) Test_server_sends_show_document_request() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	serverHandler := newTestServerHandler()
+	container := createTestConnectionsContainer(serverHandler)
+
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	dispatcher := NewDispatcher(lspCtx)
+
+	params := ShowDocumentParams{
+		URI:       "file:///test.txt",
+		TakeFocus: &True,
+		Selection: &Range{
+			Start: Position{Line: 1, Character: 2},
+			End:   Position{Line: 1, Character: 5},
+		},
+	}
+	_, err := dispatcher.ShowDocument(params)
+	s.Require().NoError(err)
+
+	// Acquire a lock on the received message list shared between goroutines.
+	container.mu.Lock()
+	defer container.mu.Unlock()
+
+	// Verify that the client received the show document message.
+	s.Require().Len(container.clientReceivedMessages, 1)
+	var message ShowDocumentParams
+	err = json.Unmarshal(*container.clientReceivedMessages[0], &message)
+	s.Require().NoError(err)
+	s.Require().Equal(params, message)
+
+	// Verify the method name.
+	s.Require().Len(container.clientReceivedMethods, 1)
+	s.Require().Equal(MethodShowDocument, container.clientReceivedMethods[0])
+}
+
+func (s *DispatchTestSuite) Test_server_sends_typed_progress_notifications() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	serverHandler := newTestServerHandler()
+	container := createTestConnectionsContainer(serverHandler)
+
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	dispatcher := NewDispatcher(lspCtx)
+
+	progressToken := "progress-test-token"
+	token := &IntOrString{StrVal: &progressToken}
+	percentage := UInteger(50)
+
+	err := dispatcher.ProgressBegin(token, WorkDoneProgressBegin{Title: "Indexing"})
+	s.Require().NoError(err)
+	err = dispatcher.ProgressReport(token, WorkDoneProgressReport{Percentage: &percentage})
+	s.Require().NoError(err)
+	err = dispatcher.ProgressEnd(token, WorkDoneProgressEnd{})
+	s.Require().NoError(err)
+	err = dispatcher.PartialResult(token, []Location{{URI: "file:///test.txt"}})
+	s.Require().NoError(err)
+
+	// Let some time pass as these are notifications,
+	// which by definition in LSP and JSON-RPC are fire-and-forget
+	// so we can't know at this point if the client has received the messages.
+	time.Sleep(10 * time.Millisecond)
+
+	// Acquire a lock on the received message list shared between goroutines.
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	s.Require().Len(container.clientReceivedMessages, 4)
+	expected := []string{
+		`{"token":"progress-test-token","value":{"kind":"begin","title":"Indexing"}}`,
+		`{"token":"progress-test-token","value":{"kind":"report","percentage":50}}`,
+		`{"token":"progress-test-token","value":{"kind":"end"}}`,
+		`{"token":"progress-test-token","value":[{"range":null,"uri":"file:///test.txt"}]}`,
+	}
+	for i, message := range container.clientReceivedMessages {
+		s.Require().Equal(expected[i], string(*message))
+		s.Require().Equal(MethodProgress, container.clientReceivedMethods[i])
+	}
+}
+
+// Calls every method of the dispatcher with zero values to make sure
+// that the dispatcher covers all the messages that can be sent from the server
+// to the client in the official LSP 3.17 meta model and doesn't send any messages
+// that are not meant to be sent from the server to the client.
+// The meta model can be downloaded with scripts/update-meta-model.sh.
+func (s *DispatchTestSuite) Test_dispatcher_covers_all_server_to_client_messages_in_meta_model() {
+	metaModel := loadMetaModel(&s.Suite)
+
+	expectedMethods := map[string]bool{}
+	for _, message := range metaModel.messages() {
+		if message.MessageDirection == "serverToClient" || message.MessageDirection == "both" {
+			expectedMethods[message.Method] = true
+		}
+	}
+	s.Require().NotEmpty(expectedMethods)
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	serverHandler := newTestServerHandler()
+	container := createTestConnectionsContainer(serverHandler)
+
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	dispatcher := reflect.ValueOf(NewDispatcher(lspCtx))
+	dispatcherType := dispatcher.Type()
+	for i := 0; i < dispatcherType.NumMethod(); i += 1 {
+		method := dispatcherType.Method(i)
+		if method.Name == "Context" {
+			continue
+		}
+
+		args := []reflect.Value{}
+		// The first input is the receiver.
+		for j := 1; j < method.Type.NumIn(); j += 1 {
+			args = append(args, reflect.Zero(method.Type.In(j)))
+		}
+		dispatcher.Method(i).Call(args)
+	}
+
+	// Let some time pass for notifications to be received by the client.
+	time.Sleep(20 * time.Millisecond)
+
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	sentMethods := map[string]bool{}
+	for _, method := range container.clientReceivedMethods {
+		sentMethods[method] = true
+	}
+
+	for method := range expectedMethods {
+		s.Assert().True(sentMethods[method], "dispatcher does not send %q", method)
+	}
+
+	for method := range sentMethods {
+		s.Assert().True(
+			expectedMethods[method],
+			"dispatcher sends %q which is not a server to client message",
+			method,
+		)
+	}
+}
+
 func TestDispatchTestSuite(t *testing.T) {
 	suite.Run(t, new(DispatchTestSuite))
 }
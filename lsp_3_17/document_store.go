@@ -0,0 +1,205 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// TextDocument is a snapshot of a text document that is open in the client
+// and has been synchronised with the server.
+// Snapshots are never modified once they have been created by a document store,
+// changes to a document produce a new snapshot, so they are safe to read
+// from multiple goroutines.
+type TextDocument struct {
+	// The text document's URI.
+	URI DocumentURI
+
+	// The text document's language identifier.
+	LanguageID string
+
+	// The version number of the document after all changes
+	// in the snapshot have been applied.
+	Version Integer
+
+	// The content of the document.
+	Text string
+}
+
+// DocumentStore provides an in-memory store of the text documents that are open
+// in the client, kept up to date by applying the full and incremental changes
+// that the client sends in `textDocument/didChange` notifications.
+//
+// A document store can be attached to a `Handler` with `WithDocumentStore` or
+// `SetDocumentStore` to keep the store up to date before the `textDocument/didOpen`,
+// `textDocument/didChange` and `textDocument/didClose` handlers are called.
+type DocumentStore struct {
+	documents       map[DocumentURI]*TextDocument
+	posEncodingKind PositionEncodingKind
+	mu              sync.RWMutex
+}
+
+// DocumentStoreOption is a function that can be used to configure a document store.
+type DocumentStoreOption func(*DocumentStore)
+
+// WithDocumentStorePositionEncodingKind sets the position encoding kind used to
+// resolve the ranges of incremental changes to byte offsets in the content of a document.
+// This defaults to `PositionEncodingKindUTF16` which is the encoding that all
+// clients must support.
+func WithDocumentStorePositionEncodingKind(posEncodingKind PositionEncodingKind) DocumentStoreOption {
+	return func(s *DocumentStore) {
+		s.posEncodingKind = posEncodingKind
+	}
+}
+
+// NewDocumentStore creates a new empty document store.
+func NewDocumentStore(opts ...DocumentStoreOption) *DocumentStore {
+	store := &DocumentStore{
+		documents:       make(map[DocumentURI]*TextDocument),
+		posEncodingKind: PositionEncodingKindUTF16,
+	}
+	for _, opt := range opts {
+		opt(store)
+	}
+	return store
+}
+
+// SetPositionEncodingKind sets the position encoding kind used to resolve
+// the ranges of incremental changes, this should be the encoding that
+// was negotiated with the client during initialisation.
+func (s *DocumentStore) SetPositionEncodingKind(posEncodingKind PositionEncodingKind) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.posEncodingKind = posEncodingKind
+}
+
+// PositionEncodingKind returns the position encoding kind used
+// to resolve the ranges of incremental changes.
+func (s *DocumentStore) PositionEncodingKind() PositionEncodingKind {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.posEncodingKind
+}
+
+// Get retrieves a snapshot of the document with the given URI,
+// the second return value will be false if the document is not open.
+func (s *DocumentStore) Get(uri DocumentURI) (*TextDocument, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	document, ok := s.documents[uri]
+	return document, ok
+}
+
+// URIs returns the URIs of all the documents that are currently open
+// in sorted order.
+func (s *DocumentStore) URIs() []DocumentURI {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	uris := make([]DocumentURI, 0, len(s.documents))
+	for uri := range s.documents {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+// Open adds the document from a `textDocument/didOpen` notification to the store,
+// replacing any existing snapshot for the same URI.
+func (s *DocumentStore) Open(params *DidOpenTextDocumentParams) *TextDocument {
+	document := &TextDocument{
+		URI:        params.TextDocument.URI,
+		LanguageID: params.TextDocument.LanguageID,
+		Version:    params.TextDocument.Version,
+		Text:       params.TextDocument.Text,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.documents[document.URI] = document
+	return document
+}
+
+// Change applies the content changes from a `textDocument/didChange` notification
+// to the document in the order they were provided and returns the new snapshot
+// of the document.
+// An error is returned if the document is not open or a change could not be applied,
+// in which case the stored document is left unchanged.
+func (s *DocumentStore) Change(params *DidChangeTextDocumentParams) (*TextDocument, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uri := params.TextDocument.URI
+	current, ok := s.documents[uri]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDocumentNotOpen, uri)
+	}
+
+	text := current.Text
+	for _, change := range params.ContentChanges {
+		var err error
+		text, err = applyContentChange(text, change, s.posEncodingKind)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	document := &TextDocument{
+		URI:        uri,
+		LanguageID: current.LanguageID,
+		Version:    params.TextDocument.Version,
+		Text:       text,
+	}
+	s.documents[uri] = document
+	return document, nil
+}
+
+// Close removes the document from a `textDocument/didClose` notification
+// from the store.
+func (s *DocumentStore) Close(params *DidCloseTextDocumentParams) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.documents, params.TextDocument.URI)
+}
+
+func applyContentChange(text string, change any, posEncodingKind PositionEncodingKind) (string, error) {
+	switch changeEvent := change.(type) {
+	case TextDocumentContentChangeEventWhole:
+		return changeEvent.Text, nil
+	case *TextDocumentContentChangeEventWhole:
+		return changeEvent.Text, nil
+	case TextDocumentContentChangeEvent:
+		return applyIncrementalChange(text, &changeEvent, posEncodingKind)
+	case *TextDocumentContentChangeEvent:
+		return applyIncrementalChange(text, changeEvent, posEncodingKind)
+	default:
+		return "", ErrInvalidContentChangeEvent
+	}
+}
+
+func applyIncrementalChange(
+	text string,
+	changeEvent *TextDocumentContentChangeEvent,
+	posEncodingKind PositionEncodingKind,
+) (string, error) {
+	if changeEvent.Range == nil {
+		return changeEvent.Text, nil
+	}
+
+	// Positions past the end of a line or the document are clamped to the end
+	// of the line or the document instead of being treated as the start
+	// of the document.
+	start, _, _ := changeEvent.Range.Start.clampedIndexIn(text, posEncodingKind)
+	end, _, _ := changeEvent.Range.End.clampedIndexIn(text, posEncodingKind)
+	if start > end {
+		return "", fmt.Errorf(
+			"%w: start %d:%d is after end %d:%d",
+			ErrInvalidContentChangeRange,
+			changeEvent.Range.Start.Line,
+			changeEvent.Range.Start.Character,
+			changeEvent.Range.End.Line,
+			changeEvent.Range.End.Character,
+		)
+	}
+
+	return text[:start] + changeEvent.Text + text[end:], nil
+}
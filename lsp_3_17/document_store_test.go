@@ -0,0 +1,293 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"github.com/two-hundred/ls-builder/server"
+	"go.uber.org/zap"
+)
+
+type DocumentStoreTestSuite struct {
+	suite.Suite
+}
+
+const testDocumentURI = "file:///test.txt"
+
+func (s *DocumentStoreTestSuite) Test_applies_full_and_incremental_changes() {
+	tests := []struct {
+		name            string
+		posEncodingKind PositionEncodingKind
+		initialText     string
+		changes         []any
+		expectedText    string
+	}{
+		{
+			name:            "replaces the whole document",
+			posEncodingKind: PositionEncodingKindUTF16,
+			initialText:     "old content",
+			changes: []any{
+				TextDocumentContentChangeEventWhole{Text: "new content"},
+			},
+			expectedText: "new content",
+		},
+		{
+			name:            "applies incremental changes in order",
+			posEncodingKind: PositionEncodingKindUTF16,
+			initialText:     "line one\nline two\n",
+			changes: []any{
+				TextDocumentContentChangeEvent{
+					Range: &Range{
+						Start: Position{Line: 1, Character: 5},
+						End:   Position{Line: 1, Character: 8},
+					},
+					Text: "2",
+				},
+				&TextDocumentContentChangeEvent{
+					Range: &Range{
+						Start: Position{Line: 2, Character: 0},
+						End:   Position{Line: 2, Character: 0},
+					},
+					Text: "line three",
+				},
+			},
+			expectedText: "line one\nline 2\nline three",
+		},
+		{
+			name:            "resolves utf-16 positions after surrogate pairs",
+			posEncodingKind: PositionEncodingKindUTF16,
+			initialText:     "a😀b",
+			changes: []any{
+				TextDocumentContentChangeEvent{
+					Range: &Range{
+						Start: Position{Line: 0, Character: 3},
+						End:   Position{Line: 0, Character: 4},
+					},
+					Text: "c",
+				},
+			},
+			expectedText: "a😀c",
+		},
+		{
+			name:            "resolves utf-8 positions",
+			posEncodingKind: PositionEncodingKindUTF8,
+			initialText:     "a😀b",
+			changes: []any{
+				TextDocumentContentChangeEvent{
+					Range: &Range{
+						Start: Position{Line: 0, Character: 1},
+						End:   Position{Line: 0, Character: 5},
+					},
+					Text: "",
+				},
+			},
+			expectedText: "ab",
+		},
+	}
+
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			store := NewDocumentStore(WithDocumentStorePositionEncodingKind(test.posEncodingKind))
+			store.Open(&DidOpenTextDocumentParams{
+				TextDocument: TextDocumentItem{
+					URI:        testDocumentURI,
+					LanguageID: "plaintext",
+					Version:    1,
+					Text:       test.initialText,
+				},
+			})
+
+			document, err := store.Change(&DidChangeTextDocumentParams{
+				TextDocument: VersionedTextDocumentIdentifier{
+					TextDocumentIdentifier: TextDocumentIdentifier{URI: testDocumentURI},
+					Version:                2,
+				},
+				ContentChanges: test.changes,
+			})
+			s.Require().NoError(err)
+			s.Require().Equal(test.expectedText, document.Text)
+			s.Require().Equal(Integer(2), document.Version)
+			s.Require().Equal("plaintext", document.LanguageID)
+		})
+	}
+}
+
+func (s *DocumentStoreTestSuite) Test_keeps_previous_snapshots_unchanged() {
+	store := NewDocumentStore()
+	opened := store.Open(&DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testDocumentURI, Version: 1, Text: "first"},
+	})
+
+	_, err := store.Change(&DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: TextDocumentIdentifier{URI: testDocumentURI},
+			Version:                2,
+		},
+		ContentChanges: []any{TextDocumentContentChangeEventWhole{Text: "second"}},
+	})
+	s.Require().NoError(err)
+
+	s.Require().Equal("first", opened.Text)
+	latest, ok := store.Get(testDocumentURI)
+	s.Require().True(ok)
+	s.Require().Equal("second", latest.Text)
+}
+
+func (s *DocumentStoreTestSuite) Test_fails_to_change_document_that_is_not_open() {
+	store := NewDocumentStore()
+	_, err := store.Change(&DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: TextDocumentIdentifier{URI: testDocumentURI},
+			Version:                2,
+		},
+		ContentChanges: []any{TextDocumentContentChangeEventWhole{Text: "new"}},
+	})
+	s.Require().ErrorIs(err, ErrDocumentNotOpen)
+}
+
+func (s *DocumentStoreTestSuite) Test_fails_for_invalid_change_range() {
+	store := NewDocumentStore()
+	store.Open(&DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testDocumentURI, Version: 1, Text: "content"},
+	})
+	_, err := store.Change(&DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: TextDocumentIdentifier{URI: testDocumentURI},
+			Version:                2,
+		},
+		ContentChanges: []any{
+			TextDocumentContentChangeEvent{
+				Range: &Range{
+					Start: Position{Line: 0, Character: 5},
+					End:   Position{Line: 0, Character: 2},
+				},
+				Text: "new",
+			},
+		},
+	})
+	s.Require().ErrorIs(err, ErrInvalidContentChangeRange)
+
+	document, ok := store.Get(testDocumentURI)
+	s.Require().True(ok)
+	s.Require().Equal("content", document.Text)
+}
+
+func (s *DocumentStoreTestSuite) Test_clamps_change_range_to_end_of_line_and_document() {
+	store := NewDocumentStore()
+	store.Open(&DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testDocumentURI, Version: 1, Text: "first\nsecond"},
+	})
+	document, err := store.Change(&DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: TextDocumentIdentifier{URI: testDocumentURI},
+			Version:                2,
+		},
+		ContentChanges: []any{
+			TextDocumentContentChangeEvent{
+				Range: &Range{
+					Start: Position{Line: 0, Character: 20},
+					End:   Position{Line: 0, Character: 30},
+				},
+				Text: " line",
+			},
+			TextDocumentContentChangeEvent{
+				Range: &Range{
+					Start: Position{Line: 1, Character: 6},
+					End:   Position{Line: 5, Character: 0},
+				},
+				Text: " line",
+			},
+		},
+	})
+	s.Require().NoError(err)
+	s.Require().Equal("first line\nsecond line", document.Text)
+}
+
+func (s *DocumentStoreTestSuite) Test_removes_closed_documents() {
+	store := NewDocumentStore()
+	store.Open(&DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: "file:///b.txt", Version: 1, Text: "b"},
+	})
+	store.Open(&DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: "file:///a.txt", Version: 1, Text: "a"},
+	})
+	s.Require().Equal([]DocumentURI{"file:///a.txt", "file:///b.txt"}, store.URIs())
+
+	store.Close(&DidCloseTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///a.txt"},
+	})
+	_, ok := store.Get("file:///a.txt")
+	s.Require().False(ok)
+	s.Require().Equal([]DocumentURI{"file:///b.txt"}, store.URIs())
+}
+
+func (s *DocumentStoreTestSuite) Test_handler_updates_store_before_calling_handlers() {
+	logger, err := zap.NewDevelopment()
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	store := NewDocumentStore()
+	callChan := make(chan string, 1)
+	serverHandler := NewHandler(
+		WithDocumentStore(store),
+		WithTextDocumentDidChangeHandler(
+			func(ctx *common.LSPContext, params *DidChangeTextDocumentParams) error {
+				document, _ := store.Get(params.TextDocument.URI)
+				callChan <- document.Text
+				return nil
+			},
+		),
+	)
+	// Emulate the LSP initialisation process.
+	serverHandler.SetInitialized(true)
+	srv := server.NewServer(serverHandler, true, nil, nil)
+
+	container := createTestConnectionsContainer(srv.NewHandler())
+
+	go srv.Serve(container.serverConn, logger)
+
+	clientLSPContext := server.NewLSPContext(ctx, container.clientConn, nil)
+
+	err = clientLSPContext.Notify(MethodTextDocumentDidOpen, DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testDocumentURI, Version: 1, Text: "Hello world"},
+	})
+	s.Require().NoError(err)
+
+	err = clientLSPContext.Notify(MethodTextDocumentDidChange, DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: TextDocumentIdentifier{URI: testDocumentURI},
+			Version:                2,
+		},
+		ContentChanges: []any{
+			TextDocumentContentChangeEvent{
+				Range: &Range{
+					Start: Position{Line: 0, Character: 6},
+					End:   Position{Line: 0, Character: 11},
+				},
+				Text: "there",
+			},
+		},
+	})
+	s.Require().NoError(err)
+
+	select {
+	case <-ctx.Done():
+		s.Fail("timeout")
+	case text := <-callChan:
+		s.Require().Equal("Hello there", text)
+	}
+
+	capabilities := serverHandler.CreateServerCapabilities()
+	syncOptions, ok := capabilities.TextDocumentSync.(*TextDocumentSyncOptions)
+	s.Require().True(ok)
+	s.Require().True(*syncOptions.OpenClose)
+	s.Require().Equal(TextDocumentSyncKindIncremental, *syncOptions.Change)
+}
+
+func TestDocumentStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(DocumentStoreTestSuite))
+}
@@ -1,17 +1,203 @@
 package lsp
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
+
+// Error codes defined by the Language Server Protocol
+// in addition to the JSON-RPC 2.0 error codes.
+const (
+	// CodeServerNotInitialized is the error code used to respond to requests
+	// that are received before the server has been initialized.
+	CodeServerNotInitialized int64 = -32002
+
+	// CodeUnknownErrorCode is the error code for errors
+	// that do not have a more specific code.
+	CodeUnknownErrorCode int64 = -32001
+
+	// CodeRequestFailed is the error code used when a request failed
+	// but it was syntactically correct, e.g the method name was known
+	// and the parameters were valid.
+	// The error message should contain human readable information
+	// about why the request failed.
+	//
+	// @since 3.17.0
+	CodeRequestFailed int64 = -32803
+
+	// CodeServerCancelled is the error code used when the server cancelled
+	// the request. This error code should only be used for requests that
+	// explicitly support being server cancellable.
+	//
+	// @since 3.17.0
+	CodeServerCancelled int64 = -32802
+
+	// CodeContentModified is the error code used when the server detected
+	// that the content of a document got modified outside normal conditions.
+	// A server should NOT send this error code if it detects a content change
+	// in its unprocessed messages.
+	//
+	// If a client decides that a result is not of any use anymore
+	// the client should cancel the request.
+	CodeContentModified int64 = -32801
+
+	// CodeRequestCancelled is the error code used when the client
+	// has cancelled a request and the server has detected the cancel.
+	CodeRequestCancelled int64 = -32800
+)
+
+// errorCodeNames maps the names of error codes used in the specification
+// to their numeric values.
+var errorCodeNames = map[string]int64{
+	"ServerNotInitialized": CodeServerNotInitialized,
+	"UnknownErrorCode":     CodeUnknownErrorCode,
+	"RequestFailed":        CodeRequestFailed,
+	"ServerCancelled":      CodeServerCancelled,
+	"ContentModified":      CodeContentModified,
+	"RequestCancelled":     CodeRequestCancelled,
+}
+
+// ResponseError is an error that is sent to the client as
+// an error response with a specific error code and optional data.
+//
+// The server converts errors returned by handlers that implement
+// the `ErrorCode` and `ErrorData` methods into JSON-RPC error responses,
+// so a ResponseError can be returned directly from a handler or wrapped
+// with additional context.
+type ResponseError struct {
+	// The error code (e.g. `CodeContentModified`).
+	Code int64
+	// A message providing a short description of the error.
+	Message string
+	// Optional data to send with the error,
+	// e.g. `DiagnosticServerCancellationData` for server cancellations
+	// of diagnostic requests or `InitializeError` for initialize requests.
+	Data any
+}
+
+// Error fulfils the error interface.
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
+// ErrorCode returns the error code to send to the client.
+func (e *ResponseError) ErrorCode() int64 {
+	return e.Code
+}
+
+// ErrorData returns the data to send to the client with the error.
+func (e *ResponseError) ErrorData() any {
+	return e.Data
+}
+
+// NewRequestFailedError creates an error for a request that failed
+// even though it was syntactically correct.
+func NewRequestFailedError(message string, data any) *ResponseError {
+	return &ResponseError{Code: CodeRequestFailed, Message: message, Data: data}
+}
+
+// NewServerCancelledError creates an error for a request that was cancelled
+// by the server, such as a diagnostic request where the data
+// is `DiagnosticServerCancellationData`.
+func NewServerCancelledError(message string, data any) *ResponseError {
+	return &ResponseError{Code: CodeServerCancelled, Message: message, Data: data}
+}
+
+// NewContentModifiedError creates an error for a request whose result
+// is no longer valid as the content of a document has been modified.
+func NewContentModifiedError(message string, data any) *ResponseError {
+	return &ResponseError{Code: CodeContentModified, Message: message, Data: data}
+}
+
+// NewRequestCancelledError creates an error for a request
+// that has been cancelled by the client.
+func NewRequestCancelledError(message string, data any) *ResponseError {
+	return &ResponseError{Code: CodeRequestCancelled, Message: message, Data: data}
+}
+
+// NewServerNotInitializedError creates an error for a request
+// that was received before the server was initialized.
+func NewServerNotInitializedError(message string, data any) *ResponseError {
+	return &ResponseError{Code: CodeServerNotInitialized, Message: message, Data: data}
+}
+
+// NewUnknownError creates an error that does not have a more specific
+// error code, such as a failed initialize request where the data
+// is an `InitializeError`.
+func NewUnknownError(message string, data any) *ResponseError {
+	return &ResponseError{Code: CodeUnknownErrorCode, Message: message, Data: data}
+}
 
 // ErrorWithData is an error that contains additional data such as that for
 // server cancellations.
+//
+// A ResponseError created with one of the constructors such as `NewServerCancelledError`
+// should be preferred, ErrorWithData is converted to an error response in the same way.
 type ErrorWithData struct {
-	// The error code (e.g. diagnostic error code)
+	// The error code (e.g. diagnostic error code),
+	// this can be the numeric code or the name of the code
+	// in the specification (e.g. "ServerCancelled").
 	Code *IntOrString
 	// The data to send with the error.
 	Data any
+	// An optional message providing a short description of the error.
+	Message string
+}
+
+// Error fulfils the error interface.
+func (e *ErrorWithData) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	if e.Code != nil && e.Code.StrVal != nil {
+		return *e.Code.StrVal
+	}
+
+	return fmt.Sprintf("error code %d", e.ErrorCode())
+}
+
+// ErrorCode returns the numeric error code to send to the client,
+// names of codes that are not defined by the specification
+// resolve to `CodeUnknownErrorCode`.
+func (e *ErrorWithData) ErrorCode() int64 {
+	if e.Code == nil {
+		return CodeUnknownErrorCode
+	}
+
+	if e.Code.IntVal != nil {
+		return int64(*e.Code.IntVal)
+	}
+
+	if e.Code.StrVal != nil {
+		if code, ok := errorCodeNames[*e.Code.StrVal]; ok {
+			return code
+		}
+	}
+
+	return CodeUnknownErrorCode
+}
+
+// ErrorData returns the data to send to the client with the error.
+func (e *ErrorWithData) ErrorData() any {
+	return e.Data
 }
 
 var (
 	ErrInvalidDocumentDiagnosticReportKind = errors.New("invalid document diagnostic report kind")
 	ErrInvalidCodeActionOrCommand          = errors.New("invalid code action or command")
+	ErrDocumentNotOpen                     = errors.New("document is not open")
+	ErrInvalidContentChangeEvent           = errors.New("invalid text document content change event")
+	ErrInvalidContentChangeRange           = errors.New("invalid text document content change range")
+	ErrNotSupportedByClient                = errors.New("not supported by client")
+	ErrRegistrationNotActive               = errors.New("registration is not active")
+	ErrUnknownSemanticTokenType            = errors.New("unknown semantic token type")
+	ErrUnknownSemanticTokenModifier        = errors.New("unknown semantic token modifier")
+	ErrTooManySemanticTokenModifiers       = errors.New("too many semantic token modifiers")
+	ErrInvalidSemanticTokenRange           = errors.New("invalid semantic token range")
+	ErrInvalidTextEdit                     = errors.New("invalid text edit")
+	ErrOverlappingTextEdits                = errors.New("text edits overlap")
+	ErrDocumentVersionMismatch             = errors.New("document version does not match")
+	ErrUnknownChangeAnnotation             = errors.New("unknown change annotation")
+	ErrInvalidSnippet                      = errors.New("invalid snippet")
 )
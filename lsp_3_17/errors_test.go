@@ -0,0 +1,65 @@
+package lsp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorsTestSuite struct {
+	suite.Suite
+}
+
+func (s *ErrorsTestSuite) Test_creates_response_errors_with_lsp_error_codes() {
+	data := &InitializeError{Retry: true}
+	tests := []struct {
+		err          *ResponseError
+		expectedCode int64
+	}{
+		{NewRequestFailedError("failed", data), CodeRequestFailed},
+		{NewServerCancelledError("failed", data), CodeServerCancelled},
+		{NewContentModifiedError("failed", data), CodeContentModified},
+		{NewRequestCancelledError("failed", data), CodeRequestCancelled},
+		{NewServerNotInitializedError("failed", data), CodeServerNotInitialized},
+		{NewUnknownError("failed", data), CodeUnknownErrorCode},
+	}
+
+	for _, test := range tests {
+		s.Assert().Equal(test.expectedCode, test.err.ErrorCode())
+		s.Assert().Equal(data, test.err.ErrorData())
+		s.Assert().Equal("failed", test.err.Error())
+	}
+}
+
+func (s *ErrorsTestSuite) Test_resolves_error_code_of_error_with_data() {
+	contentModified := "ContentModified"
+	unknown := "SomethingElse"
+	intCode := Integer(-32803)
+	tests := []struct {
+		err             *ErrorWithData
+		expectedCode    int64
+		expectedMessage string
+	}{
+		{&ErrorWithData{Code: &IntOrString{StrVal: &contentModified}}, CodeContentModified, "ContentModified"},
+		{&ErrorWithData{Code: &IntOrString{StrVal: &unknown}}, CodeUnknownErrorCode, "SomethingElse"},
+		{&ErrorWithData{Code: &IntOrString{IntVal: &intCode}}, CodeRequestFailed, "error code -32803"},
+		{&ErrorWithData{Message: "no code"}, CodeUnknownErrorCode, "no code"},
+	}
+
+	for _, test := range tests {
+		s.Assert().Equal(test.expectedCode, test.err.ErrorCode())
+		s.Assert().Equal(test.expectedMessage, test.err.Error())
+	}
+}
+
+func (s *ErrorsTestSuite) Test_response_errors_can_be_wrapped() {
+	var err error = fmt.Errorf("validating document: %w", NewContentModifiedError("document changed", nil))
+	var responseErr *ResponseError
+	s.Require().ErrorAs(err, &responseErr)
+	s.Assert().Equal(CodeContentModified, responseErr.Code)
+}
+
+func TestErrorsTestSuite(t *testing.T) {
+	suite.Run(t, new(ErrorsTestSuite))
+}
@@ -43,7 +43,17 @@ func Example() {
 	// client to select the transport to be used.
 	// See: https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#implementationConsiderations
 	connContainer := createTestConnectionsContainer(srv.NewHandler())
-	go srv.Serve(connContainer.serverConn, logger)
+
+	// Wait for the server to log that it is serving the connection
+	// so the order of the output is deterministic.
+	serving := make(chan struct{})
+	var servingOnce sync.Once
+	connLogger := logger.WithOptions(zap.Hooks(func(zapcore.Entry) error {
+		servingOnce.Do(func() { close(serving) })
+		return nil
+	}))
+	go srv.Serve(connContainer.serverConn, connLogger)
+	<-serving
 
 	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
 	defer cancel()
@@ -2,9 +2,9 @@ package lsp
 
 import (
 	"encoding/json"
-	"fmt"
 	"sync"
 
+	"github.com/sourcegraph/jsonrpc2"
 	"github.com/two-hundred/ls-builder/common"
 )
 
@@ -66,6 +66,7 @@ type Handler struct {
 	semanticTokensFull         SemanticTokensFullHandlerFunc
 	semanticTokensFullDelta    SemanticTokensFullDeltaHandlerFunc
 	semanticTokensRange        SemanticTokensRangeHandlerFunc
+	semanticTokensProvider     *SemanticTokensProvider
 	inlayHint                  InlayHintHandlerFunc
 	inlayHintResolve           InlayHintResolveHandlerFunc
 	inlineValue                InlineValueHandlerFunc
@@ -103,12 +104,34 @@ type Handler struct {
 	// Window Features
 	windowWorkDoneProgressCancel WindowWorkDoneProgressCancelHandler
 
-	isInitialized bool
+	// Optional store of open text documents that is kept up to date
+	// before text document synchronisation handlers are called.
+	documentStore      *DocumentStore
+	diagnosticsService *DiagnosticsService
+
+	// The position encodings supported by the server in order of preference
+	// and the position encoding negotiated with the client during initialisation.
+	positionEncodingPreferences []PositionEncodingKind
+	positionEncodingKind        PositionEncodingKind
+
+	// Information about the client captured from the initialize request.
+	clientState *ClientState
+
+	// Optional registration manager that determines which capabilities
+	// are registered dynamically instead of in the server capabilities.
+	registrationManager *RegistrationManager
+
+	lifecycleState      LifecycleState
+	exitedAfterShutdown bool
 	// Provides a mapping of method names to the respective handlers
 	// that are wrappers around the user-provided handler functions that will unmarshal params
 	// and optionally set some state before calling the user-provided handler.
 	messageHandlers map[string]common.Handler
-	mu              sync.Mutex
+	// Middleware that wraps the dispatching of every message
+	// along with the handler composed from the middleware.
+	middleware        []Middleware
+	middlewareHandler common.Handler
+	mu                sync.Mutex
 }
 
 // HandlerOption is a function that can be used to configure a handler
@@ -252,12 +275,35 @@ func WithWindowWorkDoneProgressCancelHandler(handler WindowWorkDoneProgressCance
 	}
 }
 
+// WithDocumentStore attaches a document store to the handler that will be
+// kept up to date with the `textDocument/didOpen`, `textDocument/didChange`
+// and `textDocument/didClose` notifications.
+func WithDocumentStore(store *DocumentStore) HandlerOption {
+	return func(root *Handler) {
+		root.SetDocumentStore(store)
+	}
+}
+
+// WithDiagnosticsService attaches a diagnostics service to the handler that will
+// be notified when documents are opened, changed and closed and will handle
+// the `textDocument/diagnostic` and `workspace/diagnostic` requests.
+func WithDiagnosticsService(service *DiagnosticsService) HandlerOption {
+	return func(root *Handler) {
+		root.SetDiagnosticsService(service)
+	}
+}
+
 // NewHandler creates a new instance of a handler, optionally,
 // with a provided set of method handlers.
 func NewHandler(opts ...HandlerOption) *Handler {
 	h := &Handler{
-		messageHandlers: make(map[string]common.Handler),
+		messageHandlers:      make(map[string]common.Handler),
+		positionEncodingKind: PositionEncodingKindUTF16,
 	}
+	// Shutdown and exit must always be handled to track the lifecycle
+	// of the connection, even when no handlers are provided for them.
+	h.messageHandlers[MethodShutdown] = createShutdownHandler(h)
+	h.messageHandlers[MethodExit] = createExitHandler(h)
 	for _, opt := range opts {
 		opt(h)
 	}
@@ -419,10 +465,94 @@ func (h *Handler) SetWindowWorkDoneProgressCancelHandler(handler WindowWorkDoneP
 	h.messageHandlers[MethodWorkDoneProgressCancel] = createWindowWorkDoneProgressCancelHandler(h)
 }
 
+// SetMessageHandler sets the handler for a message that does not have a typed
+// handler in this package, such as a message introduced in a later version
+// of the protocol, replacing the typed handler if there is one for the method.
+// The provided handler is subject to the same lifecycle rules as typed handlers
+// and the LSP context passed to it carries the client state and position encoding.
+func (h *Handler) SetMessageHandler(method string, handler common.Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messageHandlers[method] = handler
+}
+
+// SetDocumentStore attaches a document store to the handler that will be
+// kept up to date with the `textDocument/didOpen`, `textDocument/didChange`
+// and `textDocument/didClose` notifications.
+// The store is updated before the user-provided handlers for these notifications
+// are called, so handlers can read the latest snapshot of a document from the store.
+// An attached diagnostics service is rebound to validate documents from the store
+// and an attached semantic tokens provider is rebound to retrieve documents from the store.
+// When the server has been initialised, the store is set to use the position encoding
+// negotiated with the client.
+func (h *Handler) SetDocumentStore(store *DocumentStore) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.documentStore = store
+	if store != nil && h.lifecycleState >= LifecycleStateInitialized {
+		store.SetPositionEncodingKind(h.positionEncodingKind)
+	}
+	h.messageHandlers[MethodTextDocumentDidOpen] = createSetTextDocumentDidOpenHandler(h)
+	h.messageHandlers[MethodTextDocumentDidChange] = createTextDocumentDidChangeHandler(h)
+	h.messageHandlers[MethodTextDocumentDidClose] = createTextDocumentDidCloseHandler(h)
+	if h.diagnosticsService != nil {
+		h.diagnosticsService.setStore(store)
+	}
+	if h.semanticTokensProvider != nil {
+		h.semanticTokensProvider.setStore(store)
+	}
+}
+
+// SetDiagnosticsService attaches a diagnostics service to the handler that will
+// be notified when documents are opened, changed and closed and will handle
+// the `textDocument/diagnostic` and `workspace/diagnostic` requests.
+// The document store of the service is attached to the handler when the handler
+// does not already have a document store, otherwise the service is rebound to
+// validate documents from the handler's document store as that is the store
+// that is kept in sync with the client.
+func (h *Handler) SetDiagnosticsService(service *DiagnosticsService) {
+	if store := h.DocumentStore(); store == nil {
+		h.SetDocumentStore(service.Store())
+	} else {
+		service.setStore(store)
+	}
+	h.SetDocumentDiagnosticsHandler(service.DocumentDiagnostics)
+	h.SetWorkspaceDiagnosticHandler(service.WorkspaceDiagnostics)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.diagnosticsService = service
+}
+
+// DocumentStore returns the document store attached to the handler,
+// this will be nil if a document store has not been attached.
+func (h *Handler) DocumentStore() *DocumentStore {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.documentStore
+}
+
 // Fulfils the common.Handler interface.
+//
+// Messages are handled according to the lifecycle of the connection,
+// requests received before initialisation are responded to with a
+// `ServerNotInitialized` error, requests received after a shutdown request
+// are responded to with an `InvalidRequest` error and notifications other
+// than `exit` are dropped when the server is not initialized.
+//
+// Messages are passed through the middleware registered with `Use`
+// before being dispatched to the message handlers.
 func (h *Handler) Handle(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
-	if !h.IsInitialized() && ctx.Method != MethodInitialize {
-		return nil, true, true, fmt.Errorf("server is not initialized")
+	withPositionEncodingKind(ctx, h.PositionEncodingKind())
+	withClientState(ctx, h.ClientState())
+
+	return h.composedHandler().Handle(ctx)
+}
+
+func (h *Handler) dispatch(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+	canHandle, err := h.checkLifecycleState(ctx)
+	if !canHandle {
+		return nil, true, true, err
 	}
 
 	messageHandler, hasHandler := h.messageHandlers[ctx.Method]
@@ -435,10 +565,9 @@ func (h *Handler) Handle(ctx *common.LSPContext) (r any, validMethod bool, valid
 
 // IsInitialized returns whether or not the connection to the client
 // has been initialized as per "Lifecycle Messages" of the LSP specification.
+// This is false once the server has received a shutdown request.
 func (h *Handler) IsInitialized() bool {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	return h.isInitialized
+	return h.LifecycleState() == LifecycleStateInitialized
 }
 
 // SetInitialized sets the initialized state of the connection to the client
@@ -446,7 +575,11 @@ func (h *Handler) IsInitialized() bool {
 func (h *Handler) SetInitialized(initialized bool) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.isInitialized = initialized
+	if initialized {
+		h.lifecycleState = LifecycleStateInitialized
+	} else {
+		h.lifecycleState = LifecycleStateUninitialized
+	}
 }
 
 func createCancelRequestHandler(root *Handler) common.Handler {
@@ -495,8 +628,25 @@ func createInitializeHandler(root *Handler) common.Handler {
 				var params InitializeParams
 				if err = json.Unmarshal(ctx.Params, &params); err == nil {
 					validParams = true
+					if !root.transitionLifecycleState(LifecycleStateUninitialized, LifecycleStateInitializing) {
+						err = &jsonrpc2.Error{
+							Code:    jsonrpc2.CodeInvalidRequest,
+							Message: "server has already been initialized",
+						}
+						return
+					}
+					clientState := NewClientState(&params)
+					root.setClientState(clientState)
+					withClientState(ctx, clientState)
+					negotiated := root.negotiatePositionEncoding(&params)
+					withPositionEncodingKind(ctx, negotiated)
 					if r, err = root.initialize(ctx, &params); err == nil {
-						root.SetInitialized(true)
+						var posEncodingKind PositionEncodingKind
+						r, posEncodingKind = applyPositionEncoding(r, negotiated)
+						root.setPositionEncodingKind(posEncodingKind)
+						root.transitionLifecycleState(LifecycleStateInitializing, LifecycleStateInitialized)
+					} else {
+						root.transitionLifecycleState(LifecycleStateInitializing, LifecycleStateUninitialized)
 					}
 				}
 			}
@@ -546,10 +696,10 @@ func createShutdownHandler(root *Handler) common.Handler {
 		func(
 			ctx *common.LSPContext,
 		) (r any, validMethod bool, validParams bool, err error) {
-			root.SetInitialized(false)
+			validMethod = true
+			validParams = true
+			root.transitionLifecycleState(LifecycleStateInitialized, LifecycleStateShuttingDown)
 			if root.shutdown != nil {
-				validMethod = true
-				validParams = true
 				err = root.shutdown(ctx)
 			}
 			return
@@ -564,9 +714,10 @@ func createExitHandler(root *Handler) common.Handler {
 		) (r any, validMethod bool, validParams bool, err error) {
 			// Note that the server will close the
 			// connection after we handle it here.
+			validMethod = true
+			validParams = true
+			root.setExited()
 			if root.exit != nil {
-				validMethod = true
-				validParams = true
 				err = root.exit(ctx)
 			}
 			return
@@ -579,12 +730,20 @@ func createSetTextDocumentDidOpenHandler(root *Handler) common.Handler {
 		func(
 			ctx *common.LSPContext,
 		) (r any, validMethod bool, validParams bool, err error) {
-			if root.textDocumentDidOpen != nil {
+			if root.textDocumentDidOpen != nil || root.documentStore != nil {
 				validMethod = true
 				var params DidOpenTextDocumentParams
 				if err = json.Unmarshal(ctx.Params, &params); err == nil {
 					validParams = true
-					err = root.textDocumentDidOpen(ctx, &params)
+					if root.documentStore != nil {
+						root.documentStore.Open(&params)
+					}
+					if root.textDocumentDidOpen != nil {
+						err = root.textDocumentDidOpen(ctx, &params)
+					}
+					if root.diagnosticsService != nil {
+						root.diagnosticsService.DocumentOpened(ctx, params.TextDocument.URI)
+					}
 				}
 			}
 			return
@@ -597,12 +756,22 @@ func createTextDocumentDidChangeHandler(root *Handler) common.Handler {
 		func(
 			ctx *common.LSPContext,
 		) (r any, validMethod bool, validParams bool, err error) {
-			if root.textDocumentDidChange != nil {
+			if root.textDocumentDidChange != nil || root.documentStore != nil {
 				validMethod = true
 				var params DidChangeTextDocumentParams
 				if err = json.Unmarshal(ctx.Params, &params); err == nil {
 					validParams = true
-					err = root.textDocumentDidChange(ctx, &params)
+					if root.documentStore != nil {
+						if _, err = root.documentStore.Change(&params); err != nil {
+							return
+						}
+					}
+					if root.textDocumentDidChange != nil {
+						err = root.textDocumentDidChange(ctx, &params)
+					}
+					if root.diagnosticsService != nil {
+						root.diagnosticsService.DocumentChanged(ctx, params.TextDocument.URI)
+					}
 				}
 			}
 			return
@@ -669,12 +838,23 @@ func createTextDocumentDidCloseHandler(root *Handler) common.Handler {
 		func(
 			ctx *common.LSPContext,
 		) (r any, validMethod bool, validParams bool, err error) {
-			if root.textDocumentDidClose != nil {
+			if root.textDocumentDidClose != nil || root.documentStore != nil {
 				validMethod = true
 				var params DidCloseTextDocumentParams
 				if err = json.Unmarshal(ctx.Params, &params); err == nil {
 					validParams = true
-					err = root.textDocumentDidClose(ctx, &params)
+					if root.documentStore != nil {
+						root.documentStore.Close(&params)
+					}
+					if root.textDocumentDidClose != nil {
+						err = root.textDocumentDidClose(ctx, &params)
+					}
+					if root.diagnosticsService != nil {
+						root.diagnosticsService.DocumentClosed(ctx, params.TextDocument.URI)
+					}
+					if root.semanticTokensProvider != nil {
+						root.semanticTokensProvider.Cache().Delete(params.TextDocument.URI)
+					}
 				}
 			}
 			return
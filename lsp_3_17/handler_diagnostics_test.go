@@ -2,7 +2,9 @@ package lsp
 
 import (
 	"context"
+	"encoding/json"
 
+	"github.com/sourcegraph/jsonrpc2"
 	"github.com/two-hundred/ls-builder/common"
 	"github.com/two-hundred/ls-builder/server"
 	"go.uber.org/zap"
@@ -375,3 +377,65 @@ func (s *HandlerTestSuite) Test_calls_workspace_diagnostics_request_handler() {
 	s.Require().NoError(err)
 	s.Require().Equal(diagnostics, returnedDiagnostics)
 }
+
+func (s *HandlerTestSuite) Test_document_diagnostics_request_handler_responds_with_server_cancellation() {
+	logger, err := zap.NewDevelopment()
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	serverCancelled := "ServerCancelled"
+	tests := []error{
+		NewServerCancelledError(
+			"diagnostics computation was cancelled",
+			&DiagnosticServerCancellationData{RetriggerRequest: true},
+		),
+		&ErrorWithData{
+			Code:    &IntOrString{StrVal: &serverCancelled},
+			Data:    &DiagnosticServerCancellationData{RetriggerRequest: true},
+			Message: "diagnostics computation was cancelled",
+		},
+	}
+
+	for _, handlerErr := range tests {
+		serverHandler := NewHandler(
+			WithDocumentDiagnosticsHandler(
+				func(ctx *common.LSPContext, params *DocumentDiagnosticParams) (any, error) {
+					return nil, handlerErr
+				},
+			),
+		)
+
+		// Emulate the LSP initialisation process.
+		serverHandler.SetInitialized(true)
+		srv := server.NewServer(serverHandler, true, nil, nil)
+
+		container := createTestConnectionsContainer(srv.NewHandler())
+
+		go srv.Serve(container.serverConn, logger)
+
+		clientLSPContext := server.NewLSPContext(ctx, container.clientConn, nil)
+
+		diagnosticParams := DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{
+				URI: "file:///test_document.go",
+			},
+		}
+		returnedDiagnostics := RelatedFullDocumentDiagnosticReport{}
+		err = clientLSPContext.Call(
+			MethodDocumentDiagnostic,
+			diagnosticParams,
+			&returnedDiagnostics,
+		)
+		jsonrpcErr, isJSONRPCErr := err.(*jsonrpc2.Error)
+		s.Require().True(isJSONRPCErr)
+		s.Require().Equal(CodeServerCancelled, jsonrpcErr.Code)
+		s.Require().Equal("diagnostics computation was cancelled", jsonrpcErr.Message)
+		s.Require().NotNil(jsonrpcErr.Data)
+
+		data := DiagnosticServerCancellationData{}
+		s.Require().NoError(json.Unmarshal(*jsonrpcErr.Data, &data))
+		s.Require().True(data.RetriggerRequest)
+	}
+}
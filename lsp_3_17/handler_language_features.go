@@ -167,6 +167,15 @@ func WithSemanticTokensRangeHandler(handler SemanticTokensRangeHandlerFunc) Hand
 	}
 }
 
+// WithSemanticTokensProvider sets the handlers for the `textDocument/semanticTokens/full`,
+// `textDocument/semanticTokens/full/delta` and `textDocument/semanticTokens/range` requests
+// to those implemented by the provider.
+func WithSemanticTokensProvider(provider *SemanticTokensProvider) HandlerOption {
+	return func(root *Handler) {
+		root.SetSemanticTokensProvider(provider)
+	}
+}
+
 // WithInlayHintHandler sets the handler for the `textDocument/inlayHint` request.
 func WithInlayHintHandler(handler InlayHintHandlerFunc) HandlerOption {
 	return func(root *Handler) {
@@ -491,6 +500,32 @@ func (h *Handler) SetSemanticTokensRangeHandler(handler SemanticTokensRangeHandl
 	h.messageHandlers[MethodSemanticTokensRange] = createSemanticTokensRangeHandler(h)
 }
 
+// SetSemanticTokensProvider sets the handlers for the `textDocument/semanticTokens/full`,
+// `textDocument/semanticTokens/full/delta` and `textDocument/semanticTokens/range` requests
+// to those implemented by the provider.
+// The legend derived from the provider's registry is included in the server capabilities
+// created with `CreateServerCapabilities`.
+//
+// The document store of the provider is attached to the handler when the handler
+// does not already have a document store, otherwise the provider is rebound to
+// retrieve documents from the handler's document store as that is the store
+// that is kept in sync with the client.
+// The results cached by the provider for a document are removed when the document is closed.
+func (h *Handler) SetSemanticTokensProvider(provider *SemanticTokensProvider) {
+	if store := h.DocumentStore(); store == nil {
+		h.SetDocumentStore(provider.Store())
+	} else {
+		provider.setStore(store)
+	}
+	h.SetSemanticTokensFullHandler(provider.Full)
+	h.SetSemanticTokensFullDeltaHandler(provider.FullDelta)
+	h.SetSemanticTokensRangeHandler(provider.Range)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.semanticTokensProvider = provider
+}
+
 // SetInlayHintHandler sets the handler for the `textDocument/inlayHint` request.
 func (h *Handler) SetInlayHintHandler(handler InlayHintHandlerFunc) {
 	h.mu.Lock()
@@ -1140,6 +1175,7 @@ func createCompletionHandler(root *Handler) common.Handler {
 				if err = json.Unmarshal(ctx.Params, &params); err == nil {
 					validParams = true
 					r, err = root.completion(ctx, &params)
+					r = downgradeSnippetsForClient(ctx, r)
 				}
 			}
 			return
@@ -1158,6 +1194,7 @@ func createCompletionItemResolveHandler(root *Handler) common.Handler {
 				if err = json.Unmarshal(ctx.Params, &params); err == nil {
 					validParams = true
 					r, err = root.completionItemResolve(ctx, &params)
+					r = downgradeSnippetsForClient(ctx, r)
 				}
 			}
 			return
@@ -1165,6 +1202,16 @@ func createCompletionItemResolveHandler(root *Handler) common.Handler {
 	)
 }
 
+// downgradeSnippetsForClient converts snippets in completion results
+// to plain text when the client does not support snippets.
+func downgradeSnippetsForClient(ctx *common.LSPContext, result any) any {
+	clientState := ClientStateFromContext(ctx)
+	if clientState == nil || clientState.SupportsSnippets() {
+		return result
+	}
+	return DowngradeCompletionSnippets(result)
+}
+
 func createDocumentDiagnosticsHandler(root *Handler) common.Handler {
 	return common.HandlerFunc(
 		func(
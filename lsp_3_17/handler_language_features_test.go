@@ -1321,7 +1321,7 @@ func (s *HandlerTestSuite) Test_calls_semantic_tokens_full_delta_request_handler
 	}
 	serverHandler := NewHandler(
 		WithSemanticTokensFullDeltaHandler(
-			func(ctx *common.LSPContext, params *SemanticTokensDeltaParams) (*SemanticTokensDelta, error) {
+			func(ctx *common.LSPContext, params *SemanticTokensDeltaParams) (any, error) {
 				return &semanticTokensDelta, nil
 			},
 		),
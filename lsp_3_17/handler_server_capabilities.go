@@ -6,6 +6,8 @@ package lsp
 // that can be modified before being sent to the client.
 // All handlers that are not dynamically registered must be set
 // before calling this method.
+// Capabilities for methods that are registered dynamically with
+// the handler's registration manager are left out.
 //
 // For notebook synchronisation events, the server capabilities
 // need to be set with notebook selectors to indicate which
@@ -20,6 +22,7 @@ func (h *Handler) CreateServerCapabilities() ServerCapabilities {
 	h.applyLanguageFeaturesSet1Capabilities(&capabilities)
 	h.applyLanguageFeaturesSet2Capabilities(&capabilities)
 	h.applyWorkspaceFeaturesCapabilities(&capabilities)
+	h.removeDynamicallyRegisteredCapabilities(&capabilities)
 
 	return capabilities
 }
@@ -29,12 +32,13 @@ var (
 )
 
 func (h *Handler) applyTextDocumentSyncCapabilities(capabilities *ServerCapabilities) {
-	if (h.textDocumentDidOpen != nil) && (h.textDocumentDidClose != nil) {
+	hasDocumentStore := h.documentStore != nil
+	if ((h.textDocumentDidOpen != nil) && (h.textDocumentDidClose != nil)) || hasDocumentStore {
 		prepareEmptyTextDocumentSyncOptions(capabilities)
 		capabilities.TextDocumentSync.(*TextDocumentSyncOptions).OpenClose = &True
 	}
 
-	if h.textDocumentDidChange != nil {
+	if h.textDocumentDidChange != nil || hasDocumentStore {
 		prepareEmptyTextDocumentSyncOptions(capabilities)
 		incremental := TextDocumentSyncKindIncremental
 		capabilities.TextDocumentSync.(*TextDocumentSyncOptions).Change = &incremental
@@ -159,6 +163,12 @@ func (h *Handler) applyLanguageFeaturesSet2Capabilities(capabilities *ServerCapa
 		capabilities.SemanticTokensProvider.(*SemanticTokensOptions).Range = true
 	}
 
+	if h.semanticTokensProvider != nil {
+		prepareEmptySemanticTokensProvider(capabilities)
+		capabilities.SemanticTokensProvider.(*SemanticTokensOptions).Legend =
+			h.semanticTokensProvider.Registry().Legend()
+	}
+
 	if h.inlineValue != nil {
 		capabilities.InlineValueProvider = true
 	}
@@ -175,6 +185,10 @@ func (h *Handler) applyLanguageFeaturesSet2Capabilities(capabilities *ServerCapa
 		capabilities.DiagnosticProvider = true
 	}
 
+	if h.diagnosticsService != nil {
+		capabilities.DiagnosticProvider = h.diagnosticsService.Options()
+	}
+
 	if h.codeAction != nil {
 		capabilities.CodeActionProvider = true
 	}
@@ -269,3 +283,194 @@ func prepareEmptyWorkspaceCapabilities(capabilities *ServerCapabilities) {
 		}
 	}
 }
+
+func (h *Handler) removeDynamicallyRegisteredCapabilities(capabilities *ServerCapabilities) {
+	h.mu.Lock()
+	registrationManager := h.registrationManager
+	clientState := h.clientState
+	h.mu.Unlock()
+
+	if registrationManager == nil {
+		return
+	}
+
+	for _, method := range dynamicallyRegisterableMethods {
+		if registrationManager.RegistersDynamically(method, clientState) {
+			removeServerCapability(capabilities, method)
+		}
+	}
+}
+
+var dynamicallyRegisterableMethods = []string{
+	MethodTextDocumentDidOpen,
+	MethodTextDocumentDidChange,
+	MethodTextDocumentDidClose,
+	MethodTextDocumentWillSave,
+	MethodTextDocumentWillSaveWaitUntil,
+	MethodTextDocumentDidSave,
+	MethodCompletion,
+	MethodHover,
+	MethodSignatureHelp,
+	MethodGotoDeclaration,
+	MethodGotoDefinition,
+	MethodGotoTypeDefinition,
+	MethodGotoImplementation,
+	MethodFindReferences,
+	MethodDocumentHighlight,
+	MethodDocumentSymbol,
+	MethodCodeAction,
+	MethodCodeLens,
+	MethodDocumentLink,
+	MethodDocumentColor,
+	MethodDocumentFormatting,
+	MethodDocumentRangeFormatting,
+	MethodDocumentOnTypeFormatting,
+	MethodDocumentRename,
+	MethodFoldingRange,
+	MethodSelectionRange,
+	MethodDocumentLinkedEditingRange,
+	MethodPrepareCallHierarchy,
+	MethodSemanticTokens,
+	MethodMoniker,
+	MethodPrepareTypeHierarchy,
+	MethodInlineValue,
+	MethodInlayHint,
+	MethodDocumentDiagnostic,
+	MethodWorkspaceSymbol,
+	MethodWorkspaceExecuteCommand,
+	MethodWorkspaceDidCreateFiles,
+	MethodWorkspaceWillCreateFiles,
+	MethodWorkspaceDidRenameFiles,
+	MethodWorkspaceWillRenameFiles,
+	MethodWorkspaceDidDeleteFiles,
+	MethodWorkspaceWillDeleteFiles,
+}
+
+func removeServerCapability(capabilities *ServerCapabilities, method string) {
+	if removeTextDocumentSyncCapability(capabilities, method) ||
+		removeFileOperationCapability(capabilities, method) {
+		return
+	}
+
+	switch method {
+	case MethodCompletion:
+		capabilities.CompletionProvider = nil
+	case MethodHover:
+		capabilities.HoverProvider = nil
+	case MethodSignatureHelp:
+		capabilities.SignatureHelpProvider = nil
+	case MethodGotoDeclaration:
+		capabilities.DeclarationProvider = nil
+	case MethodGotoDefinition:
+		capabilities.DefinitionProvider = nil
+	case MethodGotoTypeDefinition:
+		capabilities.TypeDefinitionProvider = nil
+	case MethodGotoImplementation:
+		capabilities.ImplementationProvider = nil
+	case MethodFindReferences:
+		capabilities.ReferencesProvider = nil
+	case MethodDocumentHighlight:
+		capabilities.DocumentHighlightProvider = nil
+	case MethodDocumentSymbol:
+		capabilities.DocumentSymbolProvider = nil
+	case MethodCodeAction:
+		capabilities.CodeActionProvider = nil
+	case MethodCodeLens:
+		capabilities.CodeLensProvider = nil
+	case MethodDocumentLink:
+		capabilities.DocumentLinkProvider = nil
+	case MethodDocumentColor:
+		capabilities.ColorProvider = nil
+	case MethodDocumentFormatting:
+		capabilities.DocumentFormattingProvider = nil
+	case MethodDocumentRangeFormatting:
+		capabilities.DocumentRangeFormattingProvider = nil
+	case MethodDocumentOnTypeFormatting:
+		capabilities.DocumentOnTypeFormattingProvider = nil
+	case MethodDocumentRename:
+		capabilities.RenameProvider = nil
+	case MethodFoldingRange:
+		capabilities.FoldingRangeProvider = nil
+	case MethodSelectionRange:
+		capabilities.SelectionRangeProvider = nil
+	case MethodDocumentLinkedEditingRange:
+		capabilities.LinkedEditingRangeProvider = nil
+	case MethodPrepareCallHierarchy:
+		capabilities.CallHierarchyProvider = nil
+	case MethodSemanticTokens:
+		capabilities.SemanticTokensProvider = nil
+	case MethodMoniker:
+		capabilities.MonikerProvider = nil
+	case MethodPrepareTypeHierarchy:
+		capabilities.TypeHierarchyProvider = nil
+	case MethodInlineValue:
+		capabilities.InlineValueProvider = nil
+	case MethodInlayHint:
+		capabilities.InlayHintProvider = nil
+	case MethodDocumentDiagnostic:
+		capabilities.DiagnosticProvider = nil
+	case MethodWorkspaceSymbol:
+		capabilities.WorkspaceSymbolProvider = nil
+	case MethodWorkspaceExecuteCommand:
+		capabilities.ExecuteCommandProvider = nil
+	}
+}
+
+func removeTextDocumentSyncCapability(capabilities *ServerCapabilities, method string) bool {
+	syncOptions, hasSyncOptions := capabilities.TextDocumentSync.(*TextDocumentSyncOptions)
+
+	switch method {
+	case MethodTextDocumentDidOpen, MethodTextDocumentDidClose:
+		if hasSyncOptions {
+			syncOptions.OpenClose = nil
+		}
+	case MethodTextDocumentDidChange:
+		if hasSyncOptions {
+			syncOptions.Change = nil
+		}
+	case MethodTextDocumentWillSave:
+		if hasSyncOptions {
+			syncOptions.WillSave = nil
+		}
+	case MethodTextDocumentWillSaveWaitUntil:
+		if hasSyncOptions {
+			syncOptions.WillSaveWaitUntil = nil
+		}
+	case MethodTextDocumentDidSave:
+		if hasSyncOptions {
+			syncOptions.Save = nil
+		}
+	default:
+		return false
+	}
+
+	return true
+}
+
+func removeFileOperationCapability(capabilities *ServerCapabilities, method string) bool {
+	var fileOperations *WorkspaceFileOperationServerCapabilities
+	if capabilities.Workspace != nil && capabilities.Workspace.FileOperations != nil {
+		fileOperations = capabilities.Workspace.FileOperations
+	} else {
+		fileOperations = &WorkspaceFileOperationServerCapabilities{}
+	}
+
+	switch method {
+	case MethodWorkspaceDidCreateFiles:
+		fileOperations.DidCreate = nil
+	case MethodWorkspaceWillCreateFiles:
+		fileOperations.WillCreate = nil
+	case MethodWorkspaceDidRenameFiles:
+		fileOperations.DidRename = nil
+	case MethodWorkspaceWillRenameFiles:
+		fileOperations.WillRename = nil
+	case MethodWorkspaceDidDeleteFiles:
+		fileOperations.DidDelete = nil
+	case MethodWorkspaceWillDeleteFiles:
+		fileOperations.WillDelete = nil
+	default:
+		return false
+	}
+
+	return true
+}
@@ -134,7 +134,7 @@ func (s *HandlerServerCapabilitiesTestSuite) Test_create_server_capabilities() {
 			},
 		),
 		WithSemanticTokensFullDeltaHandler(
-			func(ctx *common.LSPContext, params *SemanticTokensDeltaParams) (*SemanticTokensDelta, error) {
+			func(ctx *common.LSPContext, params *SemanticTokensDeltaParams) (any, error) {
 				return nil, nil
 			},
 		),
@@ -863,6 +863,30 @@ func (s *HandlerTestSuite) Test_calls_window_work_done_progress_cancel_handler()
 	}
 }
 
+func (s *HandlerTestSuite) Test_calls_message_handler_set_for_method_without_typed_handler() {
+	serverHandler := NewHandler()
+	serverHandler.SetMessageHandler(
+		"custom/method",
+		common.HandlerFunc(
+			func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+				return "custom result", true, true, nil
+			},
+		),
+	)
+
+	// Message handlers are subject to the lifecycle of the connection.
+	_, validMethod, _, err := serverHandler.Handle(&common.LSPContext{Method: "custom/method"})
+	s.Require().True(validMethod)
+	s.Require().Error(err)
+
+	serverHandler.SetInitialized(true)
+	result, validMethod, validParams, err := serverHandler.Handle(&common.LSPContext{Method: "custom/method"})
+	s.Require().NoError(err)
+	s.Require().True(validMethod)
+	s.Require().True(validParams)
+	s.Require().Equal("custom result", result)
+}
+
 func TestHandlerTestSuite(t *testing.T) {
 	suite.Run(t, new(HandlerTestSuite))
 }
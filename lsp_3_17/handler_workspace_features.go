@@ -250,6 +250,9 @@ func createWorkspaceDidChangeFoldersHandler(root *Handler) common.Handler {
 				var params DidChangeWorkspaceFoldersParams
 				if err = json.Unmarshal(ctx.Params, &params); err == nil {
 					validParams = true
+					if clientState := root.ClientState(); clientState != nil {
+						clientState.ApplyWorkspaceFoldersChange(params.Event)
+					}
 					err = root.workspaceDidChangeFolders(ctx, &params)
 				}
 			}
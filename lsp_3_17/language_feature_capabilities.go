@@ -914,6 +914,13 @@ type CompletionOptions struct {
 	CompletionItem *CompletionOptionsItem `json:"completionItem,omitempty"`
 }
 
+// CompletionRegistrationOptions provides server capability registration
+// options for completion requests.
+type CompletionRegistrationOptions struct {
+	TextDocumentRegistrationOptions
+	CompletionOptions
+}
+
 // CompletionItemOptions provides server capability options for completion items.
 type CompletionOptionsItem struct {
 	// The server has support for completion item label
@@ -745,6 +745,10 @@ type SymbolInformation struct {
 
 // https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#textDocument_semanticTokens
 
+// MethodSemanticTokens is the method used to dynamically register
+// semantic token support with the client, it is not sent as a request.
+const MethodSemanticTokens = Method("textDocument/semanticTokens")
+
 const MethodSemanticTokensFull = Method("textDocument/semanticTokens/full")
 
 // SemanticTokensFullHandlerFunc is the function signature for the textDocument/semanticTokens/full
@@ -779,10 +783,12 @@ const MethodSemanticTokensFullDelta = Method("textDocument/semanticTokens/full/d
 
 // SemanticTokensFullDeltaHandlerFunc is the function signature for the textDocument/semanticTokens/full/delta
 // request handler that can be registered for a language server.
+//
+// Returns: *SemanticTokensDelta | *SemanticTokens | nil
 type SemanticTokensFullDeltaHandlerFunc func(
 	ctx *common.LSPContext,
 	params *SemanticTokensDeltaParams,
-) (*SemanticTokensDelta, error)
+) (any, error)
 
 // SemanticTokensDeltaParams contains the textDocument/semanticTokens/full/delta request parameters.
 type SemanticTokensDeltaParams struct {
@@ -1902,18 +1908,17 @@ const MethodDocumentDiagnostic = Method("textDocument/diagnostic")
 // | DocumentDiagnosticReportPartialResult
 //
 // Note: When returning a server cancellation error response (@since 3.17.0),
-// an instance of the `ErrorWithData` error type should be returned containing the
-// `ServerCancelled` code and the data of the `DiagnosticServerCancellationData` type.
+// an error with the `ServerCancelled` code and the data of the `DiagnosticServerCancellationData`
+// type should be returned.
 //
 // For example:
 //
-//	serverCancelled := "ServerCancelled"
-//	return nil, &ErrorWithData{
-//		Code: &IntOrString{ StrVal: &serverCancelled },
-//		Data: &DiagnosticServerCancellationData{
+//	return nil, NewServerCancelledError(
+//		"diagnostics computation was cancelled",
+//		&DiagnosticServerCancellationData{
 //			RetriggerRequest: false,
 //		},
-//	}
+//	)
 type DocumentDiagnosticHandlerFunc func(
 	ctx *common.LSPContext,
 	params *DocumentDiagnosticParams,
@@ -1932,6 +1937,15 @@ type DocumentDiagnosticParams struct {
 	PreviousResultID *string `json:"previousResultId,omitempty"`
 }
 
+// DiagnosticServerCancellationData is the data sent with a `ServerCancelled`
+// error in response to a diagnostic request.
+//
+// @since 3.17.0
+type DiagnosticServerCancellationData struct {
+	// Whether the client should re-trigger the request.
+	RetriggerRequest bool `json:"retriggerRequest"`
+}
+
 // FullDocumentDiagnosticReport is a diagnostic report with a
 // full set of problems.
 //
@@ -2171,18 +2185,17 @@ const MethodWorkspaceDiagnostic = Method("workspace/diagnostic")
 // partial or full results in the response to the client.
 //
 // Note: When returning a server cancellation error response (@since 3.17.0),
-// an instance of the `ErrorWithData` error type should be returned containing the
-// `ServerCancelled` code and the data of the `DiagnosticServerCancellationData` type.
+// an error with the `ServerCancelled` code and the data of the `DiagnosticServerCancellationData`
+// type should be returned.
 //
 // For example:
 //
-//	serverCancelled := "ServerCancelled"
-//	return nil, &ErrorWithData{
-//		Code: &IntOrString{ StrVal: &serverCancelled },
-//		Data: &DiagnosticServerCancellationData{
+//	return nil, NewServerCancelledError(
+//		"diagnostics computation was cancelled",
+//		&DiagnosticServerCancellationData{
 //			RetriggerRequest: false,
 //		},
-//	}
+//	)
 type WorkspaceDiagnosticHandlerFunc func(
 	ctx *common.LSPContext,
 	params *WorkspaceDiagnosticParams,
@@ -0,0 +1,109 @@
+package lsp
+
+import (
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/two-hundred/ls-builder/common"
+)
+
+// LifecycleState represents the state of the connection to the client
+// as per "Lifecycle Messages" of the LSP specification.
+type LifecycleState int
+
+const (
+	// LifecycleStateUninitialized is the state before the server
+	// has successfully handled an `initialize` request.
+	LifecycleStateUninitialized LifecycleState = iota
+
+	// LifecycleStateInitializing is the state while the server
+	// is handling an `initialize` request.
+	LifecycleStateInitializing
+
+	// LifecycleStateInitialized is the state after the server has
+	// successfully handled an `initialize` request, this is the only state
+	// where requests other than `initialize` and `shutdown` are handled.
+	LifecycleStateInitialized
+
+	// LifecycleStateShuttingDown is the state after the server has received
+	// a `shutdown` request and is waiting for the `exit` notification.
+	LifecycleStateShuttingDown
+
+	// LifecycleStateExited is the state after the server has received
+	// an `exit` notification.
+	LifecycleStateExited
+)
+
+// LifecycleState returns the current state of the connection to the client.
+func (h *Handler) LifecycleState() LifecycleState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lifecycleState
+}
+
+// ExitCode returns the exit code the server process should exit with
+// after receiving an `exit` notification.
+// As per the specification, this is 0 if the server received a `shutdown`
+// request before the `exit` notification and 1 otherwise.
+func (h *Handler) ExitCode() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.exitedAfterShutdown {
+		return 0
+	}
+	return 1
+}
+
+// Moves the lifecycle state from one state to another,
+// returning false if the current state is not the expected state.
+func (h *Handler) transitionLifecycleState(from LifecycleState, to LifecycleState) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.lifecycleState != from {
+		return false
+	}
+	h.lifecycleState = to
+	return true
+}
+
+func (h *Handler) setExited() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.exitedAfterShutdown = h.lifecycleState == LifecycleStateShuttingDown
+	h.lifecycleState = LifecycleStateExited
+}
+
+// Determines whether a message can be handled in the current lifecycle state,
+// producing the error to respond with for requests that can not be handled.
+// Notifications that can not be handled are dropped.
+func (h *Handler) checkLifecycleState(ctx *common.LSPContext) (canHandle bool, err error) {
+	state := h.LifecycleState()
+	if ctx.Method == MethodExit {
+		return state != LifecycleStateExited, nil
+	}
+
+	if ctx.IsNotification {
+		return state == LifecycleStateInitialized, nil
+	}
+
+	switch state {
+	case LifecycleStateUninitialized, LifecycleStateInitializing:
+		if ctx.Method == MethodInitialize {
+			return true, nil
+		}
+		return false, &jsonrpc2.Error{
+			Code:    CodeServerNotInitialized,
+			Message: "server is not initialized",
+		}
+	case LifecycleStateInitialized:
+		return true, nil
+	case LifecycleStateShuttingDown:
+		return false, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidRequest,
+			Message: "server is shutting down",
+		}
+	default:
+		return false, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidRequest,
+			Message: "server has exited",
+		}
+	}
+}
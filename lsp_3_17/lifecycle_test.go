@@ -0,0 +1,124 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+)
+
+type LifecycleTestSuite struct {
+	suite.Suite
+	hoverCalls int
+	didOpened  int
+	handler    *Handler
+}
+
+func (s *LifecycleTestSuite) SetupTest() {
+	s.hoverCalls = 0
+	s.didOpened = 0
+	s.handler = NewHandler(
+		WithInitializeHandler(
+			func(ctx *common.LSPContext, params *InitializeParams) (any, error) {
+				return InitializeResult{}, nil
+			},
+		),
+		WithHoverHandler(
+			func(ctx *common.LSPContext, params *HoverParams) (*Hover, error) {
+				s.hoverCalls += 1
+				return &Hover{}, nil
+			},
+		),
+		WithTextDocumentDidOpenHandler(
+			func(ctx *common.LSPContext, params *DidOpenTextDocumentParams) error {
+				s.didOpened += 1
+				return nil
+			},
+		),
+	)
+}
+
+func (s *LifecycleTestSuite) Test_responds_with_server_not_initialized_before_initialize() {
+	_, validMethod, validParams, err := s.handler.Handle(testRequestContext(MethodHover, HoverParams{}))
+	s.Require().True(validMethod)
+	s.Require().True(validParams)
+	s.requireErrorCode(err, CodeServerNotInitialized)
+	s.Require().Equal(0, s.hoverCalls)
+}
+
+func (s *LifecycleTestSuite) Test_drops_notifications_before_initialize() {
+	_, _, _, err := s.handler.Handle(
+		testNotificationContext(MethodTextDocumentDidOpen, DidOpenTextDocumentParams{}),
+	)
+	s.Require().NoError(err)
+	s.Require().Equal(0, s.didOpened)
+}
+
+func (s *LifecycleTestSuite) Test_allows_exit_before_initialize() {
+	_, validMethod, _, err := s.handler.Handle(testNotificationContext(MethodExit, nil))
+	s.Require().NoError(err)
+	s.Require().True(validMethod)
+	s.Require().Equal(LifecycleStateExited, s.handler.LifecycleState())
+	s.Require().Equal(1, s.handler.ExitCode())
+}
+
+func (s *LifecycleTestSuite) Test_follows_lifecycle_through_to_exit_after_shutdown() {
+	_, _, _, err := s.handler.Handle(testRequestContext(MethodInitialize, InitializeParams{}))
+	s.Require().NoError(err)
+	s.Require().Equal(LifecycleStateInitialized, s.handler.LifecycleState())
+
+	_, _, _, err = s.handler.Handle(testRequestContext(MethodHover, HoverParams{}))
+	s.Require().NoError(err)
+	s.Require().Equal(1, s.hoverCalls)
+
+	_, validMethod, _, err := s.handler.Handle(testRequestContext(MethodShutdown, nil))
+	s.Require().NoError(err)
+	s.Require().True(validMethod)
+	s.Require().Equal(LifecycleStateShuttingDown, s.handler.LifecycleState())
+	s.Require().False(s.handler.IsInitialized())
+
+	_, _, _, err = s.handler.Handle(testRequestContext(MethodHover, HoverParams{}))
+	s.requireErrorCode(err, jsonrpc2.CodeInvalidRequest)
+	s.Require().Equal(1, s.hoverCalls)
+
+	_, _, _, err = s.handler.Handle(testNotificationContext(MethodExit, nil))
+	s.Require().NoError(err)
+	s.Require().Equal(LifecycleStateExited, s.handler.LifecycleState())
+	s.Require().Equal(0, s.handler.ExitCode())
+}
+
+func (s *LifecycleTestSuite) Test_rejects_second_initialize_request() {
+	_, _, _, err := s.handler.Handle(testRequestContext(MethodInitialize, InitializeParams{}))
+	s.Require().NoError(err)
+
+	_, _, _, err = s.handler.Handle(testRequestContext(MethodInitialize, InitializeParams{}))
+	s.requireErrorCode(err, jsonrpc2.CodeInvalidRequest)
+	s.Require().Equal(LifecycleStateInitialized, s.handler.LifecycleState())
+}
+
+func (s *LifecycleTestSuite) requireErrorCode(err error, code int64) {
+	s.Require().Error(err)
+	jsonrpcErr, isJSONRPCErr := err.(*jsonrpc2.Error)
+	s.Require().True(isJSONRPCErr)
+	s.Require().Equal(code, jsonrpcErr.Code)
+}
+
+func testRequestContext(method string, params any) *common.LSPContext {
+	rawParams, _ := json.Marshal(params)
+	return &common.LSPContext{
+		Method: method,
+		Params: rawParams,
+	}
+}
+
+func testNotificationContext(method string, params any) *common.LSPContext {
+	ctx := testRequestContext(method, params)
+	ctx.IsNotification = true
+	return ctx
+}
+
+func TestLifecycleTestSuite(t *testing.T) {
+	suite.Run(t, new(LifecycleTestSuite))
+}
@@ -0,0 +1,127 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MetaModelTestSuite struct {
+	suite.Suite
+}
+
+// Makes sure that the package has not drifted from the official LSP 3.17 meta model
+// by checking that a method constant is declared for every request and notification
+// in the meta model.
+// The meta model can be downloaded with scripts/update-meta-model.sh.
+func (s *MetaModelTestSuite) Test_declares_methods_for_all_messages_in_meta_model() {
+	metaModel := loadMetaModel(&s.Suite)
+
+	declaredMethods := s.declaredStringConstants()
+	for _, message := range metaModel.messages() {
+		s.Assert().True(
+			declaredMethods[message.Method],
+			"no constant is declared for the %q method",
+			message.Method,
+		)
+	}
+}
+
+// declaredStringConstants collects the values of the string constants
+// declared in the package, including those declared as `Method("...")`.
+func (s *MetaModelTestSuite) declaredStringConstants() map[string]bool {
+	fileSet := token.NewFileSet()
+	packages, err := parser.ParseDir(fileSet, ".", func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	s.Require().NoError(err)
+
+	constants := map[string]bool{}
+	for _, pkg := range packages {
+		ast.Inspect(pkg, func(node ast.Node) bool {
+			decl, isGenDecl := node.(*ast.GenDecl)
+			if !isGenDecl || decl.Tok != token.CONST {
+				return true
+			}
+
+			for _, spec := range decl.Specs {
+				for _, value := range spec.(*ast.ValueSpec).Values {
+					if call, isCall := value.(*ast.CallExpr); isCall && len(call.Args) == 1 {
+						value = call.Args[0]
+					}
+					if literal, isLiteral := value.(*ast.BasicLit); isLiteral && literal.Kind == token.STRING {
+						constant, err := strconv.Unquote(literal.Value)
+						s.Require().NoError(err)
+						constants[constant] = true
+					}
+				}
+			}
+			return false
+		})
+	}
+	return constants
+}
+
+type metaModelMessage struct {
+	Method           string `json:"method"`
+	MessageDirection string `json:"messageDirection"`
+	Proposed         bool   `json:"proposed"`
+}
+
+type metaModel struct {
+	MetaData struct {
+		Version string `json:"version"`
+	} `json:"metaData"`
+	Requests      []metaModelMessage `json:"requests"`
+	Notifications []metaModelMessage `json:"notifications"`
+	Structures    []json.RawMessage  `json:"structures"`
+}
+
+// messages returns the requests and notifications of the meta model
+// that are part of the specification, leaving out proposed messages.
+func (m *metaModel) messages() []metaModelMessage {
+	messages := []metaModelMessage{}
+	for _, message := range append(m.Requests, m.Notifications...) {
+		if !message.Proposed {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// loadMetaModel loads the official LSP 3.17 meta model from testdata/metaModel.json,
+// skipping the test when the meta model has not been downloaded.
+func loadMetaModel(s *suite.Suite) *metaModel {
+	metaModelBytes, err := os.ReadFile("testdata/metaModel.json")
+	if errors.Is(err, fs.ErrNotExist) {
+		s.T().Skip("the LSP 3.17 meta model has not been downloaded, run scripts/update-meta-model.sh")
+	}
+	s.Require().NoError(err)
+
+	model := &metaModel{}
+	s.Require().NoError(json.Unmarshal(metaModelBytes, model))
+	s.Require().True(
+		strings.HasPrefix(model.MetaData.Version, "3.17."),
+		"expected a LSP 3.17 meta model, got version %q",
+		model.MetaData.Version,
+	)
+	// The official meta model describes the structures of the protocol along with
+	// the messages, a list of messages on its own is not a meta model to check against.
+	s.Require().NotEmpty(model.Structures, "expected the official LSP 3.17 meta model")
+	s.Require().NotEmpty(model.Requests)
+	s.Require().NotEmpty(model.Notifications)
+	return model
+}
+
+func TestMetaModelTestSuite(t *testing.T) {
+	suite.Run(t, new(MetaModelTestSuite))
+}
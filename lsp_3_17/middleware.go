@@ -0,0 +1,121 @@
+package lsp
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/two-hundred/ls-builder/common"
+	"go.uber.org/zap"
+)
+
+// Middleware wraps the handling of every message dispatched by a `Handler`,
+// providing a way to implement cross-cutting concerns such as logging,
+// metrics, panic recovery and authorisation in a single place.
+//
+// The method and raw params of a message are available through the LSP context
+// and the result and error of the message are returned by the next handler.
+// A middleware can choose to not call the next handler to short-circuit
+// the handling of a message.
+type Middleware func(next common.Handler) common.Handler
+
+// WithMiddleware adds middleware that wraps the handling of every message
+// dispatched by the handler.
+// See `Handler.Use` for the order in which middleware is applied.
+func WithMiddleware(middleware ...Middleware) HandlerOption {
+	return func(root *Handler) {
+		root.Use(middleware...)
+	}
+}
+
+// Use adds middleware that wraps the handling of every message dispatched
+// by the handler.
+// Middleware is applied in the order it is added, the first middleware added
+// is the outermost and will be the first to see a message and the last to see
+// the result of handling the message.
+func (h *Handler) Use(middleware ...Middleware) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.middleware = append(h.middleware, middleware...)
+	var handler common.Handler = common.HandlerFunc(h.dispatch)
+	for i := len(h.middleware) - 1; i >= 0; i -= 1 {
+		handler = h.middleware[i](handler)
+	}
+	h.middlewareHandler = handler
+}
+
+func (h *Handler) composedHandler() common.Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.middlewareHandler == nil {
+		return common.HandlerFunc(h.dispatch)
+	}
+	return h.middlewareHandler
+}
+
+// RecoveryMiddleware creates middleware that recovers from panics
+// in the handling of a message, logging the panic along with the stack trace
+// and responding with an `InternalError` so a single faulty handler
+// does not bring down the server.
+func RecoveryMiddleware(logger *zap.Logger) Middleware {
+	return func(next common.Handler) common.Handler {
+		return common.HandlerFunc(
+			func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+				defer func() {
+					if recovered := recover(); recovered != nil {
+						logger.Error(
+							"recovered from panic while handling message",
+							zap.String("method", ctx.Method),
+							zap.Any("panic", recovered),
+							zap.ByteString("stack", debug.Stack()),
+						)
+						r = nil
+						validMethod = true
+						validParams = true
+						err = &jsonrpc2.Error{
+							Code:    jsonrpc2.CodeInternalError,
+							Message: fmt.Sprintf("internal error while handling %s", ctx.Method),
+						}
+					}
+				}()
+
+				return next.Handle(ctx)
+			},
+		)
+	}
+}
+
+// LoggingMiddleware creates middleware that logs every message
+// with structured fields for the method, the kind of message and the duration
+// of handling the message.
+// Messages that are handled successfully are logged at the debug level,
+// messages that fail or are not supported by the handler are logged at the
+// error and warning levels respectively.
+func LoggingMiddleware(logger *zap.Logger) Middleware {
+	return func(next common.Handler) common.Handler {
+		return common.HandlerFunc(
+			func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+				start := time.Now()
+				r, validMethod, validParams, err = next.Handle(ctx)
+
+				fields := []zap.Field{
+					zap.String("method", ctx.Method),
+					zap.Bool("notification", ctx.IsNotification),
+					zap.Duration("duration", time.Since(start)),
+				}
+				if err != nil {
+					logger.Error("failed to handle message", append(fields, zap.Error(err))...)
+				} else if !validMethod {
+					logger.Warn("method not supported", fields...)
+				} else if !validParams {
+					logger.Warn("invalid params", fields...)
+				} else {
+					logger.Debug("handled message", fields...)
+				}
+				return
+			},
+		)
+	}
+}
@@ -0,0 +1,191 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"github.com/two-hundred/ls-builder/server"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type MiddlewareTestSuite struct {
+	suite.Suite
+}
+
+const testHoverParams = `{"textDocument":{"uri":"file:///test.txt"},"position":{"line":0,"character":0}}`
+
+var testHover = &Hover{
+	Contents: MarkupContent{Kind: MarkupKindPlainText, Value: "hover"},
+}
+
+func (s *MiddlewareTestSuite) Test_applies_middleware_in_order_it_was_added() {
+	calls := []string{}
+	recordingMiddleware := func(name string) Middleware {
+		return func(next common.Handler) common.Handler {
+			return common.HandlerFunc(
+				func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+					calls = append(calls, name+" before "+ctx.Method+" "+string(ctx.Params))
+					r, validMethod, validParams, err = next.Handle(ctx)
+					s.Require().Equal(testHover, r)
+					calls = append(calls, name+" after")
+					return
+				},
+			)
+		}
+	}
+
+	handler := NewHandler(
+		WithHoverHandler(func(ctx *common.LSPContext, params *HoverParams) (*Hover, error) {
+			calls = append(calls, "handler")
+			return testHover, nil
+		}),
+		WithMiddleware(recordingMiddleware("first")),
+	)
+	handler.Use(recordingMiddleware("second"))
+	handler.SetInitialized(true)
+
+	r, validMethod, validParams, err := handler.Handle(&common.LSPContext{
+		Method: MethodHover,
+		Params: []byte(testHoverParams),
+	})
+	s.Require().NoError(err)
+	s.Require().True(validMethod)
+	s.Require().True(validParams)
+	s.Require().Equal(testHover, r)
+	s.Require().Equal(
+		[]string{
+			"first before textDocument/hover " + testHoverParams,
+			"second before textDocument/hover " + testHoverParams,
+			"handler",
+			"second after",
+			"first after",
+		},
+		calls,
+	)
+}
+
+func (s *MiddlewareTestSuite) Test_middleware_can_short_circuit_handling_of_messages() {
+	errUnauthorised := errors.New("unauthorised")
+	handlerCalled := false
+	handler := NewHandler(
+		WithHoverHandler(func(ctx *common.LSPContext, params *HoverParams) (*Hover, error) {
+			handlerCalled = true
+			return testHover, nil
+		}),
+		WithMiddleware(func(next common.Handler) common.Handler {
+			return common.HandlerFunc(
+				func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+					if ctx.Method == MethodHover {
+						return nil, true, true, errUnauthorised
+					}
+					return next.Handle(ctx)
+				},
+			)
+		}),
+	)
+	handler.SetInitialized(true)
+
+	_, _, _, err := handler.Handle(&common.LSPContext{
+		Method: MethodHover,
+		Params: []byte(testHoverParams),
+	})
+	s.Require().ErrorIs(err, errUnauthorised)
+	s.Require().False(handlerCalled)
+}
+
+func (s *MiddlewareTestSuite) Test_recovery_middleware_responds_with_internal_error() {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	serverHandler := NewHandler(
+		WithHoverHandler(func(ctx *common.LSPContext, params *HoverParams) (*Hover, error) {
+			panic("hover handler failed")
+		}),
+		WithMiddleware(RecoveryMiddleware(logger)),
+	)
+	serverHandler.SetInitialized(true)
+	srv := server.NewServer(serverHandler, true, nil, nil)
+
+	container := createTestConnectionsContainer(srv.NewHandler())
+	go srv.Serve(container.serverConn, zap.NewNop())
+
+	clientLSPContext := server.NewLSPContext(ctx, container.clientConn, nil)
+	var hover Hover
+	err := clientLSPContext.Call(MethodHover, HoverParams{
+		TextDocumentPositionParams: TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: testDocumentURI},
+		},
+	}, &hover)
+	s.Require().Error(err)
+	jsonrpcErr, isJSONRPCError := err.(*jsonrpc2.Error)
+	s.Require().True(isJSONRPCError)
+	s.Require().Equal(int64(jsonrpc2.CodeInternalError), jsonrpcErr.Code)
+	s.Require().Equal("internal error while handling textDocument/hover", jsonrpcErr.Message)
+
+	entries := logs.FilterMessage("recovered from panic while handling message").All()
+	s.Require().Len(entries, 1)
+	fields := entries[0].ContextMap()
+	s.Require().Equal(MethodHover, fields["method"])
+	s.Require().Equal("hover handler failed", fields["panic"])
+	s.Require().Contains(fields["stack"], "middleware_test.go")
+}
+
+func (s *MiddlewareTestSuite) Test_logging_middleware_logs_messages_with_durations() {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	errHover := errors.New("hover failed")
+	failHover := false
+	handler := NewHandler(
+		WithHoverHandler(func(ctx *common.LSPContext, params *HoverParams) (*Hover, error) {
+			if failHover {
+				return nil, errHover
+			}
+			return testHover, nil
+		}),
+		WithMiddleware(LoggingMiddleware(logger)),
+	)
+	handler.SetInitialized(true)
+
+	handler.Handle(&common.LSPContext{Method: MethodHover, Params: []byte(testHoverParams)})
+	handler.Handle(&common.LSPContext{Method: "custom/unknown", Params: []byte("{}")})
+	handler.Handle(&common.LSPContext{Method: MethodHover, Params: []byte(`[]`)})
+	failHover = true
+	handler.Handle(&common.LSPContext{Method: MethodHover, Params: []byte(testHoverParams)})
+
+	entries := logs.All()
+	s.Require().Len(entries, 4)
+
+	expected := []struct {
+		level   zapcore.Level
+		message string
+		method  string
+	}{
+		{zapcore.DebugLevel, "handled message", MethodHover},
+		{zapcore.WarnLevel, "method not supported", "custom/unknown"},
+		{zapcore.ErrorLevel, "failed to handle message", MethodHover},
+		{zapcore.ErrorLevel, "failed to handle message", MethodHover},
+	}
+	for i, entry := range entries {
+		s.Require().Equal(expected[i].level, entry.Level)
+		s.Require().Equal(expected[i].message, entry.Message)
+		fields := entry.ContextMap()
+		s.Require().Equal(expected[i].method, fields["method"])
+		s.Require().Equal(false, fields["notification"])
+		s.Require().Contains(fields, "duration")
+	}
+	s.Require().Equal(errHover.Error(), entries[3].ContextMap()["error"])
+}
+
+func TestMiddlewareTestSuite(t *testing.T) {
+	suite.Run(t, new(MiddlewareTestSuite))
+}
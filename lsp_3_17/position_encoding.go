@@ -0,0 +1,144 @@
+package lsp
+
+import (
+	"context"
+	"slices"
+
+	"github.com/two-hundred/ls-builder/common"
+)
+
+// NegotiatePositionEncoding picks the position encoding to use for a session
+// from the server's preferred encodings (in order of preference) and the encodings
+// offered by the client in the `general.positionEncodings` client capability.
+//
+// As per the specification, UTF-16 is always supported by the client and is
+// used when none of the server's preferred encodings are supported by the client.
+func NegotiatePositionEncoding(
+	clientEncodings []PositionEncodingKind,
+	serverPreferences []PositionEncodingKind,
+) PositionEncodingKind {
+	for _, preferred := range serverPreferences {
+		if preferred == PositionEncodingKindUTF16 || slices.Contains(clientEncodings, preferred) {
+			return preferred
+		}
+	}
+
+	return PositionEncodingKindUTF16
+}
+
+// WithPositionEncodingPreferences sets the position encodings that the server supports
+// in order of preference to be negotiated with the client during initialisation.
+func WithPositionEncodingPreferences(preferences ...PositionEncodingKind) HandlerOption {
+	return func(root *Handler) {
+		root.SetPositionEncodingPreferences(preferences...)
+	}
+}
+
+// SetPositionEncodingPreferences sets the position encodings that the server supports
+// in order of preference to be negotiated with the client during initialisation.
+// When no preferences are set, UTF-16 will be used as the position encoding.
+//
+// The negotiated encoding is set as the `positionEncoding` server capability
+// in the initialize result unless the `initialize` handler sets it explicitly.
+func (h *Handler) SetPositionEncodingPreferences(preferences ...PositionEncodingKind) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.positionEncodingPreferences = preferences
+}
+
+// PositionEncodingKind returns the position encoding that was negotiated
+// with the client during initialisation.
+// This will be UTF-16 before the server has been initialised.
+func (h *Handler) PositionEncodingKind() PositionEncodingKind {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.positionEncodingKind
+}
+
+// negotiatePositionEncoding picks the position encoding to offer the client
+// without changing the position encoding of the session, the encoding for the session
+// is only set with `setPositionEncodingKind` once the `initialize` handler has succeeded.
+func (h *Handler) negotiatePositionEncoding(params *InitializeParams) PositionEncodingKind {
+	var clientEncodings []PositionEncodingKind
+	if params.Capabilities.General != nil {
+		clientEncodings = params.Capabilities.General.PositionEncodings
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return NegotiatePositionEncoding(clientEncodings, h.positionEncodingPreferences)
+}
+
+func (h *Handler) setPositionEncodingKind(posEncodingKind PositionEncodingKind) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.positionEncodingKind = posEncodingKind
+	if h.documentStore != nil {
+		h.documentStore.SetPositionEncodingKind(posEncodingKind)
+	}
+}
+
+// PositionEncodingResult can be implemented by initialize results other than
+// an `InitializeResult` from this package, such as those for later versions
+// of the protocol, so the negotiated position encoding can be applied to them.
+type PositionEncodingResult interface {
+	// ApplyPositionEncoding sets the negotiated position encoding in the server
+	// capabilities unless the server has picked one, returning the result to
+	// send to the client and the position encoding used for the session.
+	ApplyPositionEncoding(negotiated PositionEncodingKind) (any, PositionEncodingKind)
+}
+
+// Applies the negotiated position encoding to the server capabilities
+// of an initialize result, returning the updated result along with
+// the position encoding that will be used for the session.
+// When the initialize handler explicitly sets a position encoding,
+// that takes precedence over the negotiated encoding.
+// When the position encoding can not be set in the result (e.g. a nil result
+// or a result type that is not known), the client will assume UTF-16
+// so UTF-16 is used for the session.
+func applyPositionEncoding(result any, negotiated PositionEncodingKind) (any, PositionEncodingKind) {
+	switch initResult := result.(type) {
+	case InitializeResult:
+		if initResult.Capabilities.PositionEncoding == "" {
+			initResult.Capabilities.PositionEncoding = negotiated
+		}
+		return initResult, initResult.Capabilities.PositionEncoding
+	case *InitializeResult:
+		if initResult != nil {
+			if initResult.Capabilities.PositionEncoding == "" {
+				initResult.Capabilities.PositionEncoding = negotiated
+			}
+			return initResult, initResult.Capabilities.PositionEncoding
+		}
+	case PositionEncodingResult:
+		return initResult.ApplyPositionEncoding(negotiated)
+	}
+
+	return result, PositionEncodingKindUTF16
+}
+
+type positionEncodingKindKey struct{}
+
+func withPositionEncodingKind(ctx *common.LSPContext, posEncodingKind PositionEncodingKind) {
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx.Context = context.WithValue(parent, positionEncodingKindKey{}, posEncodingKind)
+}
+
+// PositionEncodingKindFromContext retrieves the position encoding that was negotiated
+// with the client for the session that the provided LSP context belongs to.
+// This is available for all messages handled by a `Handler` and will be UTF-16
+// if the position encoding is not present in the context.
+func PositionEncodingKindFromContext(ctx *common.LSPContext) PositionEncodingKind {
+	if ctx == nil || ctx.Context == nil {
+		return PositionEncodingKindUTF16
+	}
+
+	posEncodingKind, ok := ctx.Context.Value(positionEncodingKindKey{}).(PositionEncodingKind)
+	if !ok {
+		return PositionEncodingKindUTF16
+	}
+	return posEncodingKind
+}
@@ -0,0 +1,225 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"github.com/two-hundred/ls-builder/server"
+	"go.uber.org/zap"
+)
+
+type PositionEncodingTestSuite struct {
+	suite.Suite
+}
+
+func (s *PositionEncodingTestSuite) Test_negotiates_position_encoding() {
+	tests := []struct {
+		name              string
+		clientEncodings   []PositionEncodingKind
+		serverPreferences []PositionEncodingKind
+		expected          PositionEncodingKind
+	}{
+		{
+			name:              "defaults to utf-16 when there are no server preferences",
+			clientEncodings:   []PositionEncodingKind{PositionEncodingKindUTF8},
+			serverPreferences: nil,
+			expected:          PositionEncodingKindUTF16,
+		},
+		{
+			name:              "picks the first server preference supported by the client",
+			clientEncodings:   []PositionEncodingKind{PositionEncodingKindUTF16, PositionEncodingKindUTF32},
+			serverPreferences: []PositionEncodingKind{PositionEncodingKindUTF8, PositionEncodingKindUTF32},
+			expected:          PositionEncodingKindUTF32,
+		},
+		{
+			name:              "treats utf-16 as always supported by the client",
+			clientEncodings:   []PositionEncodingKind{PositionEncodingKindUTF8},
+			serverPreferences: []PositionEncodingKind{PositionEncodingKindUTF16, PositionEncodingKindUTF8},
+			expected:          PositionEncodingKindUTF16,
+		},
+		{
+			name:              "falls back to utf-16 when client does not provide encodings",
+			clientEncodings:   nil,
+			serverPreferences: []PositionEncodingKind{PositionEncodingKindUTF8},
+			expected:          PositionEncodingKindUTF16,
+		},
+	}
+
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.Require().Equal(
+				test.expected,
+				NegotiatePositionEncoding(test.clientEncodings, test.serverPreferences),
+			)
+		})
+	}
+}
+
+func (s *PositionEncodingTestSuite) Test_sets_negotiated_position_encoding_for_session() {
+	logger, err := zap.NewDevelopment()
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	store := NewDocumentStore()
+	callChan := make(chan PositionEncodingKind, 1)
+	serverHandler := NewHandler(
+		WithPositionEncodingPreferences(PositionEncodingKindUTF8, PositionEncodingKindUTF16),
+		WithDocumentStore(store),
+		WithInitializeHandler(
+			func(ctx *common.LSPContext, params *InitializeParams) (any, error) {
+				return &InitializeResult{}, nil
+			},
+		),
+		WithTextDocumentDidOpenHandler(
+			func(ctx *common.LSPContext, params *DidOpenTextDocumentParams) error {
+				callChan <- PositionEncodingKindFromContext(ctx)
+				return nil
+			},
+		),
+	)
+	srv := server.NewServer(serverHandler, true, nil, nil)
+
+	container := createTestConnectionsContainer(srv.NewHandler())
+
+	go srv.Serve(container.serverConn, logger)
+
+	clientLSPContext := server.NewLSPContext(ctx, container.clientConn, nil)
+
+	initializeParams := InitializeParams{
+		Capabilities: ClientCapabilities{
+			General: &GeneralClientCapabilities{
+				PositionEncodings: []PositionEncodingKind{
+					PositionEncodingKindUTF32,
+					PositionEncodingKindUTF8,
+				},
+			},
+		},
+	}
+
+	returnedResult := InitializeResult{}
+	err = clientLSPContext.Call(MethodInitialize, initializeParams, &returnedResult)
+	s.Require().NoError(err)
+	s.Require().Equal(PositionEncodingKindUTF8, returnedResult.Capabilities.PositionEncoding)
+	s.Require().Equal(PositionEncodingKindUTF8, serverHandler.PositionEncodingKind())
+	s.Require().Equal(PositionEncodingKindUTF8, store.PositionEncodingKind())
+
+	err = clientLSPContext.Notify(MethodTextDocumentDidOpen, DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testDocumentURI, Version: 1, Text: "content"},
+	})
+	s.Require().NoError(err)
+
+	select {
+	case <-ctx.Done():
+		s.Fail("timeout")
+	case posEncodingKind := <-callChan:
+		s.Require().Equal(PositionEncodingKindUTF8, posEncodingKind)
+	}
+}
+
+func (s *PositionEncodingTestSuite) Test_sets_negotiated_position_encoding_for_store_attached_after_initialisation() {
+	serverHandler := NewHandler(
+		WithPositionEncodingPreferences(PositionEncodingKindUTF8),
+		WithInitializeHandler(
+			func(ctx *common.LSPContext, params *InitializeParams) (any, error) {
+				return &InitializeResult{}, nil
+			},
+		),
+	)
+	s.initialize(serverHandler, PositionEncodingKindUTF8)
+	s.Require().Equal(PositionEncodingKindUTF8, serverHandler.PositionEncodingKind())
+
+	store := NewDocumentStore()
+	serverHandler.SetDocumentStore(store)
+	s.Require().Equal(PositionEncodingKindUTF8, store.PositionEncodingKind())
+}
+
+func (s *PositionEncodingTestSuite) Test_keeps_position_encoding_when_initialisation_fails() {
+	store := NewDocumentStore()
+	failInitialize := true
+	serverHandler := NewHandler(
+		WithPositionEncodingPreferences(PositionEncodingKindUTF8, PositionEncodingKindUTF32),
+		WithDocumentStore(store),
+		WithInitializeHandler(
+			func(ctx *common.LSPContext, params *InitializeParams) (any, error) {
+				if failInitialize {
+					return nil, errors.New("initialisation failed")
+				}
+				return &InitializeResult{}, nil
+			},
+		),
+	)
+
+	_, err := s.initialize(serverHandler, PositionEncodingKindUTF8)
+	s.Require().Error(err)
+	s.Require().Equal(PositionEncodingKindUTF16, serverHandler.PositionEncodingKind())
+	s.Require().Equal(PositionEncodingKindUTF16, store.PositionEncodingKind())
+
+	// The client can retry initialisation with different capabilities.
+	failInitialize = false
+	r, err := s.initialize(serverHandler, PositionEncodingKindUTF32)
+	s.Require().NoError(err)
+	s.Require().Equal(PositionEncodingKindUTF32, r.(*InitializeResult).Capabilities.PositionEncoding)
+	s.Require().Equal(PositionEncodingKindUTF32, serverHandler.PositionEncodingKind())
+	s.Require().Equal(PositionEncodingKindUTF32, store.PositionEncodingKind())
+}
+
+func (s *PositionEncodingTestSuite) Test_keeps_position_encoding_set_by_initialize_handler() {
+	result, posEncodingKind := applyPositionEncoding(
+		InitializeResult{
+			Capabilities: ServerCapabilities{PositionEncoding: PositionEncodingKindUTF32},
+		},
+		PositionEncodingKindUTF8,
+	)
+	s.Require().Equal(PositionEncodingKindUTF32, posEncodingKind)
+	s.Require().Equal(PositionEncodingKindUTF32, result.(InitializeResult).Capabilities.PositionEncoding)
+}
+
+func (s *PositionEncodingTestSuite) Test_falls_back_to_utf16_when_result_can_not_carry_position_encoding() {
+	var nilResult *InitializeResult
+	tests := []any{
+		nil,
+		nilResult,
+		map[string]any{"capabilities": map[string]any{}},
+	}
+
+	for _, result := range tests {
+		returned, posEncodingKind := applyPositionEncoding(result, PositionEncodingKindUTF8)
+		s.Require().Equal(PositionEncodingKindUTF16, posEncodingKind)
+		s.Require().Equal(result, returned)
+	}
+}
+
+func (s *PositionEncodingTestSuite) Test_defaults_to_utf16_without_negotiated_encoding() {
+	s.Require().Equal(PositionEncodingKindUTF16, PositionEncodingKindFromContext(&common.LSPContext{}))
+}
+
+func (s *PositionEncodingTestSuite) initialize(
+	serverHandler *Handler,
+	clientEncodings ...PositionEncodingKind,
+) (any, error) {
+	params, err := json.Marshal(InitializeParams{
+		Capabilities: ClientCapabilities{
+			General: &GeneralClientCapabilities{
+				PositionEncodings: clientEncodings,
+			},
+		},
+	})
+	s.Require().NoError(err)
+
+	r, _, _, err := serverHandler.Handle(&common.LSPContext{
+		Method:  MethodInitialize,
+		Params:  params,
+		Context: context.Background(),
+	})
+	return r, err
+}
+
+func TestPositionEncodingTestSuite(t *testing.T) {
+	suite.Run(t, new(PositionEncodingTestSuite))
+}
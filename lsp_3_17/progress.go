@@ -0,0 +1,419 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/two-hundred/ls-builder/common"
+)
+
+const (
+	workDoneProgressKindBegin  = "begin"
+	workDoneProgressKindReport = "report"
+	workDoneProgressKindEnd    = "end"
+)
+
+// DefaultProgressReportInterval is the default minimum interval between
+// work done progress reports sent to the client.
+var DefaultProgressReportInterval = 100 * time.Millisecond
+
+// ProgressService provides a convenient way to report work done progress to
+// the client, keeping track of in-progress work so that it can be cancelled
+// when the client sends a `window/workDoneProgress/cancel` notification.
+type ProgressService struct {
+	reportInterval time.Duration
+	tokenPrefix    string
+	tokenCounter   uint64
+	reporters      map[string]*ProgressReporter
+	mu             sync.Mutex
+}
+
+// ProgressServiceOption is a function that can be used to configure a progress service.
+type ProgressServiceOption func(*ProgressService)
+
+// WithProgressReportInterval sets the minimum interval between work done
+// progress reports sent to the client, only the latest of the reports made
+// more frequently than this is sent once the interval has elapsed.
+func WithProgressReportInterval(interval time.Duration) ProgressServiceOption {
+	return func(s *ProgressService) {
+		s.reportInterval = interval
+	}
+}
+
+// WithProgressTokenPrefix sets the prefix used for tokens created by the
+// server for server-initiated work done progress.
+func WithProgressTokenPrefix(prefix string) ProgressServiceOption {
+	return func(s *ProgressService) {
+		s.tokenPrefix = prefix
+	}
+}
+
+// NewProgressService creates a new progress service.
+func NewProgressService(opts ...ProgressServiceOption) *ProgressService {
+	service := &ProgressService{
+		reportInterval: DefaultProgressReportInterval,
+		tokenPrefix:    "ls-builder-progress",
+		reporters:      make(map[string]*ProgressReporter),
+	}
+	for _, opt := range opts {
+		opt(service)
+	}
+	return service
+}
+
+// CreateWorkDoneProgressCancelHandler creates a new handler function that can be
+// configured with a `Handler` instance to handle `window/workDoneProgress/cancel`
+// notifications from the client, cancelling the context of the matching progress reporter.
+func (s *ProgressService) CreateWorkDoneProgressCancelHandler() WindowWorkDoneProgressCancelHandler {
+	return func(ctx *common.LSPContext, params *WorkDoneProgressCancelParams) error {
+		if params.Token == nil {
+			return nil
+		}
+
+		s.mu.Lock()
+		reporter, ok := s.reporters[progressTokenKey(params.Token)]
+		s.mu.Unlock()
+		if ok {
+			reporter.cancel()
+		}
+		return nil
+	}
+}
+
+// NewReporter creates a new progress reporter for the provided LSP context.
+// When a work done token is provided by the client in the request parameters,
+// the reporter will use it, otherwise a token will be created with a
+// `window/workDoneProgress/create` request to the client.
+// When no token is provided and the client state shows that the client does not
+// support server-initiated work done progress, a reporter that does not send
+// anything to the client is returned.
+//
+// When the client provides a token, the context of the reporter is derived from the context
+// of the provided LSP context so it is cancelled along with the request or when the client
+// cancels the progress.
+// Server-initiated progress can outlive the request it was started from, so the context
+// of the reporter is detached from the request and is only cancelled when the client
+// cancels the progress or the progress ends.
+func (s *ProgressService) NewReporter(ctx *common.LSPContext, workDoneToken *ProgressToken) (*ProgressReporter, error) {
+	dispatcher := NewDispatcher(ctx)
+	token := workDoneToken
+	clientState := ClientStateFromContext(ctx)
+	if token == nil && clientState != nil && !clientState.SupportsWorkDoneProgress() {
+		dispatcher = nil
+	} else if token == nil {
+		token = s.nextToken()
+		err := dispatcher.CreateWorkDoneProgress(WorkDoneProgressCreateParams{Token: token})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	if workDoneToken == nil {
+		parent = context.WithoutCancel(parent)
+	}
+	reporterCtx, cancel := context.WithCancel(parent)
+
+	reporter := &ProgressReporter{
+		service:    s,
+		dispatcher: dispatcher,
+		token:      token,
+		ctx:        reporterCtx,
+		cancel:     cancel,
+	}
+
+	if dispatcher == nil {
+		return reporter, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reporters[progressTokenKey(token)] = reporter
+	return reporter, nil
+}
+
+// Run begins work done progress, calls the provided function to carry out the work
+// and ends the progress when the function returns, fails or panics.
+// When the work fails, the error message is sent as the message of the end of the progress.
+// A panic is re-raised after the progress has ended.
+// The work is still carried out when the client does not support
+// server-initiated progress and no token was provided, see `NewReporter`.
+func (s *ProgressService) Run(
+	ctx *common.LSPContext,
+	workDoneToken *ProgressToken,
+	begin WorkDoneProgressBegin,
+	work func(reporter *ProgressReporter) error,
+) (err error) {
+	reporter, err := s.NewReporter(ctx, workDoneToken)
+	if err != nil {
+		return err
+	}
+
+	if err = reporter.Begin(begin); err != nil {
+		reporter.cancel()
+		s.remove(reporter.token)
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			message := fmt.Sprintf("%v", r)
+			reporter.End(&message)
+			panic(r)
+		}
+	}()
+
+	err = work(reporter)
+	if err != nil {
+		message := err.Error()
+		reporter.End(&message)
+		return err
+	}
+
+	return reporter.End(nil)
+}
+
+func (s *ProgressService) nextToken() *ProgressToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenCounter += 1
+	token := fmt.Sprintf("%s-%d", s.tokenPrefix, s.tokenCounter)
+	return &ProgressToken{StrVal: &token}
+}
+
+func (s *ProgressService) remove(token *ProgressToken) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.reporters, progressTokenKey(token))
+}
+
+func progressTokenKey(token *ProgressToken) string {
+	if token.IntVal != nil {
+		return fmt.Sprintf("int:%d", *token.IntVal)
+	}
+
+	if token.StrVal != nil {
+		return fmt.Sprintf("string:%s", *token.StrVal)
+	}
+
+	return ""
+}
+
+// ProgressReporter reports work done progress for a single token to the client.
+// Reporters should be created with `ProgressService.NewReporter`.
+type ProgressReporter struct {
+	service *ProgressService
+	// The dispatcher is nil for reporters that do not send anything
+	// to the client.
+	dispatcher     *Dispatcher
+	token          *ProgressToken
+	ctx            context.Context
+	cancel         context.CancelFunc
+	lastReportedAt time.Time
+	// The latest report made within the report interval that is waiting
+	// to be sent to the client.
+	pending    *WorkDoneProgressReport
+	flushTimer *time.Timer
+	ended      bool
+	mu         sync.Mutex
+}
+
+// Token returns the token that progress is reported for,
+// nil when progress is not reported to the client.
+func (r *ProgressReporter) Token() *ProgressToken {
+	return r.token
+}
+
+// Context returns a context that is cancelled when the client cancels the
+// work done progress, when the request the progress is being reported for is
+// cancelled for progress with a token provided by the client or when the progress has ended.
+func (r *ProgressReporter) Context() context.Context {
+	return r.ctx
+}
+
+// Begin notifies the client of the beginning of the work.
+func (r *ProgressReporter) Begin(begin WorkDoneProgressBegin) error {
+	if r.dispatcher == nil {
+		return nil
+	}
+
+	return r.dispatcher.ProgressBegin(r.token, begin)
+}
+
+// Report notifies the client of progress made on the work.
+// Reports made more frequently than the report interval of the progress service
+// are held back so that only the latest of them is sent once the interval has elapsed,
+// or before the end of the progress if it ends first.
+// Reports made after the progress has ended are dropped.
+func (r *ProgressReporter) Report(report WorkDoneProgressReport) error {
+	if r.dispatcher == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return nil
+	}
+
+	elapsed := time.Since(r.lastReportedAt)
+	if elapsed < r.service.reportInterval {
+		r.pending = &report
+		if r.flushTimer == nil {
+			r.flushTimer = time.AfterFunc(r.service.reportInterval-elapsed, r.flushPending)
+		}
+		return nil
+	}
+
+	r.pending = nil
+	return r.sendReport(report)
+}
+
+// flushPending sends the latest report held back by `Report`,
+// errors are dropped as there is no caller to return them to.
+func (r *ProgressReporter) flushPending() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flushTimer = nil
+	if r.ended || r.pending == nil {
+		return
+	}
+
+	report := *r.pending
+	r.pending = nil
+	r.sendReport(report)
+}
+
+// sendReport must be called with the lock held so that reports
+// are sent to the client in order.
+func (r *ProgressReporter) sendReport(report WorkDoneProgressReport) error {
+	r.lastReportedAt = time.Now()
+	return r.dispatcher.ProgressReport(r.token, report)
+}
+
+// End notifies the client that the work has ended with an optional message
+// and cancels the context of the reporter.
+// A report held back by `Report` is sent before the end of the progress.
+// Only the first call to End will send a notification to the client.
+func (r *ProgressReporter) End(message *string) error {
+	r.mu.Lock()
+	if r.ended {
+		r.mu.Unlock()
+		return nil
+	}
+	r.ended = true
+	if r.flushTimer != nil {
+		r.flushTimer.Stop()
+		r.flushTimer = nil
+	}
+	pending := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+
+	defer r.cancel()
+	if r.dispatcher == nil {
+		return nil
+	}
+
+	defer r.service.remove(r.token)
+	if pending != nil {
+		if err := r.dispatcher.ProgressReport(r.token, *pending); err != nil {
+			return err
+		}
+	}
+
+	return r.dispatcher.ProgressEnd(r.token, WorkDoneProgressEnd{Message: message})
+}
+
+// PartialResultStreamer streams partial results to the client with `$/progress`
+// notifications for requests that support partial results, such as
+// `textDocument/references` and `workspace/symbol`.
+//
+// When the client has not provided a partial result token, results are collected
+// to be returned in the response to the request.
+type PartialResultStreamer[Item any] struct {
+	dispatcher *Dispatcher
+	token      *ProgressToken
+	batchSize  int
+	pending    []Item
+	mu         sync.Mutex
+}
+
+// NewPartialResultStreamer creates a new partial result streamer for the
+// provided LSP context and partial result parameters of a request.
+// Results will be sent to the client in batches of the provided size,
+// a batch size of 0 or less will send results as soon as they are added.
+func NewPartialResultStreamer[Item any](
+	ctx *common.LSPContext,
+	params PartialResultParams,
+	batchSize int,
+) *PartialResultStreamer[Item] {
+	return &PartialResultStreamer[Item]{
+		dispatcher: NewDispatcher(ctx),
+		token:      params.PartialResultToken,
+		batchSize:  batchSize,
+	}
+}
+
+// IsStreaming determines whether results are streamed to the client,
+// this is only the case when the client provided a partial result token.
+func (s *PartialResultStreamer[Item]) IsStreaming() bool {
+	return s.token != nil
+}
+
+// Add adds results to the stream, sending a batch to the client once
+// enough results have been added.
+func (s *PartialResultStreamer[Item]) Add(items ...Item) error {
+	s.mu.Lock()
+	s.pending = append(s.pending, items...)
+	shouldFlush := s.IsStreaming() && len(s.pending) >= s.batchSize
+	s.mu.Unlock()
+
+	if shouldFlush {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush sends any pending results to the client.
+func (s *PartialResultStreamer[Item]) Flush() error {
+	if !s.IsStreaming() {
+		return nil
+	}
+
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	return s.dispatcher.PartialResult(s.token, batch)
+}
+
+// Result flushes any pending results and returns the result that should be
+// sent in the response to the request.
+// When streaming, this is an empty list as all results have been
+// sent to the client as partial results, otherwise, this is all the results
+// that have been added.
+func (s *PartialResultStreamer[Item]) Result() ([]Item, error) {
+	if err := s.Flush(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := s.pending
+	s.pending = nil
+	if result == nil {
+		result = []Item{}
+	}
+	return result, nil
+}
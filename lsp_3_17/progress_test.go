@@ -0,0 +1,323 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"github.com/two-hundred/ls-builder/server"
+)
+
+type ProgressTestSuite struct {
+	suite.Suite
+}
+
+func (s *ProgressTestSuite) Test_reports_progress_for_client_provided_token() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+
+	service := NewProgressService(WithProgressReportInterval(time.Hour))
+	tokenVal := "client-token"
+	token := &ProgressToken{StrVal: &tokenVal}
+	firstMessage := "1/2"
+	secondMessage := "2/2"
+
+	err := service.Run(
+		lspCtx,
+		token,
+		WorkDoneProgressBegin{Title: "Indexing"},
+		func(reporter *ProgressReporter) error {
+			s.Require().NoError(reporter.Report(WorkDoneProgressReport{Message: &firstMessage}))
+			// Held back as it is within the report interval
+			// and sent before the end of the progress.
+			s.Require().NoError(reporter.Report(WorkDoneProgressReport{Message: &secondMessage}))
+			return nil
+		},
+	)
+	s.Require().NoError(err)
+
+	messages := s.waitForClientMessages(container, 4)
+	s.Require().Equal(
+		[]string{MethodProgress, MethodProgress, MethodProgress, MethodProgress},
+		messages.methods,
+	)
+	s.Require().Equal(
+		[]string{
+			`{"token":"client-token","value":{"kind":"begin","title":"Indexing"}}`,
+			`{"token":"client-token","value":{"kind":"report","message":"1/2"}}`,
+			`{"token":"client-token","value":{"kind":"report","message":"2/2"}}`,
+			`{"token":"client-token","value":{"kind":"end"}}`,
+		},
+		messages.params,
+	)
+}
+
+func (s *ProgressTestSuite) Test_sends_latest_report_held_back_once_report_interval_elapses() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+
+	service := NewProgressService(WithProgressReportInterval(20 * time.Millisecond))
+	tokenVal := "client-token"
+	reporter, err := service.NewReporter(lspCtx, &ProgressToken{StrVal: &tokenVal})
+	s.Require().NoError(err)
+	s.Require().NoError(reporter.Begin(WorkDoneProgressBegin{Title: "Indexing"}))
+	for _, message := range []string{"1/3", "2/3", "3/3"} {
+		s.Require().NoError(reporter.Report(WorkDoneProgressReport{Message: &message}))
+	}
+
+	// The latest report is sent once the interval has elapsed without
+	// waiting for the end of the progress.
+	messages := s.waitForClientMessages(container, 3)
+	s.Require().Equal(
+		[]string{
+			`{"token":"client-token","value":{"kind":"begin","title":"Indexing"}}`,
+			`{"token":"client-token","value":{"kind":"report","message":"1/3"}}`,
+			`{"token":"client-token","value":{"kind":"report","message":"3/3"}}`,
+		},
+		messages.params,
+	)
+
+	s.Require().NoError(reporter.End(nil))
+	messages = s.waitForClientMessages(container, 4)
+	s.Require().Equal(`{"token":"client-token","value":{"kind":"end"}}`, messages.params[3])
+}
+
+func (s *ProgressTestSuite) Test_creates_server_initiated_token_and_ends_with_error() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+
+	service := NewProgressService(WithProgressTokenPrefix("test"))
+	workErr := errors.New("failed to index")
+	err := service.Run(
+		lspCtx,
+		nil,
+		WorkDoneProgressBegin{Title: "Indexing"},
+		func(reporter *ProgressReporter) error {
+			return workErr
+		},
+	)
+	s.Require().ErrorIs(err, workErr)
+
+	messages := s.waitForClientMessages(container, 3)
+	s.Require().Equal(
+		[]string{MethodWorkDoneProgressCreate, MethodProgress, MethodProgress},
+		messages.methods,
+	)
+	s.Require().Equal(
+		[]string{
+			`{"token":"test-1"}`,
+			`{"token":"test-1","value":{"kind":"begin","title":"Indexing"}}`,
+			`{"token":"test-1","value":{"kind":"end","message":"failed to index"}}`,
+		},
+		messages.params,
+	)
+}
+
+func (s *ProgressTestSuite) Test_detaches_server_initiated_progress_from_request() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	requestCtx, cancelRequest := context.WithCancel(ctx)
+	lspCtx := server.NewLSPContext(requestCtx, container.serverConn, nil)
+
+	service := NewProgressService()
+	serverReporter, err := service.NewReporter(lspCtx, nil)
+	s.Require().NoError(err)
+	tokenVal := "client-token"
+	clientReporter, err := service.NewReporter(lspCtx, &ProgressToken{StrVal: &tokenVal})
+	s.Require().NoError(err)
+
+	// Only progress with a token provided by the client is cancelled along with the request.
+	cancelRequest()
+	s.Require().NoError(serverReporter.Context().Err())
+	s.Require().ErrorIs(clientReporter.Context().Err(), context.Canceled)
+
+	serverReporter.End(nil)
+	s.Require().ErrorIs(serverReporter.Context().Err(), context.Canceled)
+}
+
+func (s *ProgressTestSuite) Test_runs_work_without_progress_for_client_that_does_not_support_it() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	withClientState(lspCtx, NewClientState(&InitializeParams{}))
+
+	service := NewProgressService(WithProgressReportInterval(0))
+	message := "1/1"
+	worked := false
+	err := service.Run(
+		lspCtx,
+		nil,
+		WorkDoneProgressBegin{Title: "Indexing"},
+		func(reporter *ProgressReporter) error {
+			worked = true
+			s.Require().Nil(reporter.Token())
+			s.Require().NoError(reporter.Context().Err())
+			return reporter.Report(WorkDoneProgressReport{Message: &message})
+		},
+	)
+	s.Require().NoError(err)
+	s.Require().True(worked)
+
+	messages := s.waitForClientMessages(container, 0)
+	s.Require().Empty(messages.methods)
+}
+
+func (s *ProgressTestSuite) Test_ends_progress_on_panic() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+
+	service := NewProgressService()
+	tokenVal := Integer(10)
+	s.Require().PanicsWithValue("unexpected failure", func() {
+		service.Run(
+			lspCtx,
+			&ProgressToken{IntVal: &tokenVal},
+			WorkDoneProgressBegin{Title: "Indexing"},
+			func(reporter *ProgressReporter) error {
+				panic("unexpected failure")
+			},
+		)
+	})
+
+	messages := s.waitForClientMessages(container, 2)
+	s.Require().Equal(
+		`{"token":10,"value":{"kind":"end","message":"unexpected failure"}}`,
+		messages.params[1],
+	)
+}
+
+func (s *ProgressTestSuite) Test_cancels_reporter_context_when_client_cancels_progress() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+
+	service := NewProgressService()
+	tokenVal := "client-token"
+	reporter, err := service.NewReporter(lspCtx, &ProgressToken{StrVal: &tokenVal})
+	s.Require().NoError(err)
+
+	cancelHandler := service.CreateWorkDoneProgressCancelHandler()
+	cancelTokenVal := "client-token"
+	err = cancelHandler(lspCtx, &WorkDoneProgressCancelParams{
+		Token: &ProgressToken{StrVal: &cancelTokenVal},
+	})
+	s.Require().NoError(err)
+
+	select {
+	case <-ctx.Done():
+		s.Fail("timeout")
+	case <-reporter.Context().Done():
+		s.Require().ErrorIs(reporter.Context().Err(), context.Canceled)
+	}
+}
+
+func (s *ProgressTestSuite) Test_streams_partial_results_in_batches() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+
+	tokenVal := "partial-token"
+	streamer := NewPartialResultStreamer[Location](
+		lspCtx,
+		PartialResultParams{PartialResultToken: &ProgressToken{StrVal: &tokenVal}},
+		2,
+	)
+	s.Require().True(streamer.IsStreaming())
+
+	s.Require().NoError(streamer.Add(
+		Location{URI: "file:///a.txt"},
+		Location{URI: "file:///b.txt"},
+		Location{URI: "file:///c.txt"},
+	))
+	result, err := streamer.Result()
+	s.Require().NoError(err)
+	s.Require().Empty(result)
+
+	messages := s.waitForClientMessages(container, 1)
+	s.Require().Len(messages.params, 1)
+	var progressParams struct {
+		Token string     `json:"token"`
+		Value []Location `json:"value"`
+	}
+	err = json.Unmarshal([]byte(messages.params[0]), &progressParams)
+	s.Require().NoError(err)
+	s.Require().Len(progressParams.Value, 3)
+}
+
+func (s *ProgressTestSuite) Test_collects_results_when_client_does_not_support_partial_results() {
+	streamer := NewPartialResultStreamer[Location](
+		&common.LSPContext{},
+		PartialResultParams{},
+		2,
+	)
+	s.Require().False(streamer.IsStreaming())
+
+	s.Require().NoError(streamer.Add(Location{URI: "file:///a.txt"}))
+	s.Require().NoError(streamer.Add(Location{URI: "file:///b.txt"}, Location{URI: "file:///c.txt"}))
+	result, err := streamer.Result()
+	s.Require().NoError(err)
+	s.Require().Len(result, 3)
+}
+
+type receivedClientMessages struct {
+	methods []string
+	params  []string
+}
+
+func (s *ProgressTestSuite) waitForClientMessages(
+	container *testConnectionsContainer,
+	count int,
+) receivedClientMessages {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		container.mu.Lock()
+		if len(container.clientReceivedMethods) >= count {
+			messages := receivedClientMessages{
+				methods: append([]string{}, container.clientReceivedMethods...),
+			}
+			for _, params := range container.clientReceivedMessages {
+				messages.params = append(messages.params, string(*params))
+			}
+			container.mu.Unlock()
+			// Allow for any unexpected extra messages to arrive.
+			time.Sleep(10 * time.Millisecond)
+			container.mu.Lock()
+			defer container.mu.Unlock()
+			s.Require().Len(container.clientReceivedMethods, count)
+			return messages
+		}
+		container.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	s.FailNow("timeout waiting for client messages")
+	return receivedClientMessages{}
+}
+
+func TestProgressTestSuite(t *testing.T) {
+	suite.Run(t, new(ProgressTestSuite))
+}
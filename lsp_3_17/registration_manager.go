@@ -0,0 +1,292 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/two-hundred/ls-builder/common"
+)
+
+// RegistrationManager provides typed helpers to dynamically register
+// capabilities with the client using `client/registerCapability`,
+// keeping track of active registrations so they can be unregistered
+// with the handle returned when they were registered.
+//
+// Registrations are refused with ErrNotSupportedByClient when the client
+// has not declared dynamic registration support for the method
+// in its capabilities.
+type RegistrationManager struct {
+	idPrefix       string
+	idCounter      uint64
+	dynamicMethods map[string]bool
+	registrations  map[string]Registration
+	mu             sync.Mutex
+}
+
+// RegistrationManagerOption is a function that can be used to configure
+// a registration manager.
+type RegistrationManagerOption func(*RegistrationManager)
+
+// WithRegistrationIDPrefix sets the prefix used for the unique IDs
+// generated for registrations.
+func WithRegistrationIDPrefix(prefix string) RegistrationManagerOption {
+	return func(m *RegistrationManager) {
+		m.idPrefix = prefix
+	}
+}
+
+// WithDynamicRegistration declares the methods that the server will register
+// dynamically, for clients that support dynamic registration for these methods,
+// the capabilities will be left out of the server capabilities created with
+// `Handler.CreateServerCapabilities`.
+func WithDynamicRegistration(methods ...string) RegistrationManagerOption {
+	return func(m *RegistrationManager) {
+		for _, method := range methods {
+			m.dynamicMethods[method] = true
+		}
+	}
+}
+
+// NewRegistrationManager creates a new registration manager.
+func NewRegistrationManager(opts ...RegistrationManagerOption) *RegistrationManager {
+	manager := &RegistrationManager{
+		idPrefix:       "ls-builder-registration",
+		dynamicMethods: make(map[string]bool),
+		registrations:  make(map[string]Registration),
+	}
+	for _, opt := range opts {
+		opt(manager)
+	}
+	return manager
+}
+
+// RegistrationHandle is a handle for one or more registrations
+// made in a single `client/registerCapability` request
+// that can be used to unregister them.
+type RegistrationHandle struct {
+	registrations []Registration
+}
+
+// Registrations returns the registrations that the handle is for.
+func (h *RegistrationHandle) Registrations() []Registration {
+	return h.registrations
+}
+
+// RegistersDynamically determines whether the capability for the provided method
+// is registered dynamically instead of being declared in the server capabilities
+// sent to the client in the initialize result.
+// This is the case for methods declared with `WithDynamicRegistration` when the client
+// supports dynamic registration for the method, when the client state is not known,
+// the capability is declared statically as the client may not support registering it.
+func (m *RegistrationManager) RegistersDynamically(method string, clientState *ClientState) bool {
+	if clientState == nil {
+		return false
+	}
+
+	m.mu.Lock()
+	isDynamic := m.dynamicMethods[method]
+	m.mu.Unlock()
+
+	return isDynamic && clientState.SupportsDynamicRegistration(method)
+}
+
+// Register dynamically registers the capability for the provided method with
+// the given registration options.
+// Prefer the typed helpers such as `RegisterCompletion` where available.
+func (m *RegistrationManager) Register(
+	ctx *common.LSPContext,
+	method string,
+	registerOptions any,
+) (*RegistrationHandle, error) {
+	return m.register(ctx, []Registration{
+		m.newRegistration(method, registerOptions),
+	})
+}
+
+// RegisterDidChangeWatchedFiles dynamically registers file system watchers
+// with the client, the server will receive `workspace/didChangeWatchedFiles`
+// notifications for changes to files that match the watchers.
+func (m *RegistrationManager) RegisterDidChangeWatchedFiles(
+	ctx *common.LSPContext,
+	watchers []FileSystemWatcher,
+) (*RegistrationHandle, error) {
+	return m.Register(
+		ctx,
+		MethodWorkspaceDidChangeWatchedFiles,
+		DidChangeWatchedFilesRegistrationOptions{
+			Watchers: watchers,
+		},
+	)
+}
+
+// RegisterCompletion dynamically registers completion support for the documents
+// that match the provided selector.
+// When the selector is nil, the document selector provided on the client side will be used.
+func (m *RegistrationManager) RegisterCompletion(
+	ctx *common.LSPContext,
+	selector DocumentSelector,
+	options CompletionOptions,
+) (*RegistrationHandle, error) {
+	registrationOptions := CompletionRegistrationOptions{
+		CompletionOptions: options,
+	}
+	if selector != nil {
+		registrationOptions.DocumentSelector = &selector
+	}
+
+	return m.Register(ctx, MethodCompletion, registrationOptions)
+}
+
+// RegisterFileOperations dynamically registers interest in file operations
+// for each of the operations that filters are provided for in a single request.
+// The returned handle can be used to unregister all of the file operations
+// registered in the request.
+func (m *RegistrationManager) RegisterFileOperations(
+	ctx *common.LSPContext,
+	fileOperations WorkspaceFileOperationServerCapabilities,
+) (*RegistrationHandle, error) {
+	operations := []struct {
+		method  string
+		options *FileOperationRegistrationOptions
+	}{
+		{MethodWorkspaceDidCreateFiles, fileOperations.DidCreate},
+		{MethodWorkspaceWillCreateFiles, fileOperations.WillCreate},
+		{MethodWorkspaceDidRenameFiles, fileOperations.DidRename},
+		{MethodWorkspaceWillRenameFiles, fileOperations.WillRename},
+		{MethodWorkspaceDidDeleteFiles, fileOperations.DidDelete},
+		{MethodWorkspaceWillDeleteFiles, fileOperations.WillDelete},
+	}
+
+	registrations := []Registration{}
+	for _, operation := range operations {
+		if operation.options != nil {
+			registrations = append(
+				registrations,
+				m.newRegistration(operation.method, *operation.options),
+			)
+		}
+	}
+
+	return m.register(ctx, registrations)
+}
+
+// Unregister unregisters all the capabilities registered with the
+// provided handle with `client/unregisterCapability`.
+func (m *RegistrationManager) Unregister(ctx *common.LSPContext, handle *RegistrationHandle) error {
+	m.mu.Lock()
+	unregistrations := []Unregistration{}
+	for _, registration := range handle.registrations {
+		if _, isActive := m.registrations[registration.ID]; !isActive {
+			m.mu.Unlock()
+			return fmt.Errorf("%w: %s", ErrRegistrationNotActive, registration.ID)
+		}
+		unregistrations = append(unregistrations, Unregistration{
+			ID:     registration.ID,
+			Method: registration.Method,
+		})
+	}
+	m.mu.Unlock()
+
+	dispatcher := NewDispatcher(ctx)
+	err := dispatcher.UnregisterCapability(UnregistrationParams{
+		Unregistrations: unregistrations,
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, unregistration := range unregistrations {
+		delete(m.registrations, unregistration.ID)
+	}
+	return nil
+}
+
+// ActiveRegistrations returns all the registrations that are currently active,
+// ordered by registration ID.
+func (m *RegistrationManager) ActiveRegistrations() []Registration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	registrations := make([]Registration, 0, len(m.registrations))
+	for _, registration := range m.registrations {
+		registrations = append(registrations, registration)
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].ID < registrations[j].ID
+	})
+	return registrations
+}
+
+// IsRegistered determines whether there is at least one active registration
+// for the provided method.
+func (m *RegistrationManager) IsRegistered(method string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, registration := range m.registrations {
+		if registration.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *RegistrationManager) register(
+	ctx *common.LSPContext,
+	registrations []Registration,
+) (*RegistrationHandle, error) {
+	dispatcher := NewDispatcher(ctx)
+	err := dispatcher.RegisterCapability(RegistrationParams{
+		Registrations: registrations,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, registration := range registrations {
+		m.registrations[registration.ID] = registration
+	}
+	return &RegistrationHandle{registrations: registrations}, nil
+}
+
+func (m *RegistrationManager) newRegistration(method string, registerOptions any) Registration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.idCounter += 1
+	return Registration{
+		ID:              fmt.Sprintf("%s-%d", m.idPrefix, m.idCounter),
+		Method:          method,
+		RegisterOptions: registerOptions,
+	}
+}
+
+// WithRegistrationManager sets the registration manager used to determine
+// which capabilities are registered dynamically and should be left out of the
+// server capabilities created with `CreateServerCapabilities`.
+func WithRegistrationManager(manager *RegistrationManager) HandlerOption {
+	return func(root *Handler) {
+		root.SetRegistrationManager(manager)
+	}
+}
+
+// SetRegistrationManager sets the registration manager used to determine
+// which capabilities are registered dynamically and should be left out of the
+// server capabilities created with `CreateServerCapabilities`.
+func (h *Handler) SetRegistrationManager(manager *RegistrationManager) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.registrationManager = manager
+}
+
+// RegistrationManager returns the registration manager attached to the handler,
+// this will be nil if a registration manager has not been attached.
+func (h *Handler) RegistrationManager() *RegistrationManager {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.registrationManager
+}
@@ -0,0 +1,193 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"github.com/two-hundred/ls-builder/server"
+)
+
+type RegistrationManagerTestSuite struct {
+	suite.Suite
+}
+
+func (s *RegistrationManagerTestSuite) Test_registers_and_unregisters_typed_capabilities() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	withClientState(lspCtx, NewClientState(&InitializeParams{
+		Capabilities: ClientCapabilities{
+			TextDocument: &TextDocumentClientCapabilities{
+				Completion: &CompletionClientCapabilities{
+					DynamicRegistration: &True,
+				},
+			},
+			Workspace: &ClientWorkspaceCapabilities{
+				DidChangeWatchedFiles: &DidChangeWatchedFilesClientCapabilities{
+					DynamicRegistration: &True,
+				},
+				FileOperations: &FileOperationClientCapabilities{
+					DynamicRegistration: &True,
+				},
+			},
+		},
+	}))
+
+	manager := NewRegistrationManager(WithRegistrationIDPrefix("test"))
+	watchKind := WatchKindCreate | WatchKindDelete
+	_, err := manager.RegisterDidChangeWatchedFiles(lspCtx, []FileSystemWatcher{
+		{GlobPattern: "**/*.yaml", Kind: &watchKind},
+	})
+	s.Require().NoError(err)
+
+	language := "yaml"
+	completionHandle, err := manager.RegisterCompletion(
+		lspCtx,
+		DocumentSelector{{Language: &language}},
+		CompletionOptions{TriggerCharacters: []string{"."}},
+	)
+	s.Require().NoError(err)
+
+	fileOperationsHandle, err := manager.RegisterFileOperations(
+		lspCtx,
+		WorkspaceFileOperationServerCapabilities{
+			DidCreate: &FileOperationRegistrationOptions{
+				Filters: []FileOperationFilter{{Pattern: FileOperationPattern{Glob: "**/*.yaml"}}},
+			},
+			WillDelete: &FileOperationRegistrationOptions{
+				Filters: []FileOperationFilter{{Pattern: FileOperationPattern{Glob: "**"}}},
+			},
+		},
+	)
+	s.Require().NoError(err)
+	s.Require().Len(fileOperationsHandle.Registrations(), 2)
+
+	s.Require().Equal(
+		[]string{"test-1", "test-2", "test-3", "test-4"},
+		registrationIDs(manager.ActiveRegistrations()),
+	)
+	s.Require().True(manager.IsRegistered(MethodCompletion))
+
+	err = manager.Unregister(lspCtx, completionHandle)
+	s.Require().NoError(err)
+	s.Require().False(manager.IsRegistered(MethodCompletion))
+
+	err = manager.Unregister(lspCtx, fileOperationsHandle)
+	s.Require().NoError(err)
+	s.Require().Equal(
+		[]string{"test-1"},
+		registrationIDs(manager.ActiveRegistrations()),
+	)
+
+	err = manager.Unregister(lspCtx, completionHandle)
+	s.Require().ErrorIs(err, ErrRegistrationNotActive)
+
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	s.Require().Equal(
+		[]string{
+			ClientRegisterCapability,
+			ClientRegisterCapability,
+			ClientRegisterCapability,
+			ClientUnregisterCapability,
+			ClientUnregisterCapability,
+		},
+		container.clientReceivedMethods,
+	)
+	s.Require().Equal(
+		`{"registrations":[{"id":"test-1","method":"workspace/didChangeWatchedFiles",`+
+			`"registerOptions":{"watchers":[{"globPattern":"**/*.yaml","kind":5}]}}]}`,
+		string(*container.clientReceivedMessages[0]),
+	)
+	s.Require().Equal(
+		`{"registrations":[{"id":"test-2","method":"textDocument/completion",`+
+			`"registerOptions":{"documentSelector":[{"language":"yaml"}],"triggerCharacters":["."]}}]}`,
+		string(*container.clientReceivedMessages[1]),
+	)
+	s.Require().Equal(
+		`{"unregisterations":[{"id":"test-3","method":"workspace/didCreateFiles"},`+
+			`{"id":"test-4","method":"workspace/willDeleteFiles"}]}`,
+		string(*container.clientReceivedMessages[4]),
+	)
+}
+
+func (s *RegistrationManagerTestSuite) Test_refuses_registration_not_supported_by_client() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	withClientState(lspCtx, NewClientState(&InitializeParams{}))
+
+	manager := NewRegistrationManager()
+	_, err := manager.RegisterCompletion(lspCtx, nil, CompletionOptions{})
+	s.Require().ErrorIs(err, ErrNotSupportedByClient)
+	s.Require().Empty(manager.ActiveRegistrations())
+
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	s.Require().Empty(container.clientReceivedMethods)
+}
+
+func (s *RegistrationManagerTestSuite) Test_leaves_dynamically_registered_capabilities_out_of_server_capabilities() {
+	handler := NewHandler(
+		WithCompletionHandler(
+			func(ctx *common.LSPContext, params *CompletionParams) (any, error) {
+				return nil, nil
+			},
+		),
+		WithHoverHandler(
+			func(ctx *common.LSPContext, params *HoverParams) (*Hover, error) {
+				return nil, nil
+			},
+		),
+		WithWorkspaceDidCreateFilesHandler(
+			func(ctx *common.LSPContext, params *CreateFilesParams) error {
+				return nil
+			},
+		),
+		WithRegistrationManager(
+			NewRegistrationManager(
+				WithDynamicRegistration(MethodCompletion, MethodWorkspaceDidCreateFiles),
+			),
+		),
+	)
+
+	// Capabilities are declared statically when the client's support
+	// for dynamic registration is not known.
+	capabilities := handler.CreateServerCapabilities()
+	s.Require().NotNil(capabilities.CompletionProvider)
+	s.Require().NotNil(capabilities.Workspace.FileOperations.DidCreate)
+
+	handler.setClientState(NewClientState(&InitializeParams{
+		Capabilities: ClientCapabilities{
+			TextDocument: &TextDocumentClientCapabilities{
+				Completion: &CompletionClientCapabilities{
+					DynamicRegistration: &True,
+				},
+			},
+		},
+	}))
+	capabilities = handler.CreateServerCapabilities()
+	s.Require().Nil(capabilities.CompletionProvider)
+	s.Require().Equal(true, capabilities.HoverProvider)
+	// The client does not support dynamic registration for file operations
+	// so the capability should be declared statically.
+	s.Require().NotNil(capabilities.Workspace.FileOperations.DidCreate)
+}
+
+func registrationIDs(registrations []Registration) []string {
+	ids := []string{}
+	for _, registration := range registrations {
+		ids = append(ids, registration.ID)
+	}
+	return ids
+}
+
+func TestRegistrationManagerTestSuite(t *testing.T) {
+	suite.Run(t, new(RegistrationManagerTestSuite))
+}
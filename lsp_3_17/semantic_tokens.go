@@ -0,0 +1,645 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/two-hundred/ls-builder/common"
+)
+
+const (
+	// The number of integers used to encode a single semantic token
+	// in the relative format sent to the client.
+	semanticTokenIntegers = 5
+
+	// Token modifiers are encoded as bit flags in an unsigned 32-bit integer.
+	maxSemanticTokenModifiers = 32
+)
+
+// SemanticTokensRegistry holds the token types and modifiers that a server
+// uses for semantic tokens, from which the legend sent to the client in the
+// server capabilities is derived.
+//
+// The index of a token type and the bit of a token modifier in the
+// encoded tokens is determined by the order in which they are registered,
+// so all token types and modifiers should be registered before the legend is
+// sent to the client.
+type SemanticTokensRegistry struct {
+	tokenTypes     []string
+	typeIndexes    map[SemanticTokenType]UInteger
+	tokenModifiers []string
+	modifierBits   map[SemanticTokenModifier]UInteger
+	mu             sync.RWMutex
+}
+
+// NewSemanticTokensRegistry creates a new semantic tokens registry
+// with the provided token types registered.
+func NewSemanticTokensRegistry(tokenTypes ...SemanticTokenType) *SemanticTokensRegistry {
+	registry := &SemanticTokensRegistry{
+		tokenTypes:     []string{},
+		typeIndexes:    make(map[SemanticTokenType]UInteger),
+		tokenModifiers: []string{},
+		modifierBits:   make(map[SemanticTokenModifier]UInteger),
+	}
+	registry.RegisterTokenTypes(tokenTypes...)
+	return registry
+}
+
+// RegisterTokenTypes registers token types that can be used for semantic tokens,
+// token types that have already been registered are ignored.
+func (r *SemanticTokensRegistry) RegisterTokenTypes(tokenTypes ...SemanticTokenType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, tokenType := range tokenTypes {
+		if _, exists := r.typeIndexes[tokenType]; !exists {
+			r.typeIndexes[tokenType] = UInteger(len(r.tokenTypes))
+			r.tokenTypes = append(r.tokenTypes, tokenType)
+		}
+	}
+}
+
+// RegisterTokenModifiers registers token modifiers that can be used for semantic tokens,
+// token modifiers that have already been registered are ignored.
+// Token modifiers are encoded as bit flags so at most 32 modifiers can be registered,
+// an error is returned and no modifiers are registered if the limit would be exceeded.
+func (r *SemanticTokensRegistry) RegisterTokenModifiers(tokenModifiers ...SemanticTokenModifier) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	newModifiers := []SemanticTokenModifier{}
+	for _, tokenModifier := range tokenModifiers {
+		_, exists := r.modifierBits[tokenModifier]
+		if !exists && !containsModifier(newModifiers, tokenModifier) {
+			newModifiers = append(newModifiers, tokenModifier)
+		}
+	}
+
+	if len(r.tokenModifiers)+len(newModifiers) > maxSemanticTokenModifiers {
+		return fmt.Errorf(
+			"%w: %d modifiers would exceed the limit of %d",
+			ErrTooManySemanticTokenModifiers,
+			len(r.tokenModifiers)+len(newModifiers),
+			maxSemanticTokenModifiers,
+		)
+	}
+
+	for _, tokenModifier := range newModifiers {
+		r.modifierBits[tokenModifier] = 1 << len(r.tokenModifiers)
+		r.tokenModifiers = append(r.tokenModifiers, string(tokenModifier))
+	}
+	return nil
+}
+
+// Legend derives the semantic tokens legend to send to the client
+// from the registered token types and modifiers.
+func (r *SemanticTokensRegistry) Legend() SemanticTokensLegend {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return SemanticTokensLegend{
+		TokenTypes:     append([]string{}, r.tokenTypes...),
+		TokenModifiers: append([]string{}, r.tokenModifiers...),
+	}
+}
+
+func (r *SemanticTokensRegistry) encode(
+	tokenType SemanticTokenType,
+	tokenModifiers []SemanticTokenModifier,
+) (UInteger, UInteger, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	typeIndex, ok := r.typeIndexes[tokenType]
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %q", ErrUnknownSemanticTokenType, tokenType)
+	}
+
+	modifierBits := UInteger(0)
+	for _, tokenModifier := range tokenModifiers {
+		bit, ok := r.modifierBits[tokenModifier]
+		if !ok {
+			return 0, 0, fmt.Errorf("%w: %q", ErrUnknownSemanticTokenModifier, tokenModifier)
+		}
+		modifierBits |= bit
+	}
+
+	return typeIndex, modifierBits, nil
+}
+
+func containsModifier(tokenModifiers []SemanticTokenModifier, tokenModifier SemanticTokenModifier) bool {
+	for _, candidate := range tokenModifiers {
+		if candidate == tokenModifier {
+			return true
+		}
+	}
+	return false
+}
+
+type semanticToken struct {
+	// Byte offsets of the token in the document content.
+	start int
+	end   int
+
+	tokenType    UInteger
+	modifierBits UInteger
+}
+
+// SemanticTokensBuilder collects the semantic tokens of a document
+// with absolute positions and encodes them in the relative format
+// expected by the client.
+//
+// Tokens can be pushed in any order, multi-line tokens are split into a token
+// for each line so the result can be used with clients that do not support
+// multi-line tokens.
+// Character offsets and token lengths are expressed in the provided
+// position encoding, this should be the encoding negotiated with the client
+// which can be retrieved with `PositionEncodingKindFromContext`.
+type SemanticTokensBuilder struct {
+	registry        *SemanticTokensRegistry
+	text            string
+	posEncodingKind PositionEncodingKind
+	tokens          []semanticToken
+}
+
+// NewSemanticTokensBuilder creates a new builder for the semantic tokens
+// of a document with the provided content.
+func NewSemanticTokensBuilder(
+	registry *SemanticTokensRegistry,
+	text string,
+	posEncodingKind PositionEncodingKind,
+) *SemanticTokensBuilder {
+	return &SemanticTokensBuilder{
+		registry:        registry,
+		text:            text,
+		posEncodingKind: posEncodingKind,
+		tokens:          []semanticToken{},
+	}
+}
+
+// Push adds a token for the given range of the document, the range is expected
+// to be expressed in the position encoding of the builder.
+// An error is returned when the range is not within the content of the document.
+func (b *SemanticTokensBuilder) Push(
+	tokenRange Range,
+	tokenType SemanticTokenType,
+	tokenModifiers ...SemanticTokenModifier,
+) error {
+	start, startInText := b.indexIn(tokenRange.Start)
+	end, endInText := b.indexIn(tokenRange.End)
+	if !startInText || !endInText {
+		return fmt.Errorf(
+			"%w: range %d:%d to %d:%d is not within the document content",
+			ErrInvalidSemanticTokenRange,
+			tokenRange.Start.Line,
+			tokenRange.Start.Character,
+			tokenRange.End.Line,
+			tokenRange.End.Character,
+		)
+	}
+
+	return b.PushOffsets(start, end, tokenType, tokenModifiers...)
+}
+
+func (b *SemanticTokensBuilder) indexIn(position Position) (int, bool) {
+	index, lineInText, characterInLine := position.clampedIndexIn(b.text, b.posEncodingKind)
+	return index, lineInText && characterInLine
+}
+
+// PushOffsets adds a token between the given byte offsets in the content of the document,
+// this is useful for servers that use parsers that track byte offsets instead of
+// line and character positions.
+// Empty tokens are ignored.
+func (b *SemanticTokensBuilder) PushOffsets(
+	start int,
+	end int,
+	tokenType SemanticTokenType,
+	tokenModifiers ...SemanticTokenModifier,
+) error {
+	if start < 0 || end > len(b.text) || start > end {
+		return fmt.Errorf(
+			"%w: offsets %d to %d are not within the document content of length %d",
+			ErrInvalidSemanticTokenRange,
+			start,
+			end,
+			len(b.text),
+		)
+	}
+
+	typeIndex, modifierBits, err := b.registry.encode(tokenType, tokenModifiers)
+	if err != nil {
+		return err
+	}
+
+	if start == end {
+		return nil
+	}
+
+	b.tokens = append(b.tokens, semanticToken{
+		start:        start,
+		end:          end,
+		tokenType:    typeIndex,
+		modifierBits: modifierBits,
+	})
+	return nil
+}
+
+// Build encodes all the tokens that have been pushed to the builder.
+// Overlapping tokens are not supported by the protocol, when tokens overlap
+// the token that starts first takes precedence.
+func (b *SemanticTokensBuilder) Build() *SemanticTokens {
+	return &SemanticTokens{
+		Data: b.encode(0, len(b.text)),
+	}
+}
+
+// BuildRange encodes the tokens that have been pushed to the builder
+// that intersect with the given range, this can be used to respond to
+// `textDocument/semanticTokens/range` requests.
+// Parts of the range that are past the end of a line or the document are clamped
+// to the end of the line or document.
+func (b *SemanticTokensBuilder) BuildRange(tokenRange Range) *SemanticTokens {
+	start, _, _ := tokenRange.Start.clampedIndexIn(b.text, b.posEncodingKind)
+	end, _, _ := tokenRange.End.clampedIndexIn(b.text, b.posEncodingKind)
+	return &SemanticTokens{
+		Data: b.encode(start, end),
+	}
+}
+
+func (b *SemanticTokensBuilder) encode(rangeStart int, rangeEnd int) []UInteger {
+	tokens := append([]semanticToken{}, b.tokens...)
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return tokens[i].start < tokens[j].start
+	})
+
+	encoder := &semanticTokensEncoder{
+		text:            b.text,
+		posEncodingKind: b.posEncodingKind,
+		data:            make([]UInteger, 0, len(tokens)*semanticTokenIntegers),
+	}
+	for _, token := range tokens {
+		if token.end <= rangeStart || token.start >= rangeEnd {
+			continue
+		}
+		encoder.encode(token)
+	}
+
+	return encoder.data
+}
+
+// semanticTokensEncoder walks through the content of a document once
+// to convert the byte offsets of tokens sorted by their start offset into
+// positions in the negotiated encoding.
+type semanticTokensEncoder struct {
+	text            string
+	posEncodingKind PositionEncodingKind
+	data            []UInteger
+
+	// The byte offset, line and character the encoder has reached in the content.
+	offset    int
+	line      UInteger
+	character UInteger
+
+	// The line and character of the last token that was encoded.
+	prevLine      UInteger
+	prevCharacter UInteger
+
+	// The byte offset where the encoded tokens end.
+	tokensEnd int
+}
+
+func (e *semanticTokensEncoder) encode(token semanticToken) {
+	// Clip the start of tokens that overlap with a previously encoded token.
+	start := max(token.start, e.tokensEnd)
+	e.tokensEnd = max(e.tokensEnd, token.end)
+	for start < token.end {
+		segmentEnd := token.end
+		if newLine := strings.IndexByte(e.text[start:token.end], '\n'); newLine != -1 {
+			segmentEnd = start + newLine
+		}
+
+		e.advanceTo(start)
+		segment := strings.TrimSuffix(e.text[start:segmentEnd], "\r")
+		length := codeUnitsIn(segment, e.posEncodingKind)
+		if length > 0 {
+			e.push(length, token)
+		}
+
+		// Move on to the start of the next line for multi-line tokens.
+		start = segmentEnd + 1
+	}
+}
+
+func (e *semanticTokensEncoder) advanceTo(offset int) {
+	for e.offset < offset {
+		r, w := utf8.DecodeRuneInString(e.text[e.offset:])
+		if r == '\n' {
+			e.line += 1
+			e.character = 0
+		} else {
+			e.character += runeCodeUnits(r, w, e.posEncodingKind)
+		}
+		e.offset += w
+	}
+}
+
+func (e *semanticTokensEncoder) push(length UInteger, token semanticToken) {
+	deltaLine := e.line - e.prevLine
+	deltaStart := e.character
+	if deltaLine == 0 {
+		deltaStart = e.character - e.prevCharacter
+	}
+
+	e.data = append(e.data, deltaLine, deltaStart, length, token.tokenType, token.modifierBits)
+	e.prevLine = e.line
+	e.prevCharacter = e.character
+}
+
+func codeUnitsIn(text string, posEncodingKind PositionEncodingKind) UInteger {
+	count := UInteger(0)
+	for len(text) > 0 {
+		r, w := utf8.DecodeRuneInString(text)
+		count += runeCodeUnits(r, w, posEncodingKind)
+		text = text[w:]
+	}
+	return count
+}
+
+func runeCodeUnits(r rune, width int, posEncodingKind PositionEncodingKind) UInteger {
+	switch posEncodingKind {
+	case PositionEncodingKindUTF8:
+		return UInteger(width)
+	case PositionEncodingKindUTF32:
+		return 1
+	default:
+		if r >= utf16_2CodePoints {
+			return 2
+		}
+		return 1
+	}
+}
+
+// SemanticTokensCache holds the last semantic tokens result sent to the client
+// for each document so that subsequent requests can be answered with
+// a delta from the previous result.
+type SemanticTokensCache struct {
+	results      map[DocumentURI]semanticTokensResult
+	nextResultID uint64
+	mu           sync.Mutex
+}
+
+type semanticTokensResult struct {
+	resultID string
+	data     []UInteger
+}
+
+// NewSemanticTokensCache creates a new empty semantic tokens cache.
+func NewSemanticTokensCache() *SemanticTokensCache {
+	return &SemanticTokensCache{
+		results: make(map[DocumentURI]semanticTokensResult),
+	}
+}
+
+// Store assigns a new result ID to the given tokens and caches them
+// as the latest result for the document.
+func (c *SemanticTokensCache) Store(uri DocumentURI, tokens *SemanticTokens) *SemanticTokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resultID := c.store(uri, tokens.Data)
+	tokens.ResultID = &resultID
+	return tokens
+}
+
+// Delta computes the edits to transform the previous result for the document
+// into the given tokens, the given tokens are cached as the latest result
+// for the document under a new result ID.
+//
+// When the previous result ID is not the latest result cached for the document
+// (e.g. the result has been replaced or deleted), edits can not be computed,
+// so the given tokens are returned as a full result under the new result ID.
+//
+// Returns: *SemanticTokensDelta | *SemanticTokens
+func (c *SemanticTokensCache) Delta(
+	uri DocumentURI,
+	previousResultID string,
+	tokens *SemanticTokens,
+) any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous, ok := c.results[uri]
+	resultID := c.store(uri, tokens.Data)
+	if !ok || previous.resultID != previousResultID {
+		tokens.ResultID = &resultID
+		return tokens
+	}
+
+	return &SemanticTokensDelta{
+		ResultID: &resultID,
+		Edits:    ComputeSemanticTokensEdits(previous.data, tokens.Data),
+	}
+}
+
+// Delete removes the cached result for the document,
+// this should be called when a document is closed.
+// A `Handler` with a provider attached calls this for the provider's cache
+// when a document is closed.
+func (c *SemanticTokensCache) Delete(uri DocumentURI) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.results, uri)
+}
+
+// clear removes the cached results for all documents.
+func (c *SemanticTokensCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = make(map[DocumentURI]semanticTokensResult)
+}
+
+func (c *SemanticTokensCache) store(uri DocumentURI, data []UInteger) string {
+	c.nextResultID += 1
+	resultID := strconv.FormatUint(c.nextResultID, 10)
+	c.results[uri] = semanticTokensResult{
+		resultID: resultID,
+		data:     data,
+	}
+	return resultID
+}
+
+// ComputeSemanticTokensEdits computes the edits to transform the previous
+// encoded tokens into the current encoded tokens.
+// Edits to tokens are usually localised to a small region of the document
+// so a single edit that replaces everything between the common prefix and
+// suffix of the two token arrays is produced, no edits are produced when
+// the tokens are unchanged.
+func ComputeSemanticTokensEdits(previous []UInteger, current []UInteger) []SemanticTokensEdit {
+	prefix := 0
+	for prefix < len(previous) && prefix < len(current) && previous[prefix] == current[prefix] {
+		prefix += 1
+	}
+
+	if prefix == len(previous) && prefix == len(current) {
+		return []SemanticTokensEdit{}
+	}
+
+	suffix := 0
+	for suffix < len(previous)-prefix &&
+		suffix < len(current)-prefix &&
+		previous[len(previous)-suffix-1] == current[len(current)-suffix-1] {
+		suffix += 1
+	}
+
+	return []SemanticTokensEdit{
+		{
+			Start:       UInteger(prefix),
+			DeleteCount: UInteger(len(previous) - prefix - suffix),
+			Data:        append([]UInteger{}, current[prefix:len(current)-suffix]...),
+		},
+	}
+}
+
+// SemanticTokensFunc produces the semantic tokens for a document
+// by pushing them to the provided builder.
+type SemanticTokensFunc func(
+	ctx *common.LSPContext,
+	document *TextDocument,
+	builder *SemanticTokensBuilder,
+) error
+
+// SemanticTokensProvider implements the `textDocument/semanticTokens/full`,
+// `textDocument/semanticTokens/full/delta` and `textDocument/semanticTokens/range`
+// request handlers from a single function that produces the tokens
+// for the content of a document.
+//
+// Documents are retrieved from a document store that must be kept in sync
+// with the client, results are cached by result ID to compute deltas.
+//
+// A provider can be attached to a `Handler` with `WithSemanticTokensProvider`
+// or `SetSemanticTokensProvider`, the provider then retrieves documents from
+// the handler's document store and cached results are removed when documents are closed.
+type SemanticTokensProvider struct {
+	registry *SemanticTokensRegistry
+	store    *DocumentStore
+	cache    *SemanticTokensCache
+	tokens   SemanticTokensFunc
+	mu       sync.Mutex
+}
+
+// NewSemanticTokensProvider creates a new semantic tokens provider
+// for the documents in the given store.
+func NewSemanticTokensProvider(
+	registry *SemanticTokensRegistry,
+	store *DocumentStore,
+	tokens SemanticTokensFunc,
+) *SemanticTokensProvider {
+	return &SemanticTokensProvider{
+		registry: registry,
+		store:    store,
+		cache:    NewSemanticTokensCache(),
+		tokens:   tokens,
+	}
+}
+
+// Registry returns the registry that holds the token types
+// and modifiers of the provider.
+func (p *SemanticTokensProvider) Registry() *SemanticTokensRegistry {
+	return p.registry
+}
+
+// Cache returns the cache that holds the last result sent to the client
+// for each document.
+func (p *SemanticTokensProvider) Cache() *SemanticTokensCache {
+	return p.cache
+}
+
+// Store returns the document store that documents are retrieved from.
+func (p *SemanticTokensProvider) Store() *DocumentStore {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.store
+}
+
+// setStore rebinds the provider to retrieve documents from the provided store,
+// discarding the results cached for documents from the previous store.
+func (p *SemanticTokensProvider) setStore(store *DocumentStore) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.store == store {
+		return
+	}
+
+	p.store = store
+	p.cache.clear()
+}
+
+// Full handles the `textDocument/semanticTokens/full` request.
+// Fulfils the SemanticTokensFullHandlerFunc signature.
+func (p *SemanticTokensProvider) Full(
+	ctx *common.LSPContext,
+	params *SemanticTokensParams,
+) (*SemanticTokens, error) {
+	builder, err := p.build(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.cache.Store(params.TextDocument.URI, builder.Build()), nil
+}
+
+// FullDelta handles the `textDocument/semanticTokens/full/delta` request,
+// responding with the full set of tokens when the previous result is no longer cached.
+// Fulfils the SemanticTokensFullDeltaHandlerFunc signature.
+//
+// Returns: *SemanticTokensDelta | *SemanticTokens
+func (p *SemanticTokensProvider) FullDelta(
+	ctx *common.LSPContext,
+	params *SemanticTokensDeltaParams,
+) (any, error) {
+	builder, err := p.build(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.cache.Delta(params.TextDocument.URI, params.PreviousResultID, builder.Build()), nil
+}
+
+// Range handles the `textDocument/semanticTokens/range` request.
+// Fulfils the SemanticTokensRangeHandlerFunc signature.
+func (p *SemanticTokensProvider) Range(
+	ctx *common.LSPContext,
+	params *SemanticTokensRangeParams,
+) (*SemanticTokens, error) {
+	builder, err := p.build(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return builder.BuildRange(params.Range), nil
+}
+
+func (p *SemanticTokensProvider) build(
+	ctx *common.LSPContext,
+	uri DocumentURI,
+) (*SemanticTokensBuilder, error) {
+	document, ok := p.Store().Get(uri)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDocumentNotOpen, uri)
+	}
+
+	builder := NewSemanticTokensBuilder(
+		p.registry,
+		document.Text,
+		PositionEncodingKindFromContext(ctx),
+	)
+	if err := p.tokens(ctx, document, builder); err != nil {
+		return nil, err
+	}
+
+	return builder, nil
+}
@@ -0,0 +1,413 @@
+package lsp
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+)
+
+type SemanticTokensTestSuite struct {
+	suite.Suite
+}
+
+func (s *SemanticTokensTestSuite) Test_encodes_tokens_in_negotiated_position_encoding() {
+	tests := []struct {
+		name            string
+		posEncodingKind PositionEncodingKind
+		stringLength    UInteger
+	}{
+		{
+			name:            "UTF-16",
+			posEncodingKind: PositionEncodingKindUTF16,
+			stringLength:    4,
+		},
+		{
+			name:            "UTF-8",
+			posEncodingKind: PositionEncodingKindUTF8,
+			stringLength:    6,
+		},
+		{
+			name:            "UTF-32",
+			posEncodingKind: PositionEncodingKindUTF32,
+			stringLength:    3,
+		},
+	}
+
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			registry := s.createRegistry()
+			builder := NewSemanticTokensBuilder(registry, "let x = \"😀\" + y\nx", test.posEncodingKind)
+
+			// Tokens are pushed out of order to make sure the builder sorts them.
+			err := builder.Push(
+				Range{Start: Position{Line: 1, Character: 0}, End: Position{Line: 1, Character: 1}},
+				SemanticTokenTypeVariable,
+			)
+			s.Require().NoError(err)
+			err = builder.PushOffsets(8, 14, SemanticTokenTypeString)
+			s.Require().NoError(err)
+			err = builder.PushOffsets(0, 3, SemanticTokenTypeKeyword)
+			s.Require().NoError(err)
+			err = builder.PushOffsets(
+				4, 5, SemanticTokenTypeVariable,
+				SemanticTokenModifierDeclaration, SemanticTokenModifierReadonly,
+			)
+			s.Require().NoError(err)
+			err = builder.PushOffsets(17, 18, SemanticTokenTypeVariable)
+			s.Require().NoError(err)
+
+			s.Require().Equal(
+				&SemanticTokens{
+					Data: []UInteger{
+						0, 0, 3, 0, 0,
+						0, 4, 1, 1, 3,
+						0, 4, test.stringLength, 2, 0,
+						0, test.stringLength + 3, 1, 1, 0,
+						1, 0, 1, 1, 0,
+					},
+				},
+				builder.Build(),
+			)
+		})
+	}
+}
+
+func (s *SemanticTokensTestSuite) Test_splits_multi_line_tokens_and_clips_overlapping_tokens() {
+	registry := s.createRegistry()
+	builder := NewSemanticTokensBuilder(registry, "/* a\r\nbc */ x", PositionEncodingKindUTF16)
+
+	err := builder.PushOffsets(0, 11, SemanticTokenTypeComment)
+	s.Require().NoError(err)
+	// Overlaps with the comment and is dropped.
+	err = builder.PushOffsets(6, 8, SemanticTokenTypeVariable)
+	s.Require().NoError(err)
+	err = builder.PushOffsets(12, 13, SemanticTokenTypeVariable)
+	s.Require().NoError(err)
+
+	s.Require().Equal(
+		[]UInteger{
+			0, 0, 4, 3, 0,
+			1, 0, 5, 3, 0,
+			0, 6, 1, 1, 0,
+		},
+		builder.Build().Data,
+	)
+}
+
+func (s *SemanticTokensTestSuite) Test_builds_tokens_in_range() {
+	registry := s.createRegistry()
+	builder := NewSemanticTokensBuilder(registry, "let x\nlet y\nlet z", PositionEncodingKindUTF16)
+	for _, offset := range []int{0, 6, 12} {
+		err := builder.PushOffsets(offset, offset+3, SemanticTokenTypeKeyword)
+		s.Require().NoError(err)
+		err = builder.PushOffsets(offset+4, offset+5, SemanticTokenTypeVariable)
+		s.Require().NoError(err)
+	}
+
+	tokens := builder.BuildRange(
+		Range{Start: Position{Line: 1, Character: 2}, End: Position{Line: 1, Character: 5}},
+	)
+	s.Require().Equal(
+		[]UInteger{
+			1, 0, 3, 0, 0,
+			0, 4, 1, 1, 0,
+		},
+		tokens.Data,
+	)
+}
+
+func (s *SemanticTokensTestSuite) Test_rejects_unknown_and_invalid_tokens() {
+	registry := s.createRegistry()
+	builder := NewSemanticTokensBuilder(registry, "let x", PositionEncodingKindUTF16)
+
+	err := builder.PushOffsets(0, 3, SemanticTokenTypeMacro)
+	s.Require().ErrorIs(err, ErrUnknownSemanticTokenType)
+
+	err = builder.PushOffsets(0, 3, SemanticTokenTypeKeyword, SemanticTokenModifierAsync)
+	s.Require().ErrorIs(err, ErrUnknownSemanticTokenModifier)
+
+	err = builder.PushOffsets(4, 6, SemanticTokenTypeVariable)
+	s.Require().ErrorIs(err, ErrInvalidSemanticTokenRange)
+
+	err = builder.PushOffsets(3, 2, SemanticTokenTypeVariable)
+	s.Require().ErrorIs(err, ErrInvalidSemanticTokenRange)
+
+	// Ranges past the end of a line or the document are rejected
+	// instead of being mapped to the start of the document.
+	err = builder.Push(
+		Range{Start: Position{Line: 0, Character: 4}, End: Position{Line: 0, Character: 6}},
+		SemanticTokenTypeVariable,
+	)
+	s.Require().ErrorIs(err, ErrInvalidSemanticTokenRange)
+
+	err = builder.Push(
+		Range{Start: Position{Line: 1, Character: 0}, End: Position{Line: 1, Character: 1}},
+		SemanticTokenTypeVariable,
+	)
+	s.Require().ErrorIs(err, ErrInvalidSemanticTokenRange)
+
+	s.Require().Empty(builder.Build().Data)
+}
+
+func (s *SemanticTokensTestSuite) Test_derives_legend_from_registered_types_and_modifiers() {
+	registry := s.createRegistry()
+	registry.RegisterTokenTypes(SemanticTokenTypeKeyword, SemanticTokenTypeFunction)
+	err := registry.RegisterTokenModifiers(SemanticTokenModifierReadonly, SemanticTokenModifierStatic)
+	s.Require().NoError(err)
+
+	s.Require().Equal(
+		SemanticTokensLegend{
+			TokenTypes:     []string{"keyword", "variable", "string", "comment", "function"},
+			TokenModifiers: []string{"declaration", "readonly", "static"},
+		},
+		registry.Legend(),
+	)
+
+	tooMany := []SemanticTokenModifier{}
+	for i := 0; i < 30; i += 1 {
+		tooMany = append(tooMany, SemanticTokenModifier(string(rune('a'+i))))
+	}
+	err = registry.RegisterTokenModifiers(tooMany...)
+	s.Require().ErrorIs(err, ErrTooManySemanticTokenModifiers)
+	s.Require().Len(registry.Legend().TokenModifiers, 3)
+}
+
+func (s *SemanticTokensTestSuite) Test_computes_semantic_tokens_edits() {
+	tests := []struct {
+		name     string
+		previous []UInteger
+		current  []UInteger
+		expected []SemanticTokensEdit
+	}{
+		{
+			name:     "unchanged tokens",
+			previous: []UInteger{1, 2, 3},
+			current:  []UInteger{1, 2, 3},
+			expected: []SemanticTokensEdit{},
+		},
+		{
+			name:     "insertion",
+			previous: []UInteger{1, 2, 3, 4},
+			current:  []UInteger{1, 2, 9, 3, 4},
+			expected: []SemanticTokensEdit{{Start: 2, DeleteCount: 0, Data: []UInteger{9}}},
+		},
+		{
+			name:     "deletion",
+			previous: []UInteger{1, 2, 3, 4},
+			current:  []UInteger{1, 4},
+			expected: []SemanticTokensEdit{{Start: 1, DeleteCount: 2, Data: []UInteger{}}},
+		},
+		{
+			name:     "replacement",
+			previous: []UInteger{1, 2, 3, 4},
+			current:  []UInteger{1, 5, 6, 4},
+			expected: []SemanticTokensEdit{{Start: 1, DeleteCount: 2, Data: []UInteger{5, 6}}},
+		},
+		{
+			name:     "repeated values",
+			previous: []UInteger{1, 1},
+			current:  []UInteger{1, 1, 1},
+			expected: []SemanticTokensEdit{{Start: 2, DeleteCount: 0, Data: []UInteger{1}}},
+		},
+		{
+			name:     "from empty",
+			previous: []UInteger{},
+			current:  []UInteger{1, 2},
+			expected: []SemanticTokensEdit{{Start: 0, DeleteCount: 0, Data: []UInteger{1, 2}}},
+		},
+		{
+			name:     "to empty",
+			previous: []UInteger{1, 2},
+			current:  []UInteger{},
+			expected: []SemanticTokensEdit{{Start: 0, DeleteCount: 2, Data: []UInteger{}}},
+		},
+	}
+
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.Require().Equal(test.expected, ComputeSemanticTokensEdits(test.previous, test.current))
+		})
+	}
+}
+
+func (s *SemanticTokensTestSuite) Test_provider_responds_with_deltas_from_cached_results() {
+	store := NewDocumentStore()
+	provider := NewSemanticTokensProvider(s.createRegistry(), store, wordTokens)
+	ctx := &common.LSPContext{}
+	s.openDocument(store, "alpha beta")
+
+	tokens, err := provider.Full(ctx, &SemanticTokensParams{
+		TextDocument: TextDocumentIdentifier{URI: testDocumentURI},
+	})
+	s.Require().NoError(err)
+	resultID := "1"
+	s.Require().Equal(
+		&SemanticTokens{
+			ResultID: &resultID,
+			Data:     []UInteger{0, 0, 5, 1, 0, 0, 6, 4, 1, 0},
+		},
+		tokens,
+	)
+
+	s.openDocument(store, "alpha beta gamma")
+	delta, err := provider.FullDelta(ctx, &SemanticTokensDeltaParams{
+		TextDocument:     TextDocumentIdentifier{URI: testDocumentURI},
+		PreviousResultID: "1",
+	})
+	s.Require().NoError(err)
+	deltaResultID := "2"
+	s.Require().Equal(
+		&SemanticTokensDelta{
+			ResultID: &deltaResultID,
+			Edits:    []SemanticTokensEdit{{Start: 10, DeleteCount: 0, Data: []UInteger{0, 5, 5, 1, 0}}},
+		},
+		delta,
+	)
+
+	// Only the latest result for a document is kept, the full tokens
+	// are sent when the previous result is no longer cached.
+	fallback, err := provider.FullDelta(ctx, &SemanticTokensDeltaParams{
+		TextDocument:     TextDocumentIdentifier{URI: testDocumentURI},
+		PreviousResultID: "1",
+	})
+	s.Require().NoError(err)
+	fallbackResultID := "3"
+	s.Require().Equal(
+		&SemanticTokens{
+			ResultID: &fallbackResultID,
+			Data:     []UInteger{0, 0, 5, 1, 0, 0, 6, 4, 1, 0, 0, 5, 5, 1, 0},
+		},
+		fallback,
+	)
+
+	provider.Cache().Delete(testDocumentURI)
+	fallback, err = provider.FullDelta(ctx, &SemanticTokensDeltaParams{
+		TextDocument:     TextDocumentIdentifier{URI: testDocumentURI},
+		PreviousResultID: "3",
+	})
+	s.Require().NoError(err)
+	s.Require().IsType(&SemanticTokens{}, fallback)
+
+	rangeTokens, err := provider.Range(ctx, &SemanticTokensRangeParams{
+		TextDocument: TextDocumentIdentifier{URI: testDocumentURI},
+		Range:        Range{Start: Position{Line: 0, Character: 6}, End: Position{Line: 0, Character: 10}},
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(&SemanticTokens{Data: []UInteger{0, 6, 4, 1, 0}}, rangeTokens)
+
+	_, err = provider.Full(ctx, &SemanticTokensParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///missing.txt"},
+	})
+	s.Require().ErrorIs(err, ErrDocumentNotOpen)
+}
+
+func (s *SemanticTokensTestSuite) Test_handler_derives_server_capabilities_from_provider() {
+	provider := NewSemanticTokensProvider(s.createRegistry(), NewDocumentStore(), wordTokens)
+	h := NewHandler(WithSemanticTokensProvider(provider))
+
+	capabilities := h.CreateServerCapabilities()
+	s.Require().Equal(
+		&SemanticTokensOptions{
+			Legend: SemanticTokensLegend{
+				TokenTypes:     []string{"keyword", "variable", "string", "comment"},
+				TokenModifiers: []string{"declaration", "readonly"},
+			},
+			Full:  SemanticDelta{Delta: &True},
+			Range: true,
+		},
+		capabilities.SemanticTokensProvider,
+	)
+}
+
+func (s *SemanticTokensTestSuite) Test_handler_removes_cached_results_when_document_is_closed() {
+	store := NewDocumentStore()
+	provider := NewSemanticTokensProvider(s.createRegistry(), store, wordTokens)
+	h := NewHandler(WithSemanticTokensProvider(provider))
+	s.Require().Same(store, h.DocumentStore())
+	s.openDocument(store, "alpha beta")
+
+	tokens, err := provider.Full(&common.LSPContext{}, &SemanticTokensParams{
+		TextDocument: TextDocumentIdentifier{URI: testDocumentURI},
+	})
+	s.Require().NoError(err)
+
+	params, err := json.Marshal(DidCloseTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: testDocumentURI},
+	})
+	s.Require().NoError(err)
+	_, _, _, err = h.messageHandlers[MethodTextDocumentDidClose].Handle(&common.LSPContext{
+		Method:         MethodTextDocumentDidClose,
+		Params:         params,
+		IsNotification: true,
+	})
+	s.Require().NoError(err)
+
+	// The full tokens are sent as the previous result is no longer cached.
+	result := provider.Cache().Delta(testDocumentURI, *tokens.ResultID, &SemanticTokens{Data: tokens.Data})
+	s.Require().IsType(&SemanticTokens{}, result)
+}
+
+func (s *SemanticTokensTestSuite) Test_provider_retrieves_documents_from_the_handler_document_store() {
+	handlerStore := NewDocumentStore()
+	provider := NewSemanticTokensProvider(s.createRegistry(), NewDocumentStore(), wordTokens)
+	h := NewHandler(WithDocumentStore(handlerStore), WithSemanticTokensProvider(provider))
+	s.Require().Same(handlerStore, h.DocumentStore())
+	s.Require().Same(handlerStore, provider.Store())
+
+	s.openDocument(handlerStore, "alpha beta")
+	_, err := provider.Full(&common.LSPContext{}, &SemanticTokensParams{
+		TextDocument: TextDocumentIdentifier{URI: testDocumentURI},
+	})
+	s.Require().NoError(err)
+
+	// Replacing the handler's document store rebinds the attached provider.
+	replacementStore := NewDocumentStore()
+	h.SetDocumentStore(replacementStore)
+	s.Require().Same(replacementStore, provider.Store())
+	_, err = provider.Full(&common.LSPContext{}, &SemanticTokensParams{
+		TextDocument: TextDocumentIdentifier{URI: testDocumentURI},
+	})
+	s.Require().ErrorIs(err, ErrDocumentNotOpen)
+}
+
+func (s *SemanticTokensTestSuite) createRegistry() *SemanticTokensRegistry {
+	registry := NewSemanticTokensRegistry(
+		SemanticTokenTypeKeyword,
+		SemanticTokenTypeVariable,
+		SemanticTokenTypeString,
+		SemanticTokenTypeComment,
+	)
+	err := registry.RegisterTokenModifiers(
+		SemanticTokenModifierDeclaration,
+		SemanticTokenModifierReadonly,
+	)
+	s.Require().NoError(err)
+	return registry
+}
+
+func (s *SemanticTokensTestSuite) openDocument(store *DocumentStore, text string) {
+	store.Open(&DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{
+			URI:  testDocumentURI,
+			Text: text,
+		},
+	})
+}
+
+var wordPattern = regexp.MustCompile(`\w+`)
+
+func wordTokens(ctx *common.LSPContext, document *TextDocument, builder *SemanticTokensBuilder) error {
+	for _, match := range wordPattern.FindAllStringIndex(document.Text, -1) {
+		if err := builder.PushOffsets(match[0], match[1], SemanticTokenTypeVariable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSemanticTokensTestSuite(t *testing.T) {
+	suite.Run(t, new(SemanticTokensTestSuite))
+}
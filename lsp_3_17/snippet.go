@@ -0,0 +1,738 @@
+package lsp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#snippet_syntax
+
+// Snippet is a parsed or built snippet in the snippet syntax
+// used for completion items with the `InsertTextFormatSnippet` insert text format.
+type Snippet struct {
+	elements []snippetElement
+}
+
+// String returns the snippet in the snippet syntax with the text
+// of the snippet escaped.
+func (s *Snippet) String() string {
+	builder := &strings.Builder{}
+	s.writeSnippet(builder)
+	return builder.String()
+}
+
+// PlainText returns the text that the snippet expands to when tabstops are removed,
+// placeholders are replaced by their text, choices by their first option
+// and variables by their default value.
+// This is used for clients that do not support snippets.
+func (s *Snippet) PlainText() string {
+	builder := &strings.Builder{}
+	s.writePlainText(builder)
+	return builder.String()
+}
+
+func (s *Snippet) writeSnippet(builder *strings.Builder) {
+	for i, element := range s.elements {
+		var next snippetElement
+		if i+1 < len(s.elements) {
+			next = s.elements[i+1]
+		}
+		element.writeSnippet(builder, next)
+	}
+}
+
+func (s *Snippet) writePlainText(builder *strings.Builder) {
+	for _, element := range s.elements {
+		element.writePlainText(builder)
+	}
+}
+
+func (s *Snippet) appendText(text string) {
+	if text == "" {
+		return
+	}
+
+	if len(s.elements) > 0 {
+		if last, isText := s.elements[len(s.elements)-1].(*snippetText); isText {
+			last.value += text
+			return
+		}
+	}
+	s.elements = append(s.elements, &snippetText{value: text})
+}
+
+type snippetElement interface {
+	// Writes the element in the snippet syntax, the next element
+	// determines whether a tabstop or variable must be enclosed in braces
+	// to be separated from the text that follows it.
+	writeSnippet(builder *strings.Builder, next snippetElement)
+	writePlainText(builder *strings.Builder)
+}
+
+type snippetText struct {
+	value string
+}
+
+func (t *snippetText) writeSnippet(builder *strings.Builder, _ snippetElement) {
+	builder.WriteString(EscapeSnippetText(t.value))
+}
+
+func (t *snippetText) writePlainText(builder *strings.Builder) {
+	builder.WriteString(t.value)
+}
+
+type snippetTabstop struct {
+	index int
+	// The raw transform of the tabstop including the slashes,
+	// transforms are kept as they are and are not applied to plain text.
+	transform string
+}
+
+func (t *snippetTabstop) writeSnippet(builder *strings.Builder, next snippetElement) {
+	if t.transform != "" || textStartsWith(next, unicode.IsDigit) {
+		builder.WriteString(fmt.Sprintf("${%d%s}", t.index, t.transform))
+		return
+	}
+	builder.WriteString(fmt.Sprintf("$%d", t.index))
+}
+
+func (t *snippetTabstop) writePlainText(_ *strings.Builder) {}
+
+type snippetPlaceholder struct {
+	index int
+	value *Snippet
+}
+
+func (p *snippetPlaceholder) writeSnippet(builder *strings.Builder, _ snippetElement) {
+	builder.WriteString(fmt.Sprintf("${%d:", p.index))
+	p.value.writeSnippet(builder)
+	builder.WriteString("}")
+}
+
+func (p *snippetPlaceholder) writePlainText(builder *strings.Builder) {
+	p.value.writePlainText(builder)
+}
+
+type snippetChoice struct {
+	index   int
+	options []string
+}
+
+func (c *snippetChoice) writeSnippet(builder *strings.Builder, _ snippetElement) {
+	escaped := make([]string, len(c.options))
+	for i, option := range c.options {
+		escaped[i] = escapeSnippetChoice(option)
+	}
+	builder.WriteString(fmt.Sprintf("${%d|%s|}", c.index, strings.Join(escaped, ",")))
+}
+
+func (c *snippetChoice) writePlainText(builder *strings.Builder) {
+	if len(c.options) > 0 {
+		builder.WriteString(c.options[0])
+	}
+}
+
+type snippetVariable struct {
+	name         string
+	defaultValue *Snippet
+	transform    string
+}
+
+func (v *snippetVariable) writeSnippet(builder *strings.Builder, next snippetElement) {
+	if v.defaultValue != nil {
+		builder.WriteString(fmt.Sprintf("${%s:", v.name))
+		v.defaultValue.writeSnippet(builder)
+		builder.WriteString("}")
+		return
+	}
+
+	if v.transform != "" || textStartsWith(next, isSnippetVariableRune) {
+		builder.WriteString(fmt.Sprintf("${%s%s}", v.name, v.transform))
+		return
+	}
+	builder.WriteString("$" + v.name)
+}
+
+func (v *snippetVariable) writePlainText(builder *strings.Builder) {
+	if v.defaultValue != nil {
+		v.defaultValue.writePlainText(builder)
+	}
+}
+
+func textStartsWith(element snippetElement, matches func(rune) bool) bool {
+	text, isText := element.(*snippetText)
+	if !isText || text.value == "" {
+		return false
+	}
+	return matches([]rune(text.value)[0])
+}
+
+var (
+	snippetTextEscaper   = strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`)
+	snippetChoiceEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `|`, `\|`)
+)
+
+// EscapeSnippetText escapes text so it can be used as literal text in a snippet
+// where the `$`, `}` and `\` characters have a special meaning.
+func EscapeSnippetText(text string) string {
+	return snippetTextEscaper.Replace(text)
+}
+
+func escapeSnippetChoice(option string) string {
+	return snippetChoiceEscaper.Replace(option)
+}
+
+func isSnippetVariableRune(r rune) bool {
+	return r == '_' || isASCIILetter(r) || (r >= '0' && r <= '9')
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isASCIIDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// SnippetBuilder provides a way to build snippets for completion items
+// without having to escape text or keep track of the snippet syntax by hand.
+//
+// Methods can be chained and the first error that occurs is returned
+// when the snippet is built.
+type SnippetBuilder struct {
+	snippet *Snippet
+	err     error
+}
+
+// NewSnippetBuilder creates a new builder for a snippet.
+func NewSnippetBuilder() *SnippetBuilder {
+	return &SnippetBuilder{
+		snippet: &Snippet{},
+	}
+}
+
+// Text adds literal text to the snippet, the text is escaped
+// when the snippet is converted to the snippet syntax.
+func (b *SnippetBuilder) Text(text string) *SnippetBuilder {
+	b.snippet.appendText(text)
+	return b
+}
+
+// Tabstop adds a tabstop with the provided index (e.g. `$1`) to the snippet.
+// Use `FinalTabstop` for the final position of the cursor.
+func (b *SnippetBuilder) Tabstop(index int) *SnippetBuilder {
+	if b.checkIndex(index) {
+		b.snippet.elements = append(b.snippet.elements, &snippetTabstop{index: index})
+	}
+	return b
+}
+
+// FinalTabstop adds the final tabstop (`$0`) to the snippet,
+// when not provided, the final position of the cursor is the end of the snippet.
+func (b *SnippetBuilder) FinalTabstop() *SnippetBuilder {
+	return b.Tabstop(0)
+}
+
+// Placeholder adds a tabstop with the provided index
+// and placeholder text (e.g. `${1:name}`) to the snippet.
+func (b *SnippetBuilder) Placeholder(index int, text string) *SnippetBuilder {
+	return b.PlaceholderFunc(index, func(nested *SnippetBuilder) {
+		nested.Text(text)
+	})
+}
+
+// PlaceholderFunc adds a tabstop with the provided index and a placeholder
+// built with the provided function to the snippet, this allows placeholders
+// to contain other tabstops, placeholders and variables (e.g. `${1:new ${2:Type}()}`).
+func (b *SnippetBuilder) PlaceholderFunc(index int, build func(nested *SnippetBuilder)) *SnippetBuilder {
+	if !b.checkIndex(index) {
+		return b
+	}
+
+	nested := b.buildNested(build)
+	if nested != nil {
+		b.snippet.elements = append(b.snippet.elements, &snippetPlaceholder{index: index, value: nested})
+	}
+	return b
+}
+
+// Choice adds a tabstop with the provided index where the user can pick
+// one of the provided options (e.g. `${1|one,two,three|}`) to the snippet.
+func (b *SnippetBuilder) Choice(index int, options ...string) *SnippetBuilder {
+	if !b.checkIndex(index) {
+		return b
+	}
+
+	if len(options) == 0 {
+		b.setErr(fmt.Errorf("%w: choice for tabstop %d must have at least one option", ErrInvalidSnippet, index))
+		return b
+	}
+
+	b.snippet.elements = append(b.snippet.elements, &snippetChoice{
+		index:   index,
+		options: append([]string{}, options...),
+	})
+	return b
+}
+
+// Variable adds a variable (e.g. `$TM_FILENAME`) that the client resolves
+// when the snippet is inserted to the snippet.
+// The client inserts the name of the variable as a placeholder
+// when the variable is not known.
+func (b *SnippetBuilder) Variable(name string) *SnippetBuilder {
+	if b.checkVariableName(name) {
+		b.snippet.elements = append(b.snippet.elements, &snippetVariable{name: name})
+	}
+	return b
+}
+
+// VariableWithDefault adds a variable with a default value
+// (e.g. `${TM_SELECTED_TEXT:text}`) that is inserted when the variable
+// is empty or not known to the snippet.
+func (b *SnippetBuilder) VariableWithDefault(name string, defaultValue string) *SnippetBuilder {
+	if !b.checkVariableName(name) {
+		return b
+	}
+
+	b.snippet.elements = append(b.snippet.elements, &snippetVariable{
+		name:         name,
+		defaultValue: &Snippet{elements: []snippetElement{&snippetText{value: defaultValue}}},
+	})
+	return b
+}
+
+// Build returns the snippet that has been built,
+// an error is returned if any of the elements added to the builder are not valid.
+func (b *SnippetBuilder) Build() (*Snippet, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return &Snippet{elements: append([]snippetElement{}, b.snippet.elements...)}, nil
+}
+
+func (b *SnippetBuilder) buildNested(build func(nested *SnippetBuilder)) *Snippet {
+	nested := NewSnippetBuilder()
+	build(nested)
+	snippet, err := nested.Build()
+	if err != nil {
+		b.setErr(err)
+		return nil
+	}
+	return snippet
+}
+
+func (b *SnippetBuilder) checkIndex(index int) bool {
+	if index < 0 {
+		b.setErr(fmt.Errorf("%w: tabstop index %d must not be negative", ErrInvalidSnippet, index))
+		return false
+	}
+	return true
+}
+
+func (b *SnippetBuilder) checkVariableName(name string) bool {
+	if !isValidSnippetVariableName(name) {
+		b.setErr(fmt.Errorf("%w: %q is not a valid variable name", ErrInvalidSnippet, name))
+		return false
+	}
+	return true
+}
+
+func (b *SnippetBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func isValidSnippetVariableName(name string) bool {
+	for i, r := range name {
+		if !isSnippetVariableRune(r) || (i == 0 && isASCIIDigit(r)) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// ParseSnippet parses and validates a snippet in the snippet syntax.
+//
+// Parsing is stricter than clients that treat invalid snippet syntax as text,
+// a `$` that does not start a tabstop, placeholder, choice or variable must be escaped
+// and placeholders, choices and variables must be closed.
+func ParseSnippet(value string) (*Snippet, error) {
+	parser := &snippetParser{input: []rune(value)}
+	snippet, err := parser.parseElements(false)
+	if err != nil {
+		return nil, err
+	}
+	return snippet, nil
+}
+
+type snippetParser struct {
+	input []rune
+	pos   int
+}
+
+func (p *snippetParser) parseElements(nested bool) (*Snippet, error) {
+	snippet := &Snippet{}
+	for {
+		if p.pos >= len(p.input) {
+			if nested {
+				return nil, p.errorf("expected '}' before the end of the snippet")
+			}
+			return snippet, nil
+		}
+
+		current := p.input[p.pos]
+		switch {
+		case current == '\\':
+			if p.pos+1 < len(p.input) && strings.ContainsRune(`$}\`, p.input[p.pos+1]) {
+				snippet.appendText(string(p.input[p.pos+1]))
+				p.pos += 2
+			} else {
+				snippet.appendText(`\`)
+				p.pos += 1
+			}
+		case current == '$':
+			element, err := p.parseDollar()
+			if err != nil {
+				return nil, err
+			}
+			snippet.elements = append(snippet.elements, element)
+		case current == '}' && nested:
+			return snippet, nil
+		default:
+			snippet.appendText(string(current))
+			p.pos += 1
+		}
+	}
+}
+
+func (p *snippetParser) parseDollar() (snippetElement, error) {
+	start := p.pos
+	// Skip the "$".
+	p.pos += 1
+
+	if p.accept(isASCIIDigit) {
+		return &snippetTabstop{index: p.parseInt()}, nil
+	}
+
+	if p.accept(isSnippetVariableStart) {
+		return &snippetVariable{name: p.parseVariableName()}, nil
+	}
+
+	if !p.acceptRune('{') {
+		p.pos = start
+		return nil, p.errorf("unescaped '$', use '\\$' for a literal '$'")
+	}
+	// Skip the "{".
+	p.pos += 1
+
+	if p.accept(isASCIIDigit) {
+		return p.parseTabstopBody(p.parseInt())
+	}
+
+	if p.accept(isSnippetVariableStart) {
+		return p.parseVariableBody(p.parseVariableName())
+	}
+
+	return nil, p.errorf("expected a tabstop index or variable name after '${'")
+}
+
+func (p *snippetParser) parseTabstopBody(index int) (snippetElement, error) {
+	if p.pos >= len(p.input) {
+		return nil, p.errorf("expected '}' before the end of the snippet")
+	}
+
+	switch p.input[p.pos] {
+	case '}':
+		p.pos += 1
+		return &snippetTabstop{index: index}, nil
+	case ':':
+		p.pos += 1
+		value, err := p.parseElements(true)
+		if err != nil {
+			return nil, err
+		}
+		// Skip the closing "}".
+		p.pos += 1
+		return &snippetPlaceholder{index: index, value: value}, nil
+	case '|':
+		p.pos += 1
+		options, err := p.parseChoiceOptions()
+		if err != nil {
+			return nil, err
+		}
+		return &snippetChoice{index: index, options: options}, nil
+	case '/':
+		transform, err := p.parseTransform()
+		if err != nil {
+			return nil, err
+		}
+		return &snippetTabstop{index: index, transform: transform}, nil
+	}
+
+	return nil, p.errorf("expected '}', ':', '|' or '/' after tabstop index")
+}
+
+func (p *snippetParser) parseVariableBody(name string) (snippetElement, error) {
+	if p.pos >= len(p.input) {
+		return nil, p.errorf("expected '}' before the end of the snippet")
+	}
+
+	switch p.input[p.pos] {
+	case '}':
+		p.pos += 1
+		return &snippetVariable{name: name}, nil
+	case ':':
+		p.pos += 1
+		defaultValue, err := p.parseElements(true)
+		if err != nil {
+			return nil, err
+		}
+		// Skip the closing "}".
+		p.pos += 1
+		return &snippetVariable{name: name, defaultValue: defaultValue}, nil
+	case '/':
+		transform, err := p.parseTransform()
+		if err != nil {
+			return nil, err
+		}
+		return &snippetVariable{name: name, transform: transform}, nil
+	}
+
+	return nil, p.errorf("expected '}', ':' or '/' after variable name")
+}
+
+func (p *snippetParser) parseChoiceOptions() ([]string, error) {
+	options := []string{}
+	option := &strings.Builder{}
+	for p.pos < len(p.input) {
+		current := p.input[p.pos]
+		switch {
+		case current == '\\' && p.pos+1 < len(p.input) && strings.ContainsRune(`,|\`, p.input[p.pos+1]):
+			option.WriteRune(p.input[p.pos+1])
+			p.pos += 2
+		case current == ',':
+			options = append(options, option.String())
+			option.Reset()
+			p.pos += 1
+		case current == '|':
+			if p.pos+1 >= len(p.input) || p.input[p.pos+1] != '}' {
+				return nil, p.errorf("expected '}' after the options of a choice, use '\\|' for a literal '|'")
+			}
+			p.pos += 2
+			return append(options, option.String()), nil
+		default:
+			option.WriteRune(current)
+			p.pos += 1
+		}
+	}
+
+	return nil, p.errorf("expected '|}' before the end of the snippet")
+}
+
+// parseTransform parses a transform in the form of `/regex/format/options`
+// returning the raw transform.
+func (p *snippetParser) parseTransform() (string, error) {
+	start := p.pos
+	// Skip the opening "/".
+	p.pos += 1
+	slashes := 1
+	// Formats can contain `${1:/upcase}` style references to capture groups
+	// where slashes do not end the format.
+	braces := 0
+	for p.pos < len(p.input) && slashes < 3 {
+		switch {
+		case p.input[p.pos] == '\\':
+			p.pos = min(p.pos+2, len(p.input))
+			continue
+		case slashes == 2 && p.input[p.pos] == '{' && p.input[p.pos-1] == '$':
+			braces += 1
+		case braces > 0 && p.input[p.pos] == '}':
+			braces -= 1
+		case braces == 0 && p.input[p.pos] == '/':
+			slashes += 1
+		}
+		p.pos += 1
+	}
+
+	if slashes < 3 {
+		return "", p.errorf("expected a transform in the form of '/regex/format/options'")
+	}
+
+	for p.pos < len(p.input) && isASCIILetter(p.input[p.pos]) {
+		p.pos += 1
+	}
+
+	if !p.acceptRune('}') {
+		return "", p.errorf("expected '}' after transform options")
+	}
+	transform := string(p.input[start:p.pos])
+	p.pos += 1
+	return transform, nil
+}
+
+func (p *snippetParser) parseInt() int {
+	start := p.pos
+	for p.accept(isASCIIDigit) {
+		p.pos += 1
+	}
+	value, _ := strconv.Atoi(string(p.input[start:p.pos]))
+	return value
+}
+
+func (p *snippetParser) parseVariableName() string {
+	start := p.pos
+	for p.accept(isSnippetVariableRune) {
+		p.pos += 1
+	}
+	return string(p.input[start:p.pos])
+}
+
+func (p *snippetParser) accept(matches func(rune) bool) bool {
+	return p.pos < len(p.input) && matches(p.input[p.pos])
+}
+
+func (p *snippetParser) acceptRune(r rune) bool {
+	return p.pos < len(p.input) && p.input[p.pos] == r
+}
+
+func (p *snippetParser) errorf(format string, args ...any) error {
+	return fmt.Errorf(
+		"%w: %s at offset %d",
+		ErrInvalidSnippet,
+		fmt.Sprintf(format, args...),
+		p.pos,
+	)
+}
+
+func isSnippetVariableStart(r rune) bool {
+	return r == '_' || isASCIILetter(r)
+}
+
+// DowngradeCompletionSnippets converts the snippets in the result of a
+// `textDocument/completion` or `completionItem/resolve` request to plain text
+// for clients that do not support snippets.
+// The result can be a *CompletionList, CompletionList, []*CompletionItem,
+// []CompletionItem, *CompletionItem or CompletionItem, other values are returned as they are.
+//
+// Items that are converted are copies of the provided items,
+// snippets that can not be parsed are left as they are
+// and inserted as plain text by the client.
+//
+// The `Handler` applies this automatically to the results of completion handlers
+// when the client does not support snippets.
+func DowngradeCompletionSnippets(result any) any {
+	switch value := result.(type) {
+	case *CompletionList:
+		if value == nil {
+			return value
+		}
+		return downgradeCompletionList(value)
+	case CompletionList:
+		return *downgradeCompletionList(&value)
+	case []*CompletionItem:
+		return downgradeCompletionItems(value, false)
+	case []CompletionItem:
+		items := make([]CompletionItem, len(value))
+		for i := range value {
+			items[i] = *downgradeCompletionItem(&value[i], false)
+		}
+		return items
+	case *CompletionItem:
+		if value == nil {
+			return value
+		}
+		return downgradeCompletionItem(value, false)
+	case CompletionItem:
+		return *downgradeCompletionItem(&value, false)
+	}
+	return result
+}
+
+func downgradeCompletionList(list *CompletionList) *CompletionList {
+	defaultIsSnippet := false
+	downgraded := *list
+	if list.ItemDefaults != nil && isSnippetFormat(list.ItemDefaults.InsertTextFormat) {
+		defaultIsSnippet = true
+		defaults := *list.ItemDefaults
+		defaults.InsertTextFormat = plainTextFormat()
+		downgraded.ItemDefaults = &defaults
+	}
+	downgraded.Items = downgradeCompletionItems(list.Items, defaultIsSnippet)
+	return &downgraded
+}
+
+func downgradeCompletionItems(items []*CompletionItem, defaultIsSnippet bool) []*CompletionItem {
+	if items == nil {
+		return nil
+	}
+
+	downgraded := make([]*CompletionItem, len(items))
+	for i, item := range items {
+		if item != nil {
+			downgraded[i] = downgradeCompletionItem(item, defaultIsSnippet)
+		}
+	}
+	return downgraded
+}
+
+func downgradeCompletionItem(item *CompletionItem, defaultIsSnippet bool) *CompletionItem {
+	isSnippet := isSnippetFormat(item.InsertTextFormat) ||
+		(item.InsertTextFormat == nil && defaultIsSnippet)
+	if !isSnippet {
+		return item
+	}
+
+	downgraded := *item
+	downgraded.InsertTextFormat = plainTextFormat()
+	if item.InsertText != nil {
+		insertText := snippetToPlainText(*item.InsertText)
+		downgraded.InsertText = &insertText
+	}
+	if item.TextEditText != nil {
+		textEditText := snippetToPlainText(*item.TextEditText)
+		downgraded.TextEditText = &textEditText
+	}
+
+	switch edit := item.TextEdit.(type) {
+	case TextEdit:
+		edit.NewText = snippetToPlainText(edit.NewText)
+		downgraded.TextEdit = edit
+	case *TextEdit:
+		if edit != nil {
+			editCopy := *edit
+			editCopy.NewText = snippetToPlainText(edit.NewText)
+			downgraded.TextEdit = &editCopy
+		}
+	case InsertReplaceEdit:
+		edit.NewText = snippetToPlainText(edit.NewText)
+		downgraded.TextEdit = edit
+	case *InsertReplaceEdit:
+		if edit != nil {
+			editCopy := *edit
+			editCopy.NewText = snippetToPlainText(edit.NewText)
+			downgraded.TextEdit = &editCopy
+		}
+	}
+
+	return &downgraded
+}
+
+func snippetToPlainText(value string) string {
+	snippet, err := ParseSnippet(value)
+	if err != nil {
+		return value
+	}
+	return snippet.PlainText()
+}
+
+func isSnippetFormat(format *InsertTextFormat) bool {
+	return format != nil && *format == InsertTextFormatSnippet
+}
+
+func plainTextFormat() *InsertTextFormat {
+	format := InsertTextFormatPlainText
+	return &format
+}
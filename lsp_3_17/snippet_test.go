@@ -0,0 +1,342 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+)
+
+type SnippetTestSuite struct {
+	suite.Suite
+}
+
+func (s *SnippetTestSuite) Test_builds_snippet_with_escaped_text() {
+	snippet, err := NewSnippetBuilder().
+		Text("func ").
+		Placeholder(1, "name").
+		Text("(").
+		PlaceholderFunc(2, func(nested *SnippetBuilder) {
+			nested.Text("ctx ").Placeholder(3, "context.Context")
+		}).
+		Text(") {\n\t").
+		Tabstop(4).
+		Text("2 ${costs} $5 \\ ").
+		Choice(5, "a,b", "c|d", `e\f`).
+		Text(" ").
+		Variable("TM_FILENAME").
+		Text("_suffix ").
+		VariableWithDefault("TM_SELECTED_TEXT", "default}").
+		Text("\n}").
+		FinalTabstop().
+		Build()
+	s.Require().NoError(err)
+	s.Require().Equal(
+		"func ${1:name}(${2:ctx ${3:context.Context}}) {\n\t${4}2 \\${costs\\} \\$5 \\\\ "+
+			"${5|a\\,b,c\\|d,e\\\\f|} ${TM_FILENAME}_suffix ${TM_SELECTED_TEXT:default\\}}\n\\}$0",
+		snippet.String(),
+	)
+	s.Require().Equal(
+		"func name(ctx context.Context) {\n\t2 ${costs} $5 \\ a,b _suffix default}\n}",
+		snippet.PlainText(),
+	)
+}
+
+func (s *SnippetTestSuite) Test_reports_first_invalid_element_when_building() {
+	tests := []struct {
+		name          string
+		build         func(builder *SnippetBuilder)
+		expectedError string
+	}{
+		{
+			name: "negative tabstop index",
+			build: func(builder *SnippetBuilder) {
+				builder.Tabstop(-1).Variable("1invalid")
+			},
+			expectedError: "invalid snippet: tabstop index -1 must not be negative",
+		},
+		{
+			name: "invalid variable name",
+			build: func(builder *SnippetBuilder) {
+				builder.Text("a").VariableWithDefault("TM-FILENAME", "b")
+			},
+			expectedError: "invalid snippet: \"TM-FILENAME\" is not a valid variable name",
+		},
+		{
+			name: "choice without options",
+			build: func(builder *SnippetBuilder) {
+				builder.Choice(1)
+			},
+			expectedError: "invalid snippet: choice for tabstop 1 must have at least one option",
+		},
+		{
+			name: "invalid nested placeholder",
+			build: func(builder *SnippetBuilder) {
+				builder.PlaceholderFunc(1, func(nested *SnippetBuilder) {
+					nested.Variable("")
+				})
+			},
+			expectedError: "invalid snippet: \"\" is not a valid variable name",
+		},
+	}
+
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			builder := NewSnippetBuilder()
+			test.build(builder)
+			_, err := builder.Build()
+			s.Require().ErrorIs(err, ErrInvalidSnippet)
+			s.Require().EqualError(err, test.expectedError)
+		})
+	}
+}
+
+func (s *SnippetTestSuite) Test_parses_valid_snippets() {
+	tests := []struct {
+		name              string
+		snippet           string
+		expectedPlainText string
+		expectedString    string
+	}{
+		{
+			name:              "tabstops and placeholders",
+			snippet:           "for ${1:i} := 0; $1 < ${2:n}; $1++ {\n\t$0\n}",
+			expectedPlainText: "for i := 0;  < n; ++ {\n\t\n}",
+			expectedString:    "for ${1:i} := 0; $1 < ${2:n}; $1++ {\n\t$0\n\\}",
+		},
+		{
+			name:              "nested placeholders",
+			snippet:           "${1:new ${2:Type}(${3})}",
+			expectedPlainText: "new Type()",
+			expectedString:    "${1:new ${2:Type}($3)}",
+		},
+		{
+			name:              "choices",
+			snippet:           "${1|public,private\\,ish,a\\|b|} class",
+			expectedPlainText: "public class",
+		},
+		{
+			name:              "variables",
+			snippet:           "$TM_FILENAME ${CURRENT_YEAR} ${TM_SELECTED_TEXT:${1:default}}",
+			expectedPlainText: "  default",
+			expectedString:    "$TM_FILENAME $CURRENT_YEAR ${TM_SELECTED_TEXT:${1:default}}",
+		},
+		{
+			name:              "transforms",
+			snippet:           "${TM_FILENAME/(.*)\\..+$/${1:/upcase}/g} ${1/a\\/b/c/}",
+			expectedPlainText: " ",
+		},
+		{
+			name:              "escaped and literal characters",
+			snippet:           "\\$1 \\} \\\\ \\n } a|b,c",
+			expectedPlainText: "$1 } \\ \\n } a|b,c",
+			expectedString:    "\\$1 \\} \\\\ \\\\n \\} a|b,c",
+		},
+	}
+
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			snippet, err := ParseSnippet(test.snippet)
+			s.Require().NoError(err)
+			s.Require().Equal(test.expectedPlainText, snippet.PlainText())
+
+			expectedString := test.expectedString
+			if expectedString == "" {
+				expectedString = test.snippet
+			}
+			s.Require().Equal(expectedString, snippet.String())
+		})
+	}
+}
+
+func (s *SnippetTestSuite) Test_fails_to_parse_invalid_snippets() {
+	tests := []struct {
+		name          string
+		snippet       string
+		expectedError string
+	}{
+		{
+			name:          "unescaped dollar",
+			snippet:       "cost: $ 5",
+			expectedError: "invalid snippet: unescaped '$', use '\\$' for a literal '$' at offset 6",
+		},
+		{
+			name:          "unterminated placeholder",
+			snippet:       "${1:name",
+			expectedError: "invalid snippet: expected '}' before the end of the snippet at offset 8",
+		},
+		{
+			name:          "unterminated choice",
+			snippet:       "${1|a,b}",
+			expectedError: "invalid snippet: expected '|}' before the end of the snippet at offset 8",
+		},
+		{
+			name:          "missing tabstop index",
+			snippet:       "${:name}",
+			expectedError: "invalid snippet: expected a tabstop index or variable name after '${' at offset 2",
+		},
+		{
+			name:          "invalid character after variable name",
+			snippet:       "${TM_FILENAME|a|}",
+			expectedError: "invalid snippet: expected '}', ':' or '/' after variable name at offset 13",
+		},
+		{
+			name:          "incomplete transform",
+			snippet:       "${1/a/b}",
+			expectedError: "invalid snippet: expected a transform in the form of '/regex/format/options' at offset 8",
+		},
+	}
+
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			_, err := ParseSnippet(test.snippet)
+			s.Require().ErrorIs(err, ErrInvalidSnippet)
+			s.Require().EqualError(err, test.expectedError)
+		})
+	}
+}
+
+func (s *SnippetTestSuite) Test_downgrades_completion_snippets_to_plain_text() {
+	snippetText := "fmt.Println(${1:value})$0"
+	plainText := "fmt.Println(value)"
+	editRange := Range{Start: Position{Line: 1}, End: Position{Line: 1, Character: 3}}
+	list := &CompletionList{
+		ItemDefaults: &CompletionItemDefaults{
+			InsertTextFormat: &InsertTextFormatSnippet,
+		},
+		Items: []*CompletionItem{
+			{Label: "println", InsertText: &snippetText},
+			{Label: "printf", TextEditText: &snippetText},
+			{
+				Label:            "print",
+				InsertTextFormat: &InsertTextFormatSnippet,
+				TextEdit:         &TextEdit{Range: &editRange, NewText: snippetText},
+			},
+			{
+				Label:            "plain",
+				InsertText:       &snippetText,
+				InsertTextFormat: &InsertTextFormatPlainText,
+			},
+		},
+	}
+
+	downgraded, isList := DowngradeCompletionSnippets(list).(*CompletionList)
+	s.Require().True(isList)
+	s.Require().Equal(InsertTextFormatPlainText, *downgraded.ItemDefaults.InsertTextFormat)
+	s.Require().Equal(plainText, *downgraded.Items[0].InsertText)
+	s.Require().Equal(InsertTextFormatPlainText, *downgraded.Items[0].InsertTextFormat)
+	s.Require().Equal(plainText, *downgraded.Items[1].TextEditText)
+	s.Require().Equal(
+		&TextEdit{Range: &editRange, NewText: plainText},
+		downgraded.Items[2].TextEdit,
+	)
+	// Items that are already plain text are left as they are.
+	s.Require().Same(list.Items[3], downgraded.Items[3])
+
+	// The provided list is left untouched.
+	s.Require().Equal(InsertTextFormatSnippet, *list.ItemDefaults.InsertTextFormat)
+	s.Require().Equal(snippetText, *list.Items[0].InsertText)
+	s.Require().Equal(snippetText, list.Items[2].TextEdit.(*TextEdit).NewText)
+
+	items, isItems := DowngradeCompletionSnippets([]CompletionItem{
+		{
+			Label:            "println",
+			InsertTextFormat: &InsertTextFormatSnippet,
+			TextEdit:         InsertReplaceEdit{NewText: snippetText},
+		},
+	}).([]CompletionItem)
+	s.Require().True(isItems)
+	s.Require().Equal(InsertReplaceEdit{NewText: plainText}, items[0].TextEdit)
+
+	// Snippets that can not be parsed are inserted as they are.
+	invalidSnippet := "cost: $ 5"
+	item, isItem := DowngradeCompletionSnippets(CompletionItem{
+		Label:            "cost",
+		InsertText:       &invalidSnippet,
+		InsertTextFormat: &InsertTextFormatSnippet,
+	}).(CompletionItem)
+	s.Require().True(isItem)
+	s.Require().Equal(invalidSnippet, *item.InsertText)
+	s.Require().Equal(InsertTextFormatPlainText, *item.InsertTextFormat)
+}
+
+func (s *SnippetTestSuite) Test_handler_downgrades_completion_snippets_for_clients_without_snippet_support() {
+	snippetText := "fmt.Println(${1:value})"
+	serverHandler := NewHandler(
+		WithCompletionHandler(
+			func(ctx *common.LSPContext, params *CompletionParams) (any, error) {
+				return []*CompletionItem{
+					{
+						Label:            "println",
+						InsertText:       &snippetText,
+						InsertTextFormat: &InsertTextFormatSnippet,
+					},
+				}, nil
+			},
+		),
+	)
+
+	tests := []struct {
+		name               string
+		clientState        *ClientState
+		expectedInsertText string
+	}{
+		{
+			name:               "client without snippet support",
+			clientState:        NewClientState(&InitializeParams{}),
+			expectedInsertText: "fmt.Println(value)",
+		},
+		{
+			name:               "client with snippet support",
+			clientState:        s.snippetClientState(),
+			expectedInsertText: snippetText,
+		},
+		{
+			name:               "unknown client",
+			expectedInsertText: snippetText,
+		},
+	}
+
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			params, err := json.Marshal(CompletionParams{})
+			s.Require().NoError(err)
+			lspCtx := &common.LSPContext{
+				Method:  MethodCompletion,
+				Params:  params,
+				Context: context.Background(),
+			}
+			if test.clientState != nil {
+				withClientState(lspCtx, test.clientState)
+			}
+
+			result, validMethod, validParams, err := createCompletionHandler(serverHandler).Handle(lspCtx)
+			s.Require().NoError(err)
+			s.Require().True(validMethod)
+			s.Require().True(validParams)
+			items := result.([]*CompletionItem)
+			s.Require().Equal(test.expectedInsertText, *items[0].InsertText)
+		})
+	}
+}
+
+func (s *SnippetTestSuite) snippetClientState() *ClientState {
+	snippetSupport := true
+	return NewClientState(&InitializeParams{
+		Capabilities: ClientCapabilities{
+			TextDocument: &TextDocumentClientCapabilities{
+				Completion: &CompletionClientCapabilities{
+					CompletionItem: &CompletionItemCapabilities{
+						SnippetSupport: &snippetSupport,
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestSnippetTestSuite(t *testing.T) {
+	suite.Run(t, new(SnippetTestSuite))
+}
@@ -0,0 +1,263 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/two-hundred/ls-builder/common"
+)
+
+// DefaultStaleRequestMethods are the position-based requests that are checked
+// for stale results when stale request detection is enabled without
+// specifying methods.
+var DefaultStaleRequestMethods = []Method{
+	MethodHover,
+	MethodCompletion,
+	MethodSemanticTokensFull,
+	MethodSemanticTokensFullDelta,
+	MethodSemanticTokensRange,
+	MethodCodeAction,
+}
+
+// ErrContentModified is the cause of the cancellation of the context
+// of a request that has become stale because the document the request is for
+// has been modified while the request was being handled.
+var ErrContentModified = errors.New("content modified")
+
+// WithStaleRequestDetection enables the detection of stale requests for the provided
+// methods, or `DefaultStaleRequestMethods` when no methods are provided.
+// See `StaleRequestMiddleware` for how stale requests are detected and responded to.
+func WithStaleRequestDetection(methods ...Method) HandlerOption {
+	return func(root *Handler) {
+		root.Use(StaleRequestMiddleware(methods...))
+	}
+}
+
+// StaleRequestMiddleware creates middleware that detects requests that have become
+// stale because a newer version of the document the request is for was received
+// in a `textDocument/didChange` notification while the request was being handled.
+// Only requests for the provided methods, or `DefaultStaleRequestMethods` when no methods
+// are provided, that have a `textDocument` param are checked.
+//
+// The context of a stale request is cancelled with `ErrContentModified` as the cause
+// so the handler can stop work as soon as possible and the result of the handler is
+// discarded so results computed against outdated text are never sent.
+// The client is sent a `ContentModified` error in response to a stale request,
+// clients that will retry the request can be checked with `ClientState.RetriesOnContentModified`.
+//
+// A request is stale when a change to the document was received from the client after
+// the request, going by the order messages were received in (see `common.MessageOrder`)
+// so changes received before a request that are still waiting to be handled when
+// the request is handled do not make the request stale.
+// When the order is not known, the version of the document when the request is handled
+// is compared with the version in later changes.
+//
+// Document changes can only be received while a request is being handled when
+// the server handles requests concurrently (e.g. with `server.WithConcurrentRequests`).
+func StaleRequestMiddleware(methods ...Method) Middleware {
+	if len(methods) == 0 {
+		methods = DefaultStaleRequestMethods
+	}
+
+	detector := &staleRequestDetector{
+		methods:      map[string]struct{}{},
+		versions:     map[DocumentURI]Integer{},
+		changeOrders: map[DocumentURI]uint64{},
+		inFlight:     map[*staleRequest]struct{}{},
+	}
+	for _, method := range methods {
+		detector.methods[string(method)] = struct{}{}
+	}
+
+	return detector.middleware
+}
+
+type staleRequestDetector struct {
+	methods map[string]struct{}
+	// The latest version of each open document.
+	versions map[DocumentURI]Integer
+	// The order of the latest change to each open document
+	// when the order of messages is known.
+	changeOrders map[DocumentURI]uint64
+	inFlight     map[*staleRequest]struct{}
+	mu           sync.Mutex
+}
+
+// staleRequest holds the order a request was received in
+// and the document version the request started with.
+type staleRequest struct {
+	uri DocumentURI
+	// The order the request was received in, see `common.MessageOrder`.
+	order   uint64
+	ordered bool
+	// The version of the document when the request started,
+	// nil if the document has not been opened.
+	version *Integer
+	stale   bool
+	cancel  context.CancelCauseFunc
+}
+
+// outdatedBy determines whether a change to the document of the request
+// makes the request stale.
+func (r *staleRequest) outdatedBy(version Integer, order uint64, ordered bool) bool {
+	if r.ordered && ordered {
+		return r.order < order
+	}
+
+	return r.version == nil || *r.version < version
+}
+
+func (r *staleRequest) markStale() {
+	r.stale = true
+	r.cancel(ErrContentModified)
+}
+
+type textDocumentParams struct {
+	TextDocument *struct {
+		URI     DocumentURI `json:"uri"`
+		Version *Integer    `json:"version"`
+	} `json:"textDocument"`
+}
+
+func (d *staleRequestDetector) middleware(next common.Handler) common.Handler {
+	return common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			if ctx.IsNotification {
+				d.trackDocumentVersion(ctx)
+				return next.Handle(ctx)
+			}
+
+			_, isChecked := d.methods[ctx.Method]
+			if !isChecked {
+				return next.Handle(ctx)
+			}
+
+			request := d.startRequest(ctx)
+			if request == nil {
+				return next.Handle(ctx)
+			}
+			defer d.finishRequest(request)
+
+			r, validMethod, validParams, err = next.Handle(ctx)
+			if d.isStale(request) {
+				return nil, true, true, staleRequestError()
+			}
+			return
+		},
+	)
+}
+
+func (d *staleRequestDetector) trackDocumentVersion(ctx *common.LSPContext) {
+	if ctx.Method != MethodTextDocumentDidOpen &&
+		ctx.Method != MethodTextDocumentDidChange &&
+		ctx.Method != MethodTextDocumentDidClose {
+		return
+	}
+
+	params, ok := parseTextDocumentParams(ctx.Params)
+	if !ok {
+		return
+	}
+
+	uri := params.TextDocument.URI
+	order, ordered := common.MessageOrder(ctx.Context)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if ctx.Method == MethodTextDocumentDidClose {
+		delete(d.versions, uri)
+		delete(d.changeOrders, uri)
+		return
+	}
+
+	if params.TextDocument.Version == nil {
+		return
+	}
+
+	version := *params.TextDocument.Version
+	d.versions[uri] = version
+	if ctx.Method != MethodTextDocumentDidChange {
+		return
+	}
+
+	if ordered {
+		d.changeOrders[uri] = order
+	}
+
+	for request := range d.inFlight {
+		if request.uri == uri && !request.stale && request.outdatedBy(version, order, ordered) {
+			request.markStale()
+		}
+	}
+}
+
+// startRequest notes the order and the version of the document a request is for and replaces
+// the context of the request with one that is cancelled when the request becomes stale,
+// returning nil for requests that are not for a document.
+func (d *staleRequestDetector) startRequest(ctx *common.LSPContext) *staleRequest {
+	params, ok := parseTextDocumentParams(ctx.Params)
+	if !ok {
+		return nil
+	}
+
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	order, ordered := common.MessageOrder(parent)
+	reqCtx, cancel := context.WithCancelCause(parent)
+	ctx.Context = reqCtx
+	request := &staleRequest{
+		uri:     params.TextDocument.URI,
+		order:   order,
+		ordered: ordered,
+		cancel:  cancel,
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if version, ok := d.versions[request.uri]; ok {
+		request.version = &version
+	}
+	// A change received after the request may have been handled
+	// before the request.
+	if changeOrder, ok := d.changeOrders[request.uri]; ok && ordered && changeOrder > order {
+		request.markStale()
+	}
+	d.inFlight[request] = struct{}{}
+	return request
+}
+
+func (d *staleRequestDetector) finishRequest(request *staleRequest) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inFlight, request)
+	request.cancel(nil)
+}
+
+func (d *staleRequestDetector) isStale(request *staleRequest) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return request.stale
+}
+
+func parseTextDocumentParams(rawParams json.RawMessage) (*textDocumentParams, bool) {
+	if len(rawParams) == 0 {
+		return nil, false
+	}
+
+	params := &textDocumentParams{}
+	if err := json.Unmarshal(rawParams, params); err != nil || params.TextDocument == nil {
+		return nil, false
+	}
+
+	return params, params.TextDocument.URI != ""
+}
+
+func staleRequestError() error {
+	return NewContentModifiedError(
+		"the document was modified while the request was being handled",
+		nil,
+	)
+}
@@ -0,0 +1,314 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"github.com/two-hundred/ls-builder/server"
+	"go.uber.org/zap"
+)
+
+type StaleRequestsTestSuite struct {
+	suite.Suite
+}
+
+func (s *StaleRequestsTestSuite) Test_responds_with_content_modified_for_clients_that_retry() {
+	clientState := NewClientState(&InitializeParams{
+		Capabilities: ClientCapabilities{
+			General: &GeneralClientCapabilities{
+				StaleRequestSupport: &StaleRequestSupport{
+					Cancel:                 true,
+					RetryOnContentModified: []string{MethodHover},
+				},
+			},
+		},
+	})
+
+	result := s.handleRequestDuringChange(clientState, "file:///test.txt", 2)
+	s.Assert().Nil(result.r)
+	s.assertResponseErrorCode(result.err, CodeContentModified)
+	s.Assert().ErrorIs(result.cause, ErrContentModified)
+}
+
+func (s *StaleRequestsTestSuite) Test_responds_with_content_modified_for_clients_that_do_not_retry() {
+	clientState := NewClientState(&InitializeParams{})
+
+	result := s.handleRequestDuringChange(clientState, "file:///test.txt", 2)
+	s.Assert().Nil(result.r)
+	s.assertResponseErrorCode(result.err, CodeContentModified)
+	s.Assert().ErrorIs(result.cause, ErrContentModified)
+}
+
+func (s *StaleRequestsTestSuite) Test_ignores_changes_to_other_documents() {
+	result := s.handleRequestDuringChange(NewClientState(&InitializeParams{}), "file:///other.txt", 2)
+	s.Require().NoError(result.err)
+	s.Assert().Equal("hover result", result.r)
+	s.Assert().Nil(result.cause)
+}
+
+func (s *StaleRequestsTestSuite) Test_ignores_changes_that_are_not_newer() {
+	result := s.handleRequestDuringChange(NewClientState(&InitializeParams{}), "file:///test.txt", 1)
+	s.Require().NoError(result.err)
+	s.Assert().Equal("hover result", result.r)
+}
+
+func (s *StaleRequestsTestSuite) Test_ignores_methods_that_are_not_checked() {
+	middleware := StaleRequestMiddleware(MethodCompletion)
+	handler := middleware(common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			s.handleNotification(middleware, MethodTextDocumentDidChange, "file:///test.txt", 5)
+			return "hover result", true, true, ctx.Context.Err()
+		},
+	))
+
+	r, _, _, err := handler.Handle(s.requestContext(MethodHover, "file:///test.txt", nil))
+	s.Require().NoError(err)
+	s.Assert().Equal("hover result", r)
+}
+
+func (s *StaleRequestsTestSuite) Test_detects_stale_requests_without_context() {
+	middleware := StaleRequestMiddleware()
+	s.handleNotification(middleware, MethodTextDocumentDidOpen, "file:///test.txt", 1)
+
+	handler := middleware(common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			s.handleNotification(middleware, MethodTextDocumentDidChange, "file:///test.txt", 2)
+			return "hover result", true, true, nil
+		},
+	))
+
+	ctx := s.requestContext(MethodHover, "file:///test.txt", nil)
+	ctx.Context = nil
+	_, _, _, err := handler.Handle(ctx)
+	s.assertResponseErrorCode(err, CodeContentModified)
+}
+
+func (s *StaleRequestsTestSuite) Test_ignores_changes_received_before_request_handled_concurrently() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	documents := newStaleRequestDocuments()
+	defer documents.releaseNotifications()
+	clientConn := s.serve(documents)
+
+	// The change is received before the request but waits behind a slow notification
+	// until the request is being handled.
+	s.Require().NoError(clientConn.Notify(ctx, MethodTextDocumentDidOpen, documents.params(1)))
+	s.Require().NoError(clientConn.Notify(ctx, "slowNotification", nil))
+	s.Require().NoError(clientConn.Notify(ctx, MethodTextDocumentDidChange, documents.params(2)))
+	call, err := clientConn.DispatchCall(ctx, MethodHover, documents.params(2))
+	s.Require().NoError(err)
+	documents.waitForHover(s)
+	documents.releaseNotifications()
+
+	result := ""
+	s.Require().NoError(call.Wait(ctx, &result))
+	s.Assert().Equal("hover result", result)
+}
+
+func (s *StaleRequestsTestSuite) Test_detects_changes_received_after_request_handled_concurrently() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	documents := newStaleRequestDocuments()
+	defer documents.releaseNotifications()
+	clientConn := s.serve(documents)
+
+	s.Require().NoError(clientConn.Notify(ctx, MethodTextDocumentDidOpen, documents.params(1)))
+	call, err := clientConn.DispatchCall(ctx, MethodHover, documents.params(1))
+	s.Require().NoError(err)
+	documents.waitForHover(s)
+	s.Require().NoError(clientConn.Notify(ctx, MethodTextDocumentDidChange, documents.params(2)))
+
+	err = call.Wait(ctx, nil)
+	jsonrpcErr, isJSONRPCErr := err.(*jsonrpc2.Error)
+	s.Require().True(isJSONRPCErr, "expected a JSON-RPC error, got %v", err)
+	s.Assert().Equal(CodeContentModified, jsonrpcErr.Code)
+}
+
+type staleRequestResult struct {
+	r     any
+	err   error
+	cause error
+}
+
+// handleRequestDuringChange handles a hover request for file:///test.txt opened at version 1
+// where a change notification for the provided document and version is received
+// while the request is being handled.
+func (s *StaleRequestsTestSuite) handleRequestDuringChange(
+	clientState *ClientState,
+	changedURI string,
+	changedVersion Integer,
+) staleRequestResult {
+	middleware := StaleRequestMiddleware()
+	s.handleNotification(middleware, MethodTextDocumentDidOpen, "file:///test.txt", 1)
+
+	var cause error
+	started := make(chan struct{})
+	changed := make(chan struct{})
+	handler := middleware(common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			close(started)
+			<-changed
+			select {
+			case <-ctx.Context.Done():
+				cause = context.Cause(ctx.Context)
+			case <-time.After(10 * time.Millisecond):
+			}
+			return "hover result", true, true, nil
+		},
+	))
+
+	response := make(chan staleRequestResult, 1)
+	go func() {
+		r, _, _, err := handler.Handle(s.requestContext(MethodHover, "file:///test.txt", clientState))
+		response <- staleRequestResult{r: r, err: err}
+	}()
+
+	<-started
+	s.handleNotification(middleware, MethodTextDocumentDidChange, changedURI, changedVersion)
+	close(changed)
+
+	result := <-response
+	result.cause = cause
+	return result
+}
+
+func (s *StaleRequestsTestSuite) handleNotification(middleware Middleware, method string, uri string, version Integer) {
+	params, err := json.Marshal(map[string]any{
+		"textDocument": map[string]any{
+			"uri":     uri,
+			"version": version,
+		},
+	})
+	s.Require().NoError(err)
+
+	handler := middleware(common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			return nil, true, true, nil
+		},
+	))
+	_, _, _, err = handler.Handle(&common.LSPContext{
+		Method:         method,
+		Params:         params,
+		IsNotification: true,
+		Context:        context.Background(),
+	})
+	s.Require().NoError(err)
+}
+
+func (s *StaleRequestsTestSuite) requestContext(method string, uri string, clientState *ClientState) *common.LSPContext {
+	params, err := json.Marshal(map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": 0, "character": 0},
+	})
+	s.Require().NoError(err)
+
+	ctx := &common.LSPContext{
+		Method:  method,
+		Params:  params,
+		Context: context.Background(),
+	}
+	if clientState != nil {
+		withClientState(ctx, clientState)
+	}
+	return ctx
+}
+
+func (s *StaleRequestsTestSuite) assertResponseErrorCode(err error, expectedCode int64) {
+	responseErr := &ResponseError{}
+	s.Require().True(errors.As(err, &responseErr), "expected a response error, got %v", err)
+	s.Assert().Equal(expectedCode, responseErr.Code)
+}
+
+// serve starts a server that handles requests concurrently with stale request detection
+// for the handler of the provided documents, returning the client connection.
+func (s *StaleRequestsTestSuite) serve(documents *staleRequestDocuments) *jsonrpc2.Conn {
+	srv := server.NewServer(
+		StaleRequestMiddleware()(documents.handler()),
+		false,
+		zap.NewNop(),
+		nil,
+		server.WithConcurrentRequests(2),
+	)
+	container := createTestConnectionsContainer(srv.NewHandler())
+	go srv.Serve(container.serverConn, zap.NewNop())
+	s.T().Cleanup(func() {
+		container.clientConn.Close()
+	})
+	return container.clientConn
+}
+
+// staleRequestDocuments emulates a handler with a hover request that is handled
+// until a change to the document has been handled and a notification that is handled
+// once released to hold back the notifications received after it.
+type staleRequestDocuments struct {
+	hoverStarted chan struct{}
+	changed      chan struct{}
+	release      chan struct{}
+	releaseOnce  sync.Once
+}
+
+func newStaleRequestDocuments() *staleRequestDocuments {
+	return &staleRequestDocuments{
+		hoverStarted: make(chan struct{}, 1),
+		changed:      make(chan struct{}, 1),
+		release:      make(chan struct{}),
+	}
+}
+
+func (d *staleRequestDocuments) params(version Integer) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{
+			"uri":     "file:///test.txt",
+			"version": version,
+		},
+		"position": map[string]any{"line": 0, "character": 0},
+	}
+}
+
+func (d *staleRequestDocuments) releaseNotifications() {
+	d.releaseOnce.Do(func() {
+		close(d.release)
+	})
+}
+
+func (d *staleRequestDocuments) waitForHover(s *StaleRequestsTestSuite) {
+	select {
+	case <-d.hoverStarted:
+	case <-time.After(server.DefaultTimeout):
+		s.FailNow("timeout waiting for hover request to start")
+	}
+}
+
+func (d *staleRequestDocuments) handler() common.Handler {
+	return common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			switch ctx.Method {
+			case "slowNotification":
+				<-d.release
+			case MethodTextDocumentDidChange:
+				d.changed <- struct{}{}
+			case MethodHover:
+				d.hoverStarted <- struct{}{}
+				select {
+				case <-d.changed:
+				case <-time.After(server.DefaultTimeout):
+				}
+				r = "hover result"
+			}
+			return r, true, true, nil
+		},
+	)
+}
+
+func TestStaleRequestsTestSuite(t *testing.T) {
+	suite.Run(t, new(StaleRequestsTestSuite))
+}
@@ -86,6 +86,43 @@ type MessageActionItem struct {
 	Title string `json:"title"`
 }
 
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#window_showDocument
+
+const MethodShowDocument = Method("window/showDocument")
+
+// ShowDocumentParams represents the parameters of a `window/showDocument` request.
+//
+// @since 3.16.0
+type ShowDocumentParams struct {
+	// The uri to show.
+	URI URI `json:"uri"`
+
+	// Indicates to show the resource in an external program.
+	// To show, for example, `https://code.visualstudio.com/`
+	// in the default WEB browser set `external` to `true`.
+	External *bool `json:"external,omitempty"`
+
+	// An optional property to indicate whether the editor
+	// showing the document should take focus or not.
+	// Clients might ignore this property if an external
+	// program is started.
+	TakeFocus *bool `json:"takeFocus,omitempty"`
+
+	// An optional selection range if the document is a text
+	// document. Clients might ignore the property if an
+	// external program is started or the file is not a text
+	// file.
+	Selection *Range `json:"selection,omitempty"`
+}
+
+// ShowDocumentResult represents the result of a `window/showDocument` request.
+//
+// @since 3.16.0
+type ShowDocumentResult struct {
+	// A boolean indicating if the show was successful.
+	Success bool `json:"success"`
+}
+
 // https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#window_logMessage
 
 const MethodLogMessage = Method("window/logMessage")
@@ -0,0 +1,534 @@
+package lsp
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// WorkspaceEditBuilder collects text edits, file operations and change annotations
+// and produces a `WorkspaceEdit` in the shape that a client supports based on its
+// `workspace.workspaceEdit` capabilities.
+//
+// Text edits are emitted as versioned `TextDocumentEdit`s in `documentChanges` when the
+// client supports them, otherwise plain text edits are emitted in `changes`.
+// File operations the client does not support cause building the edit to fail
+// instead of producing an edit that the client would reject.
+type WorkspaceEditBuilder struct {
+	changes     []workspaceEditChange
+	annotations map[ChangeAnnotationIdentifier]ChangeAnnotation
+	store       *DocumentStore
+}
+
+// A change in a workspace edit is either the text edits for a document
+// or a single file operation.
+type workspaceEditChange struct {
+	documentEdits *documentTextEdits
+	operation     any // CreateFile | RenameFile | DeleteFile
+}
+
+type documentTextEdits struct {
+	uri DocumentURI
+	// The version of the document the edits were computed against,
+	// nil when the edits are not pinned to a version of the document.
+	version *Integer
+	edits   []annotatedTextEdit
+}
+
+type annotatedTextEdit struct {
+	edit         TextEdit
+	annotationID *ChangeAnnotationIdentifier
+}
+
+// WorkspaceEditBuilderOption is a function that can be used to configure
+// a workspace edit builder.
+type WorkspaceEditBuilderOption func(*WorkspaceEditBuilder)
+
+// WithWorkspaceEditDocumentStore sets the document store used to determine
+// the versions of the documents that are edited and to verify that documents
+// have not changed since the edits were computed.
+func WithWorkspaceEditDocumentStore(store *DocumentStore) WorkspaceEditBuilderOption {
+	return func(b *WorkspaceEditBuilder) {
+		b.store = store
+	}
+}
+
+// NewWorkspaceEditBuilder creates a new empty workspace edit builder.
+func NewWorkspaceEditBuilder(opts ...WorkspaceEditBuilderOption) *WorkspaceEditBuilder {
+	builder := &WorkspaceEditBuilder{
+		changes:     []workspaceEditChange{},
+		annotations: make(map[ChangeAnnotationIdentifier]ChangeAnnotation),
+	}
+
+	for _, opt := range opts {
+		opt(builder)
+	}
+
+	return builder
+}
+
+// AddTextEdits adds text edits for the document with the given URI.
+// When a document store is attached to the builder, the edits are applied to the
+// version of the document in the store at the time the edit is built.
+func (b *WorkspaceEditBuilder) AddTextEdits(uri DocumentURI, edits ...TextEdit) {
+	b.addTextEdits(uri, nil, nil, edits)
+}
+
+// AddDocumentTextEdits adds text edits that were computed against the given
+// snapshot of a document.
+// When a document store is attached to the builder, building the edit will fail
+// if the document in the store has changed since the snapshot was taken.
+func (b *WorkspaceEditBuilder) AddDocumentTextEdits(document *TextDocument, edits ...TextEdit) {
+	version := document.Version
+	b.addTextEdits(document.URI, &version, nil, edits)
+}
+
+// AddAnnotatedTextEdits adds text edits for the document with the given URI
+// that are described by the change annotation with the given identifier.
+// The change annotation must be added to the builder with `AddChangeAnnotation`.
+func (b *WorkspaceEditBuilder) AddAnnotatedTextEdits(
+	uri DocumentURI,
+	annotationID ChangeAnnotationIdentifier,
+	edits ...TextEdit,
+) {
+	b.addTextEdits(uri, nil, &annotationID, edits)
+}
+
+// AddChangeAnnotation adds a change annotation that can be referenced by
+// annotated text edits and file operations.
+func (b *WorkspaceEditBuilder) AddChangeAnnotation(
+	annotationID ChangeAnnotationIdentifier,
+	annotation ChangeAnnotation,
+) {
+	b.annotations[annotationID] = annotation
+}
+
+// CreateFile adds an operation to create a file,
+// the kind of the operation is set by the builder.
+func (b *WorkspaceEditBuilder) CreateFile(operation CreateFile) {
+	operation.Kind = ResourceOperationKindCreate
+	b.changes = append(b.changes, workspaceEditChange{operation: operation})
+}
+
+// RenameFile adds an operation to rename a file,
+// the kind of the operation is set by the builder.
+func (b *WorkspaceEditBuilder) RenameFile(operation RenameFile) {
+	operation.Kind = ResourceOperationKindRename
+	b.changes = append(b.changes, workspaceEditChange{operation: operation})
+}
+
+// DeleteFile adds an operation to delete a file,
+// the kind of the operation is set by the builder.
+func (b *WorkspaceEditBuilder) DeleteFile(operation DeleteFile) {
+	operation.Kind = ResourceOperationKindDelete
+	b.changes = append(b.changes, workspaceEditChange{operation: operation})
+}
+
+func (b *WorkspaceEditBuilder) addTextEdits(
+	uri DocumentURI,
+	version *Integer,
+	annotationID *ChangeAnnotationIdentifier,
+	edits []TextEdit,
+) {
+	documentEdits := b.documentEdits(uri, version)
+	for _, edit := range edits {
+		documentEdits.edits = append(documentEdits.edits, annotatedTextEdit{
+			edit:         edit,
+			annotationID: annotationID,
+		})
+	}
+}
+
+func (b *WorkspaceEditBuilder) documentEdits(uri DocumentURI, version *Integer) *documentTextEdits {
+	// Text edits for a document are grouped with the edits added before any later
+	// file operations so that edits are never moved across an operation
+	// that could affect the document.
+	for i := len(b.changes) - 1; i >= 0; i -= 1 {
+		change := b.changes[i]
+		if change.operation != nil {
+			break
+		}
+		if change.documentEdits.uri == uri && sameVersion(change.documentEdits.version, version) {
+			return change.documentEdits
+		}
+	}
+
+	documentEdits := &documentTextEdits{
+		uri:     uri,
+		version: version,
+		edits:   []annotatedTextEdit{},
+	}
+	b.changes = append(b.changes, workspaceEditChange{documentEdits: documentEdits})
+	return documentEdits
+}
+
+// Build produces the workspace edit in the shape supported by the client
+// with the given state.
+// When the client state is nil, the client is assumed to support
+// all workspace edit features, this is consistent with the `Dispatcher`
+// that only checks client support when the client state is available.
+//
+// Building the edit fails if the client does not support a file operation that was added,
+// if text edits for the same document overlap or if a document in the attached document store
+// has changed since the edits were computed.
+// Change annotations are dropped for clients that do not support them unless
+// an annotation requires confirmation from the user, in which case building the edit fails.
+// A nil builder produces an empty workspace edit.
+func (b *WorkspaceEditBuilder) Build(clientState *ClientState) (*WorkspaceEdit, error) {
+	if b == nil {
+		return &WorkspaceEdit{}, nil
+	}
+
+	support := workspaceEditSupportFor(clientState)
+
+	if err := b.validate(support); err != nil {
+		return nil, err
+	}
+
+	if !support.documentChanges {
+		return b.buildChanges(), nil
+	}
+
+	return b.buildDocumentChanges(support.changeAnnotations), nil
+}
+
+func (b *WorkspaceEditBuilder) validate(support workspaceEditSupport) error {
+	for _, change := range b.changes {
+		if err := b.validateChange(change, support); err != nil {
+			return err
+		}
+	}
+
+	return b.validateTextEdits(support.documentChanges)
+}
+
+func (b *WorkspaceEditBuilder) validateChange(
+	change workspaceEditChange,
+	support workspaceEditSupport,
+) error {
+	if change.operation != nil {
+		kind, _ := resourceOperationDetails(change.operation)
+		if !support.documentChanges || !slices.Contains(support.resourceOperations, kind) {
+			return fmt.Errorf("%w: %s file operations", ErrNotSupportedByClient, kind)
+		}
+	}
+
+	for _, annotationID := range change.annotationIDs() {
+		if err := b.checkAnnotation(annotationID, support); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *WorkspaceEditBuilder) checkAnnotation(
+	annotationID ChangeAnnotationIdentifier,
+	support workspaceEditSupport,
+) error {
+	annotation, ok := b.annotations[annotationID]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownChangeAnnotation, annotationID)
+	}
+
+	// Annotations are dropped for clients that do not support them,
+	// which is only safe when the user does not need to confirm the change.
+	if !support.changeAnnotations && isTrue(annotation.NeedsConfirmation) {
+		return fmt.Errorf(
+			"%w: change annotation %q requires confirmation",
+			ErrNotSupportedByClient,
+			annotationID,
+		)
+	}
+	return nil
+}
+
+func (c workspaceEditChange) annotationIDs() []ChangeAnnotationIdentifier {
+	annotationIDs := []ChangeAnnotationIdentifier{}
+	if c.operation != nil {
+		if _, annotationID := resourceOperationDetails(c.operation); annotationID != nil {
+			annotationIDs = append(annotationIDs, *annotationID)
+		}
+		return annotationIDs
+	}
+
+	for _, edit := range c.documentEdits.edits {
+		if edit.annotationID != nil {
+			annotationIDs = append(annotationIDs, *edit.annotationID)
+		}
+	}
+	return annotationIDs
+}
+
+// validateTextEdits checks the text edits of the workspace edit.
+// Overlapping edits are checked for each `TextDocumentEdit` when the edits are
+// emitted in `documentChanges` as the client applies each of them to the
+// document as it is after the changes before them, otherwise all of the edits
+// for a document are merged in `changes` and are checked together.
+func (b *WorkspaceEditBuilder) validateTextEdits(documentChanges bool) error {
+	editsByURI := map[DocumentURI][]TextEdit{}
+	for _, change := range b.changes {
+		if change.documentEdits == nil {
+			continue
+		}
+
+		uri := change.documentEdits.uri
+		if err := b.checkVersion(uri, change.documentEdits.version); err != nil {
+			return err
+		}
+
+		for _, edit := range change.documentEdits.edits {
+			if edit.edit.Range == nil {
+				return fmt.Errorf("%w: text edit for %s has no range", ErrInvalidTextEdit, uri)
+			}
+			editsByURI[uri] = append(editsByURI[uri], edit.edit)
+		}
+
+		if documentChanges {
+			if err := checkOverlappingTextEdits(uri, editsByURI[uri]); err != nil {
+				return err
+			}
+			delete(editsByURI, uri)
+		}
+	}
+
+	for uri, edits := range editsByURI {
+		if err := checkOverlappingTextEdits(uri, edits); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *WorkspaceEditBuilder) checkVersion(uri DocumentURI, version *Integer) error {
+	if b.store == nil || version == nil {
+		return nil
+	}
+
+	document, ok := b.store.Get(uri)
+	if !ok {
+		return fmt.Errorf(
+			"%w: %s was closed after version %d was edited",
+			ErrDocumentVersionMismatch,
+			uri,
+			*version,
+		)
+	}
+
+	if document.Version != *version {
+		return fmt.Errorf(
+			"%w: edits for %s were computed against version %d but the document is at version %d",
+			ErrDocumentVersionMismatch,
+			uri,
+			*version,
+			document.Version,
+		)
+	}
+
+	return nil
+}
+
+func (b *WorkspaceEditBuilder) buildChanges() *WorkspaceEdit {
+	changes := map[DocumentURI][]TextEdit{}
+	for _, change := range b.changes {
+		if change.documentEdits == nil {
+			continue
+		}
+
+		uri := change.documentEdits.uri
+		for _, edit := range change.documentEdits.edits {
+			changes[uri] = append(changes[uri], edit.edit)
+		}
+	}
+
+	return &WorkspaceEdit{
+		Changes: changes,
+	}
+}
+
+func (b *WorkspaceEditBuilder) buildDocumentChanges(changeAnnotations bool) *WorkspaceEdit {
+	documentChanges := []any{}
+	for _, change := range b.changes {
+		if change.documentEdits == nil {
+			documentChanges = append(documentChanges, withoutAnnotation(change.operation, changeAnnotations))
+			continue
+		}
+
+		documentChanges = append(documentChanges, TextDocumentEdit{
+			TextDocument: OptionalVersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: TextDocumentIdentifier{URI: change.documentEdits.uri},
+				Version:                b.versionFor(change.documentEdits),
+			},
+			Edits: textDocumentEdits(change.documentEdits.edits, changeAnnotations),
+		})
+	}
+
+	edit := &WorkspaceEdit{
+		DocumentChanges: documentChanges,
+	}
+	if changeAnnotations && len(b.annotations) > 0 {
+		edit.ChangeAnnotations = make(map[ChangeAnnotationIdentifier]ChangeAnnotation, len(b.annotations))
+		for annotationID, annotation := range b.annotations {
+			edit.ChangeAnnotations[annotationID] = annotation
+		}
+	}
+
+	return edit
+}
+
+func (b *WorkspaceEditBuilder) versionFor(documentEdits *documentTextEdits) *Integer {
+	if documentEdits.version != nil {
+		version := *documentEdits.version
+		return &version
+	}
+
+	if b.store == nil {
+		return nil
+	}
+
+	document, ok := b.store.Get(documentEdits.uri)
+	if !ok {
+		return nil
+	}
+
+	version := document.Version
+	return &version
+}
+
+func textDocumentEdits(edits []annotatedTextEdit, changeAnnotations bool) []any {
+	textEdits := make([]any, 0, len(edits))
+	for _, edit := range edits {
+		if edit.annotationID != nil && changeAnnotations {
+			textEdits = append(textEdits, AnnotatedTextEdit{
+				TextEdit:     edit.edit,
+				AnnotationID: *edit.annotationID,
+			})
+		} else {
+			textEdits = append(textEdits, edit.edit)
+		}
+	}
+	return textEdits
+}
+
+func withoutAnnotation(operation any, changeAnnotations bool) any {
+	if changeAnnotations {
+		return operation
+	}
+
+	switch op := operation.(type) {
+	case CreateFile:
+		op.AnnotationID = nil
+		return op
+	case RenameFile:
+		op.AnnotationID = nil
+		return op
+	case DeleteFile:
+		op.AnnotationID = nil
+		return op
+	}
+	return operation
+}
+
+func resourceOperationDetails(operation any) (ResourceOperationKind, *ChangeAnnotationIdentifier) {
+	switch op := operation.(type) {
+	case CreateFile:
+		return ResourceOperationKindCreate, op.AnnotationID
+	case RenameFile:
+		return ResourceOperationKindRename, op.AnnotationID
+	case DeleteFile:
+		return ResourceOperationKindDelete, op.AnnotationID
+	}
+	return "", nil
+}
+
+// checkOverlappingTextEdits checks that none of the text edits for a document overlap.
+// As per the specification, multiple edits may have the same start position
+// as long as they are inserts or any number of inserts followed by a single
+// remove or replace edit.
+func checkOverlappingTextEdits(uri DocumentURI, edits []TextEdit) error {
+	sorted := slices.Clone(edits)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if comparePositions(sorted[i].Range.Start, sorted[j].Range.Start) != 0 {
+			return comparePositions(sorted[i].Range.Start, sorted[j].Range.Start) < 0
+		}
+		return comparePositions(sorted[i].Range.End, sorted[j].Range.End) < 0
+	})
+
+	for i := 1; i < len(sorted); i += 1 {
+		previous := sorted[i-1].Range
+		current := sorted[i].Range
+		if comparePositions(previous.End, current.Start) > 0 {
+			return fmt.Errorf(
+				"%w: edits for %s at %d:%d-%d:%d and %d:%d-%d:%d overlap",
+				ErrOverlappingTextEdits,
+				uri,
+				previous.Start.Line, previous.Start.Character,
+				previous.End.Line, previous.End.Character,
+				current.Start.Line, current.Start.Character,
+				current.End.Line, current.End.Character,
+			)
+		}
+	}
+
+	return nil
+}
+
+func comparePositions(a Position, b Position) int {
+	if a.Line != b.Line {
+		if a.Line < b.Line {
+			return -1
+		}
+		return 1
+	}
+
+	if a.Character != b.Character {
+		if a.Character < b.Character {
+			return -1
+		}
+		return 1
+	}
+
+	return 0
+}
+
+func sameVersion(a *Integer, b *Integer) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+type workspaceEditSupport struct {
+	documentChanges    bool
+	resourceOperations []ResourceOperationKind
+	changeAnnotations  bool
+}
+
+func workspaceEditSupportFor(clientState *ClientState) workspaceEditSupport {
+	if clientState == nil {
+		return workspaceEditSupport{
+			documentChanges: true,
+			resourceOperations: []ResourceOperationKind{
+				ResourceOperationKindCreate,
+				ResourceOperationKindRename,
+				ResourceOperationKindDelete,
+			},
+			changeAnnotations: true,
+		}
+	}
+
+	workspace := clientState.Capabilities().Workspace
+	if workspace == nil || workspace.WorkspaceEdit == nil {
+		return workspaceEditSupport{}
+	}
+
+	// Change annotations can only be referenced from document changes.
+	workspaceEdit := workspace.WorkspaceEdit
+	documentChanges := isTrue(workspaceEdit.DocumentChanges)
+	return workspaceEditSupport{
+		documentChanges:    documentChanges,
+		resourceOperations: workspaceEdit.ResourceOperations,
+		changeAnnotations:  documentChanges && workspaceEdit.ChangeAnnotationSupport != nil,
+	}
+}
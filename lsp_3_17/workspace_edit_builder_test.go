@@ -0,0 +1,279 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/server"
+)
+
+type WorkspaceEditBuilderTestSuite struct {
+	suite.Suite
+}
+
+const (
+	testEditURI    = "file:///main.go"
+	testRenamedURI = "file:///renamed.go"
+)
+
+func (s *WorkspaceEditBuilderTestSuite) Test_builds_document_changes_for_client_that_supports_them() {
+	store := NewDocumentStore()
+	store.Open(&DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testEditURI, Version: 3, Text: "package main\n"},
+	})
+
+	builder := NewWorkspaceEditBuilder(WithWorkspaceEditDocumentStore(store))
+	annotationID := "rename"
+	builder.AddChangeAnnotation(annotationID, ChangeAnnotation{Label: "Rename main"})
+	builder.AddTextEdits(testEditURI, TextEdit{Range: editRange(0, 8, 0, 12), NewText: "app"})
+	builder.AddAnnotatedTextEdits(testEditURI, annotationID, TextEdit{Range: editRange(1, 0, 1, 0), NewText: "\n"})
+	builder.RenameFile(RenameFile{OldURI: testEditURI, NewURI: testRenamedURI, AnnotationID: &annotationID})
+	builder.AddTextEdits(testRenamedURI, TextEdit{Range: editRange(0, 0, 0, 0), NewText: "// app\n"})
+
+	edit, err := builder.Build(s.clientState(&WorkspaceEditClientCapabilities{
+		DocumentChanges:         &True,
+		ResourceOperations:      []ResourceOperationKind{ResourceOperationKindRename},
+		ChangeAnnotationSupport: &ChangeAnnotationSupport{},
+	}))
+	s.Require().NoError(err)
+
+	version := Integer(3)
+	s.Require().Equal(
+		&WorkspaceEdit{
+			DocumentChanges: []any{
+				TextDocumentEdit{
+					TextDocument: OptionalVersionedTextDocumentIdentifier{
+						TextDocumentIdentifier: TextDocumentIdentifier{URI: testEditURI},
+						Version:                &version,
+					},
+					Edits: []any{
+						TextEdit{Range: editRange(0, 8, 0, 12), NewText: "app"},
+						AnnotatedTextEdit{
+							TextEdit:     TextEdit{Range: editRange(1, 0, 1, 0), NewText: "\n"},
+							AnnotationID: annotationID,
+						},
+					},
+				},
+				RenameFile{
+					Kind:         ResourceOperationKindRename,
+					OldURI:       testEditURI,
+					NewURI:       testRenamedURI,
+					AnnotationID: &annotationID,
+				},
+				TextDocumentEdit{
+					TextDocument: OptionalVersionedTextDocumentIdentifier{
+						TextDocumentIdentifier: TextDocumentIdentifier{URI: testRenamedURI},
+					},
+					Edits: []any{
+						TextEdit{Range: editRange(0, 0, 0, 0), NewText: "// app\n"},
+					},
+				},
+			},
+			ChangeAnnotations: map[ChangeAnnotationIdentifier]ChangeAnnotation{
+				annotationID: {Label: "Rename main"},
+			},
+		},
+		edit,
+	)
+}
+
+func (s *WorkspaceEditBuilderTestSuite) Test_builds_plain_changes_for_client_without_document_changes() {
+	builder := NewWorkspaceEditBuilder()
+	annotationID := "format"
+	builder.AddChangeAnnotation(annotationID, ChangeAnnotation{Label: "Format"})
+	builder.AddTextEdits(testEditURI, TextEdit{Range: editRange(0, 0, 0, 1), NewText: "p"})
+	builder.AddAnnotatedTextEdits(testEditURI, annotationID, TextEdit{Range: editRange(2, 0, 2, 4), NewText: ""})
+
+	edit, err := builder.Build(s.clientState(nil))
+	s.Require().NoError(err)
+	s.Require().Equal(
+		&WorkspaceEdit{
+			Changes: map[DocumentURI][]TextEdit{
+				testEditURI: {
+					{Range: editRange(0, 0, 0, 1), NewText: "p"},
+					{Range: editRange(2, 0, 2, 4), NewText: ""},
+				},
+			},
+		},
+		edit,
+	)
+}
+
+func (s *WorkspaceEditBuilderTestSuite) Test_fails_for_operations_not_supported_by_client() {
+	tests := []struct {
+		name         string
+		capabilities *WorkspaceEditClientCapabilities
+		build        func(builder *WorkspaceEditBuilder)
+	}{
+		{
+			name: "file operation without document changes support",
+			capabilities: &WorkspaceEditClientCapabilities{
+				ResourceOperations: []ResourceOperationKind{ResourceOperationKindCreate},
+			},
+			build: func(builder *WorkspaceEditBuilder) {
+				builder.CreateFile(CreateFile{URI: testEditURI})
+			},
+		},
+		{
+			name: "unsupported file operation kind",
+			capabilities: &WorkspaceEditClientCapabilities{
+				DocumentChanges:    &True,
+				ResourceOperations: []ResourceOperationKind{ResourceOperationKindCreate},
+			},
+			build: func(builder *WorkspaceEditBuilder) {
+				builder.CreateFile(CreateFile{URI: testEditURI})
+				builder.DeleteFile(DeleteFile{URI: testEditURI})
+			},
+		},
+		{
+			name: "change annotation that needs confirmation",
+			capabilities: &WorkspaceEditClientCapabilities{
+				DocumentChanges: &True,
+			},
+			build: func(builder *WorkspaceEditBuilder) {
+				builder.AddChangeAnnotation("confirm", ChangeAnnotation{Label: "Confirm", NeedsConfirmation: &True})
+				builder.AddAnnotatedTextEdits(testEditURI, "confirm", TextEdit{Range: editRange(0, 0, 0, 0)})
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			builder := NewWorkspaceEditBuilder()
+			test.build(builder)
+			_, err := builder.Build(s.clientState(test.capabilities))
+			s.Require().ErrorIs(err, ErrNotSupportedByClient)
+		})
+	}
+}
+
+func (s *WorkspaceEditBuilderTestSuite) Test_fails_for_overlapping_text_edits() {
+	builder := NewWorkspaceEditBuilder()
+	// Inserts at the same position followed by a single replacement are allowed.
+	builder.AddTextEdits(
+		testEditURI,
+		TextEdit{Range: editRange(1, 4, 1, 8), NewText: "replaced"},
+		TextEdit{Range: editRange(1, 4, 1, 4), NewText: "first"},
+		TextEdit{Range: editRange(1, 4, 1, 4), NewText: "second"},
+		TextEdit{Range: editRange(0, 0, 1, 4), NewText: ""},
+	)
+	_, err := builder.Build(nil)
+	s.Require().NoError(err)
+
+	builder.AddTextEdits(testEditURI, TextEdit{Range: editRange(1, 6, 2, 0), NewText: ""})
+	_, err = builder.Build(nil)
+	s.Require().ErrorIs(err, ErrOverlappingTextEdits)
+
+	invalidBuilder := NewWorkspaceEditBuilder()
+	invalidBuilder.AddTextEdits(testEditURI, TextEdit{NewText: "no range"})
+	_, err = invalidBuilder.Build(nil)
+	s.Require().ErrorIs(err, ErrInvalidTextEdit)
+}
+
+func (s *WorkspaceEditBuilderTestSuite) Test_checks_overlapping_text_edits_for_each_document_edit() {
+	builder := NewWorkspaceEditBuilder()
+	builder.AddTextEdits(testEditURI, TextEdit{Range: editRange(0, 0, 0, 12), NewText: "package app"})
+	builder.DeleteFile(DeleteFile{URI: testEditURI})
+	builder.CreateFile(CreateFile{URI: testEditURI})
+	// The edits are applied to the newly created file
+	// so they do not overlap with the edits before the file operations.
+	builder.AddTextEdits(testEditURI, TextEdit{Range: editRange(0, 0, 0, 4), NewText: "main"})
+
+	edit, err := builder.Build(s.clientState(&WorkspaceEditClientCapabilities{
+		DocumentChanges: &True,
+		ResourceOperations: []ResourceOperationKind{
+			ResourceOperationKindCreate,
+			ResourceOperationKindDelete,
+		},
+	}))
+	s.Require().NoError(err)
+	s.Require().Len(edit.DocumentChanges, 4)
+}
+
+func (s *WorkspaceEditBuilderTestSuite) Test_fails_when_document_changed_since_edits_were_computed() {
+	store := NewDocumentStore()
+	snapshot := store.Open(&DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testEditURI, Version: 1, Text: "package main\n"},
+	})
+
+	builder := NewWorkspaceEditBuilder(WithWorkspaceEditDocumentStore(store))
+	builder.AddDocumentTextEdits(snapshot, TextEdit{Range: editRange(0, 8, 0, 12), NewText: "app"})
+	_, err := builder.Build(nil)
+	s.Require().NoError(err)
+
+	_, err = store.Change(&DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: TextDocumentIdentifier{URI: testEditURI},
+			Version:                2,
+		},
+		ContentChanges: []any{TextDocumentContentChangeEventWhole{Text: "package app\n"}},
+	})
+	s.Require().NoError(err)
+	_, err = builder.Build(nil)
+	s.Require().ErrorIs(err, ErrDocumentVersionMismatch)
+
+	store.Close(&DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: testEditURI}})
+	_, err = builder.Build(nil)
+	s.Require().ErrorIs(err, ErrDocumentVersionMismatch)
+}
+
+func (s *WorkspaceEditBuilderTestSuite) Test_fails_for_unknown_change_annotation() {
+	builder := NewWorkspaceEditBuilder()
+	builder.AddAnnotatedTextEdits(testEditURI, "missing", TextEdit{Range: editRange(0, 0, 0, 0)})
+	_, err := builder.Build(nil)
+	s.Require().ErrorIs(err, ErrUnknownChangeAnnotation)
+}
+
+func (s *WorkspaceEditBuilderTestSuite) Test_dispatcher_applies_built_workspace_edit() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	withClientState(lspCtx, s.clientState(&WorkspaceEditClientCapabilities{DocumentChanges: &True}))
+	dispatcher := NewDispatcher(lspCtx)
+
+	unsupported := NewWorkspaceEditBuilder()
+	unsupported.DeleteFile(DeleteFile{URI: testEditURI})
+	_, err := dispatcher.ApplyWorkspaceEditFromBuilder(nil, unsupported)
+	s.Require().ErrorIs(err, ErrNotSupportedByClient)
+
+	builder := NewWorkspaceEditBuilder()
+	builder.AddTextEdits(testEditURI, TextEdit{Range: editRange(0, 0, 0, 0), NewText: "// header\n"})
+	label := "Add header"
+	_, err = dispatcher.ApplyWorkspaceEditFromBuilder(&label, builder)
+	s.Require().NoError(err)
+
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	s.Require().Equal([]string{MethodWorkspaceApplyEdit}, container.clientReceivedMethods)
+	s.Require().JSONEq(
+		`{"label":"Add header","edit":{"documentChanges":[{"textDocument":{"uri":"file:///main.go"},`+
+			`"edits":[{"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":0}},`+
+			`"newText":"// header\n"}]}]}}`,
+		string(*container.clientReceivedMessages[0]),
+	)
+}
+
+func (s *WorkspaceEditBuilderTestSuite) clientState(capabilities *WorkspaceEditClientCapabilities) *ClientState {
+	return NewClientState(&InitializeParams{
+		Capabilities: ClientCapabilities{
+			Workspace: &ClientWorkspaceCapabilities{
+				ApplyEdit:     &True,
+				WorkspaceEdit: capabilities,
+			},
+		},
+	})
+}
+
+func editRange(startLine UInteger, startChar UInteger, endLine UInteger, endChar UInteger) *Range {
+	return &Range{
+		Start: Position{Line: startLine, Character: startChar},
+		End:   Position{Line: endLine, Character: endChar},
+	}
+}
+
+func TestWorkspaceEditBuilderTestSuite(t *testing.T) {
+	suite.Run(t, new(WorkspaceEditBuilderTestSuite))
+}
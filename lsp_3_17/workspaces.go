@@ -512,6 +512,14 @@ type DidChangeConfigurationParams struct {
 	Settings LSPAny `json:"settings"`
 }
 
+// DidChangeConfigurationRegistrationOptions provides the options
+// for dynamically registering for `workspace/didChangeConfiguration` notifications.
+type DidChangeConfigurationRegistrationOptions struct {
+	// The configuration section(s) that the server is interested in,
+	// this can be a string or a slice of strings.
+	Section any `json:"section,omitempty"`
+}
+
 // https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#workspace_workspaceFolders
 
 const MethodWorkspaceFolders = Method("workspace/workspaceFolders")
@@ -704,6 +712,75 @@ const (
 	FileChangeDeleted FileChangeType = 3
 )
 
+// DidChangeWatchedFilesRegistrationOptions describes options to be used when
+// registering for file system change events with `client/registerCapability`.
+type DidChangeWatchedFilesRegistrationOptions struct {
+	// The watchers to register.
+	Watchers []FileSystemWatcher `json:"watchers"`
+}
+
+// FileSystemWatcher describes a file system watcher to register
+// with the client.
+type FileSystemWatcher struct {
+	// The glob pattern to watch.
+	// This can be a `Pattern` (string) or a `RelativePattern`
+	// relative to a base URI or workspace folder.
+	//
+	// @since 3.17.0 support for relative patterns.
+	GlobPattern any `json:"globPattern"`
+
+	// The kind of events of interest. If omitted it defaults
+	// to WatchKind.Create | WatchKind.Change | WatchKind.Delete
+	// which is 7.
+	Kind *WatchKind `json:"kind,omitempty"`
+}
+
+// Pattern is the glob pattern to watch relative to the base path.
+// Glob patterns can have the following syntax:
+//   - `*` to match one or more characters in a path segment
+//   - `?` to match on one character in a path segment
+//   - `**` to match any number of path segments, including none
+//   - `{}` to group conditions (e.g. `**/*.{ts,js}` matches all TypeScript
+//     and JavaScript files)
+//   - `[]` to declare a range of characters to match in a path segment
+//     (e.g., `example.[0-9]` to match on `example.0`, `example.1`, …)
+//   - `[!...]` to negate a range of characters to match in a path segment
+//     (e.g., `example.[!0-9]` to match on `example.a`, `example.b`,
+//     but not `example.0`)
+//
+// @since 3.17.0
+type Pattern = string
+
+// RelativePattern is a helper to construct glob patterns that are matched
+// relatively to a base URI. The common value for a `BaseURI` is a workspace
+// folder root, but it can be another absolute URI as well.
+//
+// @since 3.17.0
+type RelativePattern struct {
+	// A workspace folder or a base URI to which this pattern will be matched
+	// against relatively.
+	// This can be a `WorkspaceFolder` or a `URI`.
+	BaseURI any `json:"baseUri"`
+
+	// The actual glob pattern.
+	Pattern Pattern `json:"pattern"`
+}
+
+// WatchKind represents the kind of file system events a watcher
+// is interested in, this is a bit flag that can be combined.
+type WatchKind = UInteger
+
+const (
+	// WatchKindCreate is interested in create events.
+	WatchKindCreate WatchKind = 1
+
+	// WatchKindChange is interested in change events.
+	WatchKindChange WatchKind = 2
+
+	// WatchKindDelete is interested in delete events.
+	WatchKindDelete WatchKind = 4
+)
+
 // https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#workspace_executeCommand
 
 const MethodWorkspaceExecuteCommand = Method("workspace/executeCommand")
@@ -0,0 +1,83 @@
+package lsp
+
+import (
+	"encoding/json"
+
+	lsp317 "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#stringValue
+
+// StringValueKindSnippet is the kind of a string value
+// that holds a snippet.
+const StringValueKindSnippet = "snippet"
+
+// StringValue is a string value used as a snippet, a template
+// to insert text into a document.
+//
+// Snippets can contain tab stops and placeholders with `$1`, `$2`
+// and `${3:foo}`, `$0` defines the final tab stop, it defaults to
+// the end of the snippet.
+// Variables are defined with `$name` and `${name:default value}`.
+//
+// @since 3.18.0
+type StringValue struct {
+	// The kind of string value, this is always `snippet`.
+	Kind string `json:"kind"`
+
+	// The snippet string.
+	Value string `json:"value"`
+}
+
+// NewSnippetStringValue creates a string value for the provided snippet.
+func NewSnippetStringValue(snippet string) StringValue {
+	return StringValue{
+		Kind:  StringValueKindSnippet,
+		Value: snippet,
+	}
+}
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#snippetTextEdit
+
+// SnippetTextEdit is an interactive text edit that can be used in the edits
+// of a `TextDocumentEdit` (as a part of a `WorkspaceEdit`) alongside
+// `TextEdit`s and `AnnotatedTextEdit`s.
+//
+// Snippet text edits must only be sent to clients that advertise support
+// with the `workspace.workspaceEdit.snippetEditSupport` client capability.
+//
+// @since 3.18.0
+type SnippetTextEdit struct {
+	// The range of the text document to be manipulated.
+	Range lsp317.Range `json:"range"`
+
+	// The snippet to be inserted.
+	Snippet StringValue `json:"snippet"`
+
+	// The actual identifier of the snippet edit.
+	AnnotationID *lsp317.ChangeAnnotationIdentifier `json:"annotationId,omitempty"`
+}
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#workspaceEditClientCapabilities
+
+// WorkspaceEditClientCapabilities defines the capabilities the client has for
+// workspace edits.
+type WorkspaceEditClientCapabilities struct {
+	lsp317.WorkspaceEditClientCapabilities
+
+	// Whether the client supports snippets as text edits.
+	//
+	// @since 3.18.0
+	SnippetEditSupport *bool `json:"snippetEditSupport,omitempty"`
+}
+
+// Decodes data into the LSP 3.17 structure embedded in a LSP 3.18 structure
+// and then into the fields that LSP 3.18 replaces, so the embedded structure
+// remains a complete LSP 3.17 view of the data.
+func unmarshalExtended(data []byte, base any, replacements any) error {
+	if err := json.Unmarshal(data, base); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, replacements)
+}
@@ -0,0 +1,129 @@
+package lsp
+
+import (
+	"context"
+	"slices"
+
+	"github.com/two-hundred/ls-builder/common"
+	lsp317 "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+// ClientState holds information about the client that is captured
+// from the `initialize` request with the client capabilities defined in LSP 3.18,
+// along with helpers to query support for features added in LSP 3.18.
+// Helpers for features that are unchanged from LSP 3.17 are provided
+// by the embedded LSP 3.17 client state.
+//
+// A `Handler` captures the client state during initialisation,
+// it can be retrieved with `Handler.ClientState` or from the context of any
+// message handled after initialisation with `ClientStateFromContext`.
+type ClientState struct {
+	*lsp317.ClientState
+	capabilities ClientCapabilities
+}
+
+// NewClientState creates a new client state from the parameters
+// of an `initialize` request.
+func NewClientState(params *InitializeParams) *ClientState {
+	return &ClientState{
+		ClientState:  lsp317.NewClientState(&params.InitializeParams),
+		capabilities: params.Capabilities,
+	}
+}
+
+// Capabilities returns the capabilities the client provided
+// in the `initialize` request.
+func (s *ClientState) Capabilities() ClientCapabilities {
+	return s.capabilities
+}
+
+// SupportsRefresh determines whether the client supports the provided
+// refresh request (e.g. `workspace/foldingRange/refresh`).
+// This will be false for methods that are not refresh requests.
+func (s *ClientState) SupportsRefresh(method string) bool {
+	workspace := s.capabilities.Workspace
+	switch method {
+	case MethodFoldingRangeRefresh:
+		return workspace != nil && workspace.FoldingRange != nil && isTrue(workspace.FoldingRange.RefreshSupport)
+	case MethodWorkspaceTextDocumentContentRefresh:
+		// There is no dedicated capability for refreshing text document content,
+		// a client that supports the `workspace/textDocumentContent` request
+		// must also support refreshing the content it has requested.
+		return workspace != nil && workspace.TextDocumentContent != nil
+	default:
+		return s.ClientState.SupportsRefresh(method)
+	}
+}
+
+// SupportsSnippetTextEdits determines whether the client supports
+// `SnippetTextEdit`s in the edits of a `TextDocumentEdit`.
+func (s *ClientState) SupportsSnippetTextEdits() bool {
+	workspace := s.capabilities.Workspace
+	return workspace != nil &&
+		workspace.WorkspaceEdit != nil &&
+		isTrue(workspace.WorkspaceEdit.SnippetEditSupport)
+}
+
+// SupportsCodeActionDocumentation determines whether the client supports
+// documentation for classes of code actions in the code action server capabilities.
+func (s *ClientState) SupportsCodeActionDocumentation() bool {
+	textDocument := s.capabilities.TextDocument
+	return textDocument != nil &&
+		textDocument.CodeAction != nil &&
+		isTrue(textDocument.CodeAction.DocumentationSupport)
+}
+
+// SupportsCodeActionTag determines whether the client supports
+// the provided tag for code actions.
+func (s *ClientState) SupportsCodeActionTag(tag CodeActionTag) bool {
+	textDocument := s.capabilities.TextDocument
+	return textDocument != nil &&
+		textDocument.CodeAction != nil &&
+		textDocument.CodeAction.TagSupport != nil &&
+		slices.Contains(textDocument.CodeAction.TagSupport.ValueSet, tag)
+}
+
+// ClientState returns the state captured from the client during initialisation,
+// this will be nil if the `initialize` request has not been handled.
+func (h *Handler) ClientState() *ClientState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.clientState
+}
+
+func (h *Handler) setClientState(state *ClientState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clientState = state
+}
+
+type clientStateKey struct{}
+
+func withClientState(ctx *common.LSPContext, state *ClientState) {
+	if state == nil {
+		return
+	}
+
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx.Context = context.WithValue(parent, clientStateKey{}, state)
+}
+
+// ClientStateFromContext retrieves the LSP 3.18 state captured from the client during
+// initialisation for the session that the provided LSP context belongs to.
+// This will be nil for contexts that were not created by a `Handler`
+// or before the `initialize` request has been handled.
+func ClientStateFromContext(ctx *common.LSPContext) *ClientState {
+	if ctx == nil || ctx.Context == nil {
+		return nil
+	}
+
+	state, _ := ctx.Context.Value(clientStateKey{}).(*ClientState)
+	return state
+}
+
+func isTrue(value *bool) bool {
+	return value != nil && *value
+}
@@ -0,0 +1,117 @@
+package lsp
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/two-hundred/ls-builder/common"
+	lsp317 "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+// Dispatcher provides a convenient way to dispatch
+// requests and notification to the client with types
+// for known requests and notifications that a server can
+// send to a client in LSP 3.18.0.
+//
+// Requests and notifications that are unchanged from LSP 3.17
+// are dispatched by the embedded LSP 3.17 dispatcher.
+//
+// When the underlying LSP context was created by a `Handler` after initialisation,
+// the dispatcher will refuse to send requests that the client has not advertised
+// support for with an `lsp317.ErrNotSupportedByClient` error.
+type Dispatcher struct {
+	*lsp317.Dispatcher
+}
+
+// NewDispatcher creates a new instance of a dispatcher.
+// A dispatcher is wrapped around a current LSP context
+// and should live as long as the underlying LSP context.
+func NewDispatcher(ctx *common.LSPContext) *Dispatcher {
+	return &Dispatcher{
+		Dispatcher: lsp317.NewDispatcher(ctx),
+	}
+}
+
+// Checks whether the client supports a feature when client state is available,
+// when there is no client state, the dispatcher can't know what the client supports
+// so will always send the message.
+func (d *Dispatcher) checkClientSupport(feature string, isSupported func(*ClientState) bool) error {
+	clientState := ClientStateFromContext(d.Context())
+	if clientState == nil || isSupported(clientState) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", lsp317.ErrNotSupportedByClient, feature)
+}
+
+func (d *Dispatcher) checkRefreshSupport(method string) error {
+	return d.checkClientSupport(method, func(clientState *ClientState) bool {
+		return clientState.SupportsRefresh(method)
+	})
+}
+
+// FoldingRangeRefresh requests the client to refresh all folding ranges
+// currently shown in editors.
+//
+// @since 3.18.0
+func (d *Dispatcher) FoldingRangeRefresh() error {
+	if err := d.checkRefreshSupport(MethodFoldingRangeRefresh); err != nil {
+		return err
+	}
+
+	return d.Context().Call(MethodFoldingRangeRefresh, nil, nil)
+}
+
+// TextDocumentContentRefresh requests the client to refresh the content
+// of a specific text document that was provided by the server
+// through a `workspace/textDocumentContent` request.
+//
+// @since 3.18.0
+func (d *Dispatcher) TextDocumentContentRefresh(params TextDocumentContentRefreshParams) error {
+	if err := d.checkRefreshSupport(MethodWorkspaceTextDocumentContentRefresh); err != nil {
+		return err
+	}
+
+	return d.Context().Call(MethodWorkspaceTextDocumentContentRefresh, params, nil)
+}
+
+// ApplyWorkspaceEdit requests that the client applies a workspace edit.
+// This will fail without sending the request if the edit contains
+// `SnippetTextEdit`s and the client does not support them.
+func (d *Dispatcher) ApplyWorkspaceEdit(
+	params lsp317.ApplyWorkspaceEditParams,
+) (*lsp317.ApplyWorkspaceEditResult, error) {
+	if containsSnippetTextEdits(&params.Edit) {
+		err := d.checkClientSupport(
+			"snippet text edits",
+			(*ClientState).SupportsSnippetTextEdits,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return d.Dispatcher.ApplyWorkspaceEdit(params)
+}
+
+func containsSnippetTextEdits(edit *lsp317.WorkspaceEdit) bool {
+	return slices.ContainsFunc(edit.DocumentChanges, func(change any) bool {
+		switch textDocumentEdit := change.(type) {
+		case lsp317.TextDocumentEdit:
+			return slices.ContainsFunc(textDocumentEdit.Edits, isSnippetTextEdit)
+		case *lsp317.TextDocumentEdit:
+			return textDocumentEdit != nil && slices.ContainsFunc(textDocumentEdit.Edits, isSnippetTextEdit)
+		default:
+			return false
+		}
+	})
+}
+
+func isSnippetTextEdit(edit any) bool {
+	switch edit.(type) {
+	case SnippetTextEdit, *SnippetTextEdit:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,142 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	lsp317 "github.com/two-hundred/ls-builder/lsp_3_17"
+	"github.com/two-hundred/ls-builder/server"
+)
+
+type DispatchTestSuite struct {
+	suite.Suite
+}
+
+func (s *DispatchTestSuite) Test_server_sends_lsp_3_18_refresh_requests() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	dispatcher := NewDispatcher(lspCtx)
+
+	err := dispatcher.FoldingRangeRefresh()
+	s.Require().NoError(err)
+
+	err = dispatcher.TextDocumentContentRefresh(TextDocumentContentRefreshParams{URI: "virtual:///schema.json"})
+	s.Require().NoError(err)
+
+	// Requests that are unchanged from LSP 3.17 are sent by the embedded dispatcher.
+	err = dispatcher.InlayHintRefresh()
+	s.Require().NoError(err)
+
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	s.Require().Equal(
+		[]string{
+			MethodFoldingRangeRefresh,
+			MethodWorkspaceTextDocumentContentRefresh,
+			lsp317.MethodInlayHintRefresh,
+		},
+		container.clientReceivedMethods,
+	)
+	s.Require().Equal(`{"uri":"virtual:///schema.json"}`, string(*container.clientReceivedMessages[1]))
+}
+
+func (s *DispatchTestSuite) Test_refuses_requests_not_supported_by_client() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	trueVal := true
+	withClientState(lspCtx, NewClientState(&InitializeParams{
+		Capabilities: ClientCapabilities{
+			Workspace: &ClientWorkspaceCapabilities{
+				ClientWorkspaceCapabilities: lsp317.ClientWorkspaceCapabilities{
+					ApplyEdit: &trueVal,
+				},
+			},
+		},
+	}))
+	dispatcher := NewDispatcher(lspCtx)
+
+	err := dispatcher.FoldingRangeRefresh()
+	s.Require().ErrorIs(err, lsp317.ErrNotSupportedByClient)
+
+	err = dispatcher.TextDocumentContentRefresh(TextDocumentContentRefreshParams{})
+	s.Require().ErrorIs(err, lsp317.ErrNotSupportedByClient)
+
+	_, err = dispatcher.ApplyWorkspaceEdit(lsp317.ApplyWorkspaceEditParams{
+		Edit: lsp317.WorkspaceEdit{
+			DocumentChanges: []any{
+				&lsp317.TextDocumentEdit{
+					Edits: []any{
+						lsp317.TextEdit{NewText: "name"},
+						&SnippetTextEdit{Snippet: NewSnippetStringValue("${1:name}")},
+					},
+				},
+			},
+		},
+	})
+	s.Require().ErrorIs(err, lsp317.ErrNotSupportedByClient)
+
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	s.Require().Empty(container.clientReceivedMethods)
+}
+
+func (s *DispatchTestSuite) Test_sends_snippet_text_edits_to_client_that_supports_them() {
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	container := createTestConnectionsContainer(newTestServerHandler())
+	lspCtx := server.NewLSPContext(ctx, container.serverConn, nil)
+	trueVal := true
+	withClientState(lspCtx, NewClientState(&InitializeParams{
+		Capabilities: ClientCapabilities{
+			Workspace: &ClientWorkspaceCapabilities{
+				WorkspaceEdit: &WorkspaceEditClientCapabilities{SnippetEditSupport: &trueVal},
+				FoldingRange:  &FoldingRangeWorkspaceClientCapabilities{RefreshSupport: &trueVal},
+			},
+		},
+	}))
+	dispatcher := NewDispatcher(lspCtx)
+
+	_, err := dispatcher.ApplyWorkspaceEdit(lsp317.ApplyWorkspaceEditParams{
+		Edit: lsp317.WorkspaceEdit{
+			DocumentChanges: []any{
+				lsp317.TextDocumentEdit{
+					TextDocument: lsp317.OptionalVersionedTextDocumentIdentifier{
+						TextDocumentIdentifier: lsp317.TextDocumentIdentifier{URI: "file:///test.go"},
+					},
+					Edits: []any{
+						SnippetTextEdit{Snippet: NewSnippetStringValue("${1:name}")},
+					},
+				},
+			},
+		},
+	})
+	s.Require().NoError(err)
+
+	err = dispatcher.FoldingRangeRefresh()
+	s.Require().NoError(err)
+
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	s.Require().Equal(
+		[]string{lsp317.MethodWorkspaceApplyEdit, MethodFoldingRangeRefresh},
+		container.clientReceivedMethods,
+	)
+	s.Require().JSONEq(
+		`{"edit":{"documentChanges":[{"textDocument":{"uri":"file:///test.go"},`+
+			`"edits":[{"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":0}},`+
+			`"snippet":{"kind":"snippet","value":"${1:name}"}}]}]}}`,
+		string(*container.clientReceivedMessages[0]),
+	)
+}
+
+func TestDispatchTestSuite(t *testing.T) {
+	suite.Run(t, new(DispatchTestSuite))
+}
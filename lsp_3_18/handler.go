@@ -0,0 +1,284 @@
+package lsp
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/two-hundred/ls-builder/common"
+	lsp317 "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+// Handler defines a set of message handlers that allows the server
+// to respond to client notifications and requests for LSP 3.18.
+//
+// The handler builds on the LSP 3.17 handler that it embeds, handlers for
+// messages that are unchanged in LSP 3.18 can be provided with `WithBaseOptions`
+// or set with the `Set*Handler` methods of the embedded handler, so existing
+// LSP 3.17 handlers can be reused as they are.
+// Messages added or changed in LSP 3.18 have handlers defined in this package
+// that take precedence over the LSP 3.17 handlers for the same methods.
+type Handler struct {
+	*lsp317.Handler
+
+	// Lifecycle Messages
+	initialize InitializeHandlerFunc
+
+	// Language Features
+	inlineCompletion  InlineCompletionHandlerFunc
+	codeAction        CodeActionHandlerFunc
+	codeActionResolve CodeActionResolveHandlerFunc
+
+	// Workspace Features
+	workspaceTextDocumentContent WorkspaceTextDocumentContentHandlerFunc
+
+	// Information about the client captured from the initialize request
+	// with the client capabilities defined in LSP 3.18.
+	clientState *ClientState
+	mu          sync.Mutex
+}
+
+// HandlerOption is a function that can be used to configure a handler
+// with options such as message handlers.
+type HandlerOption func(*Handler)
+
+// WithBaseOptions applies options for the embedded LSP 3.17 handler
+// such as handlers for messages that are unchanged in LSP 3.18.
+func WithBaseOptions(opts ...lsp317.HandlerOption) HandlerOption {
+	return func(root *Handler) {
+		for _, opt := range opts {
+			opt(root.Handler)
+		}
+	}
+}
+
+// WithInitializeHandler sets the handler for the `initialize` request.
+func WithInitializeHandler(handler InitializeHandlerFunc) HandlerOption {
+	return func(root *Handler) {
+		root.SetInitializeHandler(handler)
+	}
+}
+
+// WithInlineCompletionHandler sets the handler for the
+// `textDocument/inlineCompletion` request.
+func WithInlineCompletionHandler(handler InlineCompletionHandlerFunc) HandlerOption {
+	return func(root *Handler) {
+		root.SetInlineCompletionHandler(handler)
+	}
+}
+
+// WithCodeActionHandler sets the handler for the `textDocument/codeAction` request.
+func WithCodeActionHandler(handler CodeActionHandlerFunc) HandlerOption {
+	return func(root *Handler) {
+		root.SetCodeActionHandler(handler)
+	}
+}
+
+// WithCodeActionResolveHandler sets the handler for the `codeAction/resolve` request.
+func WithCodeActionResolveHandler(handler CodeActionResolveHandlerFunc) HandlerOption {
+	return func(root *Handler) {
+		root.SetCodeActionResolveHandler(handler)
+	}
+}
+
+// WithWorkspaceTextDocumentContentHandler sets the handler for the
+// `workspace/textDocumentContent` request.
+func WithWorkspaceTextDocumentContentHandler(handler WorkspaceTextDocumentContentHandlerFunc) HandlerOption {
+	return func(root *Handler) {
+		root.SetWorkspaceTextDocumentContentHandler(handler)
+	}
+}
+
+// NewHandler creates a new instance of a LSP 3.18 handler, optionally,
+// with a provided set of method handlers.
+func NewHandler(opts ...HandlerOption) *Handler {
+	h := &Handler{
+		Handler: lsp317.NewHandler(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// SetInitializeHandler sets the handler for the `initialize` request,
+// this replaces the LSP 3.17 `initialize` handler of the embedded handler.
+func (h *Handler) SetInitializeHandler(handler InitializeHandlerFunc) {
+	h.mu.Lock()
+	h.initialize = handler
+	h.mu.Unlock()
+	if handler == nil {
+		h.Handler.SetInitializeHandler(nil)
+		return
+	}
+	h.Handler.SetInitializeHandler(h.handleInitialize)
+}
+
+// SetInlineCompletionHandler sets the handler for the
+// `textDocument/inlineCompletion` request.
+func (h *Handler) SetInlineCompletionHandler(handler InlineCompletionHandlerFunc) {
+	h.mu.Lock()
+	h.inlineCompletion = handler
+	h.mu.Unlock()
+	h.Handler.SetMessageHandler(MethodInlineCompletion, createInlineCompletionHandler(h))
+}
+
+// SetCodeActionHandler sets the handler for the `textDocument/codeAction` request,
+// this replaces the LSP 3.17 `textDocument/codeAction` handler of the embedded handler.
+func (h *Handler) SetCodeActionHandler(handler CodeActionHandlerFunc) {
+	h.mu.Lock()
+	h.codeAction = handler
+	h.mu.Unlock()
+	h.Handler.SetMessageHandler(lsp317.MethodCodeAction, createCodeActionHandler(h))
+}
+
+// SetCodeActionResolveHandler sets the handler for the `codeAction/resolve` request,
+// this replaces the LSP 3.17 `codeAction/resolve` handler of the embedded handler.
+func (h *Handler) SetCodeActionResolveHandler(handler CodeActionResolveHandlerFunc) {
+	h.mu.Lock()
+	h.codeActionResolve = handler
+	h.mu.Unlock()
+	h.Handler.SetMessageHandler(lsp317.MethodCodeActionResolve, createCodeActionResolveHandler(h))
+}
+
+// SetWorkspaceTextDocumentContentHandler sets the handler for the
+// `workspace/textDocumentContent` request.
+func (h *Handler) SetWorkspaceTextDocumentContentHandler(handler WorkspaceTextDocumentContentHandlerFunc) {
+	h.mu.Lock()
+	h.workspaceTextDocumentContent = handler
+	h.mu.Unlock()
+	h.Handler.SetMessageHandler(
+		MethodWorkspaceTextDocumentContent,
+		createWorkspaceTextDocumentContentHandler(h),
+	)
+}
+
+// Fulfils the common.Handler interface.
+//
+// Messages are handled by the embedded LSP 3.17 handler according to the
+// lifecycle of the connection, the LSP context passed to message handlers
+// carries the LSP 3.18 client state along with the LSP 3.17 client state.
+func (h *Handler) Handle(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+	if ctx.Method == lsp317.MethodInitialize &&
+		h.LifecycleState() == lsp317.LifecycleStateUninitialized {
+		h.captureClientState(ctx)
+	}
+
+	withClientState(ctx, h.ClientState())
+	return h.Handler.Handle(ctx)
+}
+
+func (h *Handler) captureClientState(ctx *common.LSPContext) {
+	var params InitializeParams
+	// Invalid parameters are reported by the `initialize` handler
+	// of the embedded handler.
+	if err := json.Unmarshal(ctx.Params, &params); err == nil {
+		h.setClientState(NewClientState(&params))
+	}
+}
+
+func (h *Handler) handleInitialize(ctx *common.LSPContext, _ *lsp317.InitializeParams) (any, error) {
+	h.mu.Lock()
+	initialize := h.initialize
+	h.mu.Unlock()
+
+	var params InitializeParams
+	if err := json.Unmarshal(ctx.Params, &params); err != nil {
+		return nil, err
+	}
+
+	result, err := initialize(ctx, &params)
+	if initResult, isInitResult := result.(InitializeResult); isInitResult {
+		// Pass a pointer to the result so the embedded handler
+		// can apply the negotiated position encoding.
+		return &initResult, err
+	}
+	return result, err
+}
+
+func createInlineCompletionHandler(root *Handler) common.Handler {
+	return common.HandlerFunc(
+		func(
+			ctx *common.LSPContext,
+		) (r any, validMethod bool, validParams bool, err error) {
+			root.mu.Lock()
+			inlineCompletion := root.inlineCompletion
+			root.mu.Unlock()
+
+			if inlineCompletion != nil {
+				validMethod = true
+				var params InlineCompletionParams
+				if err = json.Unmarshal(ctx.Params, &params); err == nil {
+					validParams = true
+					r, err = inlineCompletion(ctx, &params)
+				}
+			}
+			return
+		},
+	)
+}
+
+func createCodeActionHandler(root *Handler) common.Handler {
+	return common.HandlerFunc(
+		func(
+			ctx *common.LSPContext,
+		) (r any, validMethod bool, validParams bool, err error) {
+			root.mu.Lock()
+			codeAction := root.codeAction
+			root.mu.Unlock()
+
+			if codeAction != nil {
+				validMethod = true
+				var params lsp317.CodeActionParams
+				if err = json.Unmarshal(ctx.Params, &params); err == nil {
+					validParams = true
+					r, err = codeAction(ctx, &params)
+				}
+			}
+			return
+		},
+	)
+}
+
+func createCodeActionResolveHandler(root *Handler) common.Handler {
+	return common.HandlerFunc(
+		func(
+			ctx *common.LSPContext,
+		) (r any, validMethod bool, validParams bool, err error) {
+			root.mu.Lock()
+			codeActionResolve := root.codeActionResolve
+			root.mu.Unlock()
+
+			if codeActionResolve != nil {
+				validMethod = true
+				var params CodeAction
+				if err = json.Unmarshal(ctx.Params, &params); err == nil {
+					validParams = true
+					r, err = codeActionResolve(ctx, &params)
+				}
+			}
+			return
+		},
+	)
+}
+
+func createWorkspaceTextDocumentContentHandler(root *Handler) common.Handler {
+	return common.HandlerFunc(
+		func(
+			ctx *common.LSPContext,
+		) (r any, validMethod bool, validParams bool, err error) {
+			root.mu.Lock()
+			workspaceTextDocumentContent := root.workspaceTextDocumentContent
+			root.mu.Unlock()
+
+			if workspaceTextDocumentContent != nil {
+				validMethod = true
+				var params TextDocumentContentParams
+				if err = json.Unmarshal(ctx.Params, &params); err == nil {
+					validParams = true
+					r, err = workspaceTextDocumentContent(ctx, &params)
+				}
+			}
+			return
+		},
+	)
+}
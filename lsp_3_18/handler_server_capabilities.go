@@ -0,0 +1,55 @@
+package lsp
+
+import (
+	lsp317 "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+// CreateServerCapabilities creates a server capabilities object
+// to be sent to the client during initialization.
+// This derives a base set of capabilities from the configured handlers
+// of both the embedded LSP 3.17 handler and this handler that can be
+// modified before being sent to the client.
+// All handlers that are not dynamically registered must be set
+// before calling this method.
+//
+// For the `workspace/textDocumentContent` request, the server capabilities
+// need to be set with the URI schemes the server provides content for.
+func (h *Handler) CreateServerCapabilities() ServerCapabilities {
+	capabilities := ServerCapabilities{
+		ServerCapabilities: h.Handler.CreateServerCapabilities(),
+	}
+	if capabilities.ServerCapabilities.Workspace != nil {
+		capabilities.Workspace = &ServerWorkspaceCapabilities{
+			ServerWorkspaceCapabilities: *capabilities.ServerCapabilities.Workspace,
+		}
+		capabilities.ServerCapabilities.Workspace = nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.inlineCompletion != nil {
+		capabilities.InlineCompletionProvider = true
+	}
+
+	if h.codeAction != nil && !h.registersDynamically(lsp317.MethodCodeAction) {
+		capabilities.CodeActionProvider = true
+	}
+
+	if h.workspaceTextDocumentContent != nil {
+		if capabilities.Workspace == nil {
+			capabilities.Workspace = &ServerWorkspaceCapabilities{}
+		}
+		capabilities.Workspace.TextDocumentContent = &TextDocumentContentOptions{
+			Schemes: []string{},
+		}
+	}
+
+	return capabilities
+}
+
+func (h *Handler) registersDynamically(method string) bool {
+	registrationManager := h.RegistrationManager()
+	return registrationManager != nil &&
+		registrationManager.RegistersDynamically(method, h.Handler.ClientState())
+}
@@ -0,0 +1,342 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	lsp317 "github.com/two-hundred/ls-builder/lsp_3_17"
+	"github.com/two-hundred/ls-builder/server"
+	"go.uber.org/zap"
+)
+
+type HandlerTestSuite struct {
+	suite.Suite
+}
+
+func (s *HandlerTestSuite) Test_initialises_with_lsp_3_18_capabilities_and_reuses_lsp_3_17_handlers() {
+	logger, err := zap.NewDevelopment()
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	inlineCompletionClientState := make(chan *ClientState, 1)
+	var serverHandler *Handler
+	serverHandler = NewHandler(
+		WithBaseOptions(
+			lsp317.WithHoverHandler(
+				func(ctx *common.LSPContext, params *lsp317.HoverParams) (*lsp317.Hover, error) {
+					return &lsp317.Hover{Contents: lsp317.MarkupContent{Kind: lsp317.MarkupKindPlainText, Value: "hover"}}, nil
+				},
+			),
+			lsp317.WithPositionEncodingPreferences(lsp317.PositionEncodingKindUTF8),
+		),
+		WithInitializeHandler(
+			func(ctx *common.LSPContext, params *InitializeParams) (any, error) {
+				s.Require().True(ClientStateFromContext(ctx).SupportsSnippetTextEdits())
+				return InitializeResult{Capabilities: serverHandler.CreateServerCapabilities()}, nil
+			},
+		),
+		WithInlineCompletionHandler(
+			func(ctx *common.LSPContext, params *InlineCompletionParams) (any, error) {
+				inlineCompletionClientState <- ClientStateFromContext(ctx)
+				return InlineCompletionList{
+					Items: []InlineCompletionItem{
+						{InsertText: "fmt.Println()"},
+						{InsertText: NewSnippetStringValue("fmt.Printf(\"$1\")")},
+					},
+				}, nil
+			},
+		),
+	)
+	srv := server.NewServer(serverHandler, true, nil, nil)
+
+	container := createTestConnectionsContainer(srv.NewHandler())
+
+	go srv.Serve(container.serverConn, logger)
+
+	clientLSPContext := server.NewLSPContext(ctx, container.clientConn, nil)
+
+	trueVal := true
+	inlineCompletionParams := InlineCompletionParams{
+		TextDocumentPositionParams: lsp317.TextDocumentPositionParams{
+			TextDocument: lsp317.TextDocumentIdentifier{URI: "file:///test.go"},
+		},
+		Context: InlineCompletionContext{TriggerKind: InlineCompletionTriggerKindInvoked},
+	}
+
+	// Requests for LSP 3.18 methods are subject to the lifecycle of the connection.
+	err = clientLSPContext.Call(MethodInlineCompletion, inlineCompletionParams, nil)
+	jsonrpcErr := &jsonrpc2.Error{}
+	s.Require().ErrorAs(err, &jsonrpcErr)
+	s.Require().Equal(lsp317.CodeServerNotInitialized, jsonrpcErr.Code)
+
+	initializeParams := InitializeParams{
+		Capabilities: ClientCapabilities{
+			ClientCapabilities: lsp317.ClientCapabilities{
+				General: &lsp317.GeneralClientCapabilities{
+					PositionEncodings: []lsp317.PositionEncodingKind{lsp317.PositionEncodingKindUTF8},
+				},
+			},
+			Workspace: &ClientWorkspaceCapabilities{
+				WorkspaceEdit: &WorkspaceEditClientCapabilities{SnippetEditSupport: &trueVal},
+			},
+			TextDocument: &TextDocumentClientCapabilities{
+				InlineCompletion: &InlineCompletionClientCapabilities{},
+			},
+		},
+	}
+
+	initializeResult := InitializeResult{}
+	err = clientLSPContext.Call(lsp317.MethodInitialize, initializeParams, &initializeResult)
+	s.Require().NoError(err)
+	s.Require().Equal(lsp317.PositionEncodingKindUTF8, initializeResult.Capabilities.PositionEncoding)
+	s.Require().Equal(true, initializeResult.Capabilities.InlineCompletionProvider)
+	s.Require().Equal(true, initializeResult.Capabilities.HoverProvider)
+	s.Require().Equal(lsp317.PositionEncodingKindUTF8, serverHandler.PositionEncodingKind())
+
+	err = clientLSPContext.Notify(lsp317.MethodInitialized, lsp317.InitializedParams{})
+	s.Require().NoError(err)
+
+	hover := lsp317.Hover{}
+	err = clientLSPContext.Call(lsp317.MethodHover, lsp317.HoverParams{}, &hover)
+	s.Require().NoError(err)
+	s.Require().Equal(lsp317.MarkupContent{Kind: lsp317.MarkupKindPlainText, Value: "hover"}, hover.Contents)
+
+	inlineCompletionList := InlineCompletionList{}
+	err = clientLSPContext.Call(MethodInlineCompletion, inlineCompletionParams, &inlineCompletionList)
+	s.Require().NoError(err)
+	s.Require().Equal(
+		[]InlineCompletionItem{
+			{InsertText: "fmt.Println()"},
+			{InsertText: StringValue{Kind: StringValueKindSnippet, Value: "fmt.Printf(\"$1\")"}},
+		},
+		inlineCompletionList.Items,
+	)
+
+	clientState := <-inlineCompletionClientState
+	s.Require().Same(serverHandler.ClientState(), clientState)
+	s.Require().True(clientState.SupportsSnippetTextEdits())
+	s.Require().Equal(
+		lsp317.PositionEncodingKindUTF8,
+		clientState.Capabilities().General.PositionEncodings[0],
+	)
+}
+
+func (s *HandlerTestSuite) Test_calls_code_action_handler_with_lsp_3_18_properties() {
+	logger, err := zap.NewDevelopment()
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	codeActions := []*CodeActionOrCommand{
+		{
+			CodeAction: &CodeAction{
+				CodeAction: lsp317.CodeAction{
+					Title: "Generated fix",
+					Edit: &lsp317.WorkspaceEdit{
+						DocumentChanges: []any{
+							lsp317.TextDocumentEdit{
+								TextDocument: lsp317.OptionalVersionedTextDocumentIdentifier{
+									TextDocumentIdentifier: lsp317.TextDocumentIdentifier{URI: "file:///test.go"},
+								},
+								Edits: []any{
+									SnippetTextEdit{Snippet: NewSnippetStringValue("${1:name}")},
+								},
+							},
+						},
+					},
+				},
+				Tags: []CodeActionTag{CodeActionTagLLMGenerated},
+			},
+		},
+	}
+	serverHandler := NewHandler(
+		// The LSP 3.18 handler takes precedence over the LSP 3.17 handler
+		// for the same method.
+		WithBaseOptions(
+			lsp317.WithCodeActionHandler(
+				func(ctx *common.LSPContext, params *lsp317.CodeActionParams) ([]*lsp317.CodeActionOrCommand, error) {
+					return nil, nil
+				},
+			),
+		),
+		WithCodeActionHandler(
+			func(ctx *common.LSPContext, params *lsp317.CodeActionParams) ([]*CodeActionOrCommand, error) {
+				return codeActions, nil
+			},
+		),
+		WithCodeActionResolveHandler(
+			func(ctx *common.LSPContext, params *CodeAction) (*CodeAction, error) {
+				params.Tags = append(params.Tags, CodeActionTagLLMGenerated)
+				return params, nil
+			},
+		),
+	)
+	// Emulate the LSP initialisation process.
+	serverHandler.SetInitialized(true)
+	srv := server.NewServer(serverHandler, true, nil, nil)
+
+	container := createTestConnectionsContainer(srv.NewHandler())
+
+	go srv.Serve(container.serverConn, logger)
+
+	clientLSPContext := server.NewLSPContext(ctx, container.clientConn, nil)
+
+	returnedCodeActions := []map[string]any{}
+	err = clientLSPContext.Call(lsp317.MethodCodeAction, lsp317.CodeActionParams{}, &returnedCodeActions)
+	s.Require().NoError(err)
+	s.Require().Equal(
+		[]map[string]any{
+			{
+				"title": "Generated fix",
+				"edit": map[string]any{
+					"documentChanges": []any{
+						map[string]any{
+							"textDocument": map[string]any{"uri": "file:///test.go"},
+							"edits": []any{
+								map[string]any{
+									"range": map[string]any{
+										"start": map[string]any{"line": float64(0), "character": float64(0)},
+										"end":   map[string]any{"line": float64(0), "character": float64(0)},
+									},
+									"snippet": map[string]any{"kind": "snippet", "value": "${1:name}"},
+								},
+							},
+						},
+					},
+				},
+				"tags": []any{float64(1)},
+			},
+		},
+		returnedCodeActions,
+	)
+
+	resolvedCodeAction := CodeAction{}
+	err = clientLSPContext.Call(
+		lsp317.MethodCodeActionResolve,
+		CodeAction{CodeAction: lsp317.CodeAction{Title: "Generated fix"}},
+		&resolvedCodeAction,
+	)
+	s.Require().NoError(err)
+	s.Require().Equal("Generated fix", resolvedCodeAction.Title)
+	s.Require().Equal([]CodeActionTag{CodeActionTagLLMGenerated}, resolvedCodeAction.Tags)
+}
+
+func (s *HandlerTestSuite) Test_calls_workspace_text_document_content_request_handler() {
+	logger, err := zap.NewDevelopment()
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.DefaultTimeout)
+	defer cancel()
+
+	serverHandler := NewHandler(
+		WithWorkspaceTextDocumentContentHandler(
+			func(ctx *common.LSPContext, params *TextDocumentContentParams) (*TextDocumentContentResult, error) {
+				return &TextDocumentContentResult{Text: "content of " + params.URI}, nil
+			},
+		),
+	)
+	// Emulate the LSP initialisation process.
+	serverHandler.SetInitialized(true)
+	srv := server.NewServer(serverHandler, true, nil, nil)
+
+	container := createTestConnectionsContainer(srv.NewHandler())
+
+	go srv.Serve(container.serverConn, logger)
+
+	clientLSPContext := server.NewLSPContext(ctx, container.clientConn, nil)
+
+	result := TextDocumentContentResult{}
+	err = clientLSPContext.Call(
+		MethodWorkspaceTextDocumentContent,
+		TextDocumentContentParams{URI: "virtual:///schema.json"},
+		&result,
+	)
+	s.Require().NoError(err)
+	s.Require().Equal("content of virtual:///schema.json", result.Text)
+}
+
+func (s *HandlerTestSuite) Test_creates_server_capabilities_for_lsp_3_18_handlers() {
+	serverHandler := NewHandler(
+		WithBaseOptions(
+			lsp317.WithWorkspaceDidCreateFilesHandler(
+				func(ctx *common.LSPContext, params *lsp317.CreateFilesParams) error {
+					return nil
+				},
+			),
+		),
+		WithInlineCompletionHandler(
+			func(ctx *common.LSPContext, params *InlineCompletionParams) (any, error) {
+				return nil, nil
+			},
+		),
+		WithCodeActionHandler(
+			func(ctx *common.LSPContext, params *lsp317.CodeActionParams) ([]*CodeActionOrCommand, error) {
+				return nil, nil
+			},
+		),
+		WithWorkspaceTextDocumentContentHandler(
+			func(ctx *common.LSPContext, params *TextDocumentContentParams) (*TextDocumentContentResult, error) {
+				return nil, nil
+			},
+		),
+	)
+
+	capabilities := serverHandler.CreateServerCapabilities()
+	s.Require().Equal(true, capabilities.InlineCompletionProvider)
+	s.Require().Equal(true, capabilities.CodeActionProvider)
+	s.Require().Nil(capabilities.ServerCapabilities.Workspace)
+	s.Require().NotNil(capabilities.Workspace.FileOperations.DidCreate)
+	s.Require().Equal(
+		&TextDocumentContentOptions{Schemes: []string{}},
+		capabilities.Workspace.TextDocumentContent,
+	)
+}
+
+func (s *HandlerTestSuite) Test_leaves_dynamically_registered_code_actions_out_of_server_capabilities() {
+	serverHandler := NewHandler(
+		WithBaseOptions(
+			lsp317.WithRegistrationManager(
+				lsp317.NewRegistrationManager(
+					lsp317.WithDynamicRegistration(lsp317.MethodCodeAction),
+				),
+			),
+		),
+		WithCodeActionHandler(
+			func(ctx *common.LSPContext, params *lsp317.CodeActionParams) ([]*CodeActionOrCommand, error) {
+				return nil, nil
+			},
+		),
+	)
+	var capabilities *ServerCapabilities
+	serverHandler.SetInitializeHandler(
+		func(ctx *common.LSPContext, params *InitializeParams) (any, error) {
+			initializeCapabilities := serverHandler.CreateServerCapabilities()
+			capabilities = &initializeCapabilities
+			return nil, nil
+		},
+	)
+
+	// The capability is declared statically until the client's support
+	// for dynamic registration is known.
+	s.Require().Equal(true, serverHandler.CreateServerCapabilities().CodeActionProvider)
+
+	_, _, _, err := serverHandler.Handle(&common.LSPContext{
+		Method:  lsp317.MethodInitialize,
+		Params:  json.RawMessage(`{"capabilities":{"textDocument":{"codeAction":{"dynamicRegistration":true}}}}`),
+		Context: context.Background(),
+	})
+	s.Require().NoError(err)
+	s.Require().NotNil(capabilities)
+	s.Require().Nil(capabilities.CodeActionProvider)
+}
+
+func TestHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(HandlerTestSuite))
+}
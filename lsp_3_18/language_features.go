@@ -0,0 +1,340 @@
+package lsp
+
+import (
+	"encoding/json"
+
+	"github.com/two-hundred/ls-builder/common"
+	lsp317 "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_inlineCompletion
+
+const MethodInlineCompletion = lsp317.Method("textDocument/inlineCompletion")
+
+// InlineCompletionHandlerFunc is the function signature for the textDocument/inlineCompletion
+// request handler that can be registered for a language server.
+//
+// Returns: InlineCompletionList | []InlineCompletionItem | nil
+type InlineCompletionHandlerFunc func(
+	ctx *common.LSPContext,
+	params *InlineCompletionParams,
+) (any, error)
+
+// InlineCompletionClientCapabilities describes the capabilities of a client
+// for inline completion requests.
+//
+// @since 3.18.0
+type InlineCompletionClientCapabilities struct {
+	// Whether implementation supports dynamic registration for inline
+	// completion providers.
+	DynamicRegistration *bool `json:"dynamicRegistration,omitempty"`
+}
+
+// InlineCompletionOptions provides server capability options
+// for inline completion requests.
+//
+// @since 3.18.0
+type InlineCompletionOptions struct {
+	lsp317.WorkDoneProgressOptions
+}
+
+// InlineCompletionRegistrationOptions provides registration options
+// for inline completion requests.
+//
+// @since 3.18.0
+type InlineCompletionRegistrationOptions struct {
+	InlineCompletionOptions
+	lsp317.TextDocumentRegistrationOptions
+	lsp317.StaticRegistrationOptions
+}
+
+// InlineCompletionParams contains the parameters for the
+// textDocument/inlineCompletion request.
+//
+// @since 3.18.0
+type InlineCompletionParams struct {
+	lsp317.TextDocumentPositionParams
+	lsp317.WorkDoneProgressParams
+
+	// Additional information about the context in which inline completions
+	// were requested.
+	Context InlineCompletionContext `json:"context"`
+}
+
+// InlineCompletionContext provides information about the context
+// in which an inline completion was requested.
+//
+// @since 3.18.0
+type InlineCompletionContext struct {
+	// Describes how the inline completion was triggered.
+	TriggerKind InlineCompletionTriggerKind `json:"triggerKind"`
+
+	// Provides information about the currently selected item in the
+	// autocomplete widget if it is visible.
+	//
+	// If set, provided inline completions must extend the text of the
+	// selected item and use the same range, otherwise they are not shown as
+	// preview.
+	// As an example, if the document text is `console.` and the selected item
+	// is `.log` replacing the `.` in the document, the inline completion must
+	// also replace `.` and start with `.log`, for example `.log()`.
+	//
+	// Inline completion providers are requested again whenever the selected
+	// item changes.
+	SelectedCompletionInfo *SelectedCompletionInfo `json:"selectedCompletionInfo,omitempty"`
+}
+
+// InlineCompletionTriggerKind describes how an inline completion
+// provider was triggered.
+//
+// @since 3.18.0
+type InlineCompletionTriggerKind = lsp317.Integer
+
+const (
+	// InlineCompletionTriggerKindInvoked is for when completion was triggered
+	// explicitly by a user gesture.
+	// Return multiple completion items to enable cycling through them.
+	InlineCompletionTriggerKindInvoked InlineCompletionTriggerKind = 1
+
+	// InlineCompletionTriggerKindAutomatic is for when completion was triggered
+	// automatically while editing.
+	// It is sufficient to return a single completion item in this case.
+	InlineCompletionTriggerKindAutomatic InlineCompletionTriggerKind = 2
+)
+
+// SelectedCompletionInfo describes the currently selected completion item.
+//
+// @since 3.18.0
+type SelectedCompletionInfo struct {
+	// The range that will be replaced if this completion item is accepted.
+	Range lsp317.Range `json:"range"`
+
+	// The text the range will be replaced with if this completion is
+	// accepted.
+	Text string `json:"text"`
+}
+
+// InlineCompletionList represents a collection of inline completion
+// items to be presented in the editor.
+//
+// @since 3.18.0
+type InlineCompletionList struct {
+	// The inline completion items.
+	Items []InlineCompletionItem `json:"items"`
+}
+
+// InlineCompletionItem is an inline completion item that represents a text
+// snippet that is proposed inline to complete text that is being typed.
+//
+// @since 3.18.0
+type InlineCompletionItem struct {
+	// The text to replace the range with. Must be set.
+	// Is used both for the preview and the accept operation.
+	//
+	// string | StringValue
+	InsertText any `json:"insertText"`
+
+	// A text that is used to decide if this inline completion should be
+	// shown. When `falsy` the insertText is used.
+	//
+	// An inline completion is shown if the text to replace is a prefix of the
+	// filter text.
+	FilterText *string `json:"filterText,omitempty"`
+
+	// The range to replace.
+	// Must begin and end on the same line.
+	//
+	// Prefer replacements over insertions to provide a better experience when
+	// the user deletes typed text.
+	Range *lsp317.Range `json:"range,omitempty"`
+
+	// An optional command that is executed *after* inserting this
+	// completion.
+	Command *lsp317.Command `json:"command,omitempty"`
+}
+
+type inlineCompletionItemIntermediate struct {
+	InsertText json.RawMessage `json:"insertText"`
+	FilterText *string         `json:"filterText,omitempty"`
+	Range      *lsp317.Range   `json:"range,omitempty"`
+	Command    *lsp317.Command `json:"command,omitempty"`
+}
+
+// Fulfils the json.Unmarshaler interface.
+func (i *InlineCompletionItem) UnmarshalJSON(data []byte) error {
+	var intermediate inlineCompletionItemIntermediate
+	if err := json.Unmarshal(data, &intermediate); err != nil {
+		return err
+	}
+
+	i.FilterText = intermediate.FilterText
+	i.Range = intermediate.Range
+	i.Command = intermediate.Command
+
+	var strVal string
+	if err := json.Unmarshal(intermediate.InsertText, &strVal); err == nil {
+		i.InsertText = strVal
+		return nil
+	}
+
+	var stringValue StringValue
+	if err := json.Unmarshal(intermediate.InsertText, &stringValue); err != nil {
+		return err
+	}
+	i.InsertText = stringValue
+	return nil
+}
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_codeAction
+
+// CodeActionHandlerFunc is the function signature for the textDocument/codeAction
+// request handler that can be registered for a language server
+// that returns code actions with LSP 3.18 properties.
+type CodeActionHandlerFunc func(
+	ctx *common.LSPContext,
+	params *lsp317.CodeActionParams,
+) ([]*CodeActionOrCommand, error)
+
+// CodeActionResolveHandlerFunc is the function signature for the codeAction/resolve
+// request handler that can be registered for a language server
+// that resolves code actions with LSP 3.18 properties.
+type CodeActionResolveHandlerFunc func(
+	ctx *common.LSPContext,
+	params *CodeAction,
+) (*CodeAction, error)
+
+// CodeActionClientCapabilities describes the capabilities of a client
+// for code action requests.
+type CodeActionClientCapabilities struct {
+	lsp317.CodeActionClientCapabilities
+
+	// Whether the client supports documentation for a class of
+	// code actions.
+	//
+	// @since 3.18.0
+	DocumentationSupport *bool `json:"documentationSupport,omitempty"`
+
+	// Client supports the tag property on a code action. Clients
+	// supporting tags have to handle unknown tags gracefully.
+	//
+	// @since 3.18.0
+	TagSupport *CodeActionTagOptions `json:"tagSupport,omitempty"`
+}
+
+// CodeActionTagOptions describes the code action tags
+// that a client supports.
+//
+// @since 3.18.0
+type CodeActionTagOptions struct {
+	// The tags supported by the client.
+	ValueSet []CodeActionTag `json:"valueSet"`
+}
+
+// CodeActionOptions provides server capability options for code action requests.
+type CodeActionOptions struct {
+	lsp317.CodeActionOptions
+
+	// Static documentation for a class of code actions.
+	//
+	// Documentation from the provider should be shown in the code actions
+	// menu if either:
+	//
+	// - Code actions of `kind` are requested by the editor. In this case,
+	//   the editor will show the documentation that most closely matches the
+	//   requested code action kind. For example, if a provider has
+	//   documentation for both `Refactor` and `RefactorExtract`, when the
+	//   user requests code actions for `RefactorExtract`, the editor will use
+	//   the documentation for `RefactorExtract` instead of the documentation
+	//   for `Refactor`.
+	//
+	// - Any code actions of `kind` are returned by the provider.
+	//
+	// At most one documentation entry should be shown per provider.
+	//
+	// @since 3.18.0
+	Documentation []CodeActionKindDocumentation `json:"documentation,omitempty"`
+}
+
+// CodeActionKindDocumentation provides documentation for a class of code actions.
+//
+// @since 3.18.0
+type CodeActionKindDocumentation struct {
+	// The kind of the code action being documented.
+	//
+	// If the kind is generic, such as `CodeActionKind.Refactor`, the
+	// documentation will be shown whenever any refactorings are returned. If
+	// the kind if more specific, such as `CodeActionKind.RefactorExtract`, the
+	// documentation will only be shown when extract refactoring code actions
+	// are returned.
+	Kind lsp317.CodeActionKind `json:"kind"`
+
+	// Command that is used to display the documentation to the user.
+	//
+	// The title of this documentation code action is taken
+	// from `Command.title`
+	Command lsp317.Command `json:"command"`
+}
+
+// CodeActionTag is a set of predefined tags that can be used
+// to provide additional information about a code action.
+//
+// @since 3.18.0
+type CodeActionTag = lsp317.UInteger
+
+const (
+	// CodeActionTagLLMGenerated marks the code action as LLM-generated.
+	CodeActionTagLLMGenerated CodeActionTag = 1
+)
+
+// CodeActionOrCommand represents a union type of a code action
+// or command.
+type CodeActionOrCommand struct {
+	CodeAction *CodeAction     `json:"codeAction,omitempty"`
+	Command    *lsp317.Command `json:"command,omitempty"`
+}
+
+// Fulfils the json.Marshaler interface.
+func (c *CodeActionOrCommand) MarshalJSON() ([]byte, error) {
+	if c.CodeAction != nil {
+		return json.Marshal(c.CodeAction)
+	}
+	return json.Marshal(c.Command)
+}
+
+// Fulfils the json.Unmarshaler interface.
+func (c *CodeActionOrCommand) UnmarshalJSON(data []byte) error {
+	var cmdVal lsp317.Command
+	if err := json.Unmarshal(data, &cmdVal); err == nil && cmdVal.Command != "" {
+		c.Command = &cmdVal
+		return nil
+	}
+
+	var actionVal CodeAction
+	err := json.Unmarshal(data, &actionVal)
+	if err == nil && actionVal.Title != "" {
+		c.CodeAction = &actionVal
+		return nil
+	}
+
+	if err == nil && actionVal.Title == "" {
+		return lsp317.ErrInvalidCodeActionOrCommand
+	}
+
+	return err
+}
+
+// CodeAction represents a change that can be performed in code, e.g. to fix
+// a problem or to refactor code.
+// Properties that are unchanged from LSP 3.17 are set on the embedded
+// LSP 3.17 code action.
+//
+// The workspace edit of a code action can contain `SnippetTextEdit`s
+// in the edits of a `TextDocumentEdit` for clients that support them.
+type CodeAction struct {
+	lsp317.CodeAction
+
+	// Tags for this code action.
+	//
+	// @since 3.18.0
+	Tags []CodeActionTag `json:"tags,omitempty"`
+}
@@ -0,0 +1,261 @@
+package lsp
+
+import (
+	"encoding/json"
+
+	"github.com/two-hundred/ls-builder/common"
+	lsp317 "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#initialize
+
+// InitializeHandlerFunc is the function signature for the initialize request
+// handler that can be registered for a LSP 3.18 language server.
+type InitializeHandlerFunc func(ctx *common.LSPContext, params *InitializeParams) (any, error)
+
+// InitializeParams contains the initialize request parameters
+// with the client capabilities defined in LSP 3.18.
+type InitializeParams struct {
+	// The parameters for the initialize request that are unchanged from LSP 3.17,
+	// the capabilities of the embedded parameters hold the LSP 3.17 view
+	// of the client capabilities.
+	lsp317.InitializeParams
+
+	// The capabilities provided by the client (editor or tool)
+	Capabilities ClientCapabilities `json:"capabilities"`
+}
+
+// Fulfils the json.Unmarshaler interface.
+func (p *InitializeParams) UnmarshalJSON(data []byte) error {
+	var replacements struct {
+		Capabilities ClientCapabilities `json:"capabilities"`
+	}
+	if err := unmarshalExtended(data, &p.InitializeParams, &replacements); err != nil {
+		return err
+	}
+
+	p.Capabilities = replacements.Capabilities
+	return nil
+}
+
+// ClientCapabilities represents the capabilities of the client (editor or tool).
+// Capabilities that are unchanged from LSP 3.17 can be accessed through
+// the embedded LSP 3.17 client capabilities.
+type ClientCapabilities struct {
+	lsp317.ClientCapabilities
+
+	// Workspace specific client capabilities.
+	Workspace *ClientWorkspaceCapabilities `json:"workspace,omitempty"`
+
+	// Text document specific client capabilities.
+	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+}
+
+// Fulfils the json.Unmarshaler interface.
+func (c *ClientCapabilities) UnmarshalJSON(data []byte) error {
+	var replacements struct {
+		Workspace    *ClientWorkspaceCapabilities    `json:"workspace"`
+		TextDocument *TextDocumentClientCapabilities `json:"textDocument"`
+	}
+	if err := unmarshalExtended(data, &c.ClientCapabilities, &replacements); err != nil {
+		return err
+	}
+
+	c.Workspace = replacements.Workspace
+	c.TextDocument = replacements.TextDocument
+	return nil
+}
+
+// ClientWorkspaceCapabilities represents the capabilities of the client
+// related to workspaces.
+type ClientWorkspaceCapabilities struct {
+	lsp317.ClientWorkspaceCapabilities
+
+	// Capabilities specific to `WorkspaceEdit`s
+	WorkspaceEdit *WorkspaceEditClientCapabilities `json:"workspaceEdit,omitempty"`
+
+	// Client workspace capabilities specific to folding ranges.
+	//
+	// @since 3.18.0
+	FoldingRange *FoldingRangeWorkspaceClientCapabilities `json:"foldingRange,omitempty"`
+
+	// Capabilities specific to the `workspace/textDocumentContent` request.
+	//
+	// @since 3.18.0
+	TextDocumentContent *TextDocumentContentClientCapabilities `json:"textDocumentContent,omitempty"`
+}
+
+// Fulfils the json.Unmarshaler interface.
+func (c *ClientWorkspaceCapabilities) UnmarshalJSON(data []byte) error {
+	var replacements struct {
+		WorkspaceEdit       *WorkspaceEditClientCapabilities         `json:"workspaceEdit"`
+		FoldingRange        *FoldingRangeWorkspaceClientCapabilities `json:"foldingRange"`
+		TextDocumentContent *TextDocumentContentClientCapabilities   `json:"textDocumentContent"`
+	}
+	if err := unmarshalExtended(data, &c.ClientWorkspaceCapabilities, &replacements); err != nil {
+		return err
+	}
+
+	c.WorkspaceEdit = replacements.WorkspaceEdit
+	c.FoldingRange = replacements.FoldingRange
+	c.TextDocumentContent = replacements.TextDocumentContent
+	return nil
+}
+
+// TextDocumentClientCapabilities represents the capabilities of the client
+// related to text documents.
+type TextDocumentClientCapabilities struct {
+	lsp317.TextDocumentClientCapabilities
+
+	// Capabilities specific to the `textDocument/codeAction` request.
+	CodeAction *CodeActionClientCapabilities `json:"codeAction,omitempty"`
+
+	// Client capabilities specific to inline completions.
+	//
+	// @since 3.18.0
+	InlineCompletion *InlineCompletionClientCapabilities `json:"inlineCompletion,omitempty"`
+}
+
+// Fulfils the json.Unmarshaler interface.
+func (c *TextDocumentClientCapabilities) UnmarshalJSON(data []byte) error {
+	var replacements struct {
+		CodeAction       *CodeActionClientCapabilities       `json:"codeAction"`
+		InlineCompletion *InlineCompletionClientCapabilities `json:"inlineCompletion"`
+	}
+	if err := unmarshalExtended(data, &c.TextDocumentClientCapabilities, &replacements); err != nil {
+		return err
+	}
+
+	c.CodeAction = replacements.CodeAction
+	c.InlineCompletion = replacements.InlineCompletion
+	return nil
+}
+
+// InitializeResult contains the result of the initialize request
+// with the server capabilities defined in LSP 3.18.
+type InitializeResult struct {
+	// The capabilities the language server provides.
+	Capabilities ServerCapabilities `json:"capabilities"`
+
+	// Information about the server.
+	//
+	// @since 3.15.0
+	ServerInfo *lsp317.InitializeResultServerInfo `json:"serverInfo,omitempty"`
+}
+
+// ApplyPositionEncoding sets the position encoding negotiated with the client
+// in the server capabilities unless the server has picked one,
+// UTF-16 is used for a nil result as the client will not see the negotiated encoding.
+// Fulfils the lsp317.PositionEncodingResult interface.
+func (r *InitializeResult) ApplyPositionEncoding(
+	negotiated lsp317.PositionEncodingKind,
+) (any, lsp317.PositionEncodingKind) {
+	if r == nil {
+		return r, lsp317.PositionEncodingKindUTF16
+	}
+
+	if r.Capabilities.PositionEncoding == "" {
+		r.Capabilities.PositionEncoding = negotiated
+	}
+	return r, r.Capabilities.PositionEncoding
+}
+
+// ServerCapabilities represents the capabilities of the server
+// returned in the initialize result.
+// Capabilities that are unchanged from LSP 3.17 are set on
+// the embedded LSP 3.17 server capabilities.
+type ServerCapabilities struct {
+	lsp317.ServerCapabilities
+
+	// The server has support for inline completions.
+	//
+	// @since 3.18.0
+	// InlineCompletionOptions | boolean | nil
+	InlineCompletionProvider any `json:"inlineCompletionProvider,omitempty"`
+
+	// Workspace specific server capabilities, this replaces the
+	// `Workspace` field of the embedded LSP 3.17 server capabilities.
+	Workspace *ServerWorkspaceCapabilities `json:"workspace,omitempty"`
+}
+
+// Fulfils the json.Unmarshaler interface.
+func (s *ServerCapabilities) UnmarshalJSON(data []byte) error {
+	var replacements struct {
+		CodeActionProvider       json.RawMessage              `json:"codeActionProvider"`
+		InlineCompletionProvider json.RawMessage              `json:"inlineCompletionProvider"`
+		Workspace                *ServerWorkspaceCapabilities `json:"workspace"`
+	}
+	if err := unmarshalExtended(data, &s.ServerCapabilities, &replacements); err != nil {
+		return err
+	}
+
+	s.Workspace = replacements.Workspace
+
+	if replacements.CodeActionProvider != nil {
+		codeActionProvider, err := unmarshalOptionsOrBool[CodeActionOptions](replacements.CodeActionProvider)
+		if err != nil {
+			return err
+		}
+		s.CodeActionProvider = codeActionProvider
+	}
+
+	if replacements.InlineCompletionProvider != nil {
+		inlineCompletionProvider, err := unmarshalOptionsOrBool[InlineCompletionOptions](
+			replacements.InlineCompletionProvider,
+		)
+		if err != nil {
+			return err
+		}
+		s.InlineCompletionProvider = inlineCompletionProvider
+	}
+
+	return nil
+}
+
+// ServerWorkspaceCapabilities represents the capabilities of the server
+// related to workspaces.
+type ServerWorkspaceCapabilities struct {
+	lsp317.ServerWorkspaceCapabilities
+
+	// The server supports the `workspace/textDocumentContent` request.
+	//
+	// @since 3.18.0
+	// TextDocumentContentOptions | TextDocumentContentRegistrationOptions | nil
+	TextDocumentContent any `json:"textDocumentContent,omitempty"`
+}
+
+// Fulfils the json.Unmarshaler interface.
+func (s *ServerWorkspaceCapabilities) UnmarshalJSON(data []byte) error {
+	var replacements struct {
+		TextDocumentContent *TextDocumentContentRegistrationOptions `json:"textDocumentContent"`
+	}
+	if err := unmarshalExtended(data, &s.ServerWorkspaceCapabilities, &replacements); err != nil {
+		return err
+	}
+
+	if replacements.TextDocumentContent == nil {
+		return nil
+	}
+
+	if replacements.TextDocumentContent.ID == nil {
+		s.TextDocumentContent = replacements.TextDocumentContent.TextDocumentContentOptions
+	} else {
+		s.TextDocumentContent = *replacements.TextDocumentContent
+	}
+	return nil
+}
+
+// unmarshals a server capability that is either
+// an options object or a boolean.
+func unmarshalOptionsOrBool[Options any](data json.RawMessage) (any, error) {
+	var optVal Options
+	if err := json.Unmarshal(data, &optVal); err == nil {
+		return optVal, nil
+	}
+
+	var boolVal bool
+	if err := json.Unmarshal(data, &boolVal); err != nil {
+		return nil, err
+	}
+	return boolVal, nil
+}
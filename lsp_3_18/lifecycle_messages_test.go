@@ -0,0 +1,166 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	lsp317 "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+type LifecycleMessagesTestSuite struct {
+	suite.Suite
+}
+
+func (s *LifecycleMessagesTestSuite) Test_unmarshals_client_capabilities_with_lsp_3_17_view() {
+	input := `{
+		"workspace": {
+			"applyEdit": true,
+			"workspaceEdit": {"documentChanges": true, "snippetEditSupport": true},
+			"foldingRange": {"refreshSupport": true},
+			"textDocumentContent": {"dynamicRegistration": true}
+		},
+		"textDocument": {
+			"hover": {"dynamicRegistration": true},
+			"codeAction": {
+				"dynamicRegistration": true,
+				"documentationSupport": true,
+				"tagSupport": {"valueSet": [1]}
+			},
+			"inlineCompletion": {"dynamicRegistration": true}
+		},
+		"window": {"workDoneProgress": true}
+	}`
+
+	capabilities := ClientCapabilities{}
+	err := json.Unmarshal([]byte(input), &capabilities)
+	s.Require().NoError(err)
+
+	trueVal := true
+	s.Require().Equal(
+		ClientCapabilities{
+			ClientCapabilities: lsp317.ClientCapabilities{
+				Workspace: &lsp317.ClientWorkspaceCapabilities{
+					ApplyEdit: &trueVal,
+					WorkspaceEdit: &lsp317.WorkspaceEditClientCapabilities{
+						DocumentChanges: &trueVal,
+					},
+				},
+				TextDocument: &lsp317.TextDocumentClientCapabilities{
+					Hover: &lsp317.HoverClientCapabilities{DynamicRegistration: &trueVal},
+					CodeAction: &lsp317.CodeActionClientCapabilities{
+						DynamicRegistration: &trueVal,
+					},
+				},
+				Window: &lsp317.WindowClientCapabilities{WorkDoneProgress: &trueVal},
+			},
+			Workspace: &ClientWorkspaceCapabilities{
+				ClientWorkspaceCapabilities: lsp317.ClientWorkspaceCapabilities{
+					ApplyEdit: &trueVal,
+					WorkspaceEdit: &lsp317.WorkspaceEditClientCapabilities{
+						DocumentChanges: &trueVal,
+					},
+				},
+				WorkspaceEdit: &WorkspaceEditClientCapabilities{
+					WorkspaceEditClientCapabilities: lsp317.WorkspaceEditClientCapabilities{
+						DocumentChanges: &trueVal,
+					},
+					SnippetEditSupport: &trueVal,
+				},
+				FoldingRange: &FoldingRangeWorkspaceClientCapabilities{RefreshSupport: &trueVal},
+				TextDocumentContent: &TextDocumentContentClientCapabilities{
+					DynamicRegistration: &trueVal,
+				},
+			},
+			TextDocument: &TextDocumentClientCapabilities{
+				TextDocumentClientCapabilities: lsp317.TextDocumentClientCapabilities{
+					Hover: &lsp317.HoverClientCapabilities{DynamicRegistration: &trueVal},
+					CodeAction: &lsp317.CodeActionClientCapabilities{
+						DynamicRegistration: &trueVal,
+					},
+				},
+				CodeAction: &CodeActionClientCapabilities{
+					CodeActionClientCapabilities: lsp317.CodeActionClientCapabilities{
+						DynamicRegistration: &trueVal,
+					},
+					DocumentationSupport: &trueVal,
+					TagSupport:           &CodeActionTagOptions{ValueSet: []CodeActionTag{CodeActionTagLLMGenerated}},
+				},
+				InlineCompletion: &InlineCompletionClientCapabilities{DynamicRegistration: &trueVal},
+			},
+		},
+		capabilities,
+	)
+}
+
+func (s *LifecycleMessagesTestSuite) Test_marshals_and_unmarshals_server_capabilities() {
+	trueVal := true
+	schemeID := "virtual-documents"
+	capabilities := ServerCapabilities{
+		ServerCapabilities: lsp317.ServerCapabilities{
+			HoverProvider: true,
+			CodeActionProvider: CodeActionOptions{
+				CodeActionOptions: lsp317.CodeActionOptions{
+					ResolveProvider: &trueVal,
+				},
+				Documentation: []CodeActionKindDocumentation{
+					{
+						Kind:    lsp317.CodeActionKindRefactor,
+						Command: lsp317.Command{Title: "Refactoring docs", Command: "docs.refactor"},
+					},
+				},
+			},
+		},
+		InlineCompletionProvider: InlineCompletionOptions{},
+		Workspace: &ServerWorkspaceCapabilities{
+			TextDocumentContent: TextDocumentContentRegistrationOptions{
+				TextDocumentContentOptions: TextDocumentContentOptions{Schemes: []string{"virtual"}},
+				StaticRegistrationOptions:  lsp317.StaticRegistrationOptions{ID: &schemeID},
+			},
+		},
+	}
+
+	data, err := json.Marshal(capabilities)
+	s.Require().NoError(err)
+	s.Require().Equal(
+		`{"hoverProvider":true,"codeActionProvider":{"resolveProvider":true,`+
+			`"documentation":[{"kind":"refactor","command":{"title":"Refactoring docs","command":"docs.refactor"}}]},`+
+			`"inlineCompletionProvider":{},`+
+			`"workspace":{"textDocumentContent":{"schemes":["virtual"],"id":"virtual-documents"}}}`,
+		string(data),
+	)
+
+	unmarshalled := ServerCapabilities{}
+	err = json.Unmarshal(data, &unmarshalled)
+	s.Require().NoError(err)
+	s.Require().Equal(capabilities.CodeActionProvider, unmarshalled.CodeActionProvider)
+	s.Require().Equal(capabilities.InlineCompletionProvider, unmarshalled.InlineCompletionProvider)
+	s.Require().Equal(true, unmarshalled.HoverProvider)
+	s.Require().Equal(
+		capabilities.Workspace.TextDocumentContent,
+		unmarshalled.Workspace.TextDocumentContent,
+	)
+}
+
+func (s *LifecycleMessagesTestSuite) Test_unmarshals_boolean_inline_completion_provider() {
+	capabilities := ServerCapabilities{}
+	err := json.Unmarshal([]byte(`{"inlineCompletionProvider":true,"codeActionProvider":false}`), &capabilities)
+	s.Require().NoError(err)
+	s.Require().Equal(true, capabilities.InlineCompletionProvider)
+	s.Require().Equal(false, capabilities.CodeActionProvider)
+}
+
+func (s *LifecycleMessagesTestSuite) Test_applies_position_encoding_to_initialize_result() {
+	result := &InitializeResult{}
+	_, posEncodingKind := result.ApplyPositionEncoding(lsp317.PositionEncodingKindUTF8)
+	s.Require().Equal(lsp317.PositionEncodingKindUTF8, posEncodingKind)
+	s.Require().Equal(lsp317.PositionEncodingKindUTF8, result.Capabilities.PositionEncoding)
+
+	var nilResult *InitializeResult
+	_, posEncodingKind = nilResult.ApplyPositionEncoding(lsp317.PositionEncodingKindUTF8)
+	s.Require().Equal(lsp317.PositionEncodingKindUTF16, posEncodingKind)
+}
+
+func TestLifecycleMessagesTestSuite(t *testing.T) {
+	suite.Run(t, new(LifecycleMessagesTestSuite))
+}
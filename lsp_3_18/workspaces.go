@@ -0,0 +1,97 @@
+package lsp
+
+import (
+	"github.com/two-hundred/ls-builder/common"
+	lsp317 "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#workspace_textDocumentContent
+
+const MethodWorkspaceTextDocumentContent = lsp317.Method("workspace/textDocumentContent")
+
+// WorkspaceTextDocumentContentHandlerFunc is the function signature for the
+// workspace/textDocumentContent request handler that can be registered for a language server.
+type WorkspaceTextDocumentContentHandlerFunc func(
+	ctx *common.LSPContext,
+	params *TextDocumentContentParams,
+) (*TextDocumentContentResult, error)
+
+// TextDocumentContentClientCapabilities describes the capabilities of a client
+// for the workspace/textDocumentContent request.
+//
+// @since 3.18.0
+type TextDocumentContentClientCapabilities struct {
+	// Text document content provider supports dynamic registration.
+	DynamicRegistration *bool `json:"dynamicRegistration,omitempty"`
+}
+
+// TextDocumentContentOptions provides server capability options
+// for the workspace/textDocumentContent request.
+//
+// @since 3.18.0
+type TextDocumentContentOptions struct {
+	// The schemes for which the server provides content.
+	Schemes []string `json:"schemes"`
+}
+
+// TextDocumentContentRegistrationOptions provides registration options
+// for the workspace/textDocumentContent request.
+//
+// @since 3.18.0
+type TextDocumentContentRegistrationOptions struct {
+	TextDocumentContentOptions
+	lsp317.StaticRegistrationOptions
+}
+
+// TextDocumentContentParams contains the parameters for the
+// workspace/textDocumentContent request.
+//
+// @since 3.18.0
+type TextDocumentContentParams struct {
+	// The uri of the text document.
+	URI lsp317.DocumentURI `json:"uri"`
+}
+
+// TextDocumentContentResult is the result of the
+// workspace/textDocumentContent request.
+//
+// @since 3.18.0
+type TextDocumentContentResult struct {
+	// The text content of the text document. Please note, that the content of
+	// any subsequent open notifications for the text document might differ
+	// from the returned content due to whitespace and line ending
+	// normalisations done on the client.
+	Text string `json:"text"`
+}
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#workspace_textDocumentContentRefresh
+
+const MethodWorkspaceTextDocumentContentRefresh = lsp317.Method("workspace/textDocumentContent/refresh")
+
+// TextDocumentContentRefreshParams contains the parameters for the
+// workspace/textDocumentContent/refresh request.
+//
+// @since 3.18.0
+type TextDocumentContentRefreshParams struct {
+	// The uri of the text document to refresh.
+	URI lsp317.DocumentURI `json:"uri"`
+}
+
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#foldingRange_refresh
+
+const MethodFoldingRangeRefresh = lsp317.Method("workspace/foldingRange/refresh")
+
+// FoldingRangeWorkspaceClientCapabilities describes the capabilities of a client
+// for folding range requests scoped to the workspace.
+//
+// @since 3.18.0
+type FoldingRangeWorkspaceClientCapabilities struct {
+	// Whether the client implementation supports a refresh request sent from the
+	// server to the client.
+	//
+	// Note that this event is global and will force the client to refresh all
+	// folding ranges currently shown. It should be used with absolute care and is
+	// useful for situation where a server for example detects a project wide
+	// change that requires such a calculation.
+	RefreshSupport *bool `json:"refreshSupport,omitempty"`
+}
@@ -0,0 +1,413 @@
+package lsptest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/two-hundred/ls-builder/common"
+	lsp "github.com/two-hundred/ls-builder/lsp_3_17"
+	"github.com/two-hundred/ls-builder/server"
+	"go.uber.org/zap"
+)
+
+// DefaultTimeout is the default amount of time the client waits
+// for a response to a request or for messages from the server.
+const DefaultTimeout = 10 * time.Second
+
+// Client is an in-process LSP 3.17 client that is connected to a server
+// over an in-memory pipe, intended to be used to write end-to-end tests
+// for language servers.
+//
+// Every notification and request the server sends to the client is recorded
+// so tests can make assertions about them, requests from the server are answered
+// with scripted responses that can be set with `WithResponder` or `Client.SetResponder`.
+type Client struct {
+	clientConn *jsonrpc2.Conn
+	serverConn *jsonrpc2.Conn
+	timeout    time.Duration
+	responders map[string]ResponderFunc
+	versions   map[lsp.DocumentURI]lsp.Integer
+	messages   []Message
+	// Closed and replaced every time a message is recorded
+	// to wake up goroutines waiting for messages.
+	received chan struct{}
+	mu       sync.Mutex
+}
+
+// Option is a function that can be used to configure a test client.
+type Option func(*options)
+
+type options struct {
+	logger        *zap.Logger
+	timeout       time.Duration
+	serverOptions []server.ServerOption
+	responders    map[string]ResponderFunc
+}
+
+// WithLogger sets the logger used by the server the client is connected to,
+// a no-op logger is used by default.
+func WithLogger(logger *zap.Logger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
+
+// WithTimeout sets the amount of time the client waits for a response
+// to a request or for messages from the server, defaults to `DefaultTimeout`.
+func WithTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.timeout = timeout
+	}
+}
+
+// WithServerOptions sets options for the server the client is connected to.
+func WithServerOptions(serverOptions ...server.ServerOption) Option {
+	return func(opts *options) {
+		opts.serverOptions = append(opts.serverOptions, serverOptions...)
+	}
+}
+
+// WithResponder sets the function that produces the response to requests
+// the server sends to the client for the provided method.
+func WithResponder(method string, responder ResponderFunc) Option {
+	return func(opts *options) {
+		opts.responders[method] = responder
+	}
+}
+
+// NewClient starts a server for the provided handler (e.g. an `lsp.Handler`)
+// and creates a client that is connected to it over an in-memory pipe.
+// The client should be closed with `Client.Close` or `Client.Shutdown`
+// at the end of a test.
+func NewClient(handler common.Handler, opts ...Option) *Client {
+	options := &options{
+		logger:     zap.NewNop(),
+		timeout:    DefaultTimeout,
+		responders: map[string]ResponderFunc{},
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	client := &Client{
+		timeout:    options.timeout,
+		responders: options.responders,
+		versions:   map[lsp.DocumentURI]lsp.Integer{},
+		messages:   []Message{},
+		received:   make(chan struct{}),
+	}
+
+	clientStream, serverStream := newPipeStreams()
+	srv := server.NewServer(handler, false, options.logger, nil, options.serverOptions...)
+	client.serverConn = server.NewStreamConnection(srv.NewHandler(), serverStream)
+	client.clientConn = server.NewStreamConnection(
+		jsonrpc2.HandlerWithError(client.handle),
+		clientStream,
+	)
+	go srv.Serve(client.serverConn, options.logger)
+
+	return client
+}
+
+// Initialize carries out the initialisation handshake with the server,
+// sending the `initialize` request followed by the `initialized` notification.
+// When params is nil, the params from `DefaultInitializeParams` are used.
+func (c *Client) Initialize(params *lsp.InitializeParams) (*lsp.InitializeResult, error) {
+	if params == nil {
+		params = DefaultInitializeParams()
+	}
+
+	result := &lsp.InitializeResult{}
+	err := c.Call(lsp.MethodInitialize, params, result)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.Notify(lsp.MethodInitialized, lsp.InitializedParams{})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Shutdown sends the `shutdown` request followed by the `exit` notification
+// and closes the connection to the server.
+func (c *Client) Shutdown() error {
+	err := c.Call(lsp.MethodShutdown, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	err = c.Notify(lsp.MethodExit, nil)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.serverConn.DisconnectNotify():
+	case <-time.After(c.timeout):
+		return fmt.Errorf("%w: server did not close the connection on exit", ErrTimeout)
+	}
+	return c.Close()
+}
+
+// Close closes the connection to the server.
+func (c *Client) Close() error {
+	err := c.clientConn.Close()
+	if err == jsonrpc2.ErrClosed {
+		return nil
+	}
+	return err
+}
+
+// Call sends a request to the server and decodes the response into result,
+// result can be nil to ignore the response.
+func (c *Client) Call(method string, params any, result any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return c.clientConn.Call(ctx, method, params, result)
+}
+
+// Notify sends a notification to the server.
+func (c *Client) Notify(method string, params any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return c.clientConn.Notify(ctx, method, params)
+}
+
+// Request sends a request to the server and decodes the response
+// into a value of the provided result type, this is useful for requests
+// that do not have a typed method on the client.
+func Request[Result any](c *Client, method string, params any) (Result, error) {
+	var result Result
+	err := c.Call(method, params, &result)
+	return result, err
+}
+
+// OpenDocument sends a `textDocument/didOpen` notification
+// for a document at version 1.
+func (c *Client) OpenDocument(uri lsp.DocumentURI, languageID string, text string) error {
+	c.mu.Lock()
+	c.versions[uri] = 1
+	c.mu.Unlock()
+
+	return c.Notify(lsp.MethodTextDocumentDidOpen, lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{
+			URI:        uri,
+			LanguageID: languageID,
+			Version:    1,
+			Text:       text,
+		},
+	})
+}
+
+// ChangeDocument sends a `textDocument/didChange` notification that replaces
+// the full content of a document, the version of the document
+// is incremented with every change.
+func (c *Client) ChangeDocument(uri lsp.DocumentURI, text string) error {
+	return c.changeDocument(uri, lsp.TextDocumentContentChangeEventWhole{Text: text})
+}
+
+// ChangeDocumentRange sends a `textDocument/didChange` notification that replaces
+// the provided range of a document with the provided text, the version of the document
+// is incremented with every change.
+func (c *Client) ChangeDocumentRange(uri lsp.DocumentURI, changeRange lsp.Range, text string) error {
+	return c.changeDocument(uri, lsp.TextDocumentContentChangeEvent{
+		Range: &changeRange,
+		Text:  text,
+	})
+}
+
+func (c *Client) changeDocument(uri lsp.DocumentURI, change any) error {
+	c.mu.Lock()
+	c.versions[uri] += 1
+	version := c.versions[uri]
+	c.mu.Unlock()
+
+	return c.Notify(lsp.MethodTextDocumentDidChange, lsp.DidChangeTextDocumentParams{
+		TextDocument: lsp.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: uri},
+			Version:                version,
+		},
+		ContentChanges: []any{change},
+	})
+}
+
+// SaveDocument sends a `textDocument/didSave` notification,
+// text can be nil when the server has not asked for the content on save.
+func (c *Client) SaveDocument(uri lsp.DocumentURI, text *string) error {
+	return c.Notify(lsp.MethodTextDocumentDidSave, lsp.DidSaveTextDocumentParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Text:         text,
+	})
+}
+
+// CloseDocument sends a `textDocument/didClose` notification.
+func (c *Client) CloseDocument(uri lsp.DocumentURI) error {
+	c.mu.Lock()
+	delete(c.versions, uri)
+	c.mu.Unlock()
+
+	return c.Notify(lsp.MethodTextDocumentDidClose, lsp.DidCloseTextDocumentParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+	})
+}
+
+// DocumentVersion returns the current version of an open document.
+func (c *Client) DocumentVersion(uri lsp.DocumentURI) (lsp.Integer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	version, isOpen := c.versions[uri]
+	return version, isOpen
+}
+
+// Hover sends a `textDocument/hover` request,
+// nil is returned when the server has no hover information.
+func (c *Client) Hover(params *lsp.HoverParams) (*lsp.Hover, error) {
+	var result *lsp.Hover
+	err := c.Call(lsp.MethodHover, params, &result)
+	return result, err
+}
+
+// Completion sends a `textDocument/completion` request,
+// results that are a list of completion items are returned as a complete list.
+// nil is returned when the server has no completions.
+func (c *Client) Completion(params *lsp.CompletionParams) (*lsp.CompletionList, error) {
+	var result json.RawMessage
+	err := c.Call(lsp.MethodCompletion, params, &result)
+	if err != nil || isNull(result) {
+		return nil, err
+	}
+
+	items := []*lsp.CompletionItem{}
+	if err := json.Unmarshal(result, &items); err == nil {
+		return &lsp.CompletionList{Items: items}, nil
+	}
+
+	list := &lsp.CompletionList{}
+	err = json.Unmarshal(result, list)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ResolveCompletionItem sends a `completionItem/resolve` request.
+func (c *Client) ResolveCompletionItem(item *lsp.CompletionItem) (*lsp.CompletionItem, error) {
+	result := &lsp.CompletionItem{}
+	err := c.Call(lsp.MethodCompletionItemResolve, item, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SignatureHelp sends a `textDocument/signatureHelp` request,
+// nil is returned when the server has no signature help.
+func (c *Client) SignatureHelp(params *lsp.SignatureHelpParams) (*lsp.SignatureHelp, error) {
+	var result *lsp.SignatureHelp
+	err := c.Call(lsp.MethodSignatureHelp, params, &result)
+	return result, err
+}
+
+// Formatting sends a `textDocument/formatting` request.
+func (c *Client) Formatting(params *lsp.DocumentFormattingParams) ([]lsp.TextEdit, error) {
+	var result []lsp.TextEdit
+	err := c.Call(lsp.MethodDocumentFormatting, params, &result)
+	return result, err
+}
+
+// Rename sends a `textDocument/rename` request,
+// nil is returned when the server has no changes to make.
+func (c *Client) Rename(params *lsp.RenameParams) (*lsp.WorkspaceEdit, error) {
+	var result *lsp.WorkspaceEdit
+	err := c.Call(lsp.MethodDocumentRename, params, &result)
+	return result, err
+}
+
+// ExecuteCommand sends a `workspace/executeCommand` request
+// returning the raw result of the command.
+func (c *Client) ExecuteCommand(params *lsp.ExecuteCommandParams) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := c.Call(lsp.MethodWorkspaceExecuteCommand, params, &result)
+	return result, err
+}
+
+func (c *Client) handle(
+	ctx context.Context,
+	conn *jsonrpc2.Conn,
+	req *jsonrpc2.Request,
+) (any, error) {
+	params := json.RawMessage("null")
+	if req.Params != nil {
+		params = *req.Params
+	}
+
+	c.mu.Lock()
+	c.messages = append(c.messages, Message{
+		Method:         req.Method,
+		Params:         params,
+		IsNotification: req.Notif,
+	})
+	close(c.received)
+	c.received = make(chan struct{})
+	responder := c.responders[req.Method]
+	c.mu.Unlock()
+
+	if req.Notif {
+		return nil, nil
+	}
+
+	if responder == nil {
+		return defaultResponse(req.Method, params)
+	}
+	return responder(params)
+}
+
+func isNull(value json.RawMessage) bool {
+	return len(value) == 0 || string(value) == "null"
+}
+
+// DefaultInitializeParams returns the params used for the `initialize` request
+// when none are provided to `Client.Initialize`.
+// The client capabilities advertise support for the features the test client
+// can respond to, such as applying workspace edits, fetching configuration,
+// dynamic registration of configuration change notifications and versioned diagnostics.
+func DefaultInitializeParams() *lsp.InitializeParams {
+	enabled := true
+	return &lsp.InitializeParams{
+		ClientInfo: &lsp.InitializeClientInfo{
+			Name: "lsptest",
+		},
+		Capabilities: lsp.ClientCapabilities{
+			Workspace: &lsp.ClientWorkspaceCapabilities{
+				ApplyEdit: &enabled,
+				WorkspaceEdit: &lsp.WorkspaceEditClientCapabilities{
+					DocumentChanges: &enabled,
+				},
+				DidChangeConfiguration: &lsp.DidChangeConfigurationClientCapabilities{
+					DynamicRegistration: &enabled,
+				},
+				Configuration: &enabled,
+			},
+			TextDocument: &lsp.TextDocumentClientCapabilities{
+				Synchronization: &lsp.TextDocumentSyncClientCapabilities{},
+				Completion: &lsp.CompletionClientCapabilities{
+					CompletionItem: &lsp.CompletionItemCapabilities{
+						SnippetSupport: &enabled,
+					},
+				},
+				Hover:         &lsp.HoverClientCapabilities{},
+				SignatureHelp: &lsp.SignatureHelpClientCapabilities{},
+				PublishDiagnostics: &lsp.PublishDiagnosticsClientCapabilities{
+					RelatedInformation: &enabled,
+					VersionSupport:     &enabled,
+				},
+			},
+		},
+	}
+}
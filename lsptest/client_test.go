@@ -0,0 +1,376 @@
+package lsptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	lsp "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+type ClientTestSuite struct {
+	suite.Suite
+	client *Client
+}
+
+const testDocumentURI = "file:///test.txt"
+
+type testSettings struct {
+	MaxProblems int `json:"maxProblems"`
+}
+
+func (s *ClientTestSuite) SetupTest() {
+	s.client = NewClient(
+		newTestHandler(),
+		WithTimeout(time.Second),
+		WithResponder(
+			lsp.MethodWorkspaceConfiguration,
+			ConfigurationResponder(map[string]any{
+				"testServer": testSettings{MaxProblems: 10},
+			}),
+		),
+	)
+}
+
+func (s *ClientTestSuite) TearDownTest() {
+	s.Require().NoError(s.client.Close())
+}
+
+func (s *ClientTestSuite) Test_initializes_server_and_records_server_messages() {
+	result, err := s.client.Initialize(nil)
+	s.Require().NoError(err)
+	s.Require().Equal("test-server", result.ServerInfo.Name)
+
+	messages, err := s.client.WaitForMessages(lsp.MethodLogMessage, 1)
+	s.Require().NoError(err)
+	s.Require().Len(messages, 1)
+	s.Require().True(messages[0].IsNotification)
+	s.Require().Equal(
+		[]lsp.LogMessageParams{
+			{Type: lsp.MessageTypeInfo, Message: "maxProblems: 10, applied: true"},
+		},
+		s.client.LogMessages(),
+	)
+
+	s.Require().Equal(
+		[]lsp.Registration{
+			{ID: "config", Method: lsp.MethodWorkspaceDidChangeConfiguration},
+		},
+		s.client.Registrations(),
+	)
+	s.Require().Len(s.client.AppliedEdits(), 1)
+	s.Require().Equal(
+		[]string{
+			lsp.MethodWorkspaceConfiguration,
+			lsp.ClientRegisterCapability,
+			lsp.MethodWorkspaceApplyEdit,
+			lsp.MethodLogMessage,
+		},
+		messageMethods(s.client.Messages()),
+	)
+
+	s.client.ClearMessages()
+	s.Require().Empty(s.client.Messages())
+	s.Require().NoError(s.client.Shutdown())
+}
+
+func (s *ClientTestSuite) Test_uses_scripted_responses_for_server_requests() {
+	s.client.SetResponder(
+		lsp.MethodWorkspaceApplyEdit,
+		ApplyEditResponder(lsp.ApplyWorkspaceEditResult{Applied: false}),
+	)
+	s.client.SetResponder(
+		lsp.MethodWorkspaceConfiguration,
+		func(params json.RawMessage) (any, error) {
+			return []testSettings{{MaxProblems: 3}}, nil
+		},
+	)
+
+	_, err := s.client.Initialize(nil)
+	s.Require().NoError(err)
+
+	_, err = s.client.WaitForMessages(lsp.MethodLogMessage, 1)
+	s.Require().NoError(err)
+	s.Require().Equal("maxProblems: 3, applied: false", s.client.LogMessages()[0].Message)
+}
+
+func (s *ClientTestSuite) Test_keeps_track_of_document_versions_and_diagnostics() {
+	_, err := s.client.Initialize(nil)
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.client.OpenDocument(testDocumentURI, "plaintext", "TODO: one"))
+	diagnostics, err := s.client.WaitForDiagnostics(testDocumentURI)
+	s.Require().NoError(err)
+	s.Require().Equal(lsp.Integer(1), *diagnostics.Version)
+	s.Require().Len(diagnostics.Diagnostics, 1)
+
+	s.client.ClearMessages()
+	s.Require().NoError(s.client.ChangeDocument(testDocumentURI, "TODO: one\nTODO: two"))
+	diagnostics, err = s.client.WaitForDiagnostics(testDocumentURI)
+	s.Require().NoError(err)
+	s.Require().Equal(lsp.Integer(2), *diagnostics.Version)
+	s.Require().Len(diagnostics.Diagnostics, 2)
+
+	s.client.ClearMessages()
+	s.Require().NoError(s.client.ChangeDocumentRange(
+		testDocumentURI,
+		lsp.Range{
+			Start: lsp.Position{Line: 0, Character: 0},
+			End:   lsp.Position{Line: 0, Character: 4},
+		},
+		"DONE",
+	))
+	diagnostics, err = s.client.WaitForDiagnostics(testDocumentURI)
+	s.Require().NoError(err)
+	s.Require().Equal(lsp.Integer(3), *diagnostics.Version)
+	s.Require().Len(diagnostics.Diagnostics, 1)
+	s.Require().Equal(lsp.UInteger(1), diagnostics.Diagnostics[0].Range.Start.Line)
+
+	version, isOpen := s.client.DocumentVersion(testDocumentURI)
+	s.Require().True(isOpen)
+	s.Require().Equal(lsp.Integer(3), version)
+	s.Require().Len(s.client.PublishedDiagnostics(testDocumentURI), 1)
+
+	s.Require().NoError(s.client.CloseDocument(testDocumentURI))
+	_, isOpen = s.client.DocumentVersion(testDocumentURI)
+	s.Require().False(isOpen)
+}
+
+func (s *ClientTestSuite) Test_sends_typed_requests() {
+	_, err := s.client.Initialize(nil)
+	s.Require().NoError(err)
+
+	position := lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: testDocumentURI},
+		Position:     lsp.Position{Line: 1, Character: 2},
+	}
+	hover, err := s.client.Hover(&lsp.HoverParams{TextDocumentPositionParams: position})
+	s.Require().NoError(err)
+	s.Require().Equal(
+		lsp.MarkupContent{Kind: lsp.MarkupKindPlainText, Value: "hover at 1:2"},
+		hover.Contents,
+	)
+
+	list, err := s.client.Completion(&lsp.CompletionParams{TextDocumentPositionParams: position})
+	s.Require().NoError(err)
+	s.Require().False(list.IsIncomplete)
+	s.Require().Len(list.Items, 2)
+	s.Require().Equal("first", list.Items[0].Label)
+
+	hover, err = Request[*lsp.Hover](s.client, lsp.MethodHover, &lsp.HoverParams{TextDocumentPositionParams: position})
+	s.Require().NoError(err)
+	s.Require().NotNil(hover)
+
+	_, err = s.client.SignatureHelp(&lsp.SignatureHelpParams{TextDocumentPositionParams: position})
+	s.Require().ErrorContains(err, "method not supported")
+}
+
+func (s *ClientTestSuite) Test_times_out_waiting_for_messages() {
+	client := NewClient(newTestHandler(), WithTimeout(20*time.Millisecond))
+	defer client.Close()
+
+	_, err := client.WaitForMessages(lsp.MethodLogMessage, 1)
+	s.Require().ErrorIs(err, ErrTimeout)
+
+	_, err = client.WaitForDiagnostics(testDocumentURI)
+	s.Require().ErrorIs(err, ErrTimeout)
+}
+
+func (s *ClientTestSuite) Test_answers_server_requests_made_while_handling_a_request() {
+	client := NewClient(
+		lsp.NewHandler(
+			lsp.WithInitializeHandler(
+				func(ctx *common.LSPContext, params *lsp.InitializeParams) (any, error) {
+					return lsp.InitializeResult{}, nil
+				},
+			),
+			lsp.WithHoverHandler(
+				func(ctx *common.LSPContext, params *lsp.HoverParams) (*lsp.Hover, error) {
+					// The configuration is fetched before responding to the hover request.
+					dispatcher := lsp.NewDispatcher(ctx)
+					section := "testServer"
+					settings := []testSettings{}
+					err := dispatcher.WorkspaceConfiguration(
+						lsp.ConfigurationParams{Items: []lsp.ConfigurationItem{{Section: &section}}},
+						&settings,
+					)
+					if err != nil {
+						return nil, err
+					}
+
+					return &lsp.Hover{
+						Contents: lsp.MarkupContent{
+							Kind:  lsp.MarkupKindPlainText,
+							Value: fmt.Sprintf("maxProblems: %d", settings[0].MaxProblems),
+						},
+					}, nil
+				},
+			),
+		),
+		WithTimeout(time.Second),
+		WithResponder(
+			lsp.MethodWorkspaceConfiguration,
+			ConfigurationResponder(map[string]any{
+				"testServer": testSettings{MaxProblems: 7},
+			}),
+		),
+	)
+	defer client.Close()
+
+	_, err := client.Initialize(nil)
+	s.Require().NoError(err)
+
+	hover, err := client.Hover(&lsp.HoverParams{
+		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: testDocumentURI},
+		},
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(
+		lsp.MarkupContent{Kind: lsp.MarkupKindPlainText, Value: "maxProblems: 7"},
+		hover.Contents,
+	)
+}
+
+// newTestHandler creates a handler for a server that reports a diagnostic
+// for every line with a TODO and fetches settings, registers for configuration changes
+// and applies an edit once it has been initialised.
+func newTestHandler() *lsp.Handler {
+	documents := lsp.NewDocumentStore()
+	return lsp.NewHandler(
+		lsp.WithInitializeHandler(
+			func(ctx *common.LSPContext, params *lsp.InitializeParams) (any, error) {
+				return lsp.InitializeResult{
+					ServerInfo: &lsp.InitializeResultServerInfo{Name: "test-server"},
+				}, nil
+			},
+		),
+		lsp.WithInitializedHandler(
+			func(ctx *common.LSPContext, params *lsp.InitializedParams) error {
+				initializeWithClient(ctx)
+				return nil
+			},
+		),
+		lsp.WithShutdownHandler(
+			func(ctx *common.LSPContext) error {
+				return nil
+			},
+		),
+		lsp.WithTextDocumentDidOpenHandler(
+			func(ctx *common.LSPContext, params *lsp.DidOpenTextDocumentParams) error {
+				documents.Open(params)
+				return publishTodos(ctx, documents, params.TextDocument.URI)
+			},
+		),
+		lsp.WithTextDocumentDidChangeHandler(
+			func(ctx *common.LSPContext, params *lsp.DidChangeTextDocumentParams) error {
+				if _, err := documents.Change(params); err != nil {
+					return err
+				}
+				return publishTodos(ctx, documents, params.TextDocument.URI)
+			},
+		),
+		lsp.WithTextDocumentDidCloseHandler(
+			func(ctx *common.LSPContext, params *lsp.DidCloseTextDocumentParams) error {
+				documents.Close(params)
+				return nil
+			},
+		),
+		lsp.WithHoverHandler(
+			func(ctx *common.LSPContext, params *lsp.HoverParams) (*lsp.Hover, error) {
+				return &lsp.Hover{
+					Contents: lsp.MarkupContent{
+						Kind: lsp.MarkupKindPlainText,
+						Value: fmt.Sprintf(
+							"hover at %d:%d",
+							params.Position.Line,
+							params.Position.Character,
+						),
+					},
+				}, nil
+			},
+		),
+		lsp.WithCompletionHandler(
+			func(ctx *common.LSPContext, params *lsp.CompletionParams) (any, error) {
+				return []*lsp.CompletionItem{{Label: "first"}, {Label: "second"}}, nil
+			},
+		),
+	)
+}
+
+func initializeWithClient(ctx *common.LSPContext) {
+	dispatcher := lsp.NewDispatcher(ctx)
+	section := "testServer"
+	settings := []testSettings{}
+	err := dispatcher.WorkspaceConfiguration(
+		lsp.ConfigurationParams{Items: []lsp.ConfigurationItem{{Section: &section}}},
+		&settings,
+	)
+	if err != nil || len(settings) == 0 {
+		return
+	}
+
+	err = dispatcher.RegisterCapability(lsp.RegistrationParams{
+		Registrations: []lsp.Registration{
+			{ID: "config", Method: lsp.MethodWorkspaceDidChangeConfiguration},
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	result, err := dispatcher.ApplyWorkspaceEdit(lsp.ApplyWorkspaceEditParams{
+		Edit: lsp.WorkspaceEdit{},
+	})
+	if err != nil {
+		return
+	}
+
+	dispatcher.LogMessage(lsp.LogMessageParams{
+		Type:    lsp.MessageTypeInfo,
+		Message: fmt.Sprintf("maxProblems: %d, applied: %t", settings[0].MaxProblems, result.Applied),
+	})
+}
+
+func publishTodos(ctx *common.LSPContext, documents *lsp.DocumentStore, uri lsp.DocumentURI) error {
+	document, isOpen := documents.Get(uri)
+	if !isOpen {
+		return lsp.ErrDocumentNotOpen
+	}
+
+	diagnostics := []lsp.Diagnostic{}
+	for i, line := range strings.Split(document.Text, "\n") {
+		if strings.HasPrefix(line, "TODO") {
+			diagnostics = append(diagnostics, lsp.Diagnostic{
+				Range: lsp.Range{
+					Start: lsp.Position{Line: lsp.UInteger(i)},
+					End:   lsp.Position{Line: lsp.UInteger(i), Character: 4},
+				},
+				Message: "unfinished work",
+			})
+		}
+	}
+
+	version := document.Version
+	return lsp.NewDispatcher(ctx).PublishDiagnostics(lsp.PublishDiagnosticsParams{
+		URI:         uri,
+		Version:     &version,
+		Diagnostics: diagnostics,
+	})
+}
+
+func messageMethods(messages []Message) []string {
+	methods := []string{}
+	for _, message := range messages {
+		methods = append(methods, message.Method)
+	}
+	return methods
+}
+
+func TestClientTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
@@ -0,0 +1,9 @@
+package lsptest
+
+import "errors"
+
+var (
+	// ErrTimeout is returned when the client has waited too long
+	// for messages from the server.
+	ErrTimeout = errors.New("timed out waiting for the server")
+)
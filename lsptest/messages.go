@@ -0,0 +1,173 @@
+package lsptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	lsp "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+// Message is a notification or request that the server
+// sent to the client.
+type Message struct {
+	Method         string
+	Params         json.RawMessage
+	IsNotification bool
+}
+
+// DecodeParams decodes the params of the message into target.
+func (m Message) DecodeParams(target any) error {
+	return json.Unmarshal(m.Params, target)
+}
+
+// Messages returns all the notifications and requests the server
+// has sent to the client in the order they were received.
+func (c *Client) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Message{}, c.messages...)
+}
+
+// MessagesForMethod returns the notifications and requests the server
+// has sent to the client for the provided method in the order they were received.
+func (c *Client) MessagesForMethod(method string) []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.messagesForMethod(method)
+}
+
+// ClearMessages removes all the recorded messages,
+// this is useful to make assertions about the messages
+// that are sent in response to a specific action.
+func (c *Client) ClearMessages() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = []Message{}
+}
+
+// WaitForMessages waits until the server has sent at least count
+// messages for the provided method, returning all the recorded messages
+// for the method.
+// `ErrTimeout` is returned if the messages are not received
+// within the timeout of the client.
+func (c *Client) WaitForMessages(method string, count int) ([]Message, error) {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	for {
+		c.mu.Lock()
+		messages := c.messagesForMethod(method)
+		received := c.received
+		c.mu.Unlock()
+
+		if len(messages) >= count {
+			return messages, nil
+		}
+
+		select {
+		case <-received:
+		case <-timer.C:
+			return nil, fmt.Errorf(
+				"%w: received %d of %d %q messages",
+				ErrTimeout,
+				len(messages),
+				count,
+				method,
+			)
+		}
+	}
+}
+
+// PublishedDiagnostics returns the diagnostics the server has published
+// for the provided document in the order they were received.
+func (c *Client) PublishedDiagnostics(uri lsp.DocumentURI) []lsp.PublishDiagnosticsParams {
+	published := []lsp.PublishDiagnosticsParams{}
+	for _, params := range decodeMessages[lsp.PublishDiagnosticsParams](
+		c.MessagesForMethod(lsp.MethodPublishDiagnostics),
+	) {
+		if params.URI == uri {
+			published = append(published, params)
+		}
+	}
+	return published
+}
+
+// WaitForDiagnostics waits until the server has published diagnostics
+// for the provided document, returning the most recently published diagnostics.
+// Call `Client.ClearMessages` before an action to wait for the diagnostics
+// published in response to the action.
+func (c *Client) WaitForDiagnostics(uri lsp.DocumentURI) (*lsp.PublishDiagnosticsParams, error) {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	for {
+		c.mu.Lock()
+		received := c.received
+		c.mu.Unlock()
+
+		published := c.PublishedDiagnostics(uri)
+		if len(published) > 0 {
+			return &published[len(published)-1], nil
+		}
+
+		select {
+		case <-received:
+		case <-timer.C:
+			return nil, fmt.Errorf("%w: no diagnostics published for %s", ErrTimeout, uri)
+		}
+	}
+}
+
+// LogMessages returns the params of the `window/logMessage` notifications
+// the server has sent to the client.
+func (c *Client) LogMessages() []lsp.LogMessageParams {
+	return decodeMessages[lsp.LogMessageParams](c.MessagesForMethod(lsp.MethodLogMessage))
+}
+
+// ShowMessages returns the params of the `window/showMessage` notifications
+// the server has sent to the client.
+func (c *Client) ShowMessages() []lsp.ShowMessageParams {
+	return decodeMessages[lsp.ShowMessageParams](c.MessagesForMethod(lsp.MethodShowMessageNotification))
+}
+
+// AppliedEdits returns the params of the `workspace/applyEdit` requests
+// the server has sent to the client.
+func (c *Client) AppliedEdits() []lsp.ApplyWorkspaceEditParams {
+	return decodeMessages[lsp.ApplyWorkspaceEditParams](c.MessagesForMethod(lsp.MethodWorkspaceApplyEdit))
+}
+
+// Registrations returns the registrations from all the `client/registerCapability`
+// requests the server has sent to the client.
+func (c *Client) Registrations() []lsp.Registration {
+	registrations := []lsp.Registration{}
+	for _, params := range decodeMessages[lsp.RegistrationParams](
+		c.MessagesForMethod(lsp.ClientRegisterCapability),
+	) {
+		registrations = append(registrations, params.Registrations...)
+	}
+	return registrations
+}
+
+func (c *Client) messagesForMethod(method string) []Message {
+	messages := []Message{}
+	for _, message := range c.messages {
+		if message.Method == method {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// decodeMessages decodes the params of the provided messages,
+// skipping messages with params that can not be decoded.
+func decodeMessages[Params any](messages []Message) []Params {
+	decoded := []Params{}
+	for _, message := range messages {
+		var params Params
+		if err := message.DecodeParams(&params); err == nil {
+			decoded = append(decoded, params)
+		}
+	}
+	return decoded
+}
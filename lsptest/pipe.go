@@ -0,0 +1,84 @@
+package lsptest
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// bufferedPipe is an in-memory pipe where writes never block,
+// the client and server both write from the goroutine that reads their
+// messages so an unbuffered pipe (e.g. net.Pipe) would deadlock when both
+// sides write to each other at the same time.
+type bufferedPipe struct {
+	buffer bytes.Buffer
+	closed bool
+	mu     sync.Mutex
+	cond   *sync.Cond
+}
+
+func newBufferedPipe() *bufferedPipe {
+	pipe := &bufferedPipe{}
+	pipe.cond = sync.NewCond(&pipe.mu)
+	return pipe
+}
+
+func (p *bufferedPipe) Read(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.buffer.Len() == 0 && !p.closed {
+		p.cond.Wait()
+	}
+
+	if p.buffer.Len() == 0 {
+		return 0, io.EOF
+	}
+	return p.buffer.Read(data)
+}
+
+func (p *bufferedPipe) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	defer p.cond.Broadcast()
+	return p.buffer.Write(data)
+}
+
+func (p *bufferedPipe) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.cond.Broadcast()
+	return nil
+}
+
+// pipeStream is one end of a connection made up of a pipe
+// to read from and a pipe to write to.
+type pipeStream struct {
+	in  *bufferedPipe
+	out *bufferedPipe
+}
+
+// newPipeStreams creates the two ends of an in-memory connection,
+// closing either end closes the connection for both.
+func newPipeStreams() (*pipeStream, *pipeStream) {
+	a := newBufferedPipe()
+	b := newBufferedPipe()
+	return &pipeStream{in: a, out: b}, &pipeStream{in: b, out: a}
+}
+
+func (s *pipeStream) Read(data []byte) (int, error) {
+	return s.in.Read(data)
+}
+
+func (s *pipeStream) Write(data []byte) (int, error) {
+	return s.out.Write(data)
+}
+
+func (s *pipeStream) Close() error {
+	s.in.Close()
+	return s.out.Close()
+}
@@ -0,0 +1,62 @@
+package lsptest
+
+import (
+	"encoding/json"
+
+	lsp "github.com/two-hundred/ls-builder/lsp_3_17"
+)
+
+// ResponderFunc produces the response to a request that the server sends
+// to the client from the raw params of the request.
+// Returning a *jsonrpc2.Error will send the error to the server as it is.
+type ResponderFunc func(params json.RawMessage) (any, error)
+
+// SetResponder sets the function that produces the response to requests
+// the server sends to the client for the provided method,
+// replacing the responder set for the method with `WithResponder`.
+func (c *Client) SetResponder(method string, responder ResponderFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responders[method] = responder
+}
+
+// ConfigurationResponder creates a responder for `workspace/configuration` requests
+// that responds with the value in settings for the section of each item,
+// null is returned for sections that are not in settings.
+func ConfigurationResponder(settings map[string]any) ResponderFunc {
+	return func(params json.RawMessage) (any, error) {
+		configParams := lsp.ConfigurationParams{}
+		if err := json.Unmarshal(params, &configParams); err != nil {
+			return nil, err
+		}
+
+		results := make([]any, len(configParams.Items))
+		for i, item := range configParams.Items {
+			if item.Section != nil {
+				results[i] = settings[*item.Section]
+			}
+		}
+		return results, nil
+	}
+}
+
+// ApplyEditResponder creates a responder for `workspace/applyEdit` requests
+// that responds with the provided result.
+func ApplyEditResponder(result lsp.ApplyWorkspaceEditResult) ResponderFunc {
+	return func(params json.RawMessage) (any, error) {
+		return result, nil
+	}
+}
+
+// defaultResponse produces the response for requests that do not have a responder,
+// responding with results that a client with no settings that accepts
+// every change would respond with.
+func defaultResponse(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case lsp.MethodWorkspaceApplyEdit:
+		return lsp.ApplyWorkspaceEditResult{Applied: true}, nil
+	case lsp.MethodWorkspaceConfiguration:
+		return ConfigurationResponder(map[string]any{})(params)
+	}
+	return nil, nil
+}
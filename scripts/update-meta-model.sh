@@ -0,0 +1,18 @@
+#!/usr/bin/env bash
+
+# Downloads the official meta model of the LSP 3.17 specification used to check
+# that the lsp_3_17 package has not drifted from the specification
+# and as input for the cmd/lspgen generator.
+# The checks are skipped when the meta model has not been downloaded,
+# commit the downloaded meta model to vendor it.
+
+META_MODEL_URL="https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/metaModel/metaModel.json"
+OUTPUT_PATH="lsp_3_17/testdata/metaModel.json"
+
+set -e
+
+mkdir -p "$(dirname "$OUTPUT_PATH")"
+curl --silent --show-error --fail --location "$META_MODEL_URL" --output "$OUTPUT_PATH"
+echo "Updated $OUTPUT_PATH from $META_MODEL_URL"
+
+go test ./lsp_3_17 -run 'TestMetaModelTestSuite|TestDispatchTestSuite'
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"go.uber.org/zap"
+)
+
+type CancellationTestSuite struct {
+	suite.Suite
+}
+
+func (s *CancellationTestSuite) Test_cancels_context_of_in_flight_request() {
+	logger, err := zap.NewDevelopment()
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	handler := common.HandlerFunc(
+		func(lspCtx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			validMethod = true
+			validParams = true
+			if lspCtx.Method == "slowRequest" {
+				close(started)
+				<-lspCtx.Context.Done()
+				cancelled <- lspCtx.Context.Err()
+				err = lspCtx.Context.Err()
+			}
+			return
+		},
+	)
+	server := NewServer(handler, false, logger, nil)
+
+	serverStream, clientStream := net.Pipe()
+	serverConn := NewStreamConnection(server.NewHandler(), serverStream)
+	go server.Serve(serverConn, logger)
+
+	clientContainer := createClientHandler()
+	clientConn := NewStreamConnection(clientContainer.handler, clientStream)
+
+	requestID := jsonrpc2.ID{Str: "slow-request", IsString: true}
+	_, err = clientConn.DispatchCall(ctx, "slowRequest", nil, jsonrpc2.PickID(requestID))
+	s.Require().NoError(err)
+
+	select {
+	case <-ctx.Done():
+		s.Fail("timeout waiting for request to start")
+	case <-started:
+	}
+
+	err = clientConn.Notify(ctx, MethodCancelRequest, cancelParams{ID: requestID})
+	s.Require().NoError(err)
+
+	select {
+	case <-ctx.Done():
+		s.Fail("timeout waiting for request to be cancelled")
+	case err := <-cancelled:
+		s.Require().ErrorIs(err, context.Canceled)
+	}
+}
+
+func (s *CancellationTestSuite) Test_ignores_cancellation_for_unknown_request() {
+	tracker := newRequestTracker()
+	cancelled := false
+	done := tracker.track(nil, jsonrpc2.ID{Num: 1}, func() { cancelled = true })
+	s.Require().False(tracker.cancel(nil, jsonrpc2.ID{Num: 2}))
+	s.Require().False(cancelled)
+
+	done()
+	s.Require().False(tracker.cancel(nil, jsonrpc2.ID{Num: 1}))
+	s.Require().False(cancelled)
+}
+
+func (s *CancellationTestSuite) Test_calls_client_after_message_has_been_handled() {
+	logger, err := zap.NewDevelopment()
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	called := make(chan error, 1)
+	handler := common.HandlerFunc(
+		func(lspCtx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			validMethod = true
+			validParams = true
+			if lspCtx.Method == "backgroundWork" {
+				go func() {
+					// Wait for the message to be handled before calling the client.
+					<-lspCtx.Context.Done()
+					called <- lspCtx.Call("client/work", nil, nil)
+				}()
+			}
+			return
+		},
+	)
+	server := NewServer(handler, false, logger, nil)
+
+	serverStream, clientStream := net.Pipe()
+	serverConn := NewStreamConnection(server.NewHandler(), serverStream)
+	go server.Serve(serverConn, logger)
+
+	// The client takes a while to respond so the server is waiting
+	// for the response after the message has been handled.
+	clientHandler := jsonrpc2.HandlerWithError(
+		func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			time.Sleep(20 * time.Millisecond)
+			return req.Method, nil
+		},
+	)
+	clientConn := NewStreamConnection(clientHandler, clientStream)
+
+	err = clientConn.Notify(ctx, "backgroundWork", nil)
+	s.Require().NoError(err)
+
+	select {
+	case <-ctx.Done():
+		s.Fail("timeout waiting for the server to call the client")
+	case err := <-called:
+		s.Require().NoError(err)
+	}
+}
+
+func (s *CancellationTestSuite) Test_sets_context_on_lsp_context() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	lspContext := NewLSPContext(ctx, nil, &jsonrpc2.Request{Method: "test"})
+	s.Require().Equal(ctx, lspContext.Context)
+}
+
+func TestCancellationTestSuite(t *testing.T) {
+	suite.Run(t, new(CancellationTestSuite))
+}
@@ -19,6 +19,7 @@ type connWrapper struct {
 	writeTimeout       time.Duration
 	wsConn             *websocket.Conn
 	jsonRPCConnOptions []jsonrpc2.ConnOpt
+	recorder           *Recorder
 }
 
 // ConnOption is a function that configures a connection.
@@ -76,7 +77,7 @@ func NewStreamConnection(handler jsonrpc2.Handler, stream io.ReadWriteCloser, op
 
 	return jsonrpc2.NewConn(
 		ctx,
-		jsonrpc2.NewBufferedStream(stream, jsonrpc2.VSCodeObjectCodec{}),
+		c.objectStream(jsonrpc2.NewBufferedStream(stream, jsonrpc2.VSCodeObjectCodec{})),
 		handler,
 		c.jsonRPCConnOptions...,
 	)
@@ -98,8 +99,15 @@ func NewWebSocketConnection(handler jsonrpc2.Handler, wsConn *websocket.Conn, op
 
 	return jsonrpc2.NewConn(
 		ctx,
-		wsjsonrpc2.NewObjectStream(wsConn),
+		c.objectStream(wsjsonrpc2.NewObjectStream(wsConn)),
 		handler,
 		c.jsonRPCConnOptions...,
 	)
 }
+
+func (c *connWrapper) objectStream(stream jsonrpc2.ObjectStream) jsonrpc2.ObjectStream {
+	if c.recorder == nil {
+		return stream
+	}
+	return &recordingStream{stream: stream, recorder: c.recorder}
+}
@@ -2,19 +2,36 @@ package server
 
 import (
 	"context"
+	"errors"
 
 	"github.com/sourcegraph/jsonrpc2"
 	"github.com/two-hundred/ls-builder/common"
 )
 
+// errMessageHandled is the cause used to cancel the context of a message
+// once the server has finished handling it.
+var errMessageHandled = errors.New("message handled")
+
 // NewLSPContext creates a new LSP context from the given connection and request.
+// The provided context is exposed as `LSPContext.Context` and will be cancelled
+// when the request times out or the client cancels the request
+// with a `$/cancelRequest` notification.
+//
+// Notifications and requests sent to the client with the context are not cancelled
+// when the server has finished handling the message, so work that outlives
+// the message (e.g. in a goroutine) can still communicate with the client.
 func NewLSPContext(ctx context.Context, conn *jsonrpc2.Conn, request *jsonrpc2.Request) *common.LSPContext {
 	lspContext := &common.LSPContext{
+		Context: ctx,
 		Notify: func(method string, params any) error {
-			return conn.Notify(ctx, method, params)
+			clientCtx, done := clientMessageContext(ctx)
+			defer done()
+			return conn.Notify(clientCtx, method, params)
 		},
 		Call: func(method string, params any, result any) error {
-			return conn.Call(ctx, method, params, result)
+			clientCtx, done := clientMessageContext(ctx)
+			defer done()
+			return conn.Call(clientCtx, method, params, result)
 		},
 	}
 
@@ -23,9 +40,27 @@ func NewLSPContext(ctx context.Context, conn *jsonrpc2.Conn, request *jsonrpc2.R
 	}
 
 	lspContext.Method = request.Method
+	lspContext.IsNotification = request.Notif
 	if request.Params != nil {
 		lspContext.Params = *request.Params
 	}
 
 	return lspContext
 }
+
+// clientMessageContext derives the context for a message sent to the client
+// that is cancelled along with the provided context, unless the provided
+// context was cancelled because the server finished handling the message.
+func clientMessageContext(ctx context.Context) (context.Context, func()) {
+	clientCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		cause := context.Cause(ctx)
+		if !errors.Is(cause, errMessageHandled) {
+			cancel(cause)
+		}
+	})
+	return clientCtx, func() {
+		stop()
+		cancel(nil)
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNamedPipeNotSupported = errors.New("windows named pipes are not supported, use a unix domain socket instead")
+	ErrMultipleTransports    = errors.New("only one transport can be selected")
+	ErrInvalidTransportFlag  = errors.New("invalid transport flag")
+)
+
+// Error codes defined by the Language Server Protocol that the server
+// responds with for errors returned by handlers.
+// These are the same across all versions of LSP that ls-builder supports.
+const (
+	// CodeRequestFailed is the error code used for errors returned by handlers
+	// for requests that were syntactically correct.
+	CodeRequestFailed int64 = -32803
+	// CodeRequestCancelled is the error code used for requests that fail
+	// because they were cancelled by the client.
+	CodeRequestCancelled int64 = -32800
+)
+
+// ResponseError is implemented by errors that should be sent to the client
+// as an error response with a specific error code and optional data,
+// such as the LSP errors provided by the packages for each version of LSP
+// (e.g. `lsp.ResponseError`).
+type ResponseError interface {
+	error
+	// ErrorCode returns the error code to send to the client.
+	ErrorCode() int64
+	// ErrorData returns the data to send with the error,
+	// nil if there is no data to send.
+	ErrorData() any
+}
+
+// isResponseError determines whether an error returned by a handler
+// has been created with a specific error code.
+func isResponseError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var jsonrpcErr *jsonrpc2.Error
+	var responseErr ResponseError
+	return errors.As(err, &jsonrpcErr) || errors.As(err, &responseErr)
+}
+
+// toJSONRPCError converts an error returned by a handler into a JSON-RPC
+// error to respond to the client with.
+// JSON-RPC errors and errors that implement `ResponseError` are sent as they are,
+// requests that fail because they were cancelled by the client are sent with the
+// `RequestCancelled` code and any other errors are sent with the `RequestFailed` code.
+func (s *Server) toJSONRPCError(ctx context.Context, err error) *jsonrpc2.Error {
+	var jsonrpcErr *jsonrpc2.Error
+	if errors.As(err, &jsonrpcErr) {
+		return jsonrpcErr
+	}
+
+	var responseErr ResponseError
+	if errors.As(err, &responseErr) {
+		return s.responseErrorToJSONRPCError(err, responseErr)
+	}
+
+	if errors.Is(err, context.Canceled) && errors.Is(context.Cause(ctx), context.Canceled) {
+		return &jsonrpc2.Error{
+			Code:    CodeRequestCancelled,
+			Message: err.Error(),
+		}
+	}
+
+	return &jsonrpc2.Error{
+		Code:    CodeRequestFailed,
+		Message: err.Error(),
+	}
+}
+
+func (s *Server) responseErrorToJSONRPCError(err error, responseErr ResponseError) *jsonrpc2.Error {
+	jsonrpcErr := &jsonrpc2.Error{
+		Code: responseErr.ErrorCode(),
+		// Use the message of the original error so context added
+		// by wrapping the response error is not lost.
+		Message: err.Error(),
+	}
+
+	data := responseErr.ErrorData()
+	if data == nil {
+		return jsonrpcErr
+	}
+
+	rawData, marshalErr := json.Marshal(data)
+	if marshalErr != nil {
+		s.logger.Error(
+			"failed to encode error data, sending error response without data",
+			zap.Int64("code", jsonrpcErr.Code),
+			zap.Error(marshalErr),
+		)
+		return jsonrpcErr
+	}
+
+	jsonrpcErr.Data = (*json.RawMessage)(&rawData)
+	return jsonrpcErr
+}
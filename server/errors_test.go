@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"go.uber.org/zap"
+)
+
+type ErrorsTestSuite struct {
+	suite.Suite
+}
+
+func (s *ErrorsTestSuite) Test_responds_with_request_failed_for_plain_errors() {
+	jsonrpcErr := s.callWithHandlerError(errors.New("could not find definition"))
+	s.Assert().Equal(CodeRequestFailed, jsonrpcErr.Code)
+	s.Assert().Equal("could not find definition", jsonrpcErr.Message)
+	s.Assert().Nil(jsonrpcErr.Data)
+}
+
+func (s *ErrorsTestSuite) Test_responds_with_code_and_data_of_response_errors() {
+	jsonrpcErr := s.callWithHandlerError(
+		fmt.Errorf("computing diagnostics: %w", &testResponseError{
+			code:    -32802,
+			message: "server cancelled",
+			data:    map[string]any{"retriggerRequest": true},
+		}),
+	)
+	s.Assert().Equal(int64(-32802), jsonrpcErr.Code)
+	s.Assert().Equal("computing diagnostics: server cancelled", jsonrpcErr.Message)
+	s.Require().NotNil(jsonrpcErr.Data)
+	s.Assert().JSONEq(`{"retriggerRequest":true}`, string(*jsonrpcErr.Data))
+}
+
+func (s *ErrorsTestSuite) Test_responds_without_data_that_can_not_be_encoded() {
+	jsonrpcErr := s.callWithHandlerError(&testResponseError{
+		code:    -32801,
+		message: "content modified",
+		data:    make(chan int),
+	})
+	s.Assert().Equal(int64(-32801), jsonrpcErr.Code)
+	s.Assert().Nil(jsonrpcErr.Data)
+}
+
+func (s *ErrorsTestSuite) Test_responds_with_json_rpc_errors_as_they_are() {
+	jsonrpcErr := s.callWithHandlerError(&jsonrpc2.Error{
+		Code:    jsonrpc2.CodeInvalidRequest,
+		Message: "server is shutting down",
+	})
+	s.Assert().Equal(int64(jsonrpc2.CodeInvalidRequest), jsonrpcErr.Code)
+	s.Assert().Equal("server is shutting down", jsonrpcErr.Message)
+}
+
+func (s *ErrorsTestSuite) Test_converts_errors_for_requests_cancelled_by_client() {
+	server := NewServer(createCounterHandler(), false, zap.NewNop(), nil)
+
+	cancelledCtx, cancel := context.WithCancelCause(context.Background())
+	cancel(context.Canceled)
+	jsonrpcErr := server.toJSONRPCError(cancelledCtx, context.Canceled)
+	s.Assert().Equal(CodeRequestCancelled, jsonrpcErr.Code)
+
+	// Requests that time out have failed rather than been cancelled.
+	timedOutCtx, cancelTimeout := context.WithTimeout(context.Background(), 0)
+	defer cancelTimeout()
+	<-timedOutCtx.Done()
+	jsonrpcErr = server.toJSONRPCError(timedOutCtx, timedOutCtx.Err())
+	s.Assert().Equal(CodeRequestFailed, jsonrpcErr.Code)
+}
+
+func (s *ErrorsTestSuite) callWithHandlerError(handlerErr error) *jsonrpc2.Error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	handler := common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			return nil, true, true, handlerErr
+		},
+	)
+	server := NewServer(handler, false, zap.NewNop(), nil)
+	serverStream, clientStream := net.Pipe()
+	serverConn := NewStreamConnection(server.NewHandler(), serverStream)
+	go server.Serve(serverConn, zap.NewNop())
+
+	clientConn := NewStreamConnection(createClientHandler().handler, clientStream)
+	defer clientConn.Close()
+
+	err := clientConn.Call(ctx, "failingRequest", nil, nil)
+	jsonrpcErr := &jsonrpc2.Error{}
+	s.Require().ErrorAs(err, &jsonrpcErr)
+	return jsonrpcErr
+}
+
+type testResponseError struct {
+	code    int64
+	message string
+	data    any
+}
+
+func (e *testResponseError) Error() string {
+	return e.message
+}
+
+func (e *testResponseError) ErrorCode() int64 {
+	return e.code
+}
+
+func (e *testResponseError) ErrorData() any {
+	return e.data
+}
+
+func TestErrorsTestSuite(t *testing.T) {
+	suite.Run(t, new(ErrorsTestSuite))
+}
@@ -5,34 +5,83 @@ import (
 	"fmt"
 
 	"github.com/sourcegraph/jsonrpc2"
+	"github.com/two-hundred/ls-builder/common"
 )
 
 // NewHandler creates a handler from the server to handle
 // JSON-RPC requests.
+//
+// By default, messages are handled one at a time in the order they are received,
+// outside of the connection's read loop so that a `$/cancelRequest` notification
+// cancels the context of the in-flight request it is for as soon as it is received
+// and handlers can wait for responses to requests sent to the client.
+// See `WithConcurrentRequests` to handle requests concurrently.
 func (s *Server) NewHandler() jsonrpc2.Handler {
-	return jsonrpc2.HandlerWithError(s.handle)
+	return s.newHandler(s.handler, nil)
 }
 
-func (s *Server) handle(ctx context.Context, connection *jsonrpc2.Conn, request *jsonrpc2.Request) (any, error) {
-	reqCtx, cancel := context.WithTimeout(ctx, s.timeout)
-	defer cancel()
+// newHandler creates a handler for the provided handler, the wrap function
+// wraps the handler that handles each message before messages are scheduled
+// so that it is called when a message is actually being handled.
+func (s *Server) newHandler(
+	handler common.Handler,
+	wrap func(jsonrpc2.Handler) jsonrpc2.Handler,
+) jsonrpc2.Handler {
+	var messageHandler jsonrpc2.Handler = jsonrpc2.HandlerWithError(
+		func(ctx context.Context, connection *jsonrpc2.Conn, request *jsonrpc2.Request) (any, error) {
+			return s.handle(ctx, handler, connection, request)
+		},
+	)
+
+	if wrap != nil {
+		messageHandler = wrap(messageHandler)
+	}
+
+	return s.newMessageScheduler(messageHandler)
+}
+
+func (s *Server) handle(
+	ctx context.Context,
+	handler common.Handler,
+	connection *jsonrpc2.Conn,
+	request *jsonrpc2.Request,
+) (any, error) {
+	timeoutCtx, cancelTimeout := context.WithTimeout(ctx, s.timeout)
+	defer cancelTimeout()
+	reqCtx, cancel := context.WithCancelCause(timeoutCtx)
+	defer cancel(errMessageHandled)
+
+	if !request.Notif {
+		done := s.inFlight.track(connection, request.ID, func() {
+			cancel(context.Canceled)
+		})
+		defer done()
+	}
+
+	if request.Method == MethodCancelRequest {
+		// Cancel the context of the in-flight request before passing the
+		// notification on to the attached handler so that handlers
+		// of cancelled requests can stop work as soon as possible.
+		s.cancelInFlightRequest(connection, request)
+	}
 
 	lspContext := NewLSPContext(reqCtx, connection, request)
 
 	if request.Method == "exit" {
 		// Give the attached handler a chance to handle the request before closing the connection
 		// but ignore the result.
-		s.handler.Handle(lspContext)
+		handler.Handle(lspContext)
 		err := connection.Close()
 		return nil, err
 	}
 
 	// Note: jsonrpc2 will not get to this point if request.Params is not valid JSON,
 	// so there is no need to handle jsonrpc2.CodeParseErrors here.
-	result, validMethod, validParams, err := s.handler.Handle(lspContext)
-	if jsonrpcErr, isJSONRPCError := err.(*jsonrpc2.Error); isJSONRPCError {
-		// If a JSON-RPC error has already been created, return it directly.
-		return nil, jsonrpcErr
+	result, validMethod, validParams, err := handler.Handle(lspContext)
+	if isResponseError(err) {
+		// Errors that have been created with a specific error code
+		// are sent to the client as they are.
+		return nil, s.toJSONRPCError(reqCtx, err)
 	} else if !validMethod {
 		return nil, &jsonrpc2.Error{
 			Code:    jsonrpc2.CodeMethodNotFound,
@@ -50,10 +99,7 @@ func (s *Server) handle(ctx context.Context, connection *jsonrpc2.Conn, request
 			}
 		}
 	} else if err != nil {
-		return nil, &jsonrpc2.Error{
-			Code:    jsonrpc2.CodeInvalidRequest,
-			Message: err.Error(),
-		}
+		return nil, s.toJSONRPCError(reqCtx, err)
 	}
 
 	return result, nil
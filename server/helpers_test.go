@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/sourcegraph/jsonrpc2"
 	"github.com/two-hundred/ls-builder/common"
@@ -22,6 +23,20 @@ func getFreePort() (port int, err error) {
 	return
 }
 
+// waitForListener waits for a server to begin listening on the provided
+// address as transports are started in separate goroutines in tests.
+func waitForListener(address string) error {
+	var err error
+	for attempt := 0; attempt < 50; attempt += 1 {
+		var conn net.Conn
+		if conn, err = net.Dial("tcp", address); err == nil {
+			return conn.Close()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return err
+}
+
 func createCounterHandler() common.Handler {
 	return common.HandlerFunc(
 		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package server
+
+import (
+	"os"
+	"syscall"
+)
+
+// processExists determines whether a process with the provided ID is running.
+func processExists(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	// Sending signal 0 checks whether the process exists without
+	// sending a signal, a permission error means the process exists
+	// but is owned by another user.
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package server
+
+import (
+	"syscall"
+)
+
+// The exit code reported by GetExitCodeProcess for processes
+// that are still running.
+const stillActive = 259
+
+// processExists determines whether a process with the provided ID is running.
+// Opening a process succeeds for processes that have exited as long as a handle
+// to the process is still open elsewhere, so the exit code of the process
+// is checked to determine whether it is still running.
+func processExists(pid int) bool {
+	handle, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// An access denied error means the process exists
+		// but is owned by another user.
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(handle)
+
+	var exitCode uint32
+	if err = syscall.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return false
+	}
+	return exitCode == stillActive
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"go.uber.org/zap"
+)
+
+// MessageDirection is the direction of a JSON-RPC message
+// from the perspective of the server.
+type MessageDirection string
+
+const (
+	// MessageDirectionInbound is for messages sent from the client to the server.
+	MessageDirectionInbound MessageDirection = "in"
+	// MessageDirectionOutbound is for messages sent from the server to the client.
+	MessageDirectionOutbound MessageDirection = "out"
+)
+
+// RecordedMessage is a JSON-RPC message that has been recorded
+// as a line in a JSONL trace file.
+type RecordedMessage struct {
+	Time      time.Time        `json:"time"`
+	Direction MessageDirection `json:"direction"`
+	Message   json.RawMessage  `json:"message"`
+}
+
+// Recorder records every JSON-RPC message sent and received over
+// a connection as JSON lines, this is used to capture sessions
+// from real clients that can be replayed with `Replay`.
+//
+// A recorder should only be used for a single connection,
+// use `WithRecorder` to record the messages of a connection.
+type Recorder struct {
+	encoder *json.Encoder
+	clock   func() time.Time
+	err     error
+	mu      sync.Mutex
+}
+
+// RecorderOption is a function that configures a recorder.
+type RecorderOption func(*Recorder)
+
+// WithRecorderClock sets the function used to get the time a message
+// was sent or received, defaults to `time.Now`.
+func WithRecorderClock(clock func() time.Time) RecorderOption {
+	return func(r *Recorder) {
+		r.clock = clock
+	}
+}
+
+// NewRecorder creates a recorder that writes recorded messages
+// as JSON lines to the provided writer (e.g. a trace file).
+func NewRecorder(writer io.Writer, opts ...RecorderOption) *Recorder {
+	recorder := &Recorder{
+		encoder: json.NewEncoder(writer),
+		clock:   time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(recorder)
+	}
+
+	return recorder
+}
+
+// Err returns the first error that occurred writing a recorded message,
+// failing to record a message does not interrupt the connection.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(direction MessageDirection, message json.RawMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+
+	r.err = r.encoder.Encode(RecordedMessage{
+		Time:      r.clock(),
+		Direction: direction,
+		Message:   message,
+	})
+}
+
+// WithRecorder configures the connection to record every message
+// sent and received over the connection with the provided recorder.
+func WithRecorder(recorder *Recorder) ConnOption {
+	return func(c *connWrapper) {
+		c.recorder = recorder
+	}
+}
+
+// SessionRecordingFactory creates the writer that the messages of a session
+// are recorded to (e.g. a trace file for each session).
+// Returning a nil writer leaves the session unrecorded.
+type SessionRecordingFactory func(session *Session) (io.Writer, error)
+
+// WithSessionRecording configures the transports provided by this package
+// (e.g. `RunTCP`, `RunStdio` and `RunWebSocketServer`) to record every message
+// sent and received over the connection of each session to the writer created
+// for the session by the provided factory.
+// Writers that implement `io.Closer` are closed once the session has ended.
+//
+// Failing to create a writer for a session is logged and does not prevent
+// the connection from being served.
+func WithSessionRecording(factory SessionRecordingFactory, opts ...RecorderOption) ServerOption {
+	return func(s *Server) {
+		s.sessionRecording = factory
+		s.sessionRecorderOptions = opts
+	}
+}
+
+// startSessionRecording creates the recorder for a session
+// when the server has been configured to record sessions.
+func (s *Server) startSessionRecording(session *Session) {
+	if s.sessionRecording == nil {
+		return
+	}
+
+	writer, err := s.sessionRecording(session)
+	if err != nil {
+		session.Logger.Error("failed to create writer to record session", zap.Error(err))
+		return
+	}
+
+	if writer == nil {
+		return
+	}
+
+	session.recording = writer
+	session.recorder = NewRecorder(writer, s.sessionRecorderOptions...)
+}
+
+func endSessionRecording(session *Session) {
+	closer, isCloser := session.recording.(io.Closer)
+	if !isCloser {
+		return
+	}
+
+	callAndLog(closer.Close, "recording.Close", session.Logger)
+}
+
+// recordingStream wraps an object stream to record the messages
+// that are read from and written to the stream.
+type recordingStream struct {
+	stream   jsonrpc2.ObjectStream
+	recorder *Recorder
+}
+
+func (s *recordingStream) WriteObject(obj any) error {
+	message, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	err = s.stream.WriteObject(json.RawMessage(message))
+	if err != nil {
+		return err
+	}
+
+	s.recorder.record(MessageDirectionOutbound, message)
+	return nil
+}
+
+func (s *recordingStream) ReadObject(v any) error {
+	var message json.RawMessage
+	err := s.stream.ReadObject(&message)
+	if err != nil {
+		return err
+	}
+
+	s.recorder.record(MessageDirectionInbound, message)
+	return json.Unmarshal(message, v)
+}
+
+func (s *recordingStream) Close() error {
+	return s.stream.Close()
+}
+
+// ReadRecording reads the messages recorded in JSONL format by a `Recorder`.
+func ReadRecording(reader io.Reader) ([]RecordedMessage, error) {
+	decoder := json.NewDecoder(reader)
+	messages := []RecordedMessage{}
+	for {
+		var message RecordedMessage
+		err := decoder.Decode(&message)
+		if err == io.EOF {
+			return messages, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type RecordingTestSuite struct {
+	suite.Suite
+}
+
+func (s *RecordingTestSuite) Test_records_inbound_and_outbound_messages() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	recordedAt := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	trace := &bytes.Buffer{}
+	recorder := NewRecorder(trace, WithRecorderClock(func() time.Time { return recordedAt }))
+
+	logger := zap.NewNop()
+	server := NewServer(createCounterHandler(), false, logger, nil)
+	serverStream, clientStream := net.Pipe()
+	serverConn := NewStreamConnection(server.NewHandler(), serverStream, WithRecorder(recorder))
+	go server.Serve(serverConn, logger)
+
+	clientConn := NewStreamConnection(createClientHandler().handler, clientStream)
+
+	result := testCountResult{}
+	err := clientConn.Call(ctx, "increment", testCountParams{Count: 2}, &result)
+	s.Require().NoError(err)
+	s.Require().NoError(clientConn.Close())
+	<-serverConn.DisconnectNotify()
+
+	s.Require().NoError(recorder.Err())
+	recording, err := ReadRecording(trace)
+	s.Require().NoError(err)
+	s.Require().Len(recording, 2)
+
+	s.Assert().Equal(MessageDirectionInbound, recording[0].Direction)
+	s.Assert().True(recordedAt.Equal(recording[0].Time))
+	s.Assert().JSONEq(
+		`{"jsonrpc":"2.0","id":0,"method":"increment","params":{"count":2}}`,
+		string(recording[0].Message),
+	)
+
+	s.Assert().Equal(MessageDirectionOutbound, recording[1].Direction)
+	s.Assert().True(recordedAt.Equal(recording[1].Time))
+	s.Assert().JSONEq(
+		`{"jsonrpc":"2.0","id":0,"result":{"count":3,"prevCount":2}}`,
+		string(recording[1].Message),
+	)
+}
+
+func (s *RecordingTestSuite) Test_records_sessions_served_by_transports() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer listener.Close()
+
+	trace := &closableBuffer{}
+	sessionIDs := []string{}
+	server := NewServer(
+		createCounterHandler(),
+		false,
+		zap.NewNop(),
+		nil,
+		WithSessionRecording(func(session *Session) (io.Writer, error) {
+			sessionIDs = append(sessionIDs, session.ID)
+			return trace, nil
+		}),
+	)
+	stopped := runInBackground(func() error {
+		return ConnectTCP(context.Background(), listener.Addr().String(), server, zap.NewNop())
+	})
+
+	connection, err := listener.Accept()
+	s.Require().NoError(err)
+	clientConn := NewStreamConnection(createClientHandler().handler, connection)
+
+	result := testCountResult{}
+	err = clientConn.Call(context.Background(), "increment", testCountParams{Count: 4}, &result)
+	s.Require().NoError(err)
+	s.Require().NoError(clientConn.Close())
+	s.Require().NoError(waitForResult(stopped))
+
+	s.Assert().Equal([]string{"1"}, sessionIDs)
+	s.Assert().True(trace.closed)
+	recording, err := ReadRecording(&trace.Buffer)
+	s.Require().NoError(err)
+	s.Require().Len(recording, 2)
+	s.Assert().JSONEq(
+		`{"jsonrpc":"2.0","id":0,"method":"increment","params":{"count":4}}`,
+		string(recording[0].Message),
+	)
+	s.Assert().JSONEq(
+		`{"jsonrpc":"2.0","id":0,"result":{"count":5,"prevCount":4}}`,
+		string(recording[1].Message),
+	)
+}
+
+func (s *RecordingTestSuite) Test_keeps_first_write_error() {
+	recorder := NewRecorder(&failingWriter{})
+	recorder.record(MessageDirectionInbound, json.RawMessage(`{}`))
+	recorder.record(MessageDirectionOutbound, json.RawMessage(`{}`))
+	s.Require().ErrorIs(recorder.Err(), errWriteFailed)
+}
+
+func (s *RecordingTestSuite) Test_fails_to_read_invalid_recording() {
+	_, err := ReadRecording(bytes.NewBufferString("{\"direction\":\"in\"}\nnot json\n"))
+	s.Require().Error(err)
+}
+
+var errWriteFailed = errors.New("write failed")
+
+type failingWriter struct{}
+
+func (w *failingWriter) Write(data []byte) (int, error) {
+	return 0, errWriteFailed
+}
+
+type closableBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closableBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestRecordingTestSuite(t *testing.T) {
+	suite.Run(t, new(RecordingTestSuite))
+}
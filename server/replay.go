@@ -0,0 +1,379 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/two-hundred/ls-builder/common"
+	"go.uber.org/zap"
+)
+
+// DefaultReplayTimeout is the default amount of time to wait for the server
+// to send a response or a request to the client when replaying a recording.
+const DefaultReplayTimeout = 5 * time.Second
+
+// ReplayOption is a function that configures how a recording is replayed.
+type ReplayOption func(*replayOptions)
+
+type replayOptions struct {
+	timeout time.Duration
+	logger  *zap.Logger
+}
+
+// WithReplayTimeout sets the amount of time to wait for the server to send
+// a response or a request to the client, defaults to `DefaultReplayTimeout`.
+func WithReplayTimeout(timeout time.Duration) ReplayOption {
+	return func(opts *replayOptions) {
+		opts.timeout = timeout
+	}
+}
+
+// WithReplayLogger sets the logger for the server that handles
+// the replayed messages, a no-op logger is used by default.
+func WithReplayLogger(logger *zap.Logger) ReplayOption {
+	return func(opts *replayOptions) {
+		opts.logger = logger
+	}
+}
+
+// ReplayDifference is a response from the server that differs from
+// the response in the recording, either the expected or actual response will be
+// nil if the response is missing from the recording or was not sent during the replay.
+type ReplayDifference struct {
+	// The ID of the request from the client
+	// or of the request from the server when the server did not send
+	// a request to the client that is in the recording.
+	ID       jsonrpc2.ID
+	Method   string
+	Expected json.RawMessage
+	Actual   json.RawMessage
+}
+
+// ReplayResult holds the outcome of replaying a recording.
+type ReplayResult struct {
+	// The number of requests from the client that were replayed.
+	Requests    int
+	Differences []ReplayDifference
+}
+
+// HasDifferences determines whether any of the responses
+// from the server differ from the recording.
+func (r *ReplayResult) HasDifferences() bool {
+	return len(r.Differences) > 0
+}
+
+// Replay feeds the client side of a recording made with a `Recorder`
+// to the provided handler and compares the responses from the server
+// with the responses in the recording.
+//
+// Requests and notifications from the client are sent in the order they were recorded,
+// responses from the client to requests from the server are sent once the server has
+// sent the matching request, so server requests are matched by their order and method
+// rather than their ID.
+// The time messages were recorded at is ignored to keep replays deterministic.
+func Replay(handler common.Handler, recording []RecordedMessage, opts ...ReplayOption) (*ReplayResult, error) {
+	options := &replayOptions{
+		timeout: DefaultReplayTimeout,
+		logger:  zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	expectedResponses, serverRequestMethods, err := indexRecording(recording)
+	if err != nil {
+		return nil, err
+	}
+
+	serverStream, clientStream := net.Pipe()
+	srv := NewServer(handler, false, options.logger, nil)
+	serverConn := NewStreamConnection(srv.NewHandler(), serverStream)
+	defer serverConn.Close()
+	go srv.Serve(serverConn, options.logger)
+
+	client := newReplayClient(
+		jsonrpc2.NewBufferedStream(clientStream, jsonrpc2.VSCodeObjectCodec{}),
+		options.timeout,
+	)
+	go client.readMessages()
+
+	result := &ReplayResult{Differences: []ReplayDifference{}}
+	pendingRequests := []replayEnvelope{}
+	for _, recorded := range recording {
+		if recorded.Direction != MessageDirectionInbound {
+			continue
+		}
+
+		envelope, err := parseReplayEnvelope(recorded.Message)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case envelope.isResponse():
+			difference, err := client.respondToServerRequest(
+				envelope,
+				recorded.Message,
+				serverRequestMethods[envelope.ID.String()],
+			)
+			if err != nil {
+				return nil, err
+			}
+			if difference != nil {
+				result.Differences = append(result.Differences, *difference)
+			}
+		case envelope.isRequest():
+			pendingRequests = append(pendingRequests, envelope)
+			err = client.write(recorded.Message)
+		default:
+			err = client.write(recorded.Message)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, request := range pendingRequests {
+		result.Requests += 1
+		expected := expectedResponses[request.ID.String()]
+		actual := client.waitForResponse(request.ID)
+		if !equalResponses(expected, actual) {
+			result.Differences = append(result.Differences, ReplayDifference{
+				ID:       *request.ID,
+				Method:   request.Method,
+				Expected: expected,
+				Actual:   actual,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// indexRecording collects the responses the server sent to requests
+// from the client and the methods of the requests the server sent to the client,
+// both keyed by request ID.
+func indexRecording(recording []RecordedMessage) (map[string]json.RawMessage, map[string]string, error) {
+	responses := map[string]json.RawMessage{}
+	serverRequestMethods := map[string]string{}
+	for _, recorded := range recording {
+		if recorded.Direction != MessageDirectionOutbound {
+			continue
+		}
+
+		envelope, err := parseReplayEnvelope(recorded.Message)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if envelope.isResponse() {
+			responses[envelope.ID.String()] = recorded.Message
+		} else if envelope.isRequest() {
+			serverRequestMethods[envelope.ID.String()] = envelope.Method
+		}
+	}
+	return responses, serverRequestMethods, nil
+}
+
+type replayEnvelope struct {
+	ID     *jsonrpc2.ID `json:"id"`
+	Method string       `json:"method"`
+}
+
+func parseReplayEnvelope(message json.RawMessage) (replayEnvelope, error) {
+	envelope := replayEnvelope{}
+	err := json.Unmarshal(message, &envelope)
+	if err != nil {
+		return envelope, fmt.Errorf("invalid recorded message: %w", err)
+	}
+	return envelope, nil
+}
+
+func (e replayEnvelope) isRequest() bool {
+	return e.Method != "" && e.ID != nil
+}
+
+func (e replayEnvelope) isResponse() bool {
+	return e.Method == "" && e.ID != nil
+}
+
+// equalResponses compares responses ignoring the formatting
+// and order of fields.
+func equalResponses(expected json.RawMessage, actual json.RawMessage) bool {
+	if expected == nil || actual == nil {
+		return expected == nil && actual == nil
+	}
+
+	var expectedValue any
+	var actualValue any
+	if json.Unmarshal(expected, &expectedValue) != nil || json.Unmarshal(actual, &actualValue) != nil {
+		return false
+	}
+	return reflect.DeepEqual(expectedValue, actualValue)
+}
+
+// replayClient plays the part of the client in a replay, keeping track
+// of the responses and requests the server sends.
+type replayClient struct {
+	stream  jsonrpc2.ObjectStream
+	timeout time.Duration
+	// Responses from the server keyed by request ID.
+	responses map[string]json.RawMessage
+	// Requests from the server that have not been responded to yet.
+	serverRequests []replayServerRequest
+	closed         bool
+	// Closed and replaced every time a message is received
+	// to wake up goroutines waiting for messages.
+	received chan struct{}
+	mu       sync.Mutex
+}
+
+type replayServerRequest struct {
+	id     jsonrpc2.ID
+	method string
+}
+
+func newReplayClient(stream jsonrpc2.ObjectStream, timeout time.Duration) *replayClient {
+	return &replayClient{
+		stream:         stream,
+		timeout:        timeout,
+		responses:      map[string]json.RawMessage{},
+		serverRequests: []replayServerRequest{},
+		received:       make(chan struct{}),
+	}
+}
+
+func (c *replayClient) readMessages() {
+	for {
+		var message json.RawMessage
+		err := c.stream.ReadObject(&message)
+
+		c.mu.Lock()
+		if err == nil {
+			c.addMessage(message)
+		} else {
+			c.closed = true
+		}
+		close(c.received)
+		c.received = make(chan struct{})
+		c.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *replayClient) addMessage(message json.RawMessage) {
+	envelope, err := parseReplayEnvelope(message)
+	if err != nil {
+		return
+	}
+
+	if envelope.isResponse() {
+		c.responses[envelope.ID.String()] = message
+	} else if envelope.isRequest() {
+		c.serverRequests = append(c.serverRequests, replayServerRequest{
+			id:     *envelope.ID,
+			method: envelope.Method,
+		})
+	}
+}
+
+func (c *replayClient) write(message json.RawMessage) error {
+	return c.stream.WriteObject(message)
+}
+
+// respondToServerRequest waits for the server to send a request with the method
+// of the recorded request the response is for and sends the recorded response
+// with the ID of the request sent during the replay.
+func (c *replayClient) respondToServerRequest(
+	response replayEnvelope,
+	message json.RawMessage,
+	method string,
+) (*ReplayDifference, error) {
+	request, found := c.waitForServerRequest(method)
+	if !found {
+		return &ReplayDifference{
+			ID:       *response.ID,
+			Method:   method,
+			Expected: message,
+		}, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal(message, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recorded message: %w", err)
+	}
+
+	fields["id"], err = json.Marshal(request.id)
+	if err != nil {
+		return nil, err
+	}
+
+	rewritten, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return nil, c.write(rewritten)
+}
+
+func (c *replayClient) waitForServerRequest(method string) (replayServerRequest, bool) {
+	var request replayServerRequest
+	found := c.waitFor(func() bool {
+		for i, serverRequest := range c.serverRequests {
+			if serverRequest.method == method {
+				request = serverRequest
+				c.serverRequests = append(c.serverRequests[:i], c.serverRequests[i+1:]...)
+				return true
+			}
+		}
+		return false
+	})
+	return request, found
+}
+
+func (c *replayClient) waitForResponse(id *jsonrpc2.ID) json.RawMessage {
+	var response json.RawMessage
+	c.waitFor(func() bool {
+		response = c.responses[id.String()]
+		return response != nil
+	})
+	return response
+}
+
+// waitFor waits until the provided condition is met, the connection is closed
+// or the timeout is reached, the condition is checked while holding the lock.
+func (c *replayClient) waitFor(condition func() bool) bool {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	for {
+		c.mu.Lock()
+		met := condition()
+		closed := c.closed
+		received := c.received
+		c.mu.Unlock()
+
+		if met {
+			return true
+		}
+
+		if closed {
+			return false
+		}
+
+		select {
+		case <-received:
+		case <-timer.C:
+			return false
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+)
+
+type ReplayTestSuite struct {
+	suite.Suite
+}
+
+func (s *ReplayTestSuite) Test_replays_recording_without_differences() {
+	result, err := Replay(createCounterHandler(), counterRecording())
+	s.Require().NoError(err)
+	s.Assert().Equal(2, result.Requests)
+	s.Assert().False(result.HasDifferences())
+}
+
+func (s *ReplayTestSuite) Test_reports_responses_that_differ_from_recording() {
+	handler := common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			validMethod = true
+			validParams = true
+			r = testCountResult{Count: 10, PrevCount: 9}
+			return
+		},
+	)
+
+	result, err := Replay(handler, counterRecording())
+	s.Require().NoError(err)
+	s.Assert().Equal(2, result.Requests)
+	s.Require().Len(result.Differences, 2)
+	s.Assert().Equal("increment", result.Differences[0].Method)
+	s.Assert().JSONEq(
+		`{"jsonrpc":"2.0","id":1,"result":{"count":3,"prevCount":2}}`,
+		string(result.Differences[0].Expected),
+	)
+	s.Assert().JSONEq(
+		`{"jsonrpc":"2.0","id":1,"result":{"count":10,"prevCount":9}}`,
+		string(result.Differences[0].Actual),
+	)
+}
+
+func (s *ReplayTestSuite) Test_responds_to_requests_from_server() {
+	values := make(chan string, 1)
+	handler := common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			validMethod = true
+			validParams = true
+			switch ctx.Method {
+			case "loadValue":
+				var value string
+				if ctx.Call("client/value", nil, &value) == nil {
+					values <- value
+				}
+			case "value":
+				select {
+				case r = <-values:
+				case <-time.After(time.Second):
+				}
+			}
+			return
+		},
+	)
+
+	result, err := Replay(handler, []RecordedMessage{
+		recordedMessage(MessageDirectionInbound, `{"jsonrpc":"2.0","method":"loadValue"}`),
+		recordedMessage(MessageDirectionOutbound, `{"jsonrpc":"2.0","id":42,"method":"client/value"}`),
+		recordedMessage(MessageDirectionInbound, `{"jsonrpc":"2.0","id":42,"result":"recorded value"}`),
+		recordedMessage(MessageDirectionInbound, `{"jsonrpc":"2.0","id":1,"method":"value"}`),
+		recordedMessage(MessageDirectionOutbound, `{"jsonrpc":"2.0","id":1,"result":"recorded value"}`),
+	})
+	s.Require().NoError(err)
+	s.Assert().Equal(1, result.Requests)
+	s.Assert().False(result.HasDifferences())
+}
+
+func (s *ReplayTestSuite) Test_reports_request_from_server_that_was_not_sent() {
+	result, err := Replay(
+		createCounterHandler(),
+		[]RecordedMessage{
+			recordedMessage(MessageDirectionOutbound, `{"jsonrpc":"2.0","id":0,"method":"client/value"}`),
+			recordedMessage(MessageDirectionInbound, `{"jsonrpc":"2.0","id":0,"result":"recorded value"}`),
+		},
+		WithReplayTimeout(50*time.Millisecond),
+	)
+	s.Require().NoError(err)
+	s.Require().Len(result.Differences, 1)
+	s.Assert().Equal("client/value", result.Differences[0].Method)
+	s.Assert().Nil(result.Differences[0].Actual)
+}
+
+func (s *ReplayTestSuite) Test_fails_for_invalid_recorded_message() {
+	_, err := Replay(createCounterHandler(), []RecordedMessage{
+		recordedMessage(MessageDirectionInbound, `"not a message"`),
+	})
+	s.Require().Error(err)
+}
+
+func counterRecording() []RecordedMessage {
+	return []RecordedMessage{
+		recordedMessage(MessageDirectionInbound, `{"jsonrpc":"2.0","id":1,"method":"increment","params":{"count":2}}`),
+		recordedMessage(MessageDirectionOutbound, `{"jsonrpc":"2.0","id":1,"result":{"count":3,"prevCount":2}}`),
+		recordedMessage(MessageDirectionInbound, `{"jsonrpc":"2.0","method":"$/ignored"}`),
+		recordedMessage(MessageDirectionInbound, `{"jsonrpc":"2.0","id":"second","method":"increment","params":{"count":7}}`),
+		recordedMessage(MessageDirectionOutbound, `{"id":"second","result":{"prevCount":7,"count":8},"jsonrpc":"2.0"}`),
+	}
+}
+
+func recordedMessage(direction MessageDirection, message string) RecordedMessage {
+	return RecordedMessage{
+		Time:      time.Now(),
+		Direction: direction,
+		Message:   json.RawMessage(message),
+	}
+}
+
+func TestReplayTestSuite(t *testing.T) {
+	suite.Run(t, new(ReplayTestSuite))
+}
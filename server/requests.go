@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// MethodCancelRequest is the LSP method used by both clients and servers
+// to cancel a request that is in flight.
+// This is the same across all versions of LSP that ls-builder supports,
+// so it is handled by the server to cancel the context of in-flight requests.
+const MethodCancelRequest = "$/cancelRequest"
+
+type inFlightRequestKey struct {
+	conn *jsonrpc2.Conn
+	id   jsonrpc2.ID
+}
+
+// requestTracker keeps track of requests that are currently being handled
+// so that they can be cancelled when a client sends a `$/cancelRequest`
+// notification.
+// Request IDs are only unique within a connection, so requests are tracked
+// per connection.
+type requestTracker struct {
+	inFlight map[inFlightRequestKey]context.CancelFunc
+	mu       sync.Mutex
+}
+
+func newRequestTracker() *requestTracker {
+	return &requestTracker{
+		inFlight: make(map[inFlightRequestKey]context.CancelFunc),
+	}
+}
+
+// track registers the cancel function for an in-flight request
+// and returns a function that must be called when the request has been handled.
+func (t *requestTracker) track(conn *jsonrpc2.Conn, id jsonrpc2.ID, cancel context.CancelFunc) func() {
+	key := inFlightRequestKey{conn: conn, id: id}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight[key] = cancel
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.inFlight, key)
+	}
+}
+
+// cancel cancels the context of the in-flight request with the provided ID
+// for the given connection, returning whether or not a matching request was found.
+func (t *requestTracker) cancel(conn *jsonrpc2.Conn, id jsonrpc2.ID) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cancel, ok := t.inFlight[inFlightRequestKey{conn: conn, id: id}]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// cancelConnection cancels the context of every in-flight request
+// for the given connection.
+func (t *requestTracker) cancelConnection(conn *jsonrpc2.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, cancel := range t.inFlight {
+		if key.conn == conn {
+			cancel()
+		}
+	}
+}
+
+type cancelParams struct {
+	ID jsonrpc2.ID `json:"id"`
+}
+
+func (s *Server) cancelInFlightRequest(conn *jsonrpc2.Conn, request *jsonrpc2.Request) {
+	if request.Params == nil {
+		return
+	}
+
+	params := cancelParams{}
+	if err := json.Unmarshal(*request.Params, &params); err != nil {
+		// Malformed cancellations are left to the attached handler
+		// to report on, there is nothing to cancel.
+		return
+	}
+
+	s.inFlight.cancel(conn, params.ID)
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/two-hundred/ls-builder/common"
+)
+
+// WithConcurrentRequests configures the handlers created by the server to handle
+// up to the provided number of requests at the same time for each connection,
+// so a slow request does not block other requests from the same client.
+//
+// Notifications (e.g. `textDocument/didOpen`, `textDocument/didChange` and `textDocument/didClose`)
+// are still handled one at a time in the order they are received, `$/cancelRequest` notifications
+// are handled as soon as they are received so requests waiting to be handled can be cancelled.
+// Requests that need to see the latest version of documents should be declared with
+// `WithDocumentDependentMethods`.
+//
+// By default, messages are handled one at a time in the order they are received
+// with `$/cancelRequest` notifications handled as soon as they are received,
+// a limit less than 1 keeps the default behaviour.
+func WithConcurrentRequests(limit int) ServerOption {
+	return func(s *Server) {
+		s.concurrentRequests = limit
+	}
+}
+
+// WithDocumentDependentMethods declares the methods of requests that depend on the latest
+// version of documents (e.g. `textDocument/hover` or `textDocument/references`).
+// When requests are handled concurrently, these requests are only handled once every
+// notification received before the request has been handled so that document changes sent
+// by the client before the request have been applied.
+func WithDocumentDependentMethods(methods ...string) ServerOption {
+	return func(s *Server) {
+		if s.documentDependentMethods == nil {
+			s.documentDependentMethods = map[string]struct{}{}
+		}
+
+		for _, method := range methods {
+			s.documentDependentMethods[method] = struct{}{}
+		}
+	}
+}
+
+// messageScheduler is a JSON-RPC handler that handles requests concurrently
+// up to a limit while handling notifications in order.
+// When the limit is less than 1, requests are handled in order along with
+// notifications.
+//
+// Messages are always handled outside of the connection's read loop so that
+// `$/cancelRequest` notifications and responses to requests the server sends to the client
+// are read while a message is being handled.
+//
+// jsonrpc2 calls Handle for each message in the order they are received
+// from the connection's read loop, so ordering decisions made in Handle
+// reflect the order messages were sent by the client.
+// The order is attached to the context of each message, see `common.MessageOrder`.
+type messageScheduler struct {
+	server      *Server
+	handler     jsonrpc2.Handler
+	limit       int
+	connections map[*jsonrpc2.Conn]*connectionSchedule
+	mu          sync.Mutex
+}
+
+func (s *Server) newMessageScheduler(handler jsonrpc2.Handler) *messageScheduler {
+	return &messageScheduler{
+		server:      s,
+		handler:     handler,
+		limit:       s.concurrentRequests,
+		connections: map[*jsonrpc2.Conn]*connectionSchedule{},
+	}
+}
+
+func (m *messageScheduler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if req.Method == MethodCancelRequest {
+		// Cancellations bypass the queue so they can cancel requests
+		// that are still waiting to be handled.
+		m.handler.Handle(ctx, conn, req)
+		return
+	}
+
+	schedule := m.connectionSchedule(conn)
+	ctx = common.WithMessageOrder(ctx, schedule.receive(req.Notif))
+	if req.Notif {
+		schedule.enqueueOrdered(func() {
+			m.handler.Handle(ctx, conn, req)
+		})
+		return
+	}
+
+	// Requests waiting to be handled are tracked so that they can be
+	// cancelled by the client before they are handled.
+	reqCtx, cancel := context.WithCancel(ctx)
+	done := m.server.inFlight.track(conn, req.ID, cancel)
+	if m.limit < 1 {
+		schedule.enqueueOrdered(func() {
+			defer cancel()
+			defer done()
+
+			if reqCtx.Err() != nil {
+				m.replyCancelled(reqCtx, conn, req)
+				return
+			}
+
+			m.handler.Handle(reqCtx, conn, req)
+		})
+		return
+	}
+
+	_, dependsOnDocuments := m.server.documentDependentMethods[req.Method]
+	// The number of ordered messages received before the request,
+	// captured in the read loop so later notifications are not waited for.
+	orderedBefore := schedule.orderedCount()
+
+	go func() {
+		defer cancel()
+		defer done()
+
+		if dependsOnDocuments && !schedule.waitForOrdered(reqCtx, orderedBefore) {
+			m.replyCancelled(reqCtx, conn, req)
+			return
+		}
+
+		if !schedule.acquire(reqCtx) {
+			m.replyCancelled(reqCtx, conn, req)
+			return
+		}
+		defer schedule.release()
+
+		m.handler.Handle(reqCtx, conn, req)
+	}()
+}
+
+func (m *messageScheduler) replyCancelled(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	err := conn.ReplyWithError(context.WithoutCancel(ctx), req.ID, &jsonrpc2.Error{
+		Code:    CodeRequestCancelled,
+		Message: "request cancelled before it was handled",
+	})
+	if err != nil && m.server.logger != nil {
+		m.server.logger.Debug("failed to reply to cancelled request: " + err.Error())
+	}
+}
+
+func (m *messageScheduler) connectionSchedule(conn *jsonrpc2.Conn) *connectionSchedule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	schedule, ok := m.connections[conn]
+	if ok {
+		return schedule
+	}
+
+	schedule = newConnectionSchedule(m.limit)
+	m.connections[conn] = schedule
+	go func() {
+		<-conn.DisconnectNotify()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.connections, conn)
+	}()
+	return schedule
+}
+
+// connectionSchedule holds the scheduling state for a single connection.
+type connectionSchedule struct {
+	// Limits the number of requests handled at the same time.
+	semaphore chan struct{}
+	// Ordered messages waiting to be handled.
+	queue []func()
+	// Whether a goroutine is handling the ordered messages in the queue.
+	draining bool
+	// The number of ordered messages that have been received.
+	enqueued uint64
+	// The number of ordered messages that have been handled.
+	handled uint64
+	// The number of notifications that have been received.
+	notifications uint64
+	// Closed and replaced every time an ordered message has been handled
+	// to wake up requests waiting for ordered messages.
+	handledSignal chan struct{}
+	mu            sync.Mutex
+}
+
+func newConnectionSchedule(limit int) *connectionSchedule {
+	return &connectionSchedule{
+		// The semaphore is not used when requests are handled in order.
+		semaphore:     make(chan struct{}, max(limit, 0)),
+		queue:         []func(){},
+		handledSignal: make(chan struct{}),
+	}
+}
+
+// receive notes that a message has been received in the read loop,
+// returning the order of the message (see `common.MessageOrder`).
+func (c *connectionSchedule) receive(isNotification bool) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if isNotification {
+		c.notifications += 1
+	}
+	return c.notifications
+}
+
+func (c *connectionSchedule) orderedCount() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enqueued
+}
+
+func (c *connectionSchedule) enqueueOrdered(handle func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enqueued += 1
+	c.queue = append(c.queue, handle)
+	if !c.draining {
+		c.draining = true
+		go c.drainOrdered()
+	}
+}
+
+func (c *connectionSchedule) drainOrdered() {
+	for {
+		c.mu.Lock()
+		if len(c.queue) == 0 {
+			c.draining = false
+			c.mu.Unlock()
+			return
+		}
+		handle := c.queue[0]
+		c.queue = c.queue[1:]
+		c.mu.Unlock()
+
+		handle()
+
+		c.mu.Lock()
+		c.handled += 1
+		close(c.handledSignal)
+		c.handledSignal = make(chan struct{})
+		c.mu.Unlock()
+	}
+}
+
+// waitForOrdered waits until at least the provided number of ordered messages
+// have been handled, returning false if the context is done first.
+func (c *connectionSchedule) waitForOrdered(ctx context.Context, count uint64) bool {
+	for {
+		c.mu.Lock()
+		handled := c.handled
+		signal := c.handledSignal
+		c.mu.Unlock()
+
+		if handled >= count {
+			return true
+		}
+
+		select {
+		case <-signal:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (c *connectionSchedule) acquire(ctx context.Context) bool {
+	select {
+	case c.semaphore <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *connectionSchedule) release() {
+	<-c.semaphore
+}
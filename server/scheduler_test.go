@@ -0,0 +1,288 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"go.uber.org/zap"
+)
+
+type SchedulerTestSuite struct {
+	suite.Suite
+}
+
+func (s *SchedulerTestSuite) Test_handles_requests_concurrently() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	documents := newTestDocuments()
+	defer documents.releaseSlowRequests()
+	clientConn := s.connect(documents.handler(), WithConcurrentRequests(2))
+
+	slowResponse := make(chan error, 1)
+	go func() {
+		slowResponse <- clientConn.Call(ctx, "slow", nil, nil)
+	}()
+	documents.waitForSlowRequest(s)
+
+	// The fast request is handled while the slow request is still being handled.
+	count := 0
+	s.Require().NoError(clientConn.Call(ctx, "count", nil, &count))
+	s.Assert().Equal(0, count)
+
+	documents.releaseSlowRequests()
+	s.Require().NoError(<-slowResponse)
+}
+
+func (s *SchedulerTestSuite) Test_limits_requests_handled_concurrently_and_cancels_queued_requests() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	documents := newTestDocuments()
+	defer documents.releaseSlowRequests()
+	clientConn := s.connect(documents.handler(), WithConcurrentRequests(1))
+
+	slowResponse := make(chan error, 1)
+	go func() {
+		slowResponse <- clientConn.Call(ctx, "slow", nil, nil)
+	}()
+	documents.waitForSlowRequest(s)
+
+	// The second request waits for the slow request as the limit has been reached,
+	// the cancellation bypasses the queue and cancels the waiting request.
+	queuedID := jsonrpc2.ID{Str: "queued", IsString: true}
+	call, err := clientConn.DispatchCall(ctx, "count", nil, jsonrpc2.PickID(queuedID))
+	s.Require().NoError(err)
+	s.Require().NoError(clientConn.Notify(ctx, MethodCancelRequest, cancelParams{ID: queuedID}))
+
+	err = call.Wait(ctx, nil)
+	jsonrpcErr, isJSONRPCErr := err.(*jsonrpc2.Error)
+	s.Require().True(isJSONRPCErr, "expected a JSON-RPC error, got %v", err)
+	s.Assert().Equal(CodeRequestCancelled, jsonrpcErr.Code)
+	s.Assert().Equal(0, documents.countCalls())
+
+	documents.releaseSlowRequests()
+	s.Require().NoError(<-slowResponse)
+}
+
+func (s *SchedulerTestSuite) Test_cancels_in_flight_request_while_notifications_are_being_handled() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	documents := newTestDocuments()
+	defer documents.releaseSlowRequests()
+	clientConn := s.connect(documents.handler(), WithConcurrentRequests(2))
+
+	slowID := jsonrpc2.ID{Str: "slow-request", IsString: true}
+	call, err := clientConn.DispatchCall(ctx, "slowUntilCancelled", nil, jsonrpc2.PickID(slowID))
+	s.Require().NoError(err)
+	documents.waitForSlowRequest(s)
+
+	// Block the ordered notifications to make sure the cancellation does not wait for them.
+	s.Require().NoError(clientConn.Notify(ctx, "slowNotification", nil))
+	s.Require().NoError(clientConn.Notify(ctx, MethodCancelRequest, cancelParams{ID: slowID}))
+
+	err = call.Wait(ctx, nil)
+	jsonrpcErr, isJSONRPCErr := err.(*jsonrpc2.Error)
+	s.Require().True(isJSONRPCErr, "expected a JSON-RPC error, got %v", err)
+	s.Assert().Equal(CodeRequestCancelled, jsonrpcErr.Code)
+}
+
+func (s *SchedulerTestSuite) Test_handles_notifications_in_order() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	documents := newTestDocuments()
+	clientConn := s.connect(
+		documents.handler(),
+		WithConcurrentRequests(4),
+		WithDocumentDependentMethods("changes"),
+	)
+
+	expected := []int{}
+	for version := 1; version <= 20; version += 1 {
+		expected = append(expected, version)
+		s.Require().NoError(clientConn.Notify(ctx, "didChange", version))
+	}
+
+	// The document dependent request only sees the document once every
+	// change sent before it has been applied.
+	changes := []int{}
+	s.Require().NoError(clientConn.Call(ctx, "changes", nil, &changes))
+	s.Assert().Equal(expected, changes)
+}
+
+func (s *SchedulerTestSuite) Test_handles_messages_in_order_without_concurrency() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	documents := newTestDocuments()
+	clientConn := s.connect(documents.handler())
+
+	for version := 1; version <= 5; version += 1 {
+		s.Require().NoError(clientConn.Notify(ctx, "didChange", version))
+	}
+
+	changes := []int{}
+	s.Require().NoError(clientConn.Call(ctx, "changes", nil, &changes))
+	s.Assert().Equal([]int{1, 2, 3, 4, 5}, changes)
+}
+
+func (s *SchedulerTestSuite) Test_cancels_queued_request_without_concurrency() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	documents := newTestDocuments()
+	defer documents.releaseSlowRequests()
+	clientConn := s.connect(documents.handler())
+
+	slowResponse := make(chan error, 1)
+	go func() {
+		slowResponse <- clientConn.Call(ctx, "slow", nil, nil)
+	}()
+	documents.waitForSlowRequest(s)
+
+	// The cancellation is read while the slow request is being handled
+	// and cancels the request waiting behind it.
+	queuedID := jsonrpc2.ID{Str: "queued", IsString: true}
+	call, err := clientConn.DispatchCall(ctx, "count", nil, jsonrpc2.PickID(queuedID))
+	s.Require().NoError(err)
+	s.Require().NoError(clientConn.Notify(ctx, MethodCancelRequest, cancelParams{ID: queuedID}))
+
+	documents.releaseSlowRequests()
+	s.Require().NoError(<-slowResponse)
+
+	err = call.Wait(ctx, nil)
+	jsonrpcErr, isJSONRPCErr := err.(*jsonrpc2.Error)
+	s.Require().True(isJSONRPCErr, "expected a JSON-RPC error, got %v", err)
+	s.Assert().Equal(CodeRequestCancelled, jsonrpcErr.Code)
+	s.Assert().Equal(0, documents.countCalls())
+}
+
+func (s *SchedulerTestSuite) Test_attaches_order_messages_were_received_in_to_context() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	clientConn := s.connect(common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			order, ok := common.MessageOrder(ctx.Context)
+			if !ok {
+				return nil, true, true, errors.New("message order not found in context")
+			}
+			return order, true, true, nil
+		},
+	), WithConcurrentRequests(2))
+
+	s.Require().NoError(clientConn.Notify(ctx, "didChange", 1))
+	s.Require().NoError(clientConn.Notify(ctx, "didChange", 2))
+
+	// Requests take the order of the last notification received before them.
+	order := uint64(0)
+	s.Require().NoError(clientConn.Call(ctx, "order", nil, &order))
+	s.Assert().Equal(uint64(2), order)
+	s.Require().NoError(clientConn.Call(ctx, "order", nil, &order))
+	s.Assert().Equal(uint64(2), order)
+}
+
+func (s *SchedulerTestSuite) connect(handler common.Handler, opts ...ServerOption) *jsonrpc2.Conn {
+	server := NewServer(handler, false, zap.NewNop(), nil, opts...)
+	serverStream, clientStream := net.Pipe()
+	serverConn := NewStreamConnection(server.NewHandler(), serverStream)
+	go server.Serve(serverConn, zap.NewNop())
+
+	clientConn := NewStreamConnection(createClientHandler().handler, clientStream)
+	s.T().Cleanup(func() {
+		clientConn.Close()
+	})
+	return clientConn
+}
+
+// testDocuments emulates a handler that keeps track of document changes
+// with requests that can be slow to handle.
+type testDocuments struct {
+	changes     []int
+	counts      int
+	slowStarted chan struct{}
+	release     chan struct{}
+	releaseOnce sync.Once
+	mu          sync.Mutex
+}
+
+func newTestDocuments() *testDocuments {
+	return &testDocuments{
+		changes:     []int{},
+		slowStarted: make(chan struct{}, 1),
+		release:     make(chan struct{}),
+	}
+}
+
+func (d *testDocuments) releaseSlowRequests() {
+	d.releaseOnce.Do(func() {
+		close(d.release)
+	})
+}
+
+func (d *testDocuments) waitForSlowRequest(s *SchedulerTestSuite) {
+	select {
+	case <-d.slowStarted:
+	case <-time.After(DefaultTimeout):
+		s.FailNow("timeout waiting for slow request to start")
+	}
+}
+
+func (d *testDocuments) countCalls() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.counts
+}
+
+func (d *testDocuments) handler() common.Handler {
+	return common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			validMethod = true
+			validParams = true
+			switch ctx.Method {
+			case "didChange":
+				version := 0
+				err = json.Unmarshal(ctx.Params, &version)
+				// Give later messages a chance to overtake this one
+				// if they were not handled in order.
+				time.Sleep(time.Millisecond)
+				d.mu.Lock()
+				d.changes = append(d.changes, version)
+				d.mu.Unlock()
+			case "changes":
+				d.mu.Lock()
+				r = append([]int{}, d.changes...)
+				d.mu.Unlock()
+			case "count":
+				d.mu.Lock()
+				d.counts += 1
+				r = len(d.changes)
+				d.mu.Unlock()
+			case "slow":
+				d.slowStarted <- struct{}{}
+				<-d.release
+			case "slowUntilCancelled":
+				d.slowStarted <- struct{}{}
+				<-ctx.Context.Done()
+				err = ctx.Context.Err()
+			case "slowNotification":
+				<-d.release
+			}
+			return
+		},
+	)
+}
+
+func TestSchedulerTestSuite(t *testing.T) {
+	suite.Run(t, new(SchedulerTestSuite))
+}
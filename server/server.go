@@ -1,6 +1,7 @@
 package server
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -20,6 +21,21 @@ type Server struct {
 	timeout      time.Duration
 	readTimeout  time.Duration
 	writeTimeout time.Duration
+	// The amount of time transports wait for connections
+	// to drain when shutting down.
+	shutdownTimeout     time.Duration
+	inFlight            *requestTracker
+	sessionFactory      SessionFactory
+	onSessionConnect    []SessionHook
+	onSessionDisconnect []SessionHook
+	sessionCount        atomic.Uint64
+	// The maximum number of requests handled at the same time
+	// for each connection, requests are handled one at a time when
+	// this is less than 1.
+	concurrentRequests       int
+	documentDependentMethods map[string]struct{}
+	sessionRecording         SessionRecordingFactory
+	sessionRecorderOptions   []RecorderOption
 }
 
 // ServerOption is a function that configures a server.
@@ -46,7 +62,21 @@ func WithServerWriteTimeout(timeout time.Duration) ServerOption {
 	}
 }
 
+// WithServerShutdownTimeout configures the amount of time transports
+// wait for open connections to finish handling messages when shutting down
+// before the connections are forcefully closed.
+func WithServerShutdownTimeout(timeout time.Duration) ServerOption {
+	return func(s *Server) {
+		s.shutdownTimeout = timeout
+	}
+}
+
 // NewServer creates a new LSP server over JSON-RPC 2.0.
+//
+// The provided handler is shared by every connection served by the server,
+// when serving multiple clients with `RunTCP` or `RunWebSocketServer`,
+// use `WithSessionFactory` to create a handler for each connection
+// in which case the handler passed in here can be nil.
 func NewServer(
 	handler common.Handler,
 	debug bool,
@@ -55,13 +85,15 @@ func NewServer(
 	opts ...ServerOption,
 ) *Server {
 	server := &Server{
-		handler:      handler,
-		debug:        debug,
-		logger:       logger,
-		conn:         conn,
-		timeout:      DefaultTimeout,
-		readTimeout:  DefaultTimeout,
-		writeTimeout: DefaultTimeout,
+		handler:         handler,
+		debug:           debug,
+		logger:          logger,
+		conn:            conn,
+		timeout:         DefaultTimeout,
+		readTimeout:     DefaultTimeout,
+		writeTimeout:    DefaultTimeout,
+		shutdownTimeout: DefaultShutdownTimeout,
+		inFlight:        newRequestTracker(),
 	}
 
 	for _, opt := range opts {
@@ -86,6 +118,12 @@ func (s *Server) GetWriteTimeout() time.Duration {
 	return s.writeTimeout
 }
 
+// GetShutdownTimeout returns the amount of time transports wait
+// for connections to drain when shutting down.
+func (s *Server) GetShutdownTimeout() time.Duration {
+	return s.shutdownTimeout
+}
+
 // Serve serves a JSON-RPC 2.0 connection. If nil is passed in
 // for the connection, the server will use the connection that
 // was configured when the server was created.
@@ -104,17 +142,38 @@ func (s *Server) Serve(optConn *jsonrpc2.Conn, connLogger *zap.Logger) {
 	connLogger.Info("stream connection closed")
 }
 
-// ServeWebSocket serves a JSON-RPC 2.0 connection over a WebSocket connection.
+// ServeWebSocket serves a JSON-RPC 2.0 connection over a WebSocket connection
+// as a new session.
 // See `RunWebSocketServer` for a complete example of how to serve JSON-RPC 2.0
 // communication over WebSockets.
 func (s *Server) ServeWebSocket(conn *websocket.Conn, logger *zap.Logger) {
-	s.logger.Info("new web socket connection")
-	<-NewWebSocketConnection(
-		s.NewHandler(),
-		conn,
+	session, err := s.startSession(logger)
+	if err != nil {
+		logger.Error("failed to create session for web socket connection", zap.Error(err))
+		return
+	}
+
+	session.Logger.Info("new web socket connection")
+	s.serveSession(
+		s.newWebSocketConnection(s.newSessionHandler(session, nil), conn, session.connOptions()...),
+		session,
+	)
+	session.Logger.Info("web socket connection closed")
+}
+
+func (s *Server) newWebSocketConnection(
+	handler jsonrpc2.Handler,
+	conn *websocket.Conn,
+	opts ...ConnOption,
+) *jsonrpc2.Conn {
+	connOpts := []ConnOption{
 		WithTimeout(s.timeout),
 		WithReadTimeout(s.readTimeout),
 		WithWriteTimeout(s.writeTimeout),
-	).DisconnectNotify()
-	s.logger.Info("web socket connection closed")
+	}
+	return NewWebSocketConnection(
+		handler,
+		conn,
+		append(connOpts, opts...)...,
+	)
 }
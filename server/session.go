@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"io"
+	"strconv"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/two-hundred/ls-builder/common"
+	"go.uber.org/zap"
+)
+
+// Session holds the state for a single connection to a client
+// for servers that serve multiple clients (e.g. with `RunTCP` or `RunWebSocketServer`).
+type Session struct {
+	// ID is a unique identifier for the session within the server process.
+	ID string
+	// Logger is a logger that includes the session ID in every entry.
+	Logger *zap.Logger
+	// Context is cancelled when the connection for the session has been closed,
+	// this can be used to stop background work for the session.
+	Context context.Context
+	// Handler is the handler for messages from the client of the session,
+	// this is the handler created by the server's session factory
+	// or the handler the server was created with.
+	Handler common.Handler
+	cancel  context.CancelFunc
+	// The writer and recorder for the messages of the session,
+	// nil when the session is not recorded.
+	recording io.Writer
+	recorder  *Recorder
+}
+
+// SessionFactory creates a fresh handler along with any state
+// needed for a new connection to a client.
+// Returning an error refuses the connection.
+//
+// For example, with the LSP 3.17 package:
+//
+//	server.WithSessionFactory(func(session *server.Session) (common.Handler, error) {
+//		app := NewApplication(session.Logger)
+//		return lsp.NewHandler(
+//			lsp.WithInitializeHandler(app.handleInitialise),
+//			lsp.WithShutdownHandler(app.handleShutdown),
+//		), nil
+//	})
+type SessionFactory func(session *Session) (common.Handler, error)
+
+// SessionHook is a function that is called when the connection
+// for a session is opened or closed.
+type SessionHook func(session *Session)
+
+// WithSessionFactory configures the server to create a new handler for each connection
+// opened by the transports provided by this package so that state such as whether
+// the server has been initialised is not shared between unrelated clients.
+//
+// Without a session factory, every connection is handled by the handler
+// the server was created with.
+func WithSessionFactory(factory SessionFactory) ServerOption {
+	return func(s *Server) {
+		s.sessionFactory = factory
+	}
+}
+
+// WithOnSessionConnect adds a hook that is called when a connection for
+// a new session has been opened, before any messages from the client are handled.
+func WithOnSessionConnect(hook SessionHook) ServerOption {
+	return func(s *Server) {
+		s.onSessionConnect = append(s.onSessionConnect, hook)
+	}
+}
+
+// WithOnSessionDisconnect adds a hook that is called once the connection
+// for a session has been closed.
+func WithOnSessionDisconnect(hook SessionHook) ServerOption {
+	return func(s *Server) {
+		s.onSessionDisconnect = append(s.onSessionDisconnect, hook)
+	}
+}
+
+// startSession creates a new session with a handler from the server's session factory
+// and calls the connect hooks, the session must be ended with endSession.
+func (s *Server) startSession(logger *zap.Logger) (*Session, error) {
+	id := strconv.FormatUint(s.sessionCount.Add(1), 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &Session{
+		ID:      id,
+		Logger:  logger.With(zap.String("sessionId", id)),
+		Context: ctx,
+		Handler: s.handler,
+		cancel:  cancel,
+	}
+
+	if s.sessionFactory != nil {
+		handler, err := s.sessionFactory(session)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		session.Handler = handler
+	}
+
+	s.startSessionRecording(session)
+	for _, hook := range s.onSessionConnect {
+		hook(session)
+	}
+
+	return session, nil
+}
+
+// endSession cancels the context of the session and calls the disconnect hooks.
+func (s *Server) endSession(session *Session) {
+	session.cancel()
+	for _, hook := range s.onSessionDisconnect {
+		hook(session)
+	}
+	endSessionRecording(session)
+}
+
+// connOptions produces the options for the connection of a session.
+func (session *Session) connOptions() []ConnOption {
+	if session.recorder == nil {
+		return nil
+	}
+	return []ConnOption{WithRecorder(session.recorder)}
+}
+
+// newSessionHandler creates a handler to handle JSON-RPC requests
+// for a session, see `newHandler` for the wrap function.
+func (s *Server) newSessionHandler(
+	session *Session,
+	wrap func(jsonrpc2.Handler) jsonrpc2.Handler,
+) jsonrpc2.Handler {
+	return s.newHandler(session.Handler, wrap)
+}
+
+// serveSession serves the connection for a session until
+// the connection has been closed.
+func (s *Server) serveSession(conn *jsonrpc2.Conn, session *Session) {
+	defer s.endSession(session)
+	<-conn.DisconnectNotify()
+}
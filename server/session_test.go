@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sourcegraph/jsonrpc2"
+	wsjsonrpc2 "github.com/sourcegraph/jsonrpc2/websocket"
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"go.uber.org/zap"
+)
+
+type SessionTestSuite struct {
+	suite.Suite
+}
+
+func (s *SessionTestSuite) Test_tcp_transport_creates_handler_per_connection() {
+	address := s.freeAddress()
+	recorder := &sessionRecorder{}
+	server := NewServer(
+		nil,
+		false,
+		zap.NewNop(),
+		nil,
+		WithSessionFactory(createSessionStateHandler),
+		WithOnSessionConnect(recorder.onConnect),
+		WithOnSessionDisconnect(recorder.onDisconnect),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go RunTCP(ctx, address, server, zap.NewNop())
+	s.Require().NoError(waitForListener(address))
+
+	firstClient := s.dialTCP(address)
+	secondClient := s.dialTCP(address)
+	s.assertSessionsAreIsolated(firstClient, secondClient)
+
+	s.Require().NoError(firstClient.Close())
+	s.Require().NoError(secondClient.Close())
+	// The connection used to wait for the listener is served as a session as well.
+	sessions := recorder.waitForDisconnects(s, 3)
+	s.assertSessionsEnded(recorder, sessions)
+}
+
+func (s *SessionTestSuite) Test_websocket_transport_creates_handler_per_connection() {
+	address := s.freeAddress()
+	recorder := &sessionRecorder{}
+	server := NewServer(
+		nil,
+		false,
+		zap.NewNop(),
+		nil,
+		WithSessionFactory(createSessionStateHandler),
+		WithOnSessionConnect(recorder.onConnect),
+		WithOnSessionDisconnect(recorder.onDisconnect),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go RunWebSocketServer(ctx, address, server, zap.NewNop(), nil)
+	s.Require().NoError(waitForListener(address))
+
+	firstClient := s.dialWebSocket(address)
+	secondClient := s.dialWebSocket(address)
+	s.assertSessionsAreIsolated(firstClient, secondClient)
+
+	s.Require().NoError(firstClient.Close())
+	s.Require().NoError(secondClient.Close())
+	sessions := recorder.waitForDisconnects(s, 2)
+	s.assertSessionsEnded(recorder, sessions)
+}
+
+func (s *SessionTestSuite) Test_shares_handler_between_connections_without_session_factory() {
+	address := s.freeAddress()
+	handler, err := createSessionStateHandler(&Session{ID: "shared"})
+	s.Require().NoError(err)
+	server := NewServer(handler, false, zap.NewNop(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go RunTCP(ctx, address, server, zap.NewNop())
+	s.Require().NoError(waitForListener(address))
+
+	firstClient := s.dialTCP(address)
+	secondClient := s.dialTCP(address)
+	defer firstClient.Close()
+	defer secondClient.Close()
+
+	s.Require().NoError(firstClient.Call(ctx, "setValue", "first", nil))
+	value := ""
+	s.Require().NoError(secondClient.Call(ctx, "getValue", nil, &value))
+	s.Assert().Equal("first", value)
+}
+
+func (s *SessionTestSuite) Test_refuses_connection_when_session_can_not_be_created() {
+	address := s.freeAddress()
+	recorder := &sessionRecorder{}
+	server := NewServer(
+		nil,
+		false,
+		zap.NewNop(),
+		nil,
+		WithSessionFactory(func(session *Session) (common.Handler, error) {
+			return nil, errors.New("too many sessions")
+		}),
+		WithOnSessionConnect(recorder.onConnect),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go RunTCP(ctx, address, server, zap.NewNop())
+	s.Require().NoError(waitForListener(address))
+
+	client := s.dialTCP(address)
+	select {
+	case <-client.DisconnectNotify():
+	case <-time.After(DefaultTimeout):
+		s.FailNow("timeout waiting for connection to be refused")
+	}
+	s.Assert().Empty(recorder.connectedSessions())
+}
+
+func (s *SessionTestSuite) assertSessionsAreIsolated(firstClient *jsonrpc2.Conn, secondClient *jsonrpc2.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	s.Require().NoError(firstClient.Call(ctx, "setValue", "first", nil))
+	s.Require().NoError(secondClient.Call(ctx, "setValue", "second", nil))
+
+	firstValue := ""
+	s.Require().NoError(firstClient.Call(ctx, "getValue", nil, &firstValue))
+	s.Assert().Equal("first", firstValue)
+
+	secondValue := ""
+	s.Require().NoError(secondClient.Call(ctx, "getValue", nil, &secondValue))
+	s.Assert().Equal("second", secondValue)
+
+	firstSessionID := ""
+	s.Require().NoError(firstClient.Call(ctx, "sessionId", nil, &firstSessionID))
+	secondSessionID := ""
+	s.Require().NoError(secondClient.Call(ctx, "sessionId", nil, &secondSessionID))
+	s.Assert().NotEmpty(firstSessionID)
+	s.Assert().NotEqual(firstSessionID, secondSessionID)
+}
+
+func (s *SessionTestSuite) assertSessionsEnded(recorder *sessionRecorder, disconnected []*Session) {
+	connected := recorder.connectedSessions()
+	s.Require().Len(connected, len(disconnected))
+	s.Assert().ElementsMatch(connected, disconnected)
+	for _, session := range disconnected {
+		s.Assert().ErrorIs(session.Context.Err(), context.Canceled)
+	}
+}
+
+func (s *SessionTestSuite) freeAddress() string {
+	port, err := getFreePort()
+	s.Require().NoError(err)
+	return fmt.Sprintf("localhost:%d", port)
+}
+
+func (s *SessionTestSuite) dialTCP(address string) *jsonrpc2.Conn {
+	conn, err := net.Dial("tcp", address)
+	s.Require().NoError(err)
+	return NewStreamConnection(createClientHandler().handler, conn)
+}
+
+func (s *SessionTestSuite) dialWebSocket(address string) *jsonrpc2.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s", address), nil)
+	s.Require().NoError(err)
+	return jsonrpc2.NewConn(
+		context.Background(),
+		wsjsonrpc2.NewObjectStream(conn),
+		createClientHandler().handler,
+	)
+}
+
+// createSessionStateHandler creates a handler with its own state,
+// emulating a handler that keeps track of state for a client
+// such as whether the server has been initialised.
+func createSessionStateHandler(session *Session) (common.Handler, error) {
+	value := ""
+	return common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			validMethod = true
+			validParams = true
+			switch ctx.Method {
+			case "setValue":
+				err = json.Unmarshal(ctx.Params, &value)
+			case "getValue":
+				r = value
+			case "sessionId":
+				r = session.ID
+			}
+			return
+		},
+	), nil
+}
+
+type sessionRecorder struct {
+	connected    []*Session
+	disconnected []*Session
+	mu           sync.Mutex
+}
+
+func (r *sessionRecorder) connectedSessions() []*Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Session{}, r.connected...)
+}
+
+func (r *sessionRecorder) onConnect(session *Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connected = append(r.connected, session)
+}
+
+func (r *sessionRecorder) onDisconnect(session *Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.disconnected = append(r.disconnected, session)
+}
+
+func (r *sessionRecorder) waitForDisconnects(s *SessionTestSuite, count int) []*Session {
+	deadline := time.Now().Add(DefaultTimeout)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		disconnected := append([]*Session{}, r.disconnected...)
+		r.mu.Unlock()
+		if len(disconnected) >= count {
+			return disconnected
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.FailNow("timeout waiting for sessions to disconnect")
+	return nil
+}
+
+func TestSessionTestSuite(t *testing.T) {
+	suite.Run(t, new(SessionTestSuite))
+}
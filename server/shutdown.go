@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"go.uber.org/zap"
+)
+
+// DefaultShutdownTimeout is the default amount of time transports wait
+// for open connections to finish handling messages when shutting down
+// before the connections are forcefully closed.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// connectionGroup keeps track of the connections opened by a transport
+// along with the messages being handled for them so the transport
+// can drain and close every connection when it is shut down.
+type connectionGroup struct {
+	server *Server
+	logger *zap.Logger
+	conns  map[*jsonrpc2.Conn]struct{}
+	// The number of messages that are currently being handled
+	// across all connections in the group.
+	activeMessages int
+	// Closed once there are no messages being handled after
+	// the group has started shutting down.
+	drained      chan struct{}
+	shuttingDown bool
+	// Keeps track of the goroutines serving each connection.
+	wg sync.WaitGroup
+	mu sync.Mutex
+}
+
+func newConnectionGroup(server *Server, logger *zap.Logger) *connectionGroup {
+	return &connectionGroup{
+		server: server,
+		logger: logger,
+		conns:  map[*jsonrpc2.Conn]struct{}{},
+	}
+}
+
+// handler wraps the provided handler to keep track of the messages
+// being handled so in-flight requests can be drained on shutdown.
+func (g *connectionGroup) handler(handler jsonrpc2.Handler) jsonrpc2.Handler {
+	return &drainingHandler{group: g, handler: handler}
+}
+
+// add registers a connection with the group, returning false if the group
+// has started shutting down in which case the connection must not be served.
+// When a connection is added, done must be called once the goroutine serving
+// the connection has finished.
+func (g *connectionGroup) add(conn *jsonrpc2.Conn) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.shuttingDown {
+		return false
+	}
+
+	g.conns[conn] = struct{}{}
+	g.wg.Add(1)
+	return true
+}
+
+func (g *connectionGroup) done(conn *jsonrpc2.Conn) {
+	g.mu.Lock()
+	delete(g.conns, conn)
+	g.mu.Unlock()
+	g.wg.Done()
+}
+
+// serve serves the connection for a session in a new goroutine,
+// closing the connection and ending the session straight away
+// if the group has started shutting down.
+func (g *connectionGroup) serve(conn *jsonrpc2.Conn, session *Session) {
+	if !g.add(conn) {
+		callAndLog(func() error { return closeConnection(conn) }, "conn.Close", session.Logger)
+		g.server.endSession(session)
+		return
+	}
+
+	go func() {
+		defer g.done(conn)
+		session.Logger.Info("new stream connection")
+		g.server.serveSession(conn, session)
+		session.Logger.Info("stream connection closed")
+	}()
+}
+
+func (g *connectionGroup) messageReceived() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.activeMessages += 1
+}
+
+func (g *connectionGroup) messageHandled() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.activeMessages -= 1
+	if g.activeMessages == 0 && g.drained != nil {
+		close(g.drained)
+		g.drained = nil
+	}
+}
+
+// shutdown stops the group from accepting new connections, cancels the context
+// of in-flight requests and waits until the provided context is done for
+// the messages being handled to drain before closing every connection.
+// This returns once the goroutines serving each connection have finished.
+//
+// Handlers that ignore the cancellation of their context may still be running
+// when the connections have been closed.
+func (g *connectionGroup) shutdown(ctx context.Context) {
+	g.mu.Lock()
+	g.shuttingDown = true
+	drained := make(chan struct{})
+	if g.activeMessages == 0 {
+		close(drained)
+	} else {
+		g.drained = drained
+	}
+	conns := make([]*jsonrpc2.Conn, 0, len(g.conns))
+	for conn := range g.conns {
+		conns = append(conns, conn)
+	}
+	g.mu.Unlock()
+
+	for _, conn := range conns {
+		g.server.inFlight.cancelConnection(conn)
+	}
+
+	select {
+	case <-drained:
+		g.logger.Info("connections drained, closing connections", zap.Int("connections", len(conns)))
+	case <-ctx.Done():
+		g.logger.Warn(
+			"timed out waiting for connections to drain, forcefully closing connections",
+			zap.Int("connections", len(conns)),
+		)
+	}
+
+	for _, conn := range conns {
+		callAndLog(func() error { return closeConnection(conn) }, "conn.Close", g.logger)
+	}
+
+	g.wg.Wait()
+}
+
+// shutdownWithTimeout shuts down the group, waiting up to the server's
+// shutdown timeout for connections to drain.
+func (g *connectionGroup) shutdownWithTimeout() {
+	ctx, cancel := context.WithTimeout(context.Background(), g.server.GetShutdownTimeout())
+	defer cancel()
+	g.shutdown(ctx)
+}
+
+type drainingHandler struct {
+	group   *connectionGroup
+	handler jsonrpc2.Handler
+}
+
+func (h *drainingHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	h.group.messageReceived()
+	defer h.group.messageHandled()
+	h.handler.Handle(ctx, conn, req)
+}
+
+// closeConnection closes a connection, ignoring the error for connections
+// that have already been closed by the client.
+func closeConnection(conn *jsonrpc2.Conn) error {
+	err := conn.Close()
+	if errors.Is(err, jsonrpc2.ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sourcegraph/jsonrpc2"
+	wsjsonrpc2 "github.com/sourcegraph/jsonrpc2/websocket"
+	"github.com/stretchr/testify/suite"
+	"github.com/two-hundred/ls-builder/common"
+	"go.uber.org/zap"
+)
+
+type ShutdownTestSuite struct {
+	suite.Suite
+}
+
+func (s *ShutdownTestSuite) Test_tcp_transport_stops_without_waiting_for_a_connection() {
+	port, err := getFreePort()
+	s.Require().NoError(err)
+	address := fmt.Sprintf("localhost:%d", port)
+
+	server := NewServer(createCounterHandler(), false, zap.NewNop(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := runInBackground(func() error {
+		return RunTCP(ctx, address, server, zap.NewNop())
+	})
+	s.Require().NoError(waitForListener(address))
+
+	cancel()
+	s.Require().NoError(s.waitForStop(stopped))
+}
+
+func (s *ShutdownTestSuite) Test_tcp_transport_cancels_in_flight_requests_and_drains_connections() {
+	port, err := getFreePort()
+	s.Require().NoError(err)
+	address := fmt.Sprintf("localhost:%d", port)
+
+	started := make(chan struct{})
+	server := NewServer(createSlowHandler(started), false, zap.NewNop(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := runInBackground(func() error {
+		return RunTCP(ctx, address, server, zap.NewNop())
+	})
+	s.Require().NoError(waitForListener(address))
+
+	conn, err := net.Dial("tcp", address)
+	s.Require().NoError(err)
+	clientConn := NewStreamConnection(createClientHandler().handler, conn)
+
+	response := s.callInBackground(clientConn)
+	s.waitForStart(started)
+
+	cancel()
+	s.Require().NoError(s.waitForStop(stopped))
+
+	// The request was cancelled and the response was sent
+	// before the connection was closed.
+	err = <-response
+	jsonrpcErr, isJSONRPCErr := err.(*jsonrpc2.Error)
+	s.Require().True(isJSONRPCErr, "expected a JSON-RPC error, got %v", err)
+	s.Assert().Equal(context.Canceled.Error(), jsonrpcErr.Message)
+	s.waitForDisconnect(clientConn)
+}
+
+func (s *ShutdownTestSuite) Test_tcp_transport_forcefully_closes_connections_after_timeout() {
+	port, err := getFreePort()
+	s.Require().NoError(err)
+	address := fmt.Sprintf("localhost:%d", port)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server := NewServer(
+		createBlockingHandler(started, release),
+		false,
+		zap.NewNop(),
+		nil,
+		WithServerShutdownTimeout(50*time.Millisecond),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := runInBackground(func() error {
+		return RunTCP(ctx, address, server, zap.NewNop())
+	})
+	s.Require().NoError(waitForListener(address))
+
+	conn, err := net.Dial("tcp", address)
+	s.Require().NoError(err)
+	clientConn := NewStreamConnection(createClientHandler().handler, conn)
+
+	response := s.callInBackground(clientConn)
+	s.waitForStart(started)
+
+	cancel()
+	s.Require().NoError(s.waitForStop(stopped))
+	s.waitForDisconnect(clientConn)
+	s.Require().ErrorIs(<-response, jsonrpc2.ErrClosed)
+}
+
+func (s *ShutdownTestSuite) Test_websocket_transport_cancels_in_flight_requests_and_drains_connections() {
+	port, err := getFreePort()
+	s.Require().NoError(err)
+	address := fmt.Sprintf("localhost:%d", port)
+
+	started := make(chan struct{})
+	server := NewServer(createSlowHandler(started), false, zap.NewNop(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := runInBackground(func() error {
+		return RunWebSocketServer(ctx, address, server, zap.NewNop(), nil)
+	})
+	s.Require().NoError(waitForListener(address))
+
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s", address), nil)
+	s.Require().NoError(err)
+	clientConn := jsonrpc2.NewConn(
+		context.Background(),
+		wsjsonrpc2.NewObjectStream(conn),
+		createClientHandler().handler,
+	)
+
+	response := s.callInBackground(clientConn)
+	s.waitForStart(started)
+
+	cancel()
+	s.Require().NoError(s.waitForStop(stopped))
+
+	err = <-response
+	jsonrpcErr, isJSONRPCErr := err.(*jsonrpc2.Error)
+	s.Require().True(isJSONRPCErr, "expected a JSON-RPC error, got %v", err)
+	s.Assert().Equal(context.Canceled.Error(), jsonrpcErr.Message)
+	s.waitForDisconnect(clientConn)
+}
+
+func (s *ShutdownTestSuite) Test_websocket_transport_stops_without_connections() {
+	port, err := getFreePort()
+	s.Require().NoError(err)
+	address := fmt.Sprintf("localhost:%d", port)
+
+	server := NewServer(createCounterHandler(), false, zap.NewNop(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := runInBackground(func() error {
+		return RunWebSocketServer(ctx, address, server, zap.NewNop(), &http.Server{})
+	})
+	s.Require().NoError(waitForListener(address))
+
+	cancel()
+	s.Require().NoError(s.waitForStop(stopped))
+}
+
+func (s *ShutdownTestSuite) callInBackground(conn *jsonrpc2.Conn) chan error {
+	response := make(chan error, 1)
+	go func() {
+		response <- conn.Call(context.Background(), "slowRequest", nil, nil)
+	}()
+	return response
+}
+
+func (s *ShutdownTestSuite) waitForStart(started chan struct{}) {
+	select {
+	case <-started:
+	case <-time.After(DefaultTimeout):
+		s.FailNow("timeout waiting for request to start")
+	}
+}
+
+func (s *ShutdownTestSuite) waitForStop(stopped chan error) error {
+	select {
+	case err := <-stopped:
+		return err
+	case <-time.After(DefaultTimeout):
+		s.FailNow("timeout waiting for transport to stop")
+		return nil
+	}
+}
+
+func (s *ShutdownTestSuite) waitForDisconnect(conn *jsonrpc2.Conn) {
+	select {
+	case <-conn.DisconnectNotify():
+	case <-time.After(DefaultTimeout):
+		s.FailNow("timeout waiting for connection to be closed")
+	}
+}
+
+func runInBackground(run func() error) chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- run()
+	}()
+	return result
+}
+
+// createSlowHandler creates a handler for requests that only
+// finish when the context of the request is cancelled.
+func createSlowHandler(started chan struct{}) common.Handler {
+	return common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			close(started)
+			<-ctx.Context.Done()
+			return nil, true, true, ctx.Context.Err()
+		},
+	)
+}
+
+// createBlockingHandler creates a handler for requests that ignore
+// the cancellation of their context.
+func createBlockingHandler(started chan struct{}, release chan struct{}) common.Handler {
+	return common.HandlerFunc(
+		func(ctx *common.LSPContext) (r any, validMethod bool, validParams bool, err error) {
+			close(started)
+			<-release
+			return nil, true, true, nil
+		},
+	)
+}
+
+func TestShutdownTestSuite(t *testing.T) {
+	suite.Run(t, new(ShutdownTestSuite))
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TransportKind is the transport a language server
+// communicates with the client over.
+type TransportKind string
+
+const (
+	// TransportStdio is for communicating over stdin and stdout (`--stdio`).
+	TransportStdio TransportKind = "stdio"
+	// TransportPipe is for connecting to a client listening on a Unix domain socket
+	// (`--pipe=<path>`), Windows named pipes are not supported.
+	TransportPipe TransportKind = "pipe"
+	// TransportSocket is for connecting to a client listening for TCP connections
+	// on a port on the local machine (`--socket=<port>`).
+	TransportSocket TransportKind = "socket"
+)
+
+// TransportFlags holds the transport selected with the command line flags
+// that clients such as `vscode-languageclient` launch language servers with.
+type TransportFlags struct {
+	// Transport is the selected transport, defaults to `TransportStdio`
+	// when no transport flag is provided.
+	Transport TransportKind
+	// PipePath is the path of the socket to connect to for `TransportPipe`.
+	PipePath string
+	// SocketPort is the port on the local machine to connect to for `TransportSocket`.
+	SocketPort int
+	// ClientProcessID is the ID of the process of the client that launched
+	// the server (`--clientProcessId=<pid>`), 0 if not provided.
+	ClientProcessID int
+}
+
+// clientProcessPollInterval is the interval at which the process of the client
+// is checked to determine whether the client has exited.
+var clientProcessPollInterval = 3 * time.Second
+
+// ParseTransportFlags parses the transport flags from the provided command line arguments
+// (e.g. `os.Args[1:]`), flags can be provided as `--flag=value` or `--flag value`.
+//
+// The following flags are supported:
+//
+//	--stdio                  communicate over stdin and stdout (default)
+//	--pipe=<path>            connect to the client listening on a Unix domain socket
+//	--socket=<port>          connect to the client listening on a TCP port on the local machine
+//	--clientProcessId=<pid>  stop the server when the client process exits
+//
+// Arguments that are not transport flags are ignored so that servers can define
+// their own flags, selecting more than one transport returns an `ErrMultipleTransports` error.
+func ParseTransportFlags(args []string) (*TransportFlags, error) {
+	flags := &TransportFlags{}
+	for i := 0; i < len(args); i += 1 {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		// Values of flags that expect one can also be provided as the next argument.
+		takesValue := name == "--pipe" || name == "--socket" || name == "--clientProcessId"
+		if takesValue && !hasValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			value = args[i+1]
+			hasValue = true
+			i += 1
+		}
+
+		var err error
+		switch name {
+		case "--stdio":
+			err = flags.setTransport(TransportStdio)
+		case "--pipe":
+			err = flags.setPipe(value, hasValue)
+		case "--socket":
+			err = flags.setSocket(value, hasValue)
+		case "--clientProcessId":
+			flags.ClientProcessID, err = parseIntFlag(name, value, hasValue)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if flags.Transport == "" {
+		flags.Transport = TransportStdio
+	}
+
+	return flags, nil
+}
+
+func (f *TransportFlags) setTransport(transport TransportKind) error {
+	if f.Transport != "" && f.Transport != transport {
+		return fmt.Errorf("%w: --%s and --%s", ErrMultipleTransports, f.Transport, transport)
+	}
+	f.Transport = transport
+	return nil
+}
+
+func (f *TransportFlags) setPipe(value string, hasValue bool) error {
+	if !hasValue || value == "" {
+		return fmt.Errorf("%w: --pipe requires a path", ErrInvalidTransportFlag)
+	}
+	f.PipePath = value
+	return f.setTransport(TransportPipe)
+}
+
+func (f *TransportFlags) setSocket(value string, hasValue bool) error {
+	port, err := parseIntFlag("--socket", value, hasValue)
+	if err != nil {
+		return err
+	}
+
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%w: --socket must be a valid port, got %d", ErrInvalidTransportFlag, port)
+	}
+	f.SocketPort = port
+	return f.setTransport(TransportSocket)
+}
+
+func parseIntFlag(name string, value string, hasValue bool) (int, error) {
+	if !hasValue {
+		return 0, fmt.Errorf("%w: %s requires a value", ErrInvalidTransportFlag, name)
+	}
+
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be an integer, got %q", ErrInvalidTransportFlag, name, value)
+	}
+	return intValue, nil
+}
+
+// RunFromArgs parses the transport flags from the provided command line arguments
+// (see `ParseTransportFlags`) and serves the client over the selected transport
+// with the provided server, providing the same launcher behaviour for every
+// language server binary.
+//
+// When a client process ID is provided, the server is shut down once the client
+// process has exited.
+// This returns once the connection to the client has been closed or the provided
+// context has been cancelled.
+//
+// For example:
+//
+//	func main() {
+//		logger, _ := zap.NewProduction()
+//		srv := server.NewServer(app.Handler(), false, logger, nil)
+//		if err := server.RunFromArgs(context.Background(), os.Args[1:], srv, logger); err != nil {
+//			logger.Fatal("language server failed", zap.Error(err))
+//		}
+//	}
+func RunFromArgs(ctx context.Context, args []string, server *Server, logger *zap.Logger) error {
+	flags, err := ParseTransportFlags(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if flags.ClientProcessID != 0 {
+		go watchClientProcess(ctx, flags.ClientProcessID, cancel, logger)
+	}
+
+	switch flags.Transport {
+	case TransportPipe:
+		return ConnectUnixSocket(ctx, flags.PipePath, server, logger)
+	case TransportSocket:
+		address := net.JoinHostPort("127.0.0.1", strconv.Itoa(flags.SocketPort))
+		return ConnectTCP(ctx, address, server, logger)
+	default:
+		return RunStdio(ctx, server, logger)
+	}
+}
+
+// watchClientProcess calls the provided function to stop the server
+// once the process of the client has exited.
+func watchClientProcess(ctx context.Context, pid int, stop func(), logger *zap.Logger) {
+	ticker := time.NewTicker(clientProcessPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !processExists(pid) {
+				logger.Info("client process has exited, shutting down", zap.Int("clientProcessId", pid))
+				stop()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type TransportArgsTestSuite struct {
+	suite.Suite
+}
+
+func (s *TransportArgsTestSuite) Test_parses_transport_flags() {
+	tests := []struct {
+		args     []string
+		expected *TransportFlags
+	}{
+		{
+			args:     []string{},
+			expected: &TransportFlags{Transport: TransportStdio},
+		},
+		{
+			args:     []string{"--stdio", "--clientProcessId=4021"},
+			expected: &TransportFlags{Transport: TransportStdio, ClientProcessID: 4021},
+		},
+		{
+			args:     []string{"--pipe=/tmp/vscode-lsp.sock", "--log-level=debug"},
+			expected: &TransportFlags{Transport: TransportPipe, PipePath: "/tmp/vscode-lsp.sock"},
+		},
+		{
+			args:     []string{"--socket", "7998", "--clientProcessId", "301"},
+			expected: &TransportFlags{Transport: TransportSocket, SocketPort: 7998, ClientProcessID: 301},
+		},
+		{
+			args:     []string{"--socket=7998", "--socket=7998"},
+			expected: &TransportFlags{Transport: TransportSocket, SocketPort: 7998},
+		},
+	}
+
+	for _, test := range tests {
+		flags, err := ParseTransportFlags(test.args)
+		s.Require().NoError(err)
+		s.Assert().Equal(test.expected, flags, "args: %v", test.args)
+	}
+}
+
+func (s *TransportArgsTestSuite) Test_fails_to_parse_invalid_transport_flags() {
+	tests := []struct {
+		args        []string
+		expectedErr error
+	}{
+		{args: []string{"--stdio", "--socket=7998"}, expectedErr: ErrMultipleTransports},
+		{args: []string{"--pipe=/tmp/lsp.sock", "--stdio"}, expectedErr: ErrMultipleTransports},
+		{args: []string{"--socket=not-a-port"}, expectedErr: ErrInvalidTransportFlag},
+		{args: []string{"--socket=70000"}, expectedErr: ErrInvalidTransportFlag},
+		{args: []string{"--pipe"}, expectedErr: ErrInvalidTransportFlag},
+		{args: []string{"--clientProcessId", "--stdio"}, expectedErr: ErrInvalidTransportFlag},
+	}
+
+	for _, test := range tests {
+		_, err := ParseTransportFlags(test.args)
+		s.Assert().ErrorIs(err, test.expectedErr, "args: %v", test.args)
+	}
+}
+
+func (s *TransportArgsTestSuite) Test_runs_socket_transport_from_args() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	server := NewServer(createCounterHandler(), false, zap.NewNop(), nil)
+	stopped := runInBackground(func() error {
+		return RunFromArgs(context.Background(), []string{fmt.Sprintf("--socket=%d", port)}, server, zap.NewNop())
+	})
+
+	connection, err := listener.Accept()
+	s.Require().NoError(err)
+	clientConn := NewStreamConnection(createClientHandler().handler, connection)
+
+	testCountRes := testCountResult{}
+	err = clientConn.Call(context.Background(), "increment", testCountParams{Count: 3}, &testCountRes)
+	s.Require().NoError(err)
+	s.Require().Equal(4, testCountRes.Count)
+
+	s.Require().NoError(clientConn.Close())
+	s.Require().NoError(s.waitForStop(stopped))
+}
+
+func (s *TransportArgsTestSuite) Test_stops_when_client_process_exits() {
+	prevInterval := clientProcessPollInterval
+	clientProcessPollInterval = 10 * time.Millisecond
+	defer func() {
+		clientProcessPollInterval = prevInterval
+	}()
+
+	// Use the ID of a process that has already exited as the client process.
+	clientProcess := exec.Command("true")
+	s.Require().NoError(clientProcess.Run())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	server := NewServer(createCounterHandler(), false, zap.NewNop(), nil)
+	stopped := runInBackground(func() error {
+		return RunFromArgs(
+			context.Background(),
+			[]string{
+				fmt.Sprintf("--socket=%d", port),
+				fmt.Sprintf("--clientProcessId=%d", clientProcess.Process.Pid),
+			},
+			server,
+			zap.NewNop(),
+		)
+	})
+
+	connection, err := listener.Accept()
+	s.Require().NoError(err)
+	clientConn := NewStreamConnection(createClientHandler().handler, connection)
+	defer clientConn.Close()
+
+	s.Require().NoError(s.waitForStop(stopped))
+	select {
+	case <-clientConn.DisconnectNotify():
+	case <-time.After(DefaultTimeout):
+		s.Fail("timeout waiting for connection to be closed")
+	}
+}
+
+func (s *TransportArgsTestSuite) Test_detects_running_client_process() {
+	s.Require().True(processExists(os.Getpid()))
+}
+
+func (s *TransportArgsTestSuite) waitForStop(stopped chan error) error {
+	select {
+	case err := <-stopped:
+		return err
+	case <-time.After(DefaultTimeout):
+		s.FailNow("timeout waiting for transport to stop")
+		return nil
+	}
+}
+
+func TestTransportArgsTestSuite(t *testing.T) {
+	suite.Run(t, new(TransportArgsTestSuite))
+}
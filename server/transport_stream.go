@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"io"
+
+	"go.uber.org/zap"
+)
+
+// RunStdio serves a single LSP over JSON-RPC 2.0 connection over stdin and stdout
+// with the provided server.
+//
+// This returns once the connection has been closed (e.g. after an `exit` notification)
+// or the provided context has been cancelled, in which case the connection is drained
+// and closed in the same way as connections served by `RunTCP`.
+func RunStdio(ctx context.Context, server *Server, logger *zap.Logger) error {
+	return serveStream(ctx, Stdio{}, server, logger)
+}
+
+// serveStream serves a single connection on top of the provided stream
+// as a new session until the connection is closed or the context is cancelled.
+func serveStream(ctx context.Context, stream io.ReadWriteCloser, server *Server, logger *zap.Logger) error {
+	session, err := server.startSession(logger)
+	if err != nil {
+		callAndLog(stream.Close, "stream.Close", logger)
+		return err
+	}
+
+	connections := newConnectionGroup(server, logger)
+	conn := NewStreamConnection(
+		server.newSessionHandler(session, connections.handler),
+		stream,
+		session.connOptions()...,
+	)
+	connections.serve(conn, session)
+
+	select {
+	case <-conn.DisconnectNotify():
+	case <-ctx.Done():
+		logger.Info("shutting down connection")
+	}
+
+	connections.shutdownWithTimeout()
+	return nil
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"net"
+	"sync"
 
 	"go.uber.org/zap"
 )
@@ -9,32 +11,100 @@ import (
 // RunTCP begins listening for TCP connections on the provided address,
 // creating a LSP over JSON-RPC 2.0 connection on top of each incoming
 // TCP connection with the provided server.
+//
+// Each connection is served as a separate session,
+// see `WithSessionFactory` for creating a handler per connection.
+//
+// When the provided context is cancelled, the server stops accepting connections,
+// cancels the context of in-flight requests and waits up to the server's shutdown
+// timeout (see `WithServerShutdownTimeout`) for open connections to finish handling
+// messages before closing them.
+// This returns once every connection has been closed.
 func RunTCP(ctx context.Context, address string, server *Server, logger *zap.Logger) error {
-	listener, err := newNetworkListener("tcp", address, logger)
+	return runNetworkServer(ctx, "tcp", address, server, logger)
+}
+
+// ConnectTCP connects to a client that is listening for TCP connections
+// on the provided address and serves a single LSP over JSON-RPC 2.0 connection
+// on top of the TCP connection with the provided server.
+// This is the transport used by clients such as `vscode-languageclient`
+// that launch servers with the `--socket=<port>` flag.
+//
+// This returns once the connection has been closed by the client or the provided
+// context has been cancelled, in which case the connection is drained and closed
+// in the same way as connections served by `RunTCP`.
+func ConnectTCP(ctx context.Context, address string, server *Server, logger *zap.Logger) error {
+	return connectAndServe(ctx, "tcp", address, server, logger)
+}
+
+func runNetworkServer(
+	ctx context.Context,
+	network string,
+	address string,
+	server *Server,
+	logger *zap.Logger,
+) error {
+	listener, err := newNetworkListener(network, address, logger)
 	if err != nil {
 		return err
 	}
 
 	connLogger := logger.With(zap.String("address", address))
-	defer callAndLog((*listener).Close, "listener.Close", connLogger)
-	logger.Info("listening for TCP connections")
+	closeListener := sync.OnceValue((*listener).Close)
+	defer callAndLog(closeListener, "listener.Close", connLogger)
+	// Closing the listener unblocks the call to accept
+	// a new connection once the context is cancelled.
+	stopClosingListener := context.AfterFunc(ctx, func() {
+		callAndLog(closeListener, "listener.Close", connLogger)
+	})
+	defer stopClosingListener()
+	logger.Info("listening for connections", zap.String("network", network))
 
-	var connectionCount uint64
+	connections := newConnectionGroup(server, connLogger)
+	defer connections.shutdownWithTimeout()
 
 	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
-			connection, err := (*listener).Accept()
-			if err != nil {
-				return err
+		connection, err := (*listener).Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				logger.Info("shutting down server", zap.String("network", network))
+				return nil
 			}
+			return err
+		}
 
-			connectionCount += 1
-			connectionLogger := logger.With(zap.Uint64("id", connectionCount))
-
-			go server.Serve(NewStreamConnection(server.NewHandler(), connection), connectionLogger)
+		session, err := server.startSession(connLogger)
+		if err != nil {
+			connLogger.Error("failed to create session for connection", zap.Error(err))
+			callAndLog(connection.Close, "connection.Close", connLogger)
+			continue
 		}
+
+		connections.serve(
+			NewStreamConnection(
+				server.newSessionHandler(session, connections.handler),
+				connection,
+				session.connOptions()...,
+			),
+			session,
+		)
 	}
 }
+
+func connectAndServe(
+	ctx context.Context,
+	network string,
+	address string,
+	server *Server,
+	logger *zap.Logger,
+) error {
+	dialer := &net.Dialer{}
+	connection, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		logger.Error("could not connect to client", zap.String("address", address), zap.Error(err))
+		return err
+	}
+
+	logger.Info("connected to client", zap.String("network", network), zap.String("address", address))
+	return serveStream(ctx, connection, server, logger.With(zap.String("address", address)))
+}
@@ -26,6 +26,9 @@ func (s *TCPTransportTestSuite) Test_tcp_transport() {
 	defer cancel()
 	go RunTCP(ctx, fmt.Sprintf("localhost:%d", port), server, logger)
 
+	err = waitForListener(fmt.Sprintf("localhost:%d", port))
+	s.Require().NoError(err)
+
 	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
 	s.Require().NoError(err)
 
@@ -38,6 +41,32 @@ func (s *TCPTransportTestSuite) Test_tcp_transport() {
 	s.Require().Equal(2, testCountRes.Count)
 }
 
+func (s *TCPTransportTestSuite) Test_connects_to_client_listening_for_tcp_connections() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer listener.Close()
+
+	server := NewServer(createCounterHandler(), false, zap.NewNop(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := runInBackground(func() error {
+		return ConnectTCP(ctx, listener.Addr().String(), server, zap.NewNop())
+	})
+
+	connection, err := listener.Accept()
+	s.Require().NoError(err)
+	clientJSONRPCConn := NewStreamConnection(createClientHandler().handler, connection)
+
+	testCountRes := testCountResult{}
+	err = clientJSONRPCConn.Call(ctx, "increment", testCountParams{Count: 1}, &testCountRes)
+	s.Require().NoError(err)
+	s.Require().Equal(2, testCountRes.Count)
+
+	// Cancelling the context closes the connection to the client.
+	cancel()
+	s.Require().NoError(waitForResult(stopped))
+	<-clientJSONRPCConn.DisconnectNotify()
+}
+
 func TestTCPTransportTestSuite(t *testing.T) {
 	suite.Run(t, new(TCPTransportTestSuite))
 }
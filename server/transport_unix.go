@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// windowsNamedPipePrefix is the prefix of the paths of named pipes on Windows,
+// clients such as `vscode-languageclient` use named pipes instead of
+// Unix domain sockets for the pipe transport on Windows.
+const windowsNamedPipePrefix = `\\.\pipe\`
+
+// RunUnixSocket begins listening for connections on a Unix domain socket at
+// the provided path, creating a LSP over JSON-RPC 2.0 connection on top of each
+// incoming connection with the provided server.
+// The socket file is removed when the server stops listening.
+//
+// Connections are served and shut down in the same way as `RunTCP`.
+func RunUnixSocket(ctx context.Context, path string, server *Server, logger *zap.Logger) error {
+	return runNetworkServer(ctx, "unix", path, server, logger)
+}
+
+// ConnectUnixSocket connects to a client that is listening on a Unix domain socket
+// at the provided path and serves a single LSP over JSON-RPC 2.0 connection
+// with the provided server.
+// This is the transport used by clients such as `vscode-languageclient`
+// that launch servers with the `--pipe=<path>` flag on Linux and macOS.
+//
+// Windows named pipes are not supported, an `ErrNamedPipeNotSupported`
+// error is returned for named pipe paths.
+//
+// This returns once the connection has been closed by the client or the provided
+// context has been cancelled, in the same way as `ConnectTCP`.
+func ConnectUnixSocket(ctx context.Context, path string, server *Server, logger *zap.Logger) error {
+	if strings.HasPrefix(path, windowsNamedPipePrefix) {
+		return ErrNamedPipeNotSupported
+	}
+
+	return connectAndServe(ctx, "unix", path, server, logger)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type UnixSocketTransportTestSuite struct {
+	suite.Suite
+	socketPath string
+}
+
+func (s *UnixSocketTransportTestSuite) SetupTest() {
+	// Socket paths are limited to around 100 characters,
+	// so a short directory is used instead of the test's temporary directory.
+	dir, err := os.MkdirTemp("", "lsb")
+	s.Require().NoError(err)
+	s.T().Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	s.socketPath = filepath.Join(dir, "lsp.sock")
+}
+
+func (s *UnixSocketTransportTestSuite) Test_unix_socket_transport() {
+	server := NewServer(createCounterHandler(), false, zap.NewNop(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := runInBackground(func() error {
+		return RunUnixSocket(ctx, s.socketPath, server, zap.NewNop())
+	})
+
+	conn := s.dialWhenListening()
+	clientConn := NewStreamConnection(createClientHandler().handler, conn)
+
+	testCountRes := testCountResult{}
+	err := clientConn.Call(ctx, "increment", testCountParams{Count: 1}, &testCountRes)
+	s.Require().NoError(err)
+	s.Require().Equal(2, testCountRes.Count)
+
+	cancel()
+	s.Require().NoError(waitForResult(stopped))
+	_, err = os.Stat(s.socketPath)
+	s.Assert().True(os.IsNotExist(err), "expected socket file to be removed")
+}
+
+func (s *UnixSocketTransportTestSuite) Test_connects_to_client_listening_on_unix_socket() {
+	listener, err := net.Listen("unix", s.socketPath)
+	s.Require().NoError(err)
+	defer listener.Close()
+
+	server := NewServer(createCounterHandler(), false, zap.NewNop(), nil)
+	stopped := runInBackground(func() error {
+		return ConnectUnixSocket(context.Background(), s.socketPath, server, zap.NewNop())
+	})
+
+	connection, err := listener.Accept()
+	s.Require().NoError(err)
+	clientConn := NewStreamConnection(createClientHandler().handler, connection)
+
+	testCountRes := testCountResult{}
+	err = clientConn.Call(context.Background(), "increment", testCountParams{Count: 5}, &testCountRes)
+	s.Require().NoError(err)
+	s.Require().Equal(6, testCountRes.Count)
+
+	s.Require().NoError(clientConn.Close())
+	s.Require().NoError(waitForResult(stopped))
+}
+
+func (s *UnixSocketTransportTestSuite) Test_rejects_windows_named_pipes() {
+	server := NewServer(createCounterHandler(), false, zap.NewNop(), nil)
+	err := ConnectUnixSocket(context.Background(), `\\.\pipe\vscode-lsp`, server, zap.NewNop())
+	s.Require().ErrorIs(err, ErrNamedPipeNotSupported)
+}
+
+func (s *UnixSocketTransportTestSuite) dialWhenListening() net.Conn {
+	var err error
+	for attempt := 0; attempt < 50; attempt += 1 {
+		var conn net.Conn
+		if conn, err = net.Dial("unix", s.socketPath); err == nil {
+			return conn
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Require().NoError(err)
+	return nil
+}
+
+func waitForResult(result chan error) error {
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(DefaultTimeout):
+		return context.DeadlineExceeded
+	}
+}
+
+func TestUnixSocketTransportTestSuite(t *testing.T) {
+	suite.Run(t, new(UnixSocketTransportTestSuite))
+}
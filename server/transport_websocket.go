@@ -1,9 +1,10 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
-	"sync/atomic"
 
 	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
@@ -11,7 +12,22 @@ import (
 )
 
 // RunWebSocketServer starts a new web socket server on the provided address.
-func RunWebSocketServer(address string, server *Server, logger *zap.Logger, httpServer *http.Server) error {
+//
+// Each connection is served as a separate session,
+// see `WithSessionFactory` for creating a handler per connection.
+//
+// When the provided context is cancelled, the server stops accepting connections,
+// cancels the context of in-flight requests and waits up to the server's shutdown
+// timeout (see `WithServerShutdownTimeout`) for open connections to finish handling
+// messages before closing them.
+// This returns once every connection has been closed.
+func RunWebSocketServer(
+	ctx context.Context,
+	address string,
+	server *Server,
+	logger *zap.Logger,
+	httpServer *http.Server,
+) error {
 	mux := http.NewServeMux()
 	upgrader := websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
@@ -19,7 +35,7 @@ func RunWebSocketServer(address string, server *Server, logger *zap.Logger, http
 		},
 	}
 
-	var connectionCount uint64
+	connections := newConnectionGroup(server, logger)
 
 	mux.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
 		conn, err := upgrader.Upgrade(writer, request, nil)
@@ -27,10 +43,29 @@ func RunWebSocketServer(address string, server *Server, logger *zap.Logger, http
 			logger.Error(fmt.Sprintf("error upgrading HTTP to web socket: %s", err.Error()))
 			return
 		}
+		defer callAndLog(func() error { return closeWebSocket(conn) }, "wsConn.Close", logger)
 
-		connLogger := logger.With(zap.Uint64("id", atomic.AddUint64(&connectionCount, 1)))
-		defer callAndLog(conn.Close, "wsConn.Close", connLogger)
-		server.ServeWebSocket(conn, connLogger)
+		session, err := server.startSession(logger)
+		if err != nil {
+			logger.Error("failed to create session for web socket connection", zap.Error(err))
+			return
+		}
+
+		jsonRPCConn := server.newWebSocketConnection(
+			server.newSessionHandler(session, connections.handler),
+			conn,
+			session.connOptions()...,
+		)
+		if !connections.add(jsonRPCConn) {
+			callAndLog(func() error { return closeConnection(jsonRPCConn) }, "conn.Close", session.Logger)
+			server.endSession(session)
+			return
+		}
+		defer connections.done(jsonRPCConn)
+
+		session.Logger.Info("new web socket connection")
+		server.serveSession(jsonRPCConn, session)
+		session.Logger.Info("web socket connection closed")
 	})
 
 	listener, err := newNetworkListener("tcp", address, logger)
@@ -51,6 +86,48 @@ func RunWebSocketServer(address string, server *Server, logger *zap.Logger, http
 		httpServer.WriteTimeout = server.GetWriteTimeout()
 	}
 
-	err = httpServer.Serve(*listener)
-	return errors.Wrap(err, "WebSocket")
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.Serve(*listener)
+	}()
+
+	serving := true
+	select {
+	case err = <-serveErr:
+		serving = false
+	case <-ctx.Done():
+		logger.Info("shutting down web socket server")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), server.GetShutdownTimeout())
+	defer cancel()
+	// Web socket connections are hijacked from the HTTP server
+	// so shutting down the HTTP server only stops it from accepting
+	// new connections, open web socket connections are drained and closed
+	// by the connection group.
+	shutdownErr := httpServer.Shutdown(shutdownCtx)
+	connections.shutdown(shutdownCtx)
+	if serving {
+		err = <-serveErr
+	}
+
+	if err != nil && err != http.ErrServerClosed {
+		return errors.Wrap(err, "WebSocket")
+	}
+
+	if shutdownErr != nil {
+		return errors.Wrap(shutdownErr, "WebSocket")
+	}
+
+	return nil
+}
+
+// closeWebSocket closes a web socket connection, ignoring the error
+// for connections that have already been closed by the JSON-RPC connection.
+func closeWebSocket(conn *websocket.Conn) error {
+	err := conn.Close()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
 }
@@ -25,9 +25,12 @@ func (s *WebSocketTransportTestSuite) Test_websocket_transport() {
 
 	handler := createCounterHandler()
 	server := NewServer(handler, false, logger, nil)
-	httpServer := &http.Server{}
-	go RunWebSocketServer(fmt.Sprintf("localhost:%d", port), server, logger, httpServer)
-	defer httpServer.Shutdown(context.TODO())
+	serverCtx, cancelServer := context.WithCancel(context.Background())
+	defer cancelServer()
+	go RunWebSocketServer(serverCtx, fmt.Sprintf("localhost:%d", port), server, logger, &http.Server{})
+
+	err = waitForListener(fmt.Sprintf("localhost:%d", port))
+	s.Require().NoError(err)
 
 	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d", port), nil)
 	s.Require().NoError(err)